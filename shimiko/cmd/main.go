@@ -6,7 +6,9 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/persistence"
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/telemetry"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/merge"
 )
 
 func main() {
@@ -32,6 +34,58 @@ func main() {
 		},
 	)
 
+	zoneCmd := &cobra.Command{
+		Use:   "zone",
+		Short: "Work with zone files",
+	}
+	zoneLintCmd := &cobra.Command{
+		Use:   "lint [FILE]",
+		Short: "Check a zone file, or the live CoreDNS zone if FILE is omitted, for problems",
+		Args:  cobra.MaximumNArgs(1),
+		Run:   ZoneLint,
+	}
+	zoneLintCmd.Flags().String("origin", persistence.DomainName+".", "origin at the top of the zone file")
+	zoneCmd.AddCommand(zoneLintCmd)
+	zoneFmtCmd := &cobra.Command{
+		Use:   "fmt FILE...",
+		Short: "Format zone files, printing the result unless -w or --check is given",
+		Args:  cobra.MinimumNArgs(1),
+		Run:   ZoneFmt,
+	}
+	zoneFmtCmd.Flags().String("origin", persistence.DomainName+".", "origin at the top of the zone files")
+	zoneFmtCmd.Flags().BoolP("write", "w", false, "write the formatted zone files back in place")
+	zoneFmtCmd.Flags().Bool("check", false, "print a diff and exit non-zero if a zone file isn't formatted")
+	zoneFmtCmd.MarkFlagsMutuallyExclusive("write", "check")
+	zoneCmd.AddCommand(zoneFmtCmd)
+	zoneCheckCmd := &cobra.Command{
+		Use:   "check FILE...",
+		Short: "Check that zone files are formatted, printing a diff for those that aren't",
+		Args:  cobra.MinimumNArgs(1),
+		Run:   ZoneCheck,
+	}
+	zoneCheckCmd.Flags().String("origin", persistence.DomainName+".", "origin at the top of the zone files")
+	zoneCmd.AddCommand(zoneCheckCmd)
+	zoneConvertCmd := &cobra.Command{
+		Use:   "convert [FILE]",
+		Short: "Convert a zone file, or standard input if FILE is omitted, between BIND, JSON and YAML",
+		Args:  cobra.MaximumNArgs(1),
+		Run:   ZoneConvert,
+	}
+	zoneConvertCmd.Flags().String("from", "bind", "input format: bind, json or yaml")
+	zoneConvertCmd.Flags().String("to", "json", "output format: bind, json or yaml")
+	zoneCmd.AddCommand(zoneConvertCmd)
+	zoneMergeCmd := &cobra.Command{
+		Use:   "merge FILE...",
+		Short: "Merge zone files into the first of them, printing the merged zone and any conflicting RRsets",
+		Args:  cobra.MinimumNArgs(1),
+		Run:   ZoneMerge,
+	}
+	zoneMergeCmd.Flags().String("origin", persistence.DomainName+".", "origin at the top of the zone files")
+	zoneMergeCmd.Flags().String("policy", string(merge.PolicyError), "how to resolve conflicting RRsets: prefer-left, prefer-right, union or error")
+	zoneMergeCmd.Flags().Bool("format", false, "format the merged zone")
+	zoneCmd.AddCommand(zoneMergeCmd)
+	rootCmd.AddCommand(zoneCmd)
+
 	err := rootCmd.Execute()
 	if err != nil {
 		telemetry.DefaultLogger.Error("error executing command", "err", err)
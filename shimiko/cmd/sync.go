@@ -45,22 +45,30 @@ func Sync(cmd *cobra.Command, args []string) {
 
 	failed := false
 	for _, record := range records {
-		recordLogger := logger.With("record", record)
-		recordLogger.InfoContext(ctx, "upserting CoreDNS")
-		err := ps.CoreDNS.UpsertRecord(ctx, record, nil)
-		if err != nil {
-			failed = true
-			recordLogger.ErrorContext(ctx, "failed to upsert CoreDNS", "error", err)
+		for _, backend := range ps.Backends {
+			recordLogger := logger.With("record", record, "backend", backend.Name())
+			recordLogger.InfoContext(ctx, "upserting record")
+			err := backend.UpsertRecord(ctx, record, nil)
+			if err != nil {
+				failed = true
+				recordLogger.ErrorContext(ctx, "failed to upsert record", "error", err)
+			}
 		}
-		recordLogger.InfoContext(ctx, "upserting Route53")
-		err = ps.Route53.UpsertRecord(ctx, record, nil)
+	}
+
+	for _, backend := range ps.Backends {
+		unmanaged, err := persistence.UnmanagedRecords(ctx, backend, records)
 		if err != nil {
 			failed = true
-			recordLogger.ErrorContext(ctx, "failed to upsert Route53", "error", err)
+			logger.ErrorContext(ctx, "failed to list records", "backend", backend.Name(), "error", err)
+			continue
+		}
+		for _, record := range unmanaged {
+			logger.WarnContext(ctx, "record is not in the database", "record", record, "backend", backend.Name())
 		}
 	}
 
 	if failed {
-		fatal("failed upserting some records", nil)
+		fatal("failed syncing some records", nil)
 	}
 }
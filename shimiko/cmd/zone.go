@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/persistence"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/telemetry"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/document"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/format"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lint"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/merge"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+)
+
+// loadZone loads the zone file at path with all of its includes resolved. If
+// path is empty the live zone is loaded from CoreDNS instead.
+func loadZone(ctx context.Context, path string, origin string) ([]ast.Node, error) {
+	if path == "" {
+		coreDNS := &persistence.CoreDNS{}
+		err := coreDNS.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return coreDNS.Entries(), nil
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("error getting absolute path of '%s': %w", path, err)
+	}
+	return parser.LoadFile(os.DirFS("/"), strings.TrimPrefix(filepath.ToSlash(path), "/"), origin)
+}
+
+func ZoneLint(cmd *cobra.Command, args []string) {
+	logger := telemetry.DefaultLogger.With("cmd", "zone lint")
+	ctx := telemetry.ContextWithLogger(cmd.Context(), logger)
+
+	fatal := func(msg string, err error) {
+		logger.ErrorContext(ctx, msg, "error", err)
+		os.Exit(1)
+	}
+
+	origin, err := cmd.Flags().GetString("origin")
+	if err != nil {
+		fatal("failed to get origin flag", err)
+	}
+
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+	entries, err := loadZone(ctx, path, origin)
+	if err != nil {
+		fatal("failed to load zone", err)
+	}
+
+	problems, err := lint.Lint(entries, origin)
+	if err != nil {
+		fatal("failed to lint zone", err)
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(cmd.OutOrStdout(), problem.String())
+	}
+	if lint.HasErrors(problems) {
+		os.Exit(1)
+	}
+}
+
+// formatZoneFiles formats the zone files at paths. The formatted files are
+// written back if write is set, or a diff is printed for each file that isn't
+// formatted if check is set; otherwise the formatted files are printed.
+// It reports whether all files were formatted already.
+func formatZoneFiles(cmd *cobra.Command, paths []string, origin string, write bool, check bool) (bool, error) {
+	out := cmd.OutOrStdout()
+	formatted := true
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return false, fmt.Errorf("error reading '%s': %w", path, err)
+		}
+		result, err := format.Source(data, origin)
+		if err != nil {
+			return false, fmt.Errorf("error formatting '%s': %w", path, err)
+		}
+		if bytes.Equal(data, result) {
+			if !write && !check {
+				out.Write(result)
+			}
+			continue
+		}
+		formatted = false
+
+		switch {
+		case write:
+			info, err := os.Stat(path)
+			if err != nil {
+				return false, fmt.Errorf("error reading '%s': %w", path, err)
+			}
+			err = os.WriteFile(path, result, info.Mode().Perm())
+			if err != nil {
+				return false, fmt.Errorf("error writing '%s': %w", path, err)
+			}
+		case check:
+			diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(string(data)),
+				B:        difflib.SplitLines(string(result)),
+				FromFile: path,
+				ToFile:   path + " (formatted)",
+				Context:  3,
+			})
+			if err != nil {
+				return false, fmt.Errorf("error diffing '%s': %w", path, err)
+			}
+			fmt.Fprint(out, diff)
+		default:
+			out.Write(result)
+		}
+	}
+	return formatted, nil
+}
+
+func ZoneFmt(cmd *cobra.Command, args []string) {
+	logger := telemetry.DefaultLogger.With("cmd", "zone fmt")
+	ctx := telemetry.ContextWithLogger(cmd.Context(), logger)
+
+	fatal := func(msg string, err error) {
+		logger.ErrorContext(ctx, msg, "error", err)
+		os.Exit(1)
+	}
+
+	origin, err := cmd.Flags().GetString("origin")
+	if err != nil {
+		fatal("failed to get origin flag", err)
+	}
+	write, err := cmd.Flags().GetBool("write")
+	if err != nil {
+		fatal("failed to get write flag", err)
+	}
+	check, err := cmd.Flags().GetBool("check")
+	if err != nil {
+		fatal("failed to get check flag", err)
+	}
+
+	formatted, err := formatZoneFiles(cmd, args, origin, write, check)
+	if err != nil {
+		fatal("failed to format zone", err)
+	}
+	if check && !formatted {
+		os.Exit(1)
+	}
+}
+
+func ZoneCheck(cmd *cobra.Command, args []string) {
+	logger := telemetry.DefaultLogger.With("cmd", "zone check")
+	ctx := telemetry.ContextWithLogger(cmd.Context(), logger)
+
+	fatal := func(msg string, err error) {
+		logger.ErrorContext(ctx, msg, "error", err)
+		os.Exit(1)
+	}
+
+	origin, err := cmd.Flags().GetString("origin")
+	if err != nil {
+		fatal("failed to get origin flag", err)
+	}
+
+	formatted, err := formatZoneFiles(cmd, args, origin, false, true)
+	if err != nil {
+		fatal("failed to check zone", err)
+	}
+	if !formatted {
+		os.Exit(1)
+	}
+}
+
+// convertZone converts data from one of the formats "bind", "json" and "yaml"
+// to another.
+func convertZone(data []byte, from string, to string) ([]byte, error) {
+	var (
+		entries []ast.Node
+		err     error
+	)
+	switch from {
+	case "bind":
+		entries, err = parser.ParseReader(bytes.NewReader(data))
+	case "json":
+		entries, err = document.FromJSON(data)
+	case "yaml":
+		entries, err = document.FromYAML(data)
+	default:
+		return nil, fmt.Errorf("unknown input format '%s'", from)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch to {
+	case "bind":
+		tokens, err := parser.Tokenize(entries)
+		if err != nil {
+			return nil, err
+		}
+		return token.RenderTokens(tokens), nil
+	case "json":
+		return document.ToJSON(entries)
+	case "yaml":
+		return document.ToYAML(entries)
+	default:
+		return nil, fmt.Errorf("unknown output format '%s'", to)
+	}
+}
+
+func ZoneConvert(cmd *cobra.Command, args []string) {
+	logger := telemetry.DefaultLogger.With("cmd", "zone convert")
+	ctx := telemetry.ContextWithLogger(cmd.Context(), logger)
+
+	fatal := func(msg string, err error) {
+		logger.ErrorContext(ctx, msg, "error", err)
+		os.Exit(1)
+	}
+
+	from, err := cmd.Flags().GetString("from")
+	if err != nil {
+		fatal("failed to get from flag", err)
+	}
+	to, err := cmd.Flags().GetString("to")
+	if err != nil {
+		fatal("failed to get to flag", err)
+	}
+
+	var data []byte
+	if len(args) > 0 {
+		data, err = os.ReadFile(args[0])
+	} else {
+		data, err = io.ReadAll(cmd.InOrStdin())
+	}
+	if err != nil {
+		fatal("failed to read zone", err)
+	}
+
+	result, err := convertZone(data, from, to)
+	if err != nil {
+		fatal("failed to convert zone", err)
+	}
+	cmd.OutOrStdout().Write(result)
+}
+
+func ZoneMerge(cmd *cobra.Command, args []string) {
+	logger := telemetry.DefaultLogger.With("cmd", "zone merge")
+	ctx := telemetry.ContextWithLogger(cmd.Context(), logger)
+
+	fatal := func(msg string, err error) {
+		logger.ErrorContext(ctx, msg, "error", err)
+		os.Exit(1)
+	}
+
+	origin, err := cmd.Flags().GetString("origin")
+	if err != nil {
+		fatal("failed to get origin flag", err)
+	}
+	policyName, err := cmd.Flags().GetString("policy")
+	if err != nil {
+		fatal("failed to get policy flag", err)
+	}
+	policy, err := merge.ParsePolicy(policyName)
+	if err != nil {
+		fatal("invalid policy", err)
+	}
+	formatted, err := cmd.Flags().GetBool("format")
+	if err != nil {
+		fatal("failed to get format flag", err)
+	}
+
+	inputs := make([]merge.Input, 0, len(args))
+	for _, path := range args {
+		entries, err := loadZone(ctx, path, origin)
+		if err != nil {
+			fatal("failed to load zone", err)
+		}
+		inputs = append(inputs, merge.Input{Name: path, Entries: entries})
+	}
+
+	entries, conflicts, err := merge.Merge(inputs, origin, policy)
+	fmt.Fprint(cmd.ErrOrStderr(), merge.Format(conflicts))
+	if err != nil {
+		fatal("failed to merge zones", err)
+	}
+	if formatted {
+		entries, err = format.Format(entries, origin)
+		if err != nil {
+			fatal("failed to format zone", err)
+		}
+	}
+	tokens, err := parser.Tokenize(entries)
+	if err != nil {
+		fatal("failed to render zone", err)
+	}
+	cmd.OutOrStdout().Write(token.RenderTokens(tokens))
+}
@@ -0,0 +1,282 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/env"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/telemetry"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/rrtype"
+)
+
+var (
+	// ErrUnknownBackend is returned for a backend name nothing is registered
+	// for.
+	ErrUnknownBackend = errors.New("unknown backend")
+	// ErrInvalidBackends is returned for a list of backends that is empty or
+	// names a backend more than once.
+	ErrInvalidBackends = errors.New("invalid backends")
+)
+
+// Backend is a DNS target the records of a PersistenceSession are published
+// to. Changes made with UpsertRecord and DeleteRecord may be held back until
+// Flush.
+type Backend interface {
+	// Name returns the name the backend is registered and configured by.
+	Name() string
+	// Load reads the current state of the backend. It is called once before
+	// any of the other methods.
+	Load(ctx context.Context) error
+	// UpsertRecord creates or replaces record. previous is the record as it
+	// was before, or nil if it is new.
+	UpsertRecord(ctx context.Context, record *DNSRecord, previous *DNSRecord) error
+	// DeleteRecord removes record.
+	DeleteRecord(ctx context.Context, record *DNSRecord) error
+	// Flush publishes any changes that were held back.
+	Flush(ctx context.Context) error
+	// ListRecords returns the records the backend has, with names relative
+	// to DomainName like those of the database.
+	ListRecords(ctx context.Context) ([]*DNSRecord, error)
+}
+
+var (
+	_ Backend = (*CoreDNS)(nil)
+	_ Backend = (*Route53)(nil)
+)
+
+// BackendFactory creates a Backend, which has yet to be loaded.
+type BackendFactory func(ctx context.Context, db *gorm.DB) (Backend, error)
+
+// RecordValidator checks that a backend can serve record. It is called before
+// any backend is created, so it can't depend on the state of one.
+type RecordValidator func(record *DNSRecord) error
+
+type backendRegistration struct {
+	factory  BackendFactory
+	validate RecordValidator
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]backendRegistration{
+		string(rrtype.BackendCoreDNS): {
+			factory: func(ctx context.Context, db *gorm.DB) (Backend, error) {
+				coreDNS, err := NewCoreDNS(ctx, db)
+				if err != nil {
+					return nil, err
+				}
+				return coreDNS, nil
+			},
+			validate: validateCoreDNSRecord,
+		},
+		string(rrtype.BackendRoute53): {
+			factory: func(ctx context.Context, db *gorm.DB) (Backend, error) {
+				r53, err := NewRoute53(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return r53, nil
+			},
+			validate: validateRoute53Record,
+		},
+	}
+)
+
+// DefaultBackends are the backends used if SHIMIKO_BACKENDS isn't set.
+var DefaultBackends = []string{
+	string(rrtype.BackendCoreDNS),
+	string(rrtype.BackendRoute53),
+}
+
+// RegisterBackend makes a backend available under name, which has to be in
+// lowercase. validate may be nil if the backend can serve any record. It
+// panics if name is invalid or already registered, or if factory is nil.
+func RegisterBackend(name string, factory BackendFactory, validate RecordValidator) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if name == "" || name != strings.ToLower(strings.TrimSpace(name)) || strings.Contains(name, ",") {
+		panic(fmt.Sprintf("persistence: invalid backend name '%s'", name))
+	}
+	if factory == nil {
+		panic(fmt.Sprintf("persistence: backend '%s' has no factory", name))
+	}
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("persistence: backend '%s' is registered twice", name))
+	}
+	backends[name] = backendRegistration{
+		factory:  factory,
+		validate: validate,
+	}
+}
+
+// lookupBackend returns the registration of the backend called name.
+func lookupBackend(name string) (backendRegistration, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	registration, ok := backends[name]
+	return registration, ok
+}
+
+// BackendNames returns the names of the registered backends in order.
+func BackendNames() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConfiguredBackends returns the names of the backends sessions should use,
+// which are set as a comma separated list by SHIMIKO_BACKENDS.
+func ConfiguredBackends() ([]string, error) {
+	raw, err := env.GetDefault("SHIMIKO_BACKENDS", strings.Join(DefaultBackends, ","))
+	if err != nil {
+		return nil, fmt.Errorf("error getting SHIMIKO_BACKENDS: %w", err)
+	}
+	names, err := ParseBackends(raw)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing SHIMIKO_BACKENDS: %w", err)
+	}
+	return names, nil
+}
+
+// ParseBackends parses a comma separated list of backend names. Names are
+// case insensitive and names listed twice are used once.
+func ParseBackends(raw string) ([]string, error) {
+	names := []string{}
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || slices.Contains(names, name) {
+			continue
+		}
+		if _, ok := lookupBackend(name); !ok {
+			return nil, fmt.Errorf("%w '%s', expected any of %s", ErrUnknownBackend, name, strings.Join(BackendNames(), ", "))
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: no backends in '%s'", ErrInvalidBackends, raw)
+	}
+	return names, nil
+}
+
+// NewBackends creates and loads the backends registered under names.
+func NewBackends(ctx context.Context, db *gorm.DB, names []string) ([]Backend, error) {
+	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.NewBackends", trace.WithAttributes(
+		attribute.StringSlice("names", names),
+	))
+	defer span.End()
+
+	if len(names) == 0 {
+		err := fmt.Errorf("%w: no backends", ErrInvalidBackends)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	loaded := make([]Backend, 0, len(names))
+	for i, name := range names {
+		if slices.Contains(names[:i], name) {
+			err := fmt.Errorf("%w: '%s' is listed more than once", ErrInvalidBackends, name)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		registration, ok := lookupBackend(name)
+		if !ok {
+			err := fmt.Errorf("%w '%s'", ErrUnknownBackend, name)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		backend, err := registration.factory(ctx, db)
+		if err != nil {
+			err = fmt.Errorf("error creating %s backend: %w", name, err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		err = backend.Load(ctx)
+		if err != nil {
+			err = fmt.Errorf("error loading %s backend: %w", name, err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		loaded = append(loaded, backend)
+	}
+
+	span.SetStatus(codes.Ok, "")
+	return loaded, nil
+}
+
+// ValidateRecord checks that each of the backends called names can serve
+// record and returns an error for each that can't.
+func ValidateRecord(record *DNSRecord, names []string) []error {
+	errs := []error{}
+	for _, name := range names {
+		registration, ok := lookupBackend(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w '%s'", ErrUnknownBackend, name))
+			continue
+		}
+		if registration.validate == nil {
+			continue
+		}
+		err := registration.validate(record)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// UnmanagedRecords returns the records backend has that aren't in managed,
+// compared by name and type.
+func UnmanagedRecords(ctx context.Context, backend Backend, managed []*DNSRecord) ([]*DNSRecord, error) {
+	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.UnmanagedRecords", trace.WithAttributes(
+		attribute.String("backend", backend.Name()),
+	))
+	defer span.End()
+
+	records, err := backend.ListRecords(ctx)
+	if err != nil {
+		err = fmt.Errorf("error listing records of %s: %w", backend.Name(), err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	unmanaged := []*DNSRecord{}
+	for _, record := range records {
+		isManaged := slices.ContainsFunc(managed, func(other *DNSRecord) bool {
+			return other.Name == record.Name && other.Type == record.Type
+		})
+		if !isManaged {
+			unmanaged = append(unmanaged, record)
+		}
+	}
+
+	span.SetAttributes(attribute.Int("unmanaged", len(unmanaged)))
+	span.SetStatus(codes.Ok, "")
+	return unmanaged, nil
+}
+
+// relativeRecordName turns an owner name as held by a backend into a name
+// relative to DomainName, or reports false if it is outside of the zone.
+func relativeRecordName(owner string) (string, bool) {
+	owner = strings.TrimSuffix(strings.ToLower(owner), ".")
+	if owner == DomainName {
+		return "@", true
+	}
+	name, ok := strings.CutSuffix(owner, "."+DomainName)
+	return name, ok
+}
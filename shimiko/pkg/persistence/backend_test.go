@@ -0,0 +1,301 @@
+package persistence_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/persistence"
+)
+
+var errFake = errors.New("fake backend failure")
+
+// fakeBackend is a Backend that keeps its records in memory.
+type fakeBackend struct {
+	name    string
+	loadErr error
+	loaded  bool
+	records []*persistence.DNSRecord
+	listErr error
+}
+
+func (fake *fakeBackend) Name() string {
+	return fake.name
+}
+
+func (fake *fakeBackend) Load(ctx context.Context) error {
+	fake.loaded = true
+	return fake.loadErr
+}
+
+func (fake *fakeBackend) UpsertRecord(ctx context.Context, record *persistence.DNSRecord, previous *persistence.DNSRecord) error {
+	fake.records = append(fake.records, record)
+	return nil
+}
+
+func (fake *fakeBackend) DeleteRecord(ctx context.Context, record *persistence.DNSRecord) error {
+	return nil
+}
+
+func (fake *fakeBackend) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (fake *fakeBackend) ListRecords(ctx context.Context) ([]*persistence.DNSRecord, error) {
+	return fake.records, fake.listErr
+}
+
+// registerFake registers a backend named name that creates fakes, or fails
+// with factoryErr, and rejects records of the types in rejectTypes.
+func registerFake(name string, factoryErr error, loadErr error, rejectTypes ...string) {
+	persistence.RegisterBackend(
+		name,
+		func(ctx context.Context, db *gorm.DB) (persistence.Backend, error) {
+			if factoryErr != nil {
+				return nil, factoryErr
+			}
+			return &fakeBackend{name: name, loadErr: loadErr}, nil
+		},
+		func(record *persistence.DNSRecord) error {
+			for _, typ := range rejectTypes {
+				if record.Type == typ {
+					return fmt.Errorf("%w: no %s records", errFake, typ)
+				}
+			}
+			return nil
+		},
+	)
+}
+
+func init() {
+	registerFake("fake", nil, nil, "TXT")
+	registerFake("fake-other", nil, nil)
+	registerFake("fake-broken", errFake, nil)
+	registerFake("fake-unloadable", nil, errFake)
+}
+
+func TestRegisterBackend(t *testing.T) {
+	t.Parallel()
+
+	factory := func(ctx context.Context, db *gorm.DB) (persistence.Backend, error) {
+		return &fakeBackend{}, nil
+	}
+
+	tests := map[string]struct {
+		name    string
+		factory persistence.BackendFactory
+		panics  bool
+	}{
+		"new name": {
+			name:    "fake-registered",
+			factory: factory,
+		},
+		"duplicate": {
+			name:    "fake",
+			factory: factory,
+			panics:  true,
+		},
+		"built-in duplicate": {
+			name:    "route53",
+			factory: factory,
+			panics:  true,
+		},
+		"empty name": {
+			name:    "",
+			factory: factory,
+			panics:  true,
+		},
+		"uppercase name": {
+			name:    "Fake-Upper",
+			factory: factory,
+			panics:  true,
+		},
+		"name with comma": {
+			name:    "fake,comma",
+			factory: factory,
+			panics:  true,
+		},
+		"no factory": {
+			name:   "fake-nil",
+			panics: true,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			register := func() {
+				persistence.RegisterBackend(tc.name, tc.factory, nil)
+			}
+			if tc.panics {
+				assert.Panics(t, register)
+				return
+			}
+			require.NotPanics(t, register)
+			assert.Contains(t, persistence.BackendNames(), tc.name)
+		})
+	}
+}
+
+func TestParseBackends(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		raw      string
+		expected []string
+		err      error
+	}{
+		"defaults": {
+			raw:      "coredns,route53",
+			expected: []string{"coredns", "route53"},
+		},
+		"order is kept": {
+			raw:      "route53,fake",
+			expected: []string{"route53", "fake"},
+		},
+		"case and spaces": {
+			raw:      " CoreDNS , Fake ",
+			expected: []string{"coredns", "fake"},
+		},
+		"duplicates": {
+			raw:      "fake,coredns,FAKE",
+			expected: []string{"fake", "coredns"},
+		},
+		"empty entries": {
+			raw:      ",fake,,",
+			expected: []string{"fake"},
+		},
+		"unknown": {
+			raw: "coredns,bind",
+			err: persistence.ErrUnknownBackend,
+		},
+		"empty": {
+			raw: "",
+			err: persistence.ErrInvalidBackends,
+		},
+		"only separators": {
+			raw: " , ,",
+			err: persistence.ErrInvalidBackends,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := persistence.ParseBackends(tc.raw)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestConfiguredBackends(t *testing.T) {
+	t.Setenv("SHIMIKO_BACKENDS", "Fake, route53")
+	got, err := persistence.ConfiguredBackends()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fake", "route53"}, got)
+
+	t.Setenv("SHIMIKO_BACKENDS", "bind")
+	_, err = persistence.ConfiguredBackends()
+	assert.ErrorIs(t, err, persistence.ErrUnknownBackend)
+	assert.ErrorContains(t, err, "SHIMIKO_BACKENDS")
+}
+
+func TestNewBackends(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		names       []string
+		expected    []string
+		err         error
+		errContains string
+	}{
+		"fakes": {
+			names:    []string{"fake-other", "fake"},
+			expected: []string{"fake-other", "fake"},
+		},
+		"unknown": {
+			names: []string{"fake", "bind"},
+			err:   persistence.ErrUnknownBackend,
+		},
+		"duplicate": {
+			names: []string{"fake", "fake-other", "fake"},
+			err:   persistence.ErrInvalidBackends,
+		},
+		"empty": {
+			names: []string{},
+			err:   persistence.ErrInvalidBackends,
+		},
+		"factory fails": {
+			names:       []string{"fake", "fake-broken"},
+			err:         errFake,
+			errContains: "error creating fake-broken backend",
+		},
+		"load fails": {
+			names:       []string{"fake-unloadable"},
+			err:         errFake,
+			errContains: "error loading fake-unloadable backend",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			backends, err := persistence.NewBackends(context.Background(), nil, tc.names)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				if tc.errContains != "" {
+					assert.ErrorContains(t, err, tc.errContains)
+				}
+				return
+			}
+			require.NoError(t, err)
+			got := []string{}
+			for _, backend := range backends {
+				got = append(got, backend.Name())
+				assert.True(t, backend.(*fakeBackend).loaded)
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestUnmanagedRecords(t *testing.T) {
+	t.Parallel()
+
+	backend := &fakeBackend{
+		name: "fake",
+		records: []*persistence.DNSRecord{
+			{Name: "www", Type: "A", Records: []string{"192.0.2.1"}},
+			{Name: "www", Type: "AAAA", Records: []string{"2001:db8::1"}},
+			{Name: "@", Type: "NS", Records: []string{"ns1.example.net."}},
+		},
+	}
+	managed := []*persistence.DNSRecord{
+		{Name: "www", Type: "A", Records: []string{"192.0.2.2"}},
+		{Name: "mail", Type: "A", Records: []string{"192.0.2.3"}},
+	}
+
+	unmanaged, err := persistence.UnmanagedRecords(context.Background(), backend, managed)
+	require.NoError(t, err)
+	assert.Equal(t, backend.records[1:], unmanaged)
+
+	backend.listErr = errFake
+	_, err = persistence.UnmanagedRecords(context.Background(), backend, managed)
+	assert.ErrorIs(t, err, errFake)
+}
@@ -6,8 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
-	"slices"
-	"strconv"
 	"strings"
 	"time"
 
@@ -15,120 +13,226 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
-	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
 
-	"github.com/sapslaj/homelab-pets/shimiko/pkg/env"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/publish"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/sshclient"
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/telemetry"
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
-	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lexer"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/diff"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/dnssec"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/format"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lint"
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/rrtype"
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/zone"
 )
 
-var CoreDNSHosts = []string{
-	// using IP addresses in the event we have a chicken-and-egg problem
-	"172.24.4.2", // rem.sapslaj.xyz
-	"172.24.4.3", // ram.sapslaj.xyz
-}
+// CoreDNSZoneFile is the path of the zone file on CoreDNS hosts that don't
+// configure one.
+const CoreDNSZoneFile = "/etc/coredns/sapslaj.xyz.zone"
 
 type CoreDNS struct {
-	Entries []ast.Node
+	// Zone holds the entries of the zone without any DNSSEC records. It is
+	// nil until the zone is loaded or a record is upserted.
+	Zone *zone.Zone
+	// Keys sign the zone on every Save. The zone is uploaded unsigned if
+	// there are none.
+	Keys []*dnssec.Key
+	// ParseErrors are the errors for the entries of the zone file that
+	// couldn't be parsed when it was loaded. Those entries are kept verbatim.
+	ParseErrors []*parser.ParseError
+	// Config holds the hosts the zone file is loaded from and saved to. It
+	// is loaded with LoadCoreDNSConfig when first needed if it has no hosts.
+	Config CoreDNSConfig
+	// Dial connects to the hosts the zone file is published to. They are
+	// connected to over SSH if it is nil.
+	Dial CoreDNSDialer
+
+	// sourcePath is the path of the zone file on the host it was loaded
+	// from, which parse errors are reported for.
+	sourcePath string
+	// unparseable holds the text of the entries that were kept verbatim when
+	// the zone was loaded, which are the only ones a published zone file may
+	// fail to parse at.
+	unparseable []string
+	// saved holds the entries as they were last loaded from or saved to the
+	// CoreDNS hosts, so Save can tell whether there is anything to upload.
+	saved []ast.Node
+	// signedUntil is the earliest expiration of the signatures of the zone
+	// as it was last loaded or saved, or zero if it wasn't signed.
+	signedUntil time.Time
+}
+
+// NewCoreDNS returns a CoreDNS backend for the hosts of LoadCoreDNSConfig,
+// which signs the zone with the keys in db if DNSSEC is enabled.
+func NewCoreDNS(ctx context.Context, db *gorm.DB) (*CoreDNS, error) {
+	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.NewCoreDNS", trace.WithAttributes())
+	defer span.End()
+
+	config, err := LoadCoreDNSConfig()
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	coreDNS := &CoreDNS{
+		Config: config,
+	}
+	if DNSSECEnabled() {
+		keys, err := LoadDNSSECKeys(ctx, db, DomainName+".", true)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		coreDNS.Keys = keys
+	}
+
+	span.SetStatus(codes.Ok, "")
+	return coreDNS, nil
+}
+
+func (coreDNS *CoreDNS) Name() string {
+	return string(rrtype.BackendCoreDNS)
+}
+
+// Entries returns the entries of the zone without any DNSSEC records.
+func (coreDNS *CoreDNS) Entries() []ast.Node {
+	if coreDNS.Zone == nil {
+		return nil
+	}
+	return coreDNS.Zone.Nodes()
 }
 
-func (coreDNS *CoreDNS) MakeScpClient(host string) (*scp.Client, error) {
-	username, err := env.Get[string]("VYOS_USERNAME")
+// setEntries replaces the zone with entries.
+func (coreDNS *CoreDNS) setEntries(entries []ast.Node) error {
+	z, err := zone.New(entries, DomainName+".")
 	if err != nil {
-		return nil, fmt.Errorf("error getting VYOS_USERNAME: %w", err)
+		return fmt.Errorf("error indexing CoreDNS entries: %w", err)
 	}
-	password, err := env.Get[string]("VYOS_PASSWORD")
+	coreDNS.Zone = z
+	return nil
+}
+
+// config returns the configuration of the hosts, loading it first if it
+// wasn't set.
+func (coreDNS *CoreDNS) config() (CoreDNSConfig, error) {
+	if len(coreDNS.Config.Hosts) == 0 {
+		config, err := LoadCoreDNSConfig()
+		if err != nil {
+			return CoreDNSConfig{}, err
+		}
+		coreDNS.Config = config
+	}
+	return coreDNS.Config, nil
+}
+
+// MakeScpClient connects to host, verifying its host key.
+func (coreDNS *CoreDNS) MakeScpClient(host CoreDNSHost) (*scp.Client, error) {
+	sshConfig, err := host.SSHConfig()
 	if err != nil {
-		return nil, fmt.Errorf("error getting VYOS_PASSWORD: %w", err)
+		return nil, err
+	}
+	sshClient, err := sshclient.Dial(host.Addr(), sshConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := scp.NewClientBySSH(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, err
 	}
-	client := scp.NewClient(fmt.Sprintf("%s:22", host), &ssh.ClientConfig{
-		User: username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	})
 	return &client, nil
 }
 
 func (coreDNS *CoreDNS) LoadZoneFileData(ctx context.Context) ([]byte, error) {
-	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.CoreDNS.LoadZoneFileData", trace.WithAttributes(
-		attribute.String("host", CoreDNSHosts[0]),
-	))
+	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.CoreDNS.LoadZoneFileData", trace.WithAttributes())
 	defer span.End()
 
-	client, err := coreDNS.MakeScpClient(CoreDNSHosts[0])
-	defer client.Close()
+	config, err := coreDNS.config()
 	if err != nil {
-		err = fmt.Errorf("error creating new scp client for CoreDNS: %w", err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
-	err = client.Connect()
+	host, err := config.SourceOfTruth()
 	if err != nil {
-		err = fmt.Errorf("error connecting scp client for CoreDNS: %w", err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
+	span.SetAttributes(
+		attribute.String("host", host.Address),
+		attribute.String("zone_path", host.ZonePath),
+	)
+
+	client, err := coreDNS.MakeScpClient(host)
+	if err != nil {
+		err = fmt.Errorf("error creating new scp client for CoreDNS: %w", err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	defer client.Close()
 	buffer := &bytes.Buffer{}
-	err = client.CopyFromRemotePassThru(ctx, buffer, "/etc/coredns/sapslaj.xyz.zone", nil)
+	err = client.CopyFromRemotePassThru(ctx, buffer, host.ZonePath, nil)
 	if err != nil {
-		err = fmt.Errorf("error copying file from remote '%s' for CoreDNS: %w", CoreDNSHosts[0], err)
+		err = fmt.Errorf("error copying file from remote '%s' for CoreDNS: %w", host.Address, err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
+	coreDNS.sourcePath = host.ZonePath
 
 	span.SetStatus(codes.Ok, "")
 	return buffer.Bytes(), nil
 }
 
+// SaveCoreDNSZoneFile publishes data to all hosts in two phases: it is
+// uploaded next to the zone file and checked on every host first, and only
+// then renamed into place, with the previous version kept as a backup. If
+// any host fails, all hosts are rolled back and the error has the result for
+// each host.
 func (coreDNS *CoreDNS) SaveCoreDNSZoneFile(ctx context.Context, data []byte) error {
 	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.CoreDNS.SaveCoreDNSZoneFile", trace.WithAttributes(
 		telemetry.OtelJSON("data", data),
 	))
 	defer span.End()
 
-	for _, host := range CoreDNSHosts {
-		err := func(host string) error {
-			subCtx, subSpan := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.CoreDNS.SaveCoreDNSZoneFile.host", trace.WithAttributes(
-				telemetry.OtelJSON("host", host),
-			))
-			defer subSpan.End()
-
-			client, err := coreDNS.MakeScpClient(host)
-			if err != nil {
-				err = fmt.Errorf("error creating new scp client for CoreDNS: %w", err)
-				subSpan.SetStatus(codes.Error, err.Error())
-				return err
-			}
-			defer client.Close()
+	logger := telemetry.LoggerFromContext(ctx)
 
-			err = client.Connect()
-			if err != nil {
-				err = fmt.Errorf("error connecting scp client for CoreDNS: %w", err)
-				subSpan.SetStatus(codes.Error, err.Error())
-				return err
-			}
-
-			reader := bytes.NewReader(data)
-			err = client.CopyFile(subCtx, reader, "/etc/coredns/sapslaj.xyz.zone", "0644")
-			if err != nil {
-				err = fmt.Errorf("error copying file to remote '%s' for CoreDNS: %w", host, err)
-				subSpan.SetStatus(codes.Error, err.Error())
-				return err
-			}
+	config, err := coreDNS.config()
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
 
-			subSpan.SetStatus(codes.Ok, "")
-			return nil
-		}(host)
-		if err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			return err
+	publishHosts := make([]*coreDNSPublishHost, 0, len(config.Hosts))
+	hosts := make([]publish.Host, 0, len(config.Hosts))
+	for _, host := range config.Hosts {
+		publishHost := &coreDNSPublishHost{
+			coreDNS: coreDNS,
+			host:    host,
+		}
+		publishHosts = append(publishHosts, publishHost)
+		hosts = append(hosts, publishHost)
+	}
+	defer func() {
+		for _, publishHost := range publishHosts {
+			publishHost.close()
+		}
+	}()
+
+	results, err := publish.Publish(ctx, hosts, data)
+	for _, result := range results {
+		span.SetAttributes(attribute.String("result."+result.Host, string(result.Status)))
+		if result.Status == publish.StatusPublished {
+			logger.InfoContext(ctx, "published CoreDNS zone file", "host", result.Host)
+		} else {
+			logger.WarnContext(ctx, "failed to publish CoreDNS zone file", "host", result.Host, "result", result.String())
 		}
 	}
+	if err != nil {
+		err = fmt.Errorf("error publishing CoreDNS zone file: %w", err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
 
 	span.SetStatus(codes.Ok, "")
 	return nil
@@ -140,15 +244,43 @@ func (coreDNS *CoreDNS) LoadData(ctx context.Context, data []byte) error {
 	))
 	defer span.End()
 
-	tokens := lexer.LexBytes(data).AllTokens()
-	entries, err := parser.ParseEntries(tokens)
+	logger := telemetry.LoggerFromContext(ctx)
+
+	// a broken line must not keep shimiko from managing the rest of the zone,
+	// so it is kept as-is and reported instead
+	entries, parseErrs, err := parser.ParseReaderTolerant(bytes.NewReader(data))
 	if err != nil {
 		err = fmt.Errorf("error parsing CoreDNS entries: %w", err)
 		span.SetStatus(codes.Error, err.Error())
 		return err
 	}
+	sourcePath := coreDNS.sourcePath
+	if sourcePath == "" {
+		sourcePath = CoreDNSZoneFile
+	}
+	for _, parseErr := range parseErrs {
+		parseErr.SourceFile = sourcePath
+		logger.WarnContext(ctx, "keeping unparseable CoreDNS zone file entry as-is", "error", parseErr.Error())
+	}
+	unparseable := []string{}
+	for i := range entries {
+		if entries[i].IsRawEntry() {
+			entries[i].SourceFile = sourcePath
+			unparseable = append(unparseable, entries[i].RawEntry().Text)
+		}
+	}
+	span.SetAttributes(attribute.Int("parse_errors", len(parseErrs)))
+	coreDNS.ParseErrors = parseErrs
+	coreDNS.unparseable = unparseable
 
-	coreDNS.Entries = entries
+	// the DNSSEC records are recreated by Save
+	coreDNS.signedUntil = dnssec.EarliestExpiration(entries)
+	err = coreDNS.setEntries(dnssec.Strip(entries))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	coreDNS.saved = coreDNS.Entries()
 
 	span.SetStatus(codes.Ok, "")
 	return nil
@@ -170,10 +302,14 @@ func (coreDNS *CoreDNS) Load(ctx context.Context) error {
 }
 
 func (coreDNS *CoreDNS) ToBytes(ctx context.Context) ([]byte, error) {
+	return coreDNS.render(ctx, coreDNS.Entries())
+}
+
+func (coreDNS *CoreDNS) render(ctx context.Context, entries []ast.Node) ([]byte, error) {
 	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.CoreDNS.ToBytes", trace.WithAttributes())
 	defer span.End()
 
-	tokens, err := parser.Tokenize(coreDNS.Entries)
+	tokens, err := parser.Tokenize(entries)
 	if err != nil {
 		err = fmt.Errorf("error tokenizing CoreDNS zone file: %w", err)
 		span.SetStatus(codes.Error, err.Error())
@@ -184,6 +320,102 @@ func (coreDNS *CoreDNS) ToBytes(ctx context.Context) ([]byte, error) {
 	return token.RenderTokens(tokens), nil
 }
 
+// Lint checks the entries for problems that would make CoreDNS refuse the
+// zone. Warnings are logged; errors are returned.
+func (coreDNS *CoreDNS) Lint(ctx context.Context) error {
+	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.CoreDNS.Lint", trace.WithAttributes())
+	defer span.End()
+
+	logger := telemetry.LoggerFromContext(ctx)
+
+	problems, err := lint.Validate(coreDNS.Entries(), DomainName+".")
+	for _, problem := range problems {
+		if problem.Severity == lint.SeverityWarning {
+			logger.WarnContext(ctx, "CoreDNS zone file lint warning", "problem", problem.String())
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("error linting CoreDNS zone file: %w", err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
+// Changed reports whether the entries serve different data than they did
+// when they were last loaded or saved. The SOA serial is ignored since Save
+// picks a new one every time. If the entries were never loaded or can't be
+// compared they are considered changed.
+func (coreDNS *CoreDNS) Changed(ctx context.Context) bool {
+	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.CoreDNS.Changed", trace.WithAttributes())
+	defer span.End()
+
+	logger := telemetry.LoggerFromContext(ctx)
+
+	if coreDNS.saved == nil {
+		span.SetStatus(codes.Ok, "not loaded")
+		return true
+	}
+
+	changes, err := diff.Compare(coreDNS.saved, coreDNS.Entries(), DomainName+".", diff.Options{
+		IgnoreSOASerial: true,
+	})
+	if err != nil {
+		logger.WarnContext(ctx, "error comparing CoreDNS zone file, assuming it changed", "error", err)
+		span.SetStatus(codes.Error, err.Error())
+		return true
+	}
+	span.SetAttributes(attribute.Int("changes", len(changes)))
+	if len(changes) == 0 {
+		logger.InfoContext(ctx, "CoreDNS zone file is unchanged")
+	} else {
+		logger.InfoContext(ctx, "CoreDNS zone file changed", "changes", diff.Format(changes))
+	}
+
+	span.SetStatus(codes.Ok, "")
+	return len(changes) > 0
+}
+
+// Sign returns the entries signed with Keys, with signatures valid from now
+// on for DNSSECSignatureValidity. If there are no keys the entries are
+// returned as they are.
+func (coreDNS *CoreDNS) Sign(ctx context.Context, now time.Time) ([]ast.Node, error) {
+	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.CoreDNS.Sign", trace.WithAttributes(
+		attribute.Int("keys", len(coreDNS.Keys)),
+	))
+	defer span.End()
+
+	if len(coreDNS.Keys) == 0 {
+		span.SetStatus(codes.Ok, "unsigned")
+		return coreDNS.Entries(), nil
+	}
+
+	signed, err := dnssec.SignZone(coreDNS.Entries(), DomainName+".", coreDNS.Keys, dnssec.Options{
+		Inception:  now.Add(-dnssecInceptionSkew),
+		Expiration: now.Add(DNSSECSignatureValidity),
+	})
+	if err != nil {
+		err = fmt.Errorf("error signing CoreDNS zone file: %w", err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetStatus(codes.Ok, "")
+	return signed, nil
+}
+
+// signaturesDue reports whether the zone has to be uploaded for the sake of
+// its signatures: because they are about to expire, because it isn't signed
+// yet or because it is signed but there are no keys anymore.
+func (coreDNS *CoreDNS) signaturesDue(now time.Time) bool {
+	if len(coreDNS.Keys) == 0 {
+		return !coreDNS.signedUntil.IsZero()
+	}
+	return coreDNS.signedUntil.Sub(now) < DNSSECResignWindow
+}
+
 func (coreDNS *CoreDNS) Save(ctx context.Context) error {
 	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.CoreDNS.Save", trace.WithAttributes())
 	defer span.End()
@@ -200,7 +432,23 @@ func (coreDNS *CoreDNS) Save(ctx context.Context) error {
 		span.SetStatus(codes.Error, err.Error())
 		return err
 	}
-	data, err := coreDNS.ToBytes(ctx)
+	err = coreDNS.Lint(ctx)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	now := time.Now()
+	if !coreDNS.Changed(ctx) && !coreDNS.signaturesDue(now) {
+		span.SetAttributes(attribute.Bool("unchanged", true))
+		span.SetStatus(codes.Ok, "unchanged")
+		return nil
+	}
+	signed, err := coreDNS.Sign(ctx, now)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	data, err := coreDNS.render(ctx, signed)
 	if err != nil {
 		err = fmt.Errorf("error rendering CoreDNS zone file: %w", err)
 		span.SetStatus(codes.Error, err.Error())
@@ -212,11 +460,62 @@ func (coreDNS *CoreDNS) Save(ctx context.Context) error {
 		span.SetStatus(codes.Error, err.Error())
 		return err
 	}
+	coreDNS.saved = coreDNS.Entries()
+	coreDNS.signedUntil = dnssec.EarliestExpiration(signed)
 
 	span.SetStatus(codes.Ok, "")
 	return nil
 }
 
+// Flush saves the zone to the CoreDNS hosts. See Save.
+func (coreDNS *CoreDNS) Flush(ctx context.Context) error {
+	return coreDNS.Save(ctx)
+}
+
+// ListRecords returns the RRsets of the zone as records, except for the SOA
+// record, which is generated, and those outside of the zone.
+func (coreDNS *CoreDNS) ListRecords(ctx context.Context) ([]*DNSRecord, error) {
+	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.CoreDNS.ListRecords", trace.WithAttributes())
+	defer span.End()
+
+	records := []*DNSRecord{}
+	if coreDNS.Zone == nil {
+		span.SetStatus(codes.Ok, "")
+		return records, nil
+	}
+	for _, key := range coreDNS.Zone.Keys() {
+		name, ok := relativeRecordName(key.Owner)
+		if !ok || key.Type == "SOA" {
+			continue
+		}
+		record := &DNSRecord{
+			Name: name,
+			Type: key.Type,
+		}
+		for _, rrecord := range coreDNS.Zone.Get(key.Owner, key.Type) {
+			if rrecord.TTL != 0 {
+				record.TTL = int(rrecord.TTL / time.Second)
+			}
+			values := make([]string, 0, len(rrecord.RData))
+			for _, field := range rrecord.RData {
+				values = append(values, field.Value)
+			}
+			record.Records = append(record.Records, strings.Join(values, " "))
+		}
+		records = append(records, record)
+	}
+
+	span.SetAttributes(attribute.Int("records", len(records)))
+	span.SetStatus(codes.Ok, "")
+	return records, nil
+}
+
+// validateCoreDNSRecord checks that CoreDNS can serve record.
+func validateCoreDNSRecord(record *DNSRecord) error {
+	_, err := rrtype.Check(record.Type, rrtype.BackendCoreDNS)
+	return err
+}
+
 func (coreDNS *CoreDNS) UpsertRecord(ctx context.Context, record *DNSRecord, previous *DNSRecord) error {
 	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.CoreDNS.UpsertRecord", trace.WithAttributes(
 		telemetry.OtelJSON("record", record),
@@ -230,6 +529,12 @@ func (coreDNS *CoreDNS) UpsertRecord(ctx context.Context, record *DNSRecord, pre
 		return err
 	}
 
+	err := validateCoreDNSRecord(record)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
 	if record.ShouldReplace(previous) {
 		err := coreDNS.DeleteRecord(ctx, previous)
 		if err != nil {
@@ -239,39 +544,63 @@ func (coreDNS *CoreDNS) UpsertRecord(ctx context.Context, record *DNSRecord, pre
 		}
 	}
 
-	// TODO: make this algo less shit
-	err := coreDNS.DeleteRecord(ctx, record)
-	if err != nil {
-		err = fmt.Errorf("error deleting record: %w", err)
-		span.SetStatus(codes.Error, err.Error())
-		return err
-	}
+	records := make([]ast.RRecord, 0, len(record.Records))
 	for _, value := range record.Records {
 		rrecord := ast.RRecord{
 			Class: "IN",
 			Type:  record.Type,
-			RData: []ast.RData{
-				{
-					Value: value,
-				},
-			},
+			RData: recordValueRData(record.Type, value),
 		}
 		if record.TTL != 0 {
 			rrecord.TTL = time.Duration(record.TTL) * time.Second
 		}
-		coreDNS.Entries = append(coreDNS.Entries, ast.Node{
-			NodeType: ast.NodeTypeRREntry,
-			Entry: ast.RREntry{
-				DomainName: record.Name,
-				RRecord:    rrecord,
-			},
-		})
+		records = append(records, rrecord)
+	}
+	if coreDNS.Zone == nil {
+		err := coreDNS.setEntries(nil)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+	}
+	err = coreDNS.Zone.Set(record.Name, record.Type, records)
+	if err != nil {
+		err = fmt.Errorf("error setting record: %w", err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
 	}
 
 	span.SetStatus(codes.Ok, "")
 	return nil
 }
 
+// recordValueRData turns a value of a DNSRecord into RDATA fields. TXT values
+// without any quotes are taken as a single string so that e.g. SPF policies
+// keep their spaces, and strings longer than 255 bytes are split so that
+// DKIM keys don't get rejected.
+func recordValueRData(rrtype string, value string) []ast.RData {
+	if !strings.EqualFold(rrtype, "TXT") {
+		return []ast.RData{
+			{
+				Value: value,
+			},
+		}
+	}
+	if !strings.Contains(value, `"`) {
+		return ast.NewTXT(value).RData()
+	}
+	txt, err := ast.ParseTXT(ast.SplitRData(value))
+	if err != nil {
+		// already rejected by DNSRecord.Validate, keep it as-is
+		return []ast.RData{
+			{
+				Value: value,
+			},
+		}
+	}
+	return txt.RData()
+}
+
 func (coreDNS *CoreDNS) DeleteRecord(ctx context.Context, record *DNSRecord) error {
 	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.CoreDNS.DeleteRecord", trace.WithAttributes(
 		telemetry.OtelJSON("record", record),
@@ -284,49 +613,29 @@ func (coreDNS *CoreDNS) DeleteRecord(ctx context.Context, record *DNSRecord) err
 		return err
 	}
 
-	coreDNS.Entries = slices.DeleteFunc(coreDNS.Entries, func(node ast.Node) bool {
-		if !node.IsRREntry() {
-			return false
-		}
-		entry := node.RREntry()
-		if entry.RRecord.Type != record.Type {
-			return false
-		}
-		if entry.DomainName == record.Name {
-			return true
-		}
-		if entry.DomainName == record.FullHostname()+"." {
-			return true
-		}
-		return false
-	})
+	if coreDNS.Zone != nil {
+		coreDNS.Zone.Remove(record.FullHostname()+".", record.Type)
+	}
 
 	span.SetStatus(codes.Ok, "")
 	return nil
 }
 
 func (coreDNS *CoreDNS) BumpSOASerial() error {
-	for i := range coreDNS.Entries {
-		if !coreDNS.Entries[i].IsRREntry() {
-			continue
-		}
-		entry := coreDNS.Entries[i].RREntry()
-		if entry.RRecord.Type != "SOA" {
-			continue
-		}
-		if len(entry.RRecord.RData) != 7 {
-			return errors.New("invalid SOA entry")
-		}
-		serial, err := strconv.Atoi(entry.RRecord.RData[2].Value)
-		if err != nil {
-			return fmt.Errorf("could not parse serial: %w", err)
-		}
-		entry.RRecord.RData[2].Value = strconv.Itoa(serial + 1)
-		coreDNS.Entries[i].Entry = entry
-		return nil
+	if coreDNS.Zone == nil {
+		return errors.New("could not find SOA entry")
 	}
-
-	return errors.New("could not find SOA entry")
+	records := coreDNS.Zone.Get(DomainName+".", "SOA")
+	if len(records) == 0 {
+		return errors.New("could not find SOA entry")
+	}
+	soa, err := ast.ParseSOA(records[0].RData)
+	if err != nil {
+		return fmt.Errorf("invalid SOA entry: %w", err)
+	}
+	soa.Serial++
+	records[0] = records[0].WithTypedRData(soa)
+	return coreDNS.Zone.Set(DomainName+".", "SOA", records)
 }
 
 func (coreDNS *CoreDNS) GenerateZonePreamble() error {
@@ -351,29 +660,15 @@ func (coreDNS *CoreDNS) GenerateZonePreamble() error {
 				RRecord: ast.RRecord{
 					Class: "IN",
 					Type:  "SOA",
-					RData: []ast.RData{
-						{
-							Value: "rem.sapslaj.xyz.",
-						},
-						{
-							Value: "dns.sapslaj.com",
-						},
-						{
-							Value: fmt.Sprint(serial),
-						},
-						{
-							Value: "180",
-						},
-						{
-							Value: "60",
-						},
-						{
-							Value: "1209600",
-						},
-						{
-							Value: "900",
-						},
-					},
+					RData: ast.SOA{
+						MName:   "rem.sapslaj.xyz.",
+						RName:   "dns.sapslaj.com",
+						Serial:  uint32(serial),
+						Refresh: 180 * time.Second,
+						Retry:   60 * time.Second,
+						Expire:  1209600 * time.Second,
+						Minimum: 900 * time.Second,
+					}.RData(),
 				},
 			},
 		},
@@ -409,135 +704,29 @@ func (coreDNS *CoreDNS) GenerateZonePreamble() error {
 		},
 	}
 
-	for _, entry := range coreDNS.Entries {
-		if !entry.IsRREntry() {
-			continue
+	if coreDNS.Zone != nil {
+		for _, key := range coreDNS.Zone.Keys() {
+			if key.Type == "SOA" || key.Type == "NS" {
+				coreDNS.Zone.Remove(key.Owner, key.Type)
+			}
 		}
-		if entry.RREntry().RRecord.Type == "SOA" || entry.RREntry().RRecord.Type == "NS" {
-			continue
+	}
+	for _, entry := range coreDNS.Entries() {
+		// unparseable entries are kept so that they end up in the zone file
+		// unchanged
+		if entry.IsGenerateControlEntry() || entry.IsRawEntry() || entry.IsRREntry() {
+			newEntries = append(newEntries, entry)
 		}
-		newEntries = append(newEntries, entry)
 	}
 
-	coreDNS.Entries = newEntries
-
-	return nil
+	return coreDNS.setEntries(newEntries)
 }
 
+// FormatEntries puts the entries into the canonical layout of format.Format.
 func (coreDNS *CoreDNS) FormatEntries() error {
-	sortedEntries := []ast.Node{}
-
-	for _, entry := range coreDNS.Entries {
-		if !entry.IsOriginControlEntry() {
-			continue
-		}
-		sortedEntries = append(sortedEntries, entry)
-		break
-	}
-	for _, entry := range coreDNS.Entries {
-		if !entry.IsTTLControlEntry() {
-			continue
-		}
-		sortedEntries = append(sortedEntries, entry)
-		break
-	}
-	if len(sortedEntries) > 0 {
-		sortedEntries = append(sortedEntries, ast.Node{
-			NodeType: ast.NodeTypeEmpty,
-		})
-	}
-
-	for _, node := range coreDNS.Entries {
-		if !node.IsRREntry() {
-			continue
-		}
-		entry := node.RREntry()
-		if entry.RRecord.Type != "SOA" {
-			continue
-		}
-		if entry.DomainName != DomainName+"." {
-			continue
-		}
-		sortedEntries = append(sortedEntries, node)
-	}
-	for _, node := range coreDNS.Entries {
-		if !node.IsRREntry() {
-			continue
-		}
-		entry := node.RREntry()
-		if entry.RRecord.Type != "NS" {
-			continue
-		}
-		if entry.DomainName != DomainName+"." {
-			continue
-		}
-		sortedEntries = append(sortedEntries, node)
-	}
-
-	recordGroup := []ast.Node{}
-	flushRecordGroup := func() {
-		if len(recordGroup) == 0 {
-			return
-		}
-		sortedEntries = append(sortedEntries, ast.Node{
-			NodeType: ast.NodeTypeEmpty,
-		})
-		slices.SortFunc(recordGroup, func(a ast.Node, b ast.Node) int {
-			if a.NodeType != b.NodeType {
-				return 0
-			}
-			if !a.IsRREntry() || !b.IsRREntry() {
-				return 0
-			}
-			aEntry := a.RREntry()
-			bEntry := b.RREntry()
-			n := strings.Compare(aEntry.DomainName, bEntry.DomainName)
-			if n != 0 {
-				return n
-			}
-			n = strings.Compare(aEntry.RRecord.Class, bEntry.RRecord.Class)
-			if n != 0 {
-				return n
-			}
-			n = strings.Compare(aEntry.RRecord.Type, bEntry.RRecord.Type)
-			if n != 0 {
-				return n
-			}
-			return 0
-		})
-		if len(sortedEntries) > 0 && sortedEntries[len(sortedEntries)-1].NodeType != ast.NodeTypeEmpty {
-			sortedEntries = append(sortedEntries, ast.Node{
-				NodeType: ast.NodeTypeEmpty,
-			})
-		}
-		sortedEntries = append(sortedEntries, recordGroup...)
-		recordGroup = []ast.Node{}
-	}
-
-	for _, node := range coreDNS.Entries {
-		if node.IsIncludeControlEntry() {
-			flushRecordGroup()
-			if len(sortedEntries) > 0 && sortedEntries[len(sortedEntries)-1].NodeType != ast.NodeTypeEmpty {
-				sortedEntries = append(sortedEntries, ast.Node{
-					NodeType: ast.NodeTypeEmpty,
-				})
-			}
-			sortedEntries = append(sortedEntries, node)
-		}
-		if node.IsRREntry() {
-			entry := node.RREntry()
-			if entry.RRecord.Type == "SOA" && entry.DomainName == DomainName+"." {
-				continue
-			}
-			if entry.RRecord.Type == "NS" && entry.DomainName == DomainName+"." {
-				continue
-			}
-			recordGroup = append(recordGroup, node)
-		}
+	entries, err := format.Format(coreDNS.Entries(), DomainName+".")
+	if err != nil {
+		return err
 	}
-	flushRecordGroup()
-
-	coreDNS.Entries = sortedEntries
-
-	return nil
+	return coreDNS.setEntries(entries)
 }
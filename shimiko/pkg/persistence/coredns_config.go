@@ -0,0 +1,320 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/env"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/sshclient"
+)
+
+// ErrInvalidCoreDNSConfig is returned for a CoreDNS host configuration that
+// can't be used.
+var ErrInvalidCoreDNSConfig = errors.New("invalid CoreDNS configuration")
+
+// CoreDNSAuth is how shimiko authenticates to a CoreDNS host over SSH.
+type CoreDNSAuth string
+
+const (
+	// CoreDNSAuthPassword authenticates with the password in the environment
+	// variable named by CoreDNSHost.PasswordEnv.
+	CoreDNSAuthPassword CoreDNSAuth = "password"
+	// CoreDNSAuthKey authenticates with the private key at
+	// CoreDNSHost.KeyFile.
+	CoreDNSAuthKey CoreDNSAuth = "key"
+	// CoreDNSAuthAgent authenticates with the keys of the ssh-agent at
+	// CoreDNSHost.AgentSocket.
+	CoreDNSAuthAgent CoreDNSAuth = "agent"
+)
+
+const (
+	// DefaultCoreDNSPort is the SSH port of hosts that don't set one.
+	DefaultCoreDNSPort = 22
+	// DefaultCoreDNSMode is the file mode of zone files of hosts that don't
+	// set one.
+	DefaultCoreDNSMode = "0644"
+	// CoreDNSDialTimeout limits how long connecting to a host may take.
+	CoreDNSDialTimeout = 30 * time.Second
+	// DefaultCoreDNSUserEnv and DefaultCoreDNSPasswordEnv are the environment
+	// variables the credentials of hosts that don't set any come from.
+	DefaultCoreDNSUserEnv     = "VYOS_USERNAME"
+	DefaultCoreDNSPasswordEnv = "VYOS_PASSWORD"
+)
+
+// CoreDNSHost is a host running CoreDNS that the zone file is uploaded to.
+type CoreDNSHost struct {
+	// Address is the IP address or hostname of the host.
+	Address string `json:"address" yaml:"address"`
+	// Port is the SSH port, DefaultCoreDNSPort if zero.
+	Port int `json:"port,omitempty" yaml:"port,omitempty"`
+	// User is the SSH user, taken from DefaultCoreDNSUserEnv if empty.
+	User string `json:"user,omitempty" yaml:"user,omitempty"`
+	// Auth is the SSH authentication method, CoreDNSAuthPassword if empty.
+	Auth CoreDNSAuth `json:"auth,omitempty" yaml:"auth,omitempty"`
+	// PasswordEnv is the environment variable holding the password for
+	// CoreDNSAuthPassword, DefaultCoreDNSPasswordEnv if empty. Passwords
+	// aren't kept in the configuration itself.
+	PasswordEnv string `json:"password_env,omitempty" yaml:"password_env,omitempty"`
+	// KeyFile is the path of the private key for CoreDNSAuthKey.
+	KeyFile string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	// KeyPassphraseEnv is the environment variable holding the passphrase of
+	// KeyFile if it is encrypted.
+	KeyPassphraseEnv string `json:"key_passphrase_env,omitempty" yaml:"key_passphrase_env,omitempty"`
+	// AgentSocket is the socket of the ssh-agent for CoreDNSAuthAgent,
+	// taken from SSH_AUTH_SOCK if empty.
+	AgentSocket string `json:"agent_socket,omitempty" yaml:"agent_socket,omitempty"`
+	// KnownHosts is a known_hosts file the host key is looked up in.
+	KnownHosts string `json:"known_hosts,omitempty" yaml:"known_hosts,omitempty"`
+	// HostKeyFingerprints pin the host key, either as OpenSSH prints them
+	// ("SHA256:...") or as the RDATA of SSHFP records ("4 2 1E5E...").
+	HostKeyFingerprints []string `json:"host_key_fingerprints,omitempty" yaml:"host_key_fingerprints,omitempty"`
+	// InsecureIgnoreHostKey accepts any host key. If neither KnownHosts nor
+	// HostKeyFingerprints are set and this isn't either, the host key is
+	// looked up in the known_hosts file set by SHIMIKO_SSH_KNOWN_HOSTS, or
+	// ~/.ssh/known_hosts.
+	InsecureIgnoreHostKey bool `json:"insecure_ignore_host_key,omitempty" yaml:"insecure_ignore_host_key,omitempty"`
+	// ZonePath is the path of the zone file on the host, CoreDNSZoneFile if
+	// empty.
+	ZonePath string `json:"zone_path,omitempty" yaml:"zone_path,omitempty"`
+	// Mode is the octal file mode the zone file is written with,
+	// DefaultCoreDNSMode if empty.
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// SourceOfTruth marks the host the zone file is loaded from. Exactly one
+	// host has to be marked unless there is only one.
+	SourceOfTruth bool `json:"source_of_truth,omitempty" yaml:"source_of_truth,omitempty"`
+}
+
+// CoreDNSConfig configures the CoreDNS backend.
+type CoreDNSConfig struct {
+	Hosts []CoreDNSHost `json:"hosts" yaml:"hosts"`
+}
+
+// DefaultCoreDNSConfig is used if SHIMIKO_COREDNS_CONFIG isn't set.
+var DefaultCoreDNSConfig = CoreDNSConfig{
+	Hosts: []CoreDNSHost{
+		{
+			// using IP addresses in the event we have a chicken-and-egg problem
+			Address:       "172.24.4.2", // rem.sapslaj.xyz
+			SourceOfTruth: true,
+		},
+		{
+			Address: "172.24.4.3", // ram.sapslaj.xyz
+		},
+	},
+}
+
+// LoadCoreDNSConfig reads the CoreDNS configuration from the YAML or JSON
+// file at the path set by SHIMIKO_COREDNS_CONFIG, or returns
+// DefaultCoreDNSConfig if it isn't set. The hosts are returned with their
+// defaults filled in.
+func LoadCoreDNSConfig() (CoreDNSConfig, error) {
+	path := env.MustGetDefault("SHIMIKO_COREDNS_CONFIG", "")
+	if path == "" {
+		return DefaultCoreDNSConfig.withDefaults()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CoreDNSConfig{}, fmt.Errorf("error reading CoreDNS configuration '%s': %w", path, err)
+	}
+	return ParseCoreDNSConfig(data)
+}
+
+// ParseCoreDNSConfig parses and checks a CoreDNS configuration in YAML or
+// JSON and fills in the defaults of its hosts.
+func ParseCoreDNSConfig(data []byte) (CoreDNSConfig, error) {
+	var config CoreDNSConfig
+	err := yaml.Unmarshal(data, &config)
+	if err != nil {
+		return CoreDNSConfig{}, fmt.Errorf("%w: %w", ErrInvalidCoreDNSConfig, err)
+	}
+	return config.withDefaults()
+}
+
+func (config CoreDNSConfig) withDefaults() (CoreDNSConfig, error) {
+	if len(config.Hosts) == 0 {
+		return CoreDNSConfig{}, fmt.Errorf("%w: no hosts", ErrInvalidCoreDNSConfig)
+	}
+	hosts := make([]CoreDNSHost, 0, len(config.Hosts))
+	sources := 0
+	for _, host := range config.Hosts {
+		if host.Address == "" {
+			return CoreDNSConfig{}, fmt.Errorf("%w: host without an address", ErrInvalidCoreDNSConfig)
+		}
+		if host.Port == 0 {
+			host.Port = DefaultCoreDNSPort
+		}
+		if host.Port < 0 || host.Port > 65535 {
+			return CoreDNSConfig{}, fmt.Errorf("%w: port %d of %s is out of range", ErrInvalidCoreDNSConfig, host.Port, host.Address)
+		}
+		if host.Auth == "" {
+			host.Auth = CoreDNSAuthPassword
+		}
+		switch host.Auth {
+		case CoreDNSAuthPassword:
+			if host.PasswordEnv == "" {
+				host.PasswordEnv = DefaultCoreDNSPasswordEnv
+			}
+		case CoreDNSAuthKey:
+			if host.KeyFile == "" {
+				return CoreDNSConfig{}, fmt.Errorf("%w: %s uses key auth without a key_file", ErrInvalidCoreDNSConfig, host.Address)
+			}
+		case CoreDNSAuthAgent:
+		default:
+			return CoreDNSConfig{}, fmt.Errorf("%w: unknown auth method '%s' of %s", ErrInvalidCoreDNSConfig, host.Auth, host.Address)
+		}
+		for _, fingerprint := range host.HostKeyFingerprints {
+			_, err := sshclient.ParseFingerprint(fingerprint)
+			if err != nil {
+				return CoreDNSConfig{}, fmt.Errorf("%w: host key fingerprint of %s: %w", ErrInvalidCoreDNSConfig, host.Address, err)
+			}
+		}
+		if host.ZonePath == "" {
+			host.ZonePath = CoreDNSZoneFile
+		}
+		if host.Mode == "" {
+			host.Mode = DefaultCoreDNSMode
+		}
+		_, err := host.FileMode()
+		if err != nil {
+			return CoreDNSConfig{}, fmt.Errorf("%w: mode '%s' of %s: %w", ErrInvalidCoreDNSConfig, host.Mode, host.Address, err)
+		}
+		if host.SourceOfTruth {
+			sources++
+		}
+		hosts = append(hosts, host)
+	}
+	if len(hosts) == 1 {
+		hosts[0].SourceOfTruth = true
+	} else if sources != 1 {
+		return CoreDNSConfig{}, fmt.Errorf("%w: %d hosts are marked as the source of truth, expected 1", ErrInvalidCoreDNSConfig, sources)
+	}
+	return CoreDNSConfig{
+		Hosts: hosts,
+	}, nil
+}
+
+// SourceOfTruth returns the host the zone file is loaded from.
+func (config CoreDNSConfig) SourceOfTruth() (CoreDNSHost, error) {
+	for _, host := range config.Hosts {
+		if host.SourceOfTruth {
+			return host, nil
+		}
+	}
+	if len(config.Hosts) == 1 {
+		return config.Hosts[0], nil
+	}
+	return CoreDNSHost{}, fmt.Errorf("%w: no host is marked as the source of truth", ErrInvalidCoreDNSConfig)
+}
+
+// Addr returns the address to connect to with SSH.
+func (host CoreDNSHost) Addr() string {
+	port := host.Port
+	if port == 0 {
+		port = DefaultCoreDNSPort
+	}
+	return net.JoinHostPort(host.Address, strconv.Itoa(port))
+}
+
+// FileMode parses Mode.
+func (host CoreDNSHost) FileMode() (fs.FileMode, error) {
+	mode := host.Mode
+	if mode == "" {
+		mode = DefaultCoreDNSMode
+	}
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	if perm&^uint64(fs.ModePerm) != 0 {
+		return 0, fmt.Errorf("only permission bits may be set")
+	}
+	return fs.FileMode(perm), nil
+}
+
+// SSHConfig returns the SSH configuration of the host, with the credentials
+// taken from the environment.
+func (host CoreDNSHost) SSHConfig() (sshclient.Config, error) {
+	config := sshclient.Config{
+		User:                  host.User,
+		Fingerprints:          host.HostKeyFingerprints,
+		InsecureIgnoreHostKey: host.InsecureIgnoreHostKey,
+		Timeout:               CoreDNSDialTimeout,
+	}
+	if config.User == "" {
+		var err error
+		config.User, err = env.Get[string](DefaultCoreDNSUserEnv)
+		if err != nil {
+			return config, fmt.Errorf("error getting %s: %w", DefaultCoreDNSUserEnv, err)
+		}
+	}
+
+	if host.KnownHosts != "" {
+		config.KnownHostsFiles = []string{host.KnownHosts}
+	} else if len(host.HostKeyFingerprints) == 0 && !host.InsecureIgnoreHostKey {
+		knownHosts, err := defaultKnownHosts()
+		if err != nil {
+			return config, err
+		}
+		config.KnownHostsFiles = []string{knownHosts}
+	}
+
+	switch host.Auth {
+	case CoreDNSAuthPassword, "":
+		passwordEnv := host.PasswordEnv
+		if passwordEnv == "" {
+			passwordEnv = DefaultCoreDNSPasswordEnv
+		}
+		password, err := env.Get[string](passwordEnv)
+		if err != nil {
+			return config, fmt.Errorf("error getting %s: %w", passwordEnv, err)
+		}
+		config.Password = password
+	case CoreDNSAuthKey:
+		key, err := os.ReadFile(host.KeyFile)
+		if err != nil {
+			return config, fmt.Errorf("error reading private key of %s: %w", host.Address, err)
+		}
+		config.PrivateKey = key
+		if host.KeyPassphraseEnv != "" {
+			config.PrivateKeyPassphrase, err = env.Get[string](host.KeyPassphraseEnv)
+			if err != nil {
+				return config, fmt.Errorf("error getting %s: %w", host.KeyPassphraseEnv, err)
+			}
+		}
+	case CoreDNSAuthAgent:
+		config.AgentSocket = host.AgentSocket
+		if config.AgentSocket == "" {
+			socket, err := env.Get[string]("SSH_AUTH_SOCK")
+			if err != nil {
+				return config, fmt.Errorf("error getting SSH_AUTH_SOCK: %w", err)
+			}
+			config.AgentSocket = socket
+		}
+	default:
+		return config, fmt.Errorf("%w: unknown auth method '%s' of %s", ErrInvalidCoreDNSConfig, host.Auth, host.Address)
+	}
+
+	return config, nil
+}
+
+// defaultKnownHosts returns the known_hosts file of hosts that don't pin
+// their host keys themselves.
+func defaultKnownHosts() (string, error) {
+	path := env.MustGetDefault("SHIMIKO_SSH_KNOWN_HOSTS", "")
+	if path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding known_hosts file: %w", err)
+	}
+	return filepath.Join(home, ".ssh", "known_hosts"), nil
+}
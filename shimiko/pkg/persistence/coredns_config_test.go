@@ -0,0 +1,352 @@
+package persistence_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/env"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/persistence"
+)
+
+// defaultHost is a host with all defaults filled in.
+func defaultHost(address string, sourceOfTruth bool) persistence.CoreDNSHost {
+	return persistence.CoreDNSHost{
+		Address:       address,
+		Port:          persistence.DefaultCoreDNSPort,
+		Auth:          persistence.CoreDNSAuthPassword,
+		PasswordEnv:   persistence.DefaultCoreDNSPasswordEnv,
+		ZonePath:      persistence.CoreDNSZoneFile,
+		Mode:          persistence.DefaultCoreDNSMode,
+		SourceOfTruth: sourceOfTruth,
+	}
+}
+
+func TestParseCoreDNSConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input       string
+		expected    []persistence.CoreDNSHost
+		errContains string
+	}{
+		"defaults": {
+			input: "hosts:\n  - address: 192.0.2.1\n",
+			expected: []persistence.CoreDNSHost{
+				defaultHost("192.0.2.1", true),
+			},
+		},
+		"JSON": {
+			input: `{"hosts": [{"address": "192.0.2.1"}, {"address": "192.0.2.2", "source_of_truth": true}]}`,
+			expected: []persistence.CoreDNSHost{
+				defaultHost("192.0.2.1", false),
+				defaultHost("192.0.2.2", true),
+			},
+		},
+		"explicit values are kept": {
+			input: `
+hosts:
+  - address: ns1.example.com
+    port: 2222
+    user: coredns
+    auth: key
+    key_file: /etc/shimiko/id_ed25519
+    key_passphrase_env: SHIMIKO_KEY_PASSPHRASE
+    known_hosts: /etc/shimiko/known_hosts
+    host_key_fingerprints:
+      - "4 2 0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF"
+    zone_path: /srv/coredns/example.com.zone
+    mode: "0640"
+`,
+			expected: []persistence.CoreDNSHost{
+				{
+					Address:             "ns1.example.com",
+					Port:                2222,
+					User:                "coredns",
+					Auth:                persistence.CoreDNSAuthKey,
+					KeyFile:             "/etc/shimiko/id_ed25519",
+					KeyPassphraseEnv:    "SHIMIKO_KEY_PASSPHRASE",
+					KnownHosts:          "/etc/shimiko/known_hosts",
+					HostKeyFingerprints: []string{"4 2 0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF"},
+					ZonePath:            "/srv/coredns/example.com.zone",
+					Mode:                "0640",
+					SourceOfTruth:       true,
+				},
+			},
+		},
+		"agent auth": {
+			input: "hosts:\n  - address: 192.0.2.1\n    auth: agent\n",
+			expected: []persistence.CoreDNSHost{
+				{
+					Address:       "192.0.2.1",
+					Port:          persistence.DefaultCoreDNSPort,
+					Auth:          persistence.CoreDNSAuthAgent,
+					ZonePath:      persistence.CoreDNSZoneFile,
+					Mode:          persistence.DefaultCoreDNSMode,
+					SourceOfTruth: true,
+				},
+			},
+		},
+		"no hosts": {
+			input:       "hosts: []\n",
+			errContains: "no hosts",
+		},
+		"host without address": {
+			input:       "hosts:\n  - port: 22\n",
+			errContains: "host without an address",
+		},
+		"port out of range": {
+			input:       "hosts:\n  - address: 192.0.2.1\n    port: 65536\n",
+			errContains: "port 65536 of 192.0.2.1 is out of range",
+		},
+		"unknown auth": {
+			input:       "hosts:\n  - address: 192.0.2.1\n    auth: kerberos\n",
+			errContains: "unknown auth method 'kerberos' of 192.0.2.1",
+		},
+		"key auth without key file": {
+			input:       "hosts:\n  - address: 192.0.2.1\n    auth: key\n",
+			errContains: "192.0.2.1 uses key auth without a key_file",
+		},
+		"bad fingerprint": {
+			input:       "hosts:\n  - address: 192.0.2.1\n    host_key_fingerprints: [nope]\n",
+			errContains: "host key fingerprint of 192.0.2.1",
+		},
+		"mode that isn't octal": {
+			input:       "hosts:\n  - address: 192.0.2.1\n    mode: \"0899\"\n",
+			errContains: "mode '0899' of 192.0.2.1",
+		},
+		"mode with more than permission bits": {
+			input:       "hosts:\n  - address: 192.0.2.1\n    mode: \"1644\"\n",
+			errContains: "only permission bits may be set",
+		},
+		"no source of truth": {
+			input:       "hosts:\n  - address: 192.0.2.1\n  - address: 192.0.2.2\n",
+			errContains: "0 hosts are marked as the source of truth",
+		},
+		"several sources of truth": {
+			input:       "hosts:\n  - address: 192.0.2.1\n    source_of_truth: true\n  - address: 192.0.2.2\n    source_of_truth: true\n",
+			errContains: "2 hosts are marked as the source of truth",
+		},
+		"not YAML": {
+			input:       "hosts: [",
+			errContains: "invalid CoreDNS configuration",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config, err := persistence.ParseCoreDNSConfig([]byte(tc.input))
+			if tc.errContains != "" {
+				assert.ErrorIs(t, err, persistence.ErrInvalidCoreDNSConfig)
+				assert.ErrorContains(t, err, tc.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, config.Hosts)
+		})
+	}
+}
+
+func TestCoreDNSConfigSourceOfTruth(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		hosts    []persistence.CoreDNSHost
+		expected string
+		err      error
+	}{
+		"marked host": {
+			hosts: []persistence.CoreDNSHost{
+				{Address: "192.0.2.1"},
+				{Address: "192.0.2.2", SourceOfTruth: true},
+			},
+			expected: "192.0.2.2",
+		},
+		"single unmarked host": {
+			hosts: []persistence.CoreDNSHost{
+				{Address: "192.0.2.1"},
+			},
+			expected: "192.0.2.1",
+		},
+		"several marked hosts": {
+			hosts: []persistence.CoreDNSHost{
+				{Address: "192.0.2.1"},
+				{Address: "192.0.2.2", SourceOfTruth: true},
+				{Address: "192.0.2.3", SourceOfTruth: true},
+			},
+			expected: "192.0.2.2",
+		},
+		"no marked host": {
+			hosts: []persistence.CoreDNSHost{
+				{Address: "192.0.2.1"},
+				{Address: "192.0.2.2"},
+			},
+			err: persistence.ErrInvalidCoreDNSConfig,
+		},
+		"no hosts": {
+			err: persistence.ErrInvalidCoreDNSConfig,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := persistence.CoreDNSConfig{Hosts: tc.hosts}
+			host, err := config.SourceOfTruth()
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, host.Address)
+		})
+	}
+}
+
+// TestLoadCoreDNSConfig can't run in parallel because it sets environment
+// variables.
+func TestLoadCoreDNSConfig(t *testing.T) {
+	t.Setenv("SHIMIKO_COREDNS_CONFIG", "")
+	config, err := persistence.LoadCoreDNSConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []persistence.CoreDNSHost{
+		defaultHost("172.24.4.2", true),
+		defaultHost("172.24.4.3", false),
+	}, config.Hosts)
+
+	path := filepath.Join(t.TempDir(), "coredns.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("hosts:\n  - address: 192.0.2.1\n"), 0o600))
+	t.Setenv("SHIMIKO_COREDNS_CONFIG", path)
+	config, err = persistence.LoadCoreDNSConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []persistence.CoreDNSHost{defaultHost("192.0.2.1", true)}, config.Hosts)
+
+	t.Setenv("SHIMIKO_COREDNS_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	_, err = persistence.LoadCoreDNSConfig()
+	assert.ErrorContains(t, err, "error reading CoreDNS configuration")
+}
+
+// TestCoreDNSHostSSHConfig can't run in parallel because it sets environment
+// variables.
+func TestCoreDNSHostSSHConfig(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, []byte("not really a key"), 0o600))
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+
+	t.Setenv(persistence.DefaultCoreDNSUserEnv, "vyos")
+	t.Setenv(persistence.DefaultCoreDNSPasswordEnv, "hunter2")
+	t.Setenv("SHIMIKO_TEST_PASSWORD", "correct horse")
+	t.Setenv("SHIMIKO_TEST_PASSPHRASE", "battery staple")
+	t.Setenv("SHIMIKO_SSH_KNOWN_HOSTS", knownHosts)
+	t.Setenv("SSH_AUTH_SOCK", "/run/agent.sock")
+	os.Unsetenv("SHIMIKO_TEST_MISSING_PASSWORD")
+
+	tests := map[string]struct {
+		host            persistence.CoreDNSHost
+		user            string
+		password        string
+		privateKey      string
+		passphrase      string
+		agentSocket     string
+		knownHostsFiles []string
+		fingerprints    []string
+		errVar          string
+		errContains     string
+	}{
+		"defaults": {
+			host:            persistence.CoreDNSHost{Address: "192.0.2.1"},
+			user:            "vyos",
+			password:        "hunter2",
+			knownHostsFiles: []string{knownHosts},
+		},
+		"password from another variable": {
+			host: persistence.CoreDNSHost{
+				Address:     "192.0.2.1",
+				User:        "coredns",
+				Auth:        persistence.CoreDNSAuthPassword,
+				PasswordEnv: "SHIMIKO_TEST_PASSWORD",
+				KnownHosts:  "/etc/shimiko/known_hosts",
+			},
+			user:            "coredns",
+			password:        "correct horse",
+			knownHostsFiles: []string{"/etc/shimiko/known_hosts"},
+		},
+		"missing password variable": {
+			host: persistence.CoreDNSHost{
+				Address:     "192.0.2.1",
+				PasswordEnv: "SHIMIKO_TEST_MISSING_PASSWORD",
+			},
+			errVar:      "SHIMIKO_TEST_MISSING_PASSWORD",
+			errContains: "error getting SHIMIKO_TEST_MISSING_PASSWORD",
+		},
+		"key": {
+			host: persistence.CoreDNSHost{
+				Address:             "192.0.2.1",
+				Auth:                persistence.CoreDNSAuthKey,
+				KeyFile:             keyFile,
+				KeyPassphraseEnv:    "SHIMIKO_TEST_PASSPHRASE",
+				HostKeyFingerprints: []string{"SHA256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU"},
+			},
+			user:         "vyos",
+			privateKey:   "not really a key",
+			passphrase:   "battery staple",
+			fingerprints: []string{"SHA256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU"},
+		},
+		"missing key file": {
+			host: persistence.CoreDNSHost{
+				Address: "192.0.2.1",
+				Auth:    persistence.CoreDNSAuthKey,
+				KeyFile: filepath.Join(t.TempDir(), "missing"),
+			},
+			errContains: "error reading private key of 192.0.2.1",
+		},
+		"agent": {
+			host: persistence.CoreDNSHost{
+				Address:               "192.0.2.1",
+				Auth:                  persistence.CoreDNSAuthAgent,
+				InsecureIgnoreHostKey: true,
+			},
+			user:        "vyos",
+			agentSocket: "/run/agent.sock",
+		},
+		"unknown auth": {
+			host: persistence.CoreDNSHost{
+				Address: "192.0.2.1",
+				Auth:    "kerberos",
+			},
+			errContains: "unknown auth method 'kerberos'",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			config, err := tc.host.SSHConfig()
+			if tc.errContains != "" {
+				assert.ErrorContains(t, err, tc.errContains)
+				if tc.errVar != "" {
+					var notFound *env.ErrVarNotFound
+					require.ErrorAs(t, err, &notFound)
+					assert.Equal(t, tc.errVar, notFound.Name)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.user, config.User)
+			assert.Equal(t, tc.password, config.Password)
+			assert.Equal(t, tc.privateKey, string(config.PrivateKey))
+			assert.Equal(t, tc.passphrase, config.PrivateKeyPassphrase)
+			assert.Equal(t, tc.agentSocket, config.AgentSocket)
+			assert.Equal(t, tc.knownHostsFiles, config.KnownHostsFiles)
+			assert.Equal(t, tc.fingerprints, config.Fingerprints)
+			assert.Equal(t, persistence.CoreDNSDialTimeout, config.Timeout)
+		})
+	}
+}
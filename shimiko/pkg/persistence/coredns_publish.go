@@ -0,0 +1,215 @@
+package persistence
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/bramvdbogaerde/go-scp"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/publish"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/dnssec"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lint"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+)
+
+// CoreDNSRemote is a connection to a CoreDNS host that the zone file is
+// published over.
+type CoreDNSRemote interface {
+	// CopyFile writes data to path on the host with the permissions of mode.
+	CopyFile(ctx context.Context, data []byte, path string, mode fs.FileMode) error
+	// Run runs command with the shell of the host and returns its combined
+	// output.
+	Run(ctx context.Context, command string) (string, error)
+	Close() error
+}
+
+// CoreDNSDialer connects to a CoreDNS host.
+type CoreDNSDialer func(host CoreDNSHost) (CoreDNSRemote, error)
+
+// scpRemote is a CoreDNSRemote over SSH.
+type scpRemote struct {
+	client *scp.Client
+}
+
+func (remote *scpRemote) CopyFile(ctx context.Context, data []byte, path string, mode fs.FileMode) error {
+	return remote.client.CopyFile(ctx, bytes.NewReader(data), path, fmt.Sprintf("%04o", uint32(mode.Perm())))
+}
+
+func (remote *scpRemote) Run(ctx context.Context, command string) (string, error) {
+	session, err := remote.client.SSHClient().NewSession()
+	if err != nil {
+		return "", fmt.Errorf("error opening SSH session: %w", err)
+	}
+	defer session.Close()
+	output, err := session.CombinedOutput(command)
+	return string(output), err
+}
+
+func (remote *scpRemote) Close() error {
+	remote.client.Close()
+	return nil
+}
+
+// dial connects to host with Dial, or over SSH if it is nil.
+func (coreDNS *CoreDNS) dial(host CoreDNSHost) (CoreDNSRemote, error) {
+	if coreDNS.Dial != nil {
+		return coreDNS.Dial(host)
+	}
+	client, err := coreDNS.MakeScpClient(host)
+	if err != nil {
+		return nil, err
+	}
+	return &scpRemote{client: client}, nil
+}
+
+// coreDNSPublishHost publishes the zone file to a CoreDNS host. The new
+// version is staged at StagingPath and the previous one kept at BackupPath.
+type coreDNSPublishHost struct {
+	coreDNS *CoreDNS
+	host    CoreDNSHost
+	remote  CoreDNSRemote
+	// backedUp is set once Commit copied the zone file to the backup path,
+	// and existed if there was a zone file to copy.
+	backedUp bool
+	existed  bool
+}
+
+var _ publish.Host = (*coreDNSPublishHost)(nil)
+
+// StagingPath is where the zone file is uploaded to before it is renamed
+// into place. It is next to ZonePath so that the rename is atomic.
+func (host CoreDNSHost) StagingPath() string {
+	return host.ZonePath + ".shimiko-new"
+}
+
+// BackupPath is where the previous version of the zone file is kept.
+func (host CoreDNSHost) BackupPath() string {
+	return host.ZonePath + ".bak"
+}
+
+// shellQuote quotes s as a single word for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (ph *coreDNSPublishHost) Name() string {
+	return ph.host.Address
+}
+
+// run runs command on the host and returns its output.
+func (ph *coreDNSPublishHost) run(ctx context.Context, command string) (string, error) {
+	output, err := ph.remote.Run(ctx, command)
+	if err != nil {
+		return output, fmt.Errorf("error running '%s': %w: %s", command, err, strings.TrimSpace(output))
+	}
+	return output, nil
+}
+
+func (ph *coreDNSPublishHost) Stage(ctx context.Context, data []byte) error {
+	mode, err := ph.host.FileMode()
+	if err != nil {
+		return fmt.Errorf("error parsing zone file mode: %w", err)
+	}
+	ph.remote, err = ph.coreDNS.dial(ph.host)
+	if err != nil {
+		return fmt.Errorf("error creating new scp client for CoreDNS: %w", err)
+	}
+	err = ph.remote.CopyFile(ctx, data, ph.host.StagingPath(), mode)
+	if err != nil {
+		return fmt.Errorf("error copying file to remote '%s' for CoreDNS: %w", ph.host.StagingPath(), err)
+	}
+	return nil
+}
+
+// Validate reads the staged zone file back and checks that it is byte for
+// byte the uploaded data and that it passes shimiko's own checks: every entry
+// must parse, except for the ones that were kept verbatim when the zone was
+// loaded, and the zone must pass lint without errors. CoreDNS itself isn't
+// run, so that it loads the zone file is not guaranteed.
+func (ph *coreDNSPublishHost) Validate(ctx context.Context, data []byte) error {
+	stagingPath := ph.host.StagingPath()
+	staged, err := ph.run(ctx, "cat "+shellQuote(stagingPath))
+	if err != nil {
+		return err
+	}
+	if staged != string(data) {
+		return fmt.Errorf("staged zone file '%s' differs from the uploaded zone file (%d bytes, expected %d)", stagingPath, len(staged), len(data))
+	}
+
+	entries, parseErrs, err := parser.ParseReaderTolerant(strings.NewReader(staged))
+	if err != nil {
+		return fmt.Errorf("error parsing staged zone file '%s': %w", stagingPath, err)
+	}
+	// the entries that fail to parse must have been kept verbatim since the
+	// zone was loaded, each of them at most as often as it was loaded
+	unparseable := map[string]int{}
+	for _, text := range ph.coreDNS.unparseable {
+		unparseable[text]++
+	}
+	for _, parseErr := range parseErrs {
+		text := entries[parseErr.Entry].RawEntry().Text
+		if unparseable[text] == 0 {
+			return fmt.Errorf("error parsing staged zone file '%s': %w", stagingPath, parseErr)
+		}
+		unparseable[text]--
+	}
+	_, err = lint.Validate(dnssec.Strip(entries), DomainName+".")
+	if err != nil {
+		return fmt.Errorf("staged zone file '%s' is invalid: %w", stagingPath, err)
+	}
+	return nil
+}
+
+func (ph *coreDNSPublishHost) Commit(ctx context.Context) error {
+	zonePath := shellQuote(ph.host.ZonePath)
+	output, err := ph.run(ctx, fmt.Sprintf(
+		"if [ -e %s ]; then cp -p %s %s && echo existed; fi",
+		zonePath, zonePath, shellQuote(ph.host.BackupPath()),
+	))
+	if err != nil {
+		return fmt.Errorf("error backing up zone file: %w", err)
+	}
+	ph.backedUp = true
+	ph.existed = strings.TrimSpace(output) == "existed"
+
+	_, err = ph.run(ctx, fmt.Sprintf("mv -f %s %s", shellQuote(ph.host.StagingPath()), zonePath))
+	if err != nil {
+		return fmt.Errorf("error moving staged zone file into place: %w", err)
+	}
+	return nil
+}
+
+func (ph *coreDNSPublishHost) Rollback(ctx context.Context) error {
+	if ph.remote == nil || !ph.backedUp {
+		// nothing was changed
+		return ph.Abort(ctx)
+	}
+	zonePath := shellQuote(ph.host.ZonePath)
+	stagingPath := shellQuote(ph.host.StagingPath())
+	if !ph.existed {
+		_, err := ph.run(ctx, fmt.Sprintf("rm -f %s %s", zonePath, stagingPath))
+		return err
+	}
+	// restore through the staging path so that the zone file is replaced
+	// atomically again
+	_, err := ph.run(ctx, fmt.Sprintf("cp -p %s %s && mv -f %s %s", shellQuote(ph.host.BackupPath()), stagingPath, stagingPath, zonePath))
+	return err
+}
+
+func (ph *coreDNSPublishHost) Abort(ctx context.Context) error {
+	if ph.remote == nil {
+		return nil
+	}
+	_, err := ph.run(ctx, "rm -f "+shellQuote(ph.host.StagingPath()))
+	return err
+}
+
+// close closes the connection to the host, if there is one.
+func (ph *coreDNSPublishHost) close() {
+	if ph.remote != nil {
+		ph.remote.Close()
+	}
+}
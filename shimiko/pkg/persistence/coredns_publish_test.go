@@ -0,0 +1,281 @@
+package persistence_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/persistence"
+)
+
+const publishZone = `$ORIGIN sapslaj.xyz.
+@ 3600 IN SOA ns1 hostmaster 2024010101 7200 3600 1209600 3600
+@ 3600 IN NS ns1
+ns1 3600 IN A 192.0.2.1
+www 3600 IN A 192.0.2.2
+`
+
+// fakeRemote is a CoreDNSRemote that runs commands with the local shell.
+// The paths of the zone files are in dir, which is cut from the recorded
+// commands.
+type fakeRemote struct {
+	dir string
+	// corrupt truncates the files copied to the host.
+	corrupt bool
+	// fail is the prefix of a command that fails the first time it is run.
+	fail string
+
+	mu       *sync.Mutex
+	commands *[]string
+}
+
+func (remote *fakeRemote) CopyFile(ctx context.Context, data []byte, path string, mode fs.FileMode) error {
+	remote.record("copy " + path)
+	if remote.corrupt {
+		data = data[:len(data)/2]
+	}
+	return os.WriteFile(path, data, mode)
+}
+
+func (remote *fakeRemote) Run(ctx context.Context, command string) (string, error) {
+	remote.record(command)
+	if remote.fail != "" && strings.HasPrefix(command, remote.fail) {
+		remote.fail = ""
+		return "fake failure", errFake
+	}
+	output, err := exec.CommandContext(ctx, "sh", "-c", command).CombinedOutput()
+	return string(output), err
+}
+
+func (remote *fakeRemote) Close() error {
+	return nil
+}
+
+func (remote *fakeRemote) record(command string) {
+	remote.mu.Lock()
+	defer remote.mu.Unlock()
+	*remote.commands = append(*remote.commands, strings.ReplaceAll(command, remote.dir, ""))
+}
+
+func TestSaveCoreDNSZoneFile(t *testing.T) {
+	t.Parallel()
+
+	const previous = "; previous zone\n"
+
+	tests := map[string]struct {
+		data string
+		// loaded is loaded into the zone before data is published.
+		loaded   string
+		previous map[string]string
+		corrupt  map[string]bool
+		fail     map[string]string
+		// expected is the zone file of each host afterwards, or "" if there
+		// must be none.
+		expected    map[string]string
+		commands    map[string][]string
+		errContains string
+	}{
+		"new zone files": {
+			data:     publishZone,
+			expected: map[string]string{"a": publishZone, "b": publishZone},
+			commands: map[string][]string{
+				"a": {
+					"copy /a/zone.shimiko-new",
+					"cat '/a/zone.shimiko-new'",
+					"if [ -e '/a/zone' ]; then cp -p '/a/zone' '/a/zone.bak' && echo existed; fi",
+					"mv -f '/a/zone.shimiko-new' '/a/zone'",
+				},
+				"b": {
+					"copy /b/zone.shimiko-new",
+					"cat '/b/zone.shimiko-new'",
+					"if [ -e '/b/zone' ]; then cp -p '/b/zone' '/b/zone.bak' && echo existed; fi",
+					"mv -f '/b/zone.shimiko-new' '/b/zone'",
+				},
+			},
+		},
+		"replaces zone files": {
+			data:     publishZone,
+			previous: map[string]string{"a": previous, "b": previous},
+			expected: map[string]string{"a": publishZone, "b": publishZone},
+		},
+		"invalid zone is not moved into place": {
+			data:     publishZone + "www 3600 IN CNAME ns1\n",
+			previous: map[string]string{"a": previous, "b": previous},
+			expected: map[string]string{"a": previous, "b": previous},
+			commands: map[string][]string{
+				"a": {
+					"copy /a/zone.shimiko-new",
+					"cat '/a/zone.shimiko-new'",
+					"rm -f '/a/zone.shimiko-new'",
+				},
+				"b": {},
+			},
+			errContains: "is invalid",
+		},
+		"unparseable zone is not moved into place": {
+			data:        publishZone + "mail 300 300 IN A 192.0.2.3\n",
+			previous:    map[string]string{"a": previous, "b": previous},
+			expected:    map[string]string{"a": previous, "b": previous},
+			errContains: "error parsing staged zone file",
+		},
+		"entries kept verbatim since loading": {
+			data:     publishZone + "mail 300 300 IN A 192.0.2.3\n",
+			loaded:   publishZone + "mail 300 300 IN A 192.0.2.3\n",
+			previous: map[string]string{"a": previous, "b": previous},
+			expected: map[string]string{
+				"a": publishZone + "mail 300 300 IN A 192.0.2.3\n",
+				"b": publishZone + "mail 300 300 IN A 192.0.2.3\n",
+			},
+		},
+		"entry broken differently than when loading": {
+			data:        publishZone + "ftp 300 300 IN A 192.0.2.4\n",
+			loaded:      publishZone + "mail 300 300 IN A 192.0.2.3\n",
+			previous:    map[string]string{"a": previous, "b": previous},
+			expected:    map[string]string{"a": previous, "b": previous},
+			errContains: "error parsing staged zone file",
+		},
+		"entry kept verbatim more often than loaded": {
+			data:        publishZone + "mail 300 300 IN A 192.0.2.3\nmail 300 300 IN A 192.0.2.3\n",
+			loaded:      publishZone + "mail 300 300 IN A 192.0.2.3\n",
+			previous:    map[string]string{"a": previous, "b": previous},
+			expected:    map[string]string{"a": previous, "b": previous},
+			errContains: "error parsing staged zone file",
+		},
+		"corrupted upload": {
+			data:     publishZone,
+			previous: map[string]string{"a": previous, "b": previous},
+			corrupt:  map[string]bool{"b": true},
+			expected: map[string]string{"a": previous, "b": previous},
+			commands: map[string][]string{
+				"a": {
+					"copy /a/zone.shimiko-new",
+					"cat '/a/zone.shimiko-new'",
+					"rm -f '/a/zone.shimiko-new'",
+				},
+				"b": {
+					"copy /b/zone.shimiko-new",
+					"cat '/b/zone.shimiko-new'",
+					"rm -f '/b/zone.shimiko-new'",
+				},
+			},
+			errContains: "differs from the uploaded zone file",
+		},
+		"failed commit is rolled back": {
+			data:     publishZone,
+			previous: map[string]string{"a": previous, "b": previous},
+			fail:     map[string]string{"b": "mv -f"},
+			expected: map[string]string{"a": previous, "b": previous},
+			commands: map[string][]string{
+				"a": {
+					"copy /a/zone.shimiko-new",
+					"cat '/a/zone.shimiko-new'",
+					"if [ -e '/a/zone' ]; then cp -p '/a/zone' '/a/zone.bak' && echo existed; fi",
+					"mv -f '/a/zone.shimiko-new' '/a/zone'",
+					"cp -p '/a/zone.bak' '/a/zone.shimiko-new' && mv -f '/a/zone.shimiko-new' '/a/zone'",
+				},
+				"b": {
+					"copy /b/zone.shimiko-new",
+					"cat '/b/zone.shimiko-new'",
+					"if [ -e '/b/zone' ]; then cp -p '/b/zone' '/b/zone.bak' && echo existed; fi",
+					"mv -f '/b/zone.shimiko-new' '/b/zone'",
+					"cp -p '/b/zone.bak' '/b/zone.shimiko-new' && mv -f '/b/zone.shimiko-new' '/b/zone'",
+				},
+			},
+			errContains: "error moving staged zone file into place",
+		},
+		"failed commit removes new zone files": {
+			data:     publishZone,
+			previous: map[string]string{"b": previous},
+			fail:     map[string]string{"b": "mv -f"},
+			expected: map[string]string{"a": "", "b": previous},
+			commands: map[string][]string{
+				"a": {
+					"copy /a/zone.shimiko-new",
+					"cat '/a/zone.shimiko-new'",
+					"if [ -e '/a/zone' ]; then cp -p '/a/zone' '/a/zone.bak' && echo existed; fi",
+					"mv -f '/a/zone.shimiko-new' '/a/zone'",
+					"rm -f '/a/zone' '/a/zone.shimiko-new'",
+				},
+			},
+			errContains: "error moving staged zone file into place",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			var mu sync.Mutex
+			commands := map[string]*[]string{}
+			hosts := []persistence.CoreDNSHost{}
+			for _, address := range []string{"a", "b"} {
+				require.NoError(t, os.Mkdir(filepath.Join(dir, address), 0o755))
+				host := persistence.CoreDNSHost{
+					Address:  address,
+					ZonePath: filepath.Join(dir, address, "zone"),
+					Mode:     "0640",
+				}
+				if content, ok := tc.previous[address]; ok {
+					require.NoError(t, os.WriteFile(host.ZonePath, []byte(content), 0o640))
+				}
+				hosts = append(hosts, host)
+				commands[address] = &[]string{}
+			}
+
+			coreDNS := &persistence.CoreDNS{
+				Config: persistence.CoreDNSConfig{Hosts: hosts},
+				Dial: func(host persistence.CoreDNSHost) (persistence.CoreDNSRemote, error) {
+					return &fakeRemote{
+						dir:      dir,
+						corrupt:  tc.corrupt[host.Address],
+						fail:     tc.fail[host.Address],
+						mu:       &mu,
+						commands: commands[host.Address],
+					}, nil
+				},
+			}
+
+			if tc.loaded != "" {
+				require.NoError(t, coreDNS.LoadData(context.Background(), []byte(tc.loaded)))
+			}
+
+			err := coreDNS.SaveCoreDNSZoneFile(context.Background(), []byte(tc.data))
+			if tc.errContains != "" {
+				assert.ErrorContains(t, err, tc.errContains)
+			} else {
+				require.NoError(t, err)
+			}
+
+			for _, host := range hosts {
+				expected, ok := tc.expected[host.Address]
+				require.True(t, ok, "no expected zone file for %s", host.Address)
+				content, err := os.ReadFile(host.ZonePath)
+				if expected == "" {
+					assert.ErrorIs(t, err, fs.ErrNotExist, host.Address)
+				} else {
+					require.NoError(t, err, host.Address)
+					assert.Equal(t, expected, string(content), host.Address)
+				}
+				assert.NoFileExists(t, host.StagingPath(), host.Address)
+				if previous, ok := tc.previous[host.Address]; ok && tc.errContains == "" {
+					backup, err := os.ReadFile(host.BackupPath())
+					require.NoError(t, err, host.Address)
+					assert.Equal(t, previous, string(backup), host.Address)
+				}
+				if expected := tc.commands[host.Address]; expected != nil {
+					assert.Equal(t, expected, *commands[host.Address], host.Address)
+				}
+			}
+		})
+	}
+}
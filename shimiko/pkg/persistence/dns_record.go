@@ -15,10 +15,14 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/telemetry"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/wire"
 )
 
 var HostnameRegex = regexp.MustCompile(`^[a-z0-9_][a-z0-9\.\-]+[a-z0-9]$`)
 
+// SupportedRecordTypes are the types records can be managed for. Backends may
+// not be able to serve all of them, see DNSRecord.ValidateFor.
 var SupportedRecordTypes = []string{
 	"A",
 	"AAAA",
@@ -63,7 +67,20 @@ func (record *DNSRecord) FullHostname() string {
 	return record.Name + "." + DomainName
 }
 
+// Validate checks record and that the backends configured by
+// SHIMIKO_BACKENDS can serve it.
 func (record *DNSRecord) Validate() *DNSRecordValidation {
+	backends, err := ConfiguredBackends()
+	if err != nil {
+		return &DNSRecordValidation{
+			Messages: []string{fmt.Sprintf("The configured backends are invalid: %s", err)},
+		}
+	}
+	return record.ValidateFor(backends)
+}
+
+// ValidateFor checks record and that each of backends can serve it.
+func (record *DNSRecord) ValidateFor(backends []string) *DNSRecordValidation {
 	messages := []string{}
 
 	if strings.HasSuffix(record.Name, DomainName) || strings.HasSuffix(record.Name, DomainName+".") {
@@ -90,6 +107,19 @@ func (record *DNSRecord) Validate() *DNSRecordValidation {
 		messages = append(messages, fmt.Sprintf("Record type '%s' is not supported.", record.Type))
 	}
 
+	if slices.Contains(SupportedRecordTypes, record.Type) {
+		for _, value := range record.Records {
+			// encoding the value checks it against the shape of the type
+			_, err := wire.EncodeRData(record.Type, ast.SplitRData(value), DomainName+".")
+			if err != nil {
+				messages = append(messages, fmt.Sprintf("The %s record value '%s' is invalid: %s", record.Type, value, err))
+			}
+		}
+		for _, err := range ValidateRecord(record, backends) {
+			messages = append(messages, fmt.Sprintf("The record can not be served by %s.", err))
+		}
+	}
+
 	if len(messages) > 0 {
 		return &DNSRecordValidation{
 			Messages: messages,
@@ -183,16 +213,12 @@ func (record *DNSRecord) Upsert(ctx context.Context, ps *PersistenceSession) err
 	}
 
 	if !ps.Shallow {
-		err := ps.CoreDNS.UpsertRecord(ctx, record, existing)
-		if err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			return err
-		}
-
-		err = ps.Route53.UpsertRecord(ctx, record, existing)
-		if err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			return err
+		for _, backend := range ps.Backends {
+			err := backend.UpsertRecord(ctx, record, existing)
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+				return err
+			}
 		}
 	}
 
@@ -232,14 +258,11 @@ func (record *DNSRecord) Delete(ctx context.Context, ps *PersistenceSession) err
 	}
 
 	if !ps.Shallow {
-		err := ps.CoreDNS.DeleteRecord(ctx, record)
-		if err != nil {
-			return err
-		}
-
-		err = ps.Route53.DeleteRecord(ctx, record)
-		if err != nil {
-			return err
+		for _, backend := range ps.Backends {
+			err := backend.DeleteRecord(ctx, record)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -0,0 +1,127 @@
+package persistence_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/persistence"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/rrtype"
+)
+
+func TestSupportedRecordTypes(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range persistence.SupportedRecordTypes {
+		_, err := rrtype.Check(name, rrtype.BackendCoreDNS, rrtype.BackendRoute53)
+		assert.NoError(t, err, name)
+	}
+	assert.NotContains(t, persistence.SupportedRecordTypes, "SOA")
+	assert.NotContains(t, persistence.SupportedRecordTypes, "SPF")
+}
+
+func TestDNSRecordValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		record   persistence.DNSRecord
+		backends []string
+		messages []string
+	}{
+		"valid": {
+			record: persistence.DNSRecord{
+				Name:    "www",
+				Type:    "A",
+				Records: []string{"192.0.2.1"},
+			},
+		},
+		"valid typed": {
+			record: persistence.DNSRecord{
+				Name:    "lab",
+				Type:    "MX",
+				Records: []string{"10 mail"},
+			},
+		},
+		"unsupported type": {
+			record: persistence.DNSRecord{
+				Name:    "www",
+				Type:    "HINFO",
+				Records: []string{`"PC" "Linux"`},
+			},
+			messages: []string{"Record type 'HINFO' is not supported."},
+		},
+		"invalid value": {
+			record: persistence.DNSRecord{
+				Name:    "www",
+				Type:    "A",
+				Records: []string{"2001:db8::1"},
+			},
+			messages: []string{"The A record value '2001:db8::1' is invalid"},
+		},
+		"generic RDATA": {
+			record: persistence.DNSRecord{
+				Name:    "www",
+				Type:    "A",
+				Records: []string{`\# 4 0A000001`},
+			},
+			messages: []string{`The record can not be served by route53: the A record value '\# 4 0A000001' uses the generic RDATA syntax`},
+		},
+		"generic RDATA of a typed record": {
+			record: persistence.DNSRecord{
+				Name:    "www",
+				Type:    "TXT",
+				Records: []string{`"ok"`, `\# 3 026869`},
+			},
+			messages: []string{`The record can not be served by route53: the TXT record value '\# 3 026869' uses the generic RDATA syntax`},
+		},
+		"generic RDATA without Route53": {
+			record: persistence.DNSRecord{
+				Name:    "www",
+				Type:    "A",
+				Records: []string{`\# 4 0A000001`},
+			},
+			backends: []string{"coredns"},
+		},
+		"rejected by a backend": {
+			record: persistence.DNSRecord{
+				Name:    "www",
+				Type:    "TXT",
+				Records: []string{`"ok"`},
+			},
+			backends: []string{"coredns", "fake"},
+			messages: []string{"The record can not be served by fake: fake backend failure: no TXT records."},
+		},
+		"unknown backend": {
+			record: persistence.DNSRecord{
+				Name:    "www",
+				Type:    "A",
+				Records: []string{"192.0.2.1"},
+			},
+			backends: []string{"bind"},
+			messages: []string{"The record can not be served by unknown backend 'bind'."},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			backends := tc.backends
+			if backends == nil {
+				backends = persistence.DefaultBackends
+			}
+			validation := tc.record.ValidateFor(backends)
+			if len(tc.messages) == 0 {
+				assert.Nil(t, validation)
+				return
+			}
+			require.NotNil(t, validation)
+			require.Len(t, validation.Messages, len(tc.messages))
+			for i, message := range tc.messages {
+				assert.Contains(t, validation.Messages[i], message)
+			}
+		})
+	}
+}
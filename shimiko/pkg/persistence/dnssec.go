@@ -0,0 +1,135 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/env"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/telemetry"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/dnssec"
+)
+
+const (
+	// DNSSECSignatureValidity is how long the signatures made by CoreDNS.Save
+	// are valid for.
+	DNSSECSignatureValidity = 14 * 24 * time.Hour
+	// DNSSECResignWindow is how long before they expire the signatures are
+	// renewed even if the zone didn't change.
+	DNSSECResignWindow = 7 * 24 * time.Hour
+	// dnssecInceptionSkew backdates the inception of signatures to allow for
+	// clock skew on validating resolvers.
+	dnssecInceptionSkew = time.Hour
+)
+
+// DNSSECKey is a DNSSEC signing key of a zone. The private key is stored PEM
+// encoded and never leaves the database through the API. A zone has at most
+// one key per role, i.e. one KSK and one ZSK, which the unique index on Zone
+// and Flags enforces.
+type DNSSECKey struct {
+	ID         uint      `json:"_id,omitempty" gorm:"primaryKey"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Zone       string    `json:"zone" gorm:"uniqueIndex:idx_dnssec_keys_zone_flags"`
+	Flags      uint16    `json:"flags" gorm:"uniqueIndex:idx_dnssec_keys_zone_flags"`
+	Algorithm  uint8     `json:"algorithm"`
+	PrivateKey string    `json:"-"`
+}
+
+// DNSSECEnabled reports whether the CoreDNS zone should be signed, which is
+// controlled by SHIMIKO_DNSSEC.
+func DNSSECEnabled() bool {
+	return env.MustGetDefault("SHIMIKO_DNSSEC", false)
+}
+
+// Key parses the stored private key.
+func (key *DNSSECKey) Key() (*dnssec.Key, error) {
+	if key.Algorithm != dnssec.AlgorithmECDSAP256SHA256 {
+		return nil, fmt.Errorf("%w: unsupported algorithm %d", dnssec.ErrInvalidKey, key.Algorithm)
+	}
+	return dnssec.ParseKey(key.Flags, []byte(key.PrivateKey))
+}
+
+// LoadDNSSECKeys loads the signing keys of zone. If there are none and create
+// is set a KSK and a ZSK are generated and stored. When several callers
+// generate keys at the same time only the keys of the first one are stored
+// and every caller gets those.
+func LoadDNSSECKeys(ctx context.Context, db *gorm.DB, zone string, create bool) ([]*dnssec.Key, error) {
+	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.LoadDNSSECKeys", trace.WithAttributes(
+		attribute.String("zone", zone),
+		attribute.Bool("create", create),
+	))
+	defer span.End()
+
+	logger := telemetry.LoggerFromContext(ctx)
+
+	var stored []*DNSSECKey
+	result := db.WithContext(ctx).Where("zone = ?", zone).Order("id").Find(&stored)
+	if result.Error != nil {
+		err := fmt.Errorf("error querying DNSSEC keys: %w", result.Error)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if len(stored) == 0 && create {
+		logger.InfoContext(ctx, "generating DNSSEC keys", "zone", zone)
+		for _, flags := range []uint16{dnssec.FlagsKSK, dnssec.FlagsZSK} {
+			key, err := dnssec.GenerateKey(flags)
+			if err != nil {
+				err = fmt.Errorf("error generating DNSSEC key: %w", err)
+				span.SetStatus(codes.Error, err.Error())
+				return nil, err
+			}
+			data, err := key.MarshalPrivateKey()
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+				return nil, err
+			}
+			stored = append(stored, &DNSSECKey{
+				Zone:       zone,
+				Flags:      flags,
+				Algorithm:  dnssec.AlgorithmECDSAP256SHA256,
+				PrivateKey: string(data),
+			})
+		}
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&stored)
+			if result.Error != nil {
+				return fmt.Errorf("error storing DNSSEC keys: %w", result.Error)
+			}
+			// another caller may have stored its keys first, in which case
+			// nothing was inserted
+			stored = nil
+			result = tx.Where("zone = ?", zone).Order("id").Find(&stored)
+			if result.Error != nil {
+				return fmt.Errorf("error querying DNSSEC keys: %w", result.Error)
+			}
+			return nil
+		})
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+	}
+
+	keys := make([]*dnssec.Key, 0, len(stored))
+	for _, storedKey := range stored {
+		key, err := storedKey.Key()
+		if err != nil {
+			err = fmt.Errorf("error loading DNSSEC key %d: %w", storedKey.ID, err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	span.SetAttributes(attribute.Int("keys", len(keys)))
+	span.SetStatus(codes.Ok, "")
+	return keys, nil
+}
@@ -0,0 +1,97 @@
+package persistence_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/persistence"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/dnssec"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "shimiko.sqlite3")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&persistence.DNSRecord{}, &persistence.DNSSECKey{}))
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestLoadDNSSECKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := openDB(t)
+
+	keys, err := persistence.LoadDNSSECKeys(ctx, db, "example.com.", false)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	keys, err = persistence.LoadDNSSECKeys(ctx, db, "example.com.", true)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.True(t, keys[0].IsKSK())
+	assert.False(t, keys[1].IsKSK())
+
+	again, err := persistence.LoadDNSSECKeys(ctx, db, "example.com.", true)
+	require.NoError(t, err)
+	assert.Equal(t, keyTags(t, keys), keyTags(t, again))
+
+	other, err := persistence.LoadDNSSECKeys(ctx, db, "example.net.", true)
+	require.NoError(t, err)
+	assert.Len(t, other, 2)
+}
+
+func TestLoadDNSSECKeysConcurrent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := openDB(t)
+
+	const callers = 8
+	results := make([][]*dnssec.Key, callers)
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = persistence.LoadDNSSECKeys(ctx, db, "example.com.", true)
+		}()
+	}
+	wg.Wait()
+
+	for i := range callers {
+		require.NoError(t, errs[i])
+		require.Len(t, results[i], 2)
+		assert.Equal(t, keyTags(t, results[0]), keyTags(t, results[i]))
+	}
+
+	var count int64
+	require.NoError(t, db.Model(&persistence.DNSSECKey{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
+
+func keyTags(t *testing.T, keys []*dnssec.Key) []uint16 {
+	t.Helper()
+	tags := []uint16{}
+	for _, key := range keys {
+		tags = append(tags, key.KeyTag())
+	}
+	return tags
+}
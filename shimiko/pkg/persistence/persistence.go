@@ -48,7 +48,7 @@ func OpenDB(ctx context.Context) (*gorm.DB, error) {
 	}
 
 	logger.InfoContext(ctx, "migrating database")
-	err = db.AutoMigrate(&DNSRecord{})
+	err = db.AutoMigrate(&DNSRecord{}, &DNSSECKey{})
 	if err != nil {
 		logger.ErrorContext(ctx, "error running migrations", "error", err)
 		err = fmt.Errorf("error running migrations: %w", err)
@@ -3,6 +3,7 @@ package persistence
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
@@ -12,12 +13,15 @@ import (
 )
 
 type PersistenceSession struct {
-	DB      *gorm.DB
-	CoreDNS *CoreDNS
-	Route53 *Route53
-	Shallow bool
+	DB *gorm.DB
+	// Backends are the DNS targets records are published to, in the order
+	// they are written to.
+	Backends []Backend
+	Shallow  bool
 }
 
+// NewSession starts a session with the backends configured by
+// SHIMIKO_BACKENDS loaded.
 func NewSession(ctx context.Context, db *gorm.DB) (*PersistenceSession, error) {
 	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.NewSession", trace.WithAttributes())
 	defer span.End()
@@ -26,19 +30,16 @@ func NewSession(ctx context.Context, db *gorm.DB) (*PersistenceSession, error) {
 		DB: db,
 	}
 
-	ps.CoreDNS = &CoreDNS{}
-	err := ps.CoreDNS.Load(ctx)
+	names, err := ConfiguredBackends()
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 		return ps, err
 	}
-
-	ps.Route53, err = NewRoute53(ctx)
+	ps.Backends, err = NewBackends(ctx, db, names)
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 		return ps, err
 	}
-	ps.Route53.StartChangeBatch()
 
 	span.SetStatus(codes.Ok, "")
 	return ps, nil
@@ -49,10 +50,15 @@ func FinishSession(ctx context.Context, session *PersistenceSession) error {
 	defer span.End()
 
 	if !session.Shallow {
-		coreDNSErr := session.CoreDNS.Save(ctx)
-		_, r53err := session.Route53.FlushChangeBatch(ctx)
+		errs := []error{}
+		for _, backend := range session.Backends {
+			err := backend.Flush(ctx)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error flushing %s: %w", backend.Name(), err))
+			}
+		}
 
-		err := errors.Join(coreDNSErr, r53err)
+		err := errors.Join(errs...)
 
 		if err != nil {
 			span.SetStatus(codes.Error, err.Error())
@@ -67,3 +73,14 @@ func FinishSession(ctx context.Context, session *PersistenceSession) error {
 func (ps *PersistenceSession) Finish(ctx context.Context) error {
 	return FinishSession(ctx, ps)
 }
+
+// Backend returns the backend of the session registered under name, or nil
+// if the session doesn't use it.
+func (ps *PersistenceSession) Backend(name string) Backend {
+	for _, backend := range ps.Backends {
+		if backend.Name() == name {
+			return backend
+		}
+	}
+	return nil
+}
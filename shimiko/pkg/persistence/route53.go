@@ -3,6 +3,7 @@ package persistence
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -15,6 +16,8 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/telemetry"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/rrtype"
 )
 
 const Route53HostedZoneId = "Z00048261CEI1B6JY63KT"
@@ -42,6 +45,80 @@ func NewRoute53(ctx context.Context) (*Route53, error) {
 	return r53, nil
 }
 
+func (r53 *Route53) Name() string {
+	return string(rrtype.BackendRoute53)
+}
+
+// Load starts a change batch, which collects the changes until Flush.
+func (r53 *Route53) Load(ctx context.Context) error {
+	r53.StartChangeBatch()
+	return nil
+}
+
+// Flush applies the changes of the change batch, if there is one.
+func (r53 *Route53) Flush(ctx context.Context) error {
+	if r53.ChangeBatch == nil {
+		return nil
+	}
+	_, err := r53.FlushChangeBatch(ctx)
+	return err
+}
+
+// ListRecords returns the record sets of the hosted zone as records, except
+// for the SOA record, alias records and those outside of the zone.
+func (r53 *Route53) ListRecords(ctx context.Context) ([]*DNSRecord, error) {
+	ctx, span := telemetry.Tracer.Start(ctx, "shimiko/pkg/persistence.Route53.ListRecords", trace.WithAttributes())
+	defer span.End()
+
+	records := []*DNSRecord{}
+	paginator := route53.NewListResourceRecordSetsPaginator(r53.Client, &route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(Route53HostedZoneId),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			err = fmt.Errorf("error listing Route53 record sets: %w", err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		for _, rr := range page.ResourceRecordSets {
+			name, ok := relativeRecordName(aws.ToString(rr.Name))
+			if !ok || rr.Type == types.RRTypeSoa || rr.AliasTarget != nil {
+				continue
+			}
+			record := &DNSRecord{
+				Name: name,
+				Type: string(rr.Type),
+				TTL:  int(aws.ToInt64(rr.TTL)),
+			}
+			for _, res := range rr.ResourceRecords {
+				record.Records = append(record.Records, aws.ToString(res.Value))
+			}
+			records = append(records, record)
+		}
+	}
+
+	span.SetAttributes(attribute.Int("records", len(records)))
+	span.SetStatus(codes.Ok, "")
+	return records, nil
+}
+
+// validateRoute53Record checks that Route53 can serve record. Route53 only
+// takes RDATA in the presentation form of its type, not in the generic form
+// of RFC 3597.
+func validateRoute53Record(record *DNSRecord) error {
+	_, err := rrtype.Check(record.Type, rrtype.BackendRoute53)
+	if err != nil {
+		return err
+	}
+	for _, value := range record.Records {
+		if ast.IsGenericRData(ast.SplitRData(value)) {
+			return fmt.Errorf("the %s record value '%s' uses the generic RDATA syntax, which Route53 does not support", record.Type, value)
+		}
+	}
+	return nil
+}
+
 func (r53 *Route53) StartChangeBatch() {
 	if r53.ChangeBatch == nil {
 		r53.ChangeBatch = &types.ChangeBatch{
@@ -98,6 +175,12 @@ func (r53 *Route53) UpsertRecord(ctx context.Context, record *DNSRecord, previou
 		return err
 	}
 
+	rrType, err := rrtype.Check(record.Type, rrtype.BackendRoute53)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
 	adhocChangeBatch := r53.ChangeBatch == nil
 	if adhocChangeBatch {
 		r53.StartChangeBatch()
@@ -127,7 +210,7 @@ func (r53 *Route53) UpsertRecord(ctx context.Context, record *DNSRecord, previou
 		Action: "UPSERT",
 		ResourceRecordSet: &types.ResourceRecordSet{
 			Name:            aws.String(record.FullHostname()),
-			Type:            types.RRType(record.Type),
+			Type:            types.RRType(rrType.Name),
 			TTL:             aws.Int64(int64(ttl)),
 			ResourceRecords: resourceRecords,
 		},
@@ -0,0 +1,178 @@
+// Package publish replaces a file on several hosts in two phases, so that
+// either every host ends up with the new version or every host keeps the
+// previous one.
+package publish
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Host is a host the file is published to. Stage and Validate must not
+// change what the host serves; Commit makes the staged file live and keeps
+// the previous version so that Rollback can restore it.
+type Host interface {
+	// Name identifies the host in results.
+	Name() string
+	// Stage uploads data to a temporary file on the host.
+	Stage(ctx context.Context, data []byte) error
+	// Validate checks that the staged file holds data.
+	Validate(ctx context.Context, data []byte) error
+	// Commit backs up the current file and renames the staged file into
+	// its place.
+	Commit(ctx context.Context) error
+	// Rollback restores the file backed up by Commit. It is called for hosts
+	// whose Commit was called, whether it succeeded or not.
+	Rollback(ctx context.Context) error
+	// Abort removes the staged file. It is called for hosts whose Stage was
+	// called but that aren't committed, whether Stage succeeded or not.
+	Abort(ctx context.Context) error
+}
+
+// Status is the outcome of publishing to a host.
+type Status string
+
+const (
+	// StatusPublished is a host that serves the new version.
+	StatusPublished Status = "published"
+	// StatusFailed is a host that a phase failed on. Its Result has the
+	// error.
+	StatusFailed Status = "failed"
+	// StatusAborted is a host the new version was staged on, but that
+	// wasn't committed because another host failed.
+	StatusAborted Status = "aborted"
+	// StatusRolledBack is a host that was committed and then restored to the
+	// previous version because another host failed.
+	StatusRolledBack Status = "rolled back"
+	// StatusSkipped is a host that wasn't touched because another host had
+	// failed before.
+	StatusSkipped Status = "skipped"
+)
+
+// Phase is a step of publishing to a host.
+type Phase string
+
+const (
+	PhaseStage    Phase = "stage"
+	PhaseValidate Phase = "validate"
+	PhaseCommit   Phase = "commit"
+)
+
+// Result is the outcome of publishing to a single host.
+type Result struct {
+	Host   string
+	Status Status
+	// Phase is the phase that failed if Status is StatusFailed.
+	Phase Phase
+	// Err is the error of the phase that failed.
+	Err error
+	// CleanupErr is the error of undoing the changes to the host with Abort
+	// or Rollback. If it is set after a Rollback the host may still serve
+	// the new version.
+	CleanupErr error
+}
+
+func (result Result) String() string {
+	var b strings.Builder
+	b.WriteString(result.Host)
+	b.WriteString(": ")
+	b.WriteString(string(result.Status))
+	if result.Err != nil {
+		fmt.Fprintf(&b, " in %s: %s", result.Phase, result.Err)
+	}
+	if result.CleanupErr != nil {
+		fmt.Fprintf(&b, " (cleanup failed: %s)", result.CleanupErr)
+	}
+	return b.String()
+}
+
+// Error is returned by Publish if the file couldn't be published to every
+// host.
+type Error struct {
+	Results []Result
+}
+
+func (err *Error) Error() string {
+	lines := make([]string, 0, len(err.Results))
+	for _, result := range err.Results {
+		lines = append(lines, result.String())
+	}
+	return "publish failed, results per host: " + strings.Join(lines, "; ")
+}
+
+// Unwrap returns the errors of all hosts.
+func (err *Error) Unwrap() []error {
+	errs := []error{}
+	for _, result := range err.Results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+		if result.CleanupErr != nil {
+			errs = append(errs, result.CleanupErr)
+		}
+	}
+	return errs
+}
+
+// Publish stages and validates data on every host and, only if that
+// succeeded everywhere, commits it on every host. If committing fails on a
+// host, the hosts committed before it are rolled back. The results are in
+// the order of hosts; the error is an *Error if publishing failed.
+func Publish(ctx context.Context, hosts []Host, data []byte) ([]Result, error) {
+	results := make([]Result, len(hosts))
+	for i, host := range hosts {
+		results[i] = Result{
+			Host:   host.Name(),
+			Status: StatusSkipped,
+		}
+	}
+
+	// hosts[:staged] had Stage called
+	staged := 0
+	failed := false
+	for i, host := range hosts {
+		staged = i + 1
+		err := host.Stage(ctx, data)
+		if err != nil {
+			results[i].Status, results[i].Phase, results[i].Err = StatusFailed, PhaseStage, err
+			failed = true
+			break
+		}
+		err = host.Validate(ctx, data)
+		if err != nil {
+			results[i].Status, results[i].Phase, results[i].Err = StatusFailed, PhaseValidate, err
+			failed = true
+			break
+		}
+		results[i].Status = StatusAborted
+	}
+	if failed {
+		for i, host := range hosts[:staged] {
+			results[i].CleanupErr = host.Abort(ctx)
+		}
+		return results, &Error{Results: results}
+	}
+
+	for i, host := range hosts {
+		err := host.Commit(ctx)
+		if err == nil {
+			results[i].Status = StatusPublished
+			continue
+		}
+
+		results[i].Status, results[i].Phase, results[i].Err = StatusFailed, PhaseCommit, err
+		for j := range hosts[:i+1] {
+			if j < i {
+				results[j].Status = StatusRolledBack
+			}
+			results[j].CleanupErr = hosts[j].Rollback(ctx)
+		}
+		for j, host := range hosts[i+1:] {
+			results[i+1+j].CleanupErr = host.Abort(ctx)
+		}
+		return results, &Error{Results: results}
+	}
+
+	return results, nil
+}
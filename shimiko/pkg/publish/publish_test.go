@@ -0,0 +1,212 @@
+package publish_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/publish"
+)
+
+var errInjected = errors.New("injected")
+
+// fakeHost serves live and records the calls made to it. Phases in fail
+// return errInjected.
+type fakeHost struct {
+	name   string
+	fail   map[string]bool
+	live   string
+	staged string
+	backup string
+	calls  []string
+}
+
+func (host *fakeHost) call(name string) error {
+	host.calls = append(host.calls, name)
+	if host.fail[name] {
+		return errInjected
+	}
+	return nil
+}
+
+func (host *fakeHost) Name() string {
+	return host.name
+}
+
+func (host *fakeHost) Stage(ctx context.Context, data []byte) error {
+	err := host.call("stage")
+	if err == nil {
+		host.staged = string(data)
+	}
+	return err
+}
+
+func (host *fakeHost) Validate(ctx context.Context, data []byte) error {
+	err := host.call("validate")
+	if err == nil && host.staged != string(data) {
+		err = errors.New("staged file differs")
+	}
+	return err
+}
+
+func (host *fakeHost) Commit(ctx context.Context) error {
+	err := host.call("commit")
+	if err == nil {
+		host.backup, host.live, host.staged = host.live, host.staged, ""
+	}
+	return err
+}
+
+func (host *fakeHost) Rollback(ctx context.Context) error {
+	err := host.call("rollback")
+	if err == nil && host.backup != "" {
+		host.live = host.backup
+	}
+	return err
+}
+
+func (host *fakeHost) Abort(ctx context.Context) error {
+	err := host.call("abort")
+	if err == nil {
+		host.staged = ""
+	}
+	return err
+}
+
+func TestPublish(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fail     map[string][]string
+		statuses []publish.Status
+		calls    [][]string
+		live     []string
+		cleanup  []bool
+	}{
+		"success": {
+			statuses: []publish.Status{publish.StatusPublished, publish.StatusPublished},
+			calls: [][]string{
+				{"stage", "validate", "commit"},
+				{"stage", "validate", "commit"},
+			},
+			live: []string{"new", "new"},
+		},
+		"stage fails": {
+			fail: map[string][]string{
+				"ram": {"stage"},
+			},
+			statuses: []publish.Status{publish.StatusAborted, publish.StatusFailed},
+			calls: [][]string{
+				{"stage", "validate", "abort"},
+				{"stage", "abort"},
+			},
+			live: []string{"old", "old"},
+		},
+		"validate fails on the first host": {
+			fail: map[string][]string{
+				"rem": {"validate"},
+			},
+			statuses: []publish.Status{publish.StatusFailed, publish.StatusSkipped},
+			calls: [][]string{
+				{"stage", "validate", "abort"},
+				nil,
+			},
+			live: []string{"old", "old"},
+		},
+		"commit fails": {
+			fail: map[string][]string{
+				"ram": {"commit"},
+			},
+			statuses: []publish.Status{publish.StatusRolledBack, publish.StatusFailed},
+			calls: [][]string{
+				{"stage", "validate", "commit", "rollback"},
+				{"stage", "validate", "commit", "rollback"},
+			},
+			live: []string{"old", "old"},
+		},
+		"rollback fails": {
+			fail: map[string][]string{
+				"ram": {"commit"},
+				"rem": {"rollback"},
+			},
+			statuses: []publish.Status{publish.StatusRolledBack, publish.StatusFailed},
+			calls: [][]string{
+				{"stage", "validate", "commit", "rollback"},
+				{"stage", "validate", "commit", "rollback"},
+			},
+			live:    []string{"new", "old"},
+			cleanup: []bool{true, false},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			hosts := []*fakeHost{}
+			targets := []publish.Host{}
+			for _, name := range []string{"rem", "ram"} {
+				host := &fakeHost{
+					name: name,
+					fail: map[string]bool{},
+					live: "old",
+				}
+				for _, phase := range tc.fail[name] {
+					host.fail[phase] = true
+				}
+				hosts = append(hosts, host)
+				targets = append(targets, host)
+			}
+
+			results, err := publish.Publish(context.Background(), targets, []byte("new"))
+			require.Len(t, results, len(hosts))
+			if len(tc.fail) == 0 {
+				require.NoError(t, err)
+			} else {
+				var publishErr *publish.Error
+				require.ErrorAs(t, err, &publishErr)
+				assert.Equal(t, results, publishErr.Results)
+				assert.ErrorIs(t, err, errInjected)
+			}
+			for i, host := range hosts {
+				assert.Equal(t, host.name, results[i].Host)
+				assert.Equal(t, tc.statuses[i], results[i].Status, host.name)
+				assert.Equal(t, tc.calls[i], host.calls, host.name)
+				assert.Equal(t, tc.live[i], host.live, host.name)
+				assert.Equal(t, tc.statuses[i] == publish.StatusFailed, results[i].Err != nil, host.name)
+				cleanupFailed := tc.cleanup != nil && tc.cleanup[i]
+				assert.Equal(t, cleanupFailed, results[i].CleanupErr != nil, host.name)
+			}
+		})
+	}
+}
+
+func TestErrorReportsEveryHost(t *testing.T) {
+	t.Parallel()
+
+	err := &publish.Error{
+		Results: []publish.Result{
+			{
+				Host:       "rem",
+				Status:     publish.StatusRolledBack,
+				CleanupErr: errors.New("permission denied"),
+			},
+			{
+				Host:   "ram",
+				Status: publish.StatusFailed,
+				Phase:  publish.PhaseCommit,
+				Err:    errors.New("no space left on device"),
+			},
+		},
+	}
+	lines := strings.Split(strings.TrimPrefix(err.Error(), "publish failed, results per host: "), "; ")
+	assert.Equal(t, []string{
+		"rem: rolled back (cleanup failed: permission denied)",
+		"ram: failed in commit: no space left on device",
+	}, lines)
+}
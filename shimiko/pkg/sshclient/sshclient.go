@@ -0,0 +1,369 @@
+// Package sshclient dials SSH servers with pinned host keys, taken from
+// known_hosts files or SSHFP-style fingerprints, and with password, private
+// key or ssh-agent authentication.
+package sshclient
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+)
+
+var (
+	// ErrHostKeyMismatch is returned when a server presents a host key other
+	// than the ones pinned for it, which may mean someone is impersonating
+	// it.
+	ErrHostKeyMismatch = errors.New("host key mismatch")
+	// ErrUnknownHost is returned when there is no host key pinned for a
+	// server in the known_hosts files.
+	ErrUnknownHost = errors.New("unknown host")
+	// ErrHostKeyRevoked is returned when a server presents a host key marked
+	// as revoked in a known_hosts file.
+	ErrHostKeyRevoked = errors.New("host key revoked")
+	// ErrInvalidFingerprint is returned for a fingerprint that can't be
+	// parsed.
+	ErrInvalidFingerprint = errors.New("invalid fingerprint")
+	// ErrInvalidConfig is returned for a Config that pins no host keys or has
+	// no usable authentication method.
+	ErrInvalidConfig = errors.New("invalid SSH configuration")
+)
+
+// Fingerprint types, as in SSHFP records (RFC 4255, RFC 6594).
+const (
+	FingerprintSHA1   uint8 = 1
+	FingerprintSHA256 uint8 = 2
+)
+
+// sshfpAlgorithms maps the key algorithms of SSHFP records to the types of
+// the public keys they cover.
+var sshfpAlgorithms = map[uint8][]string{
+	1: {ssh.KeyAlgoRSA},
+	2: {ssh.KeyAlgoDSA},
+	3: {ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521},
+	4: {ssh.KeyAlgoED25519},
+}
+
+// Fingerprint pins a host key by its digest.
+type Fingerprint struct {
+	// Algorithm is the SSHFP key algorithm, or zero if the fingerprint
+	// applies to a key of any type.
+	Algorithm uint8
+	// Type is the digest type, FingerprintSHA1 or FingerprintSHA256.
+	Type   uint8
+	Digest []byte
+	// text is the fingerprint as it was parsed, for error messages.
+	text string
+}
+
+// ParseFingerprint parses a fingerprint either as OpenSSH prints it, i.e.
+// "SHA256:" followed by the unpadded base64 digest, or as the RDATA of an
+// SSHFP record, e.g. "4 2 1E5E...".
+func ParseFingerprint(text string) (Fingerprint, error) {
+	text = strings.TrimSpace(text)
+	if digest, ok := strings.CutPrefix(text, "SHA256:"); ok {
+		decoded, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(digest, "="))
+		if err != nil || len(decoded) != sha256.Size {
+			return Fingerprint{}, fmt.Errorf("%w: '%s' isn't a SHA256 digest", ErrInvalidFingerprint, text)
+		}
+		return Fingerprint{
+			Type:   FingerprintSHA256,
+			Digest: decoded,
+			text:   text,
+		}, nil
+	}
+
+	sshfp, err := ast.ParseSSHFP(ast.SplitRData(text))
+	if err != nil {
+		return Fingerprint{}, fmt.Errorf("%w: %w", ErrInvalidFingerprint, err)
+	}
+	if _, ok := sshfpAlgorithms[sshfp.Algorithm]; !ok {
+		return Fingerprint{}, fmt.Errorf("%w: unsupported key algorithm %d in '%s'", ErrInvalidFingerprint, sshfp.Algorithm, text)
+	}
+	size := map[uint8]int{
+		FingerprintSHA1:   sha1.Size,
+		FingerprintSHA256: sha256.Size,
+	}[sshfp.Type]
+	if size == 0 {
+		return Fingerprint{}, fmt.Errorf("%w: unsupported fingerprint type %d in '%s'", ErrInvalidFingerprint, sshfp.Type, text)
+	}
+	if len(sshfp.Fingerprint) != size {
+		return Fingerprint{}, fmt.Errorf("%w: digest in '%s' is %d octets long, expected %d", ErrInvalidFingerprint, text, len(sshfp.Fingerprint), size)
+	}
+	return Fingerprint{
+		Algorithm: sshfp.Algorithm,
+		Type:      sshfp.Type,
+		Digest:    sshfp.Fingerprint,
+		text:      text,
+	}, nil
+}
+
+// String returns the fingerprint as it was parsed.
+func (fp Fingerprint) String() string {
+	return fp.text
+}
+
+// Match reports whether key is the key the fingerprint was taken of.
+func (fp Fingerprint) Match(key ssh.PublicKey) bool {
+	if fp.Algorithm != 0 && !slices.Contains(sshfpAlgorithms[fp.Algorithm], key.Type()) {
+		return false
+	}
+	var digest []byte
+	switch fp.Type {
+	case FingerprintSHA1:
+		sum := sha1.Sum(key.Marshal())
+		digest = sum[:]
+	case FingerprintSHA256:
+		sum := sha256.Sum256(key.Marshal())
+		digest = sum[:]
+	default:
+		return false
+	}
+	return bytes.Equal(digest, fp.Digest)
+}
+
+// Config is how to verify and authenticate with an SSH server.
+type Config struct {
+	User string
+
+	// KnownHostsFiles are OpenSSH known_hosts files the host key of the
+	// server is looked up in.
+	KnownHostsFiles []string
+	// Fingerprints pin host keys of the server, in any of the forms of
+	// ParseFingerprint. A key is accepted if it matches any fingerprint or
+	// is in the known_hosts files.
+	Fingerprints []string
+	// InsecureIgnoreHostKey accepts any host key. It has to be set
+	// explicitly if neither KnownHostsFiles nor Fingerprints are.
+	InsecureIgnoreHostKey bool
+
+	// Password enables password authentication if it is set.
+	Password string
+	// PrivateKey is a private key in PEM or OpenSSH form and enables public
+	// key authentication if it is set. PrivateKeyPassphrase decrypts it if
+	// it is encrypted.
+	PrivateKey           []byte
+	PrivateKeyPassphrase string
+	// AgentSocket is the path of the socket of an ssh-agent, e.g. the value
+	// of SSH_AUTH_SOCK, and enables authentication with its keys if it is
+	// set.
+	AgentSocket string
+
+	// Timeout limits how long establishing the connection may take. Zero
+	// means no limit.
+	Timeout time.Duration
+}
+
+// pinnedKeys parses the fingerprints of config and reads its known_hosts
+// files. The callback is nil if there are no known_hosts files.
+func (config Config) pinnedKeys() ([]Fingerprint, ssh.HostKeyCallback, error) {
+	fingerprints := make([]Fingerprint, 0, len(config.Fingerprints))
+	for _, text := range config.Fingerprints {
+		fp, err := ParseFingerprint(text)
+		if err != nil {
+			return nil, nil, err
+		}
+		fingerprints = append(fingerprints, fp)
+	}
+	var knownHosts ssh.HostKeyCallback
+	if len(config.KnownHostsFiles) > 0 {
+		var err error
+		knownHosts, err = knownhosts.New(config.KnownHostsFiles...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading known_hosts files: %w", err)
+		}
+	}
+	return fingerprints, knownHosts, nil
+}
+
+// HostKeyCallback returns the callback verifying host keys against the known
+// hosts files and fingerprints of config.
+func (config Config) HostKeyCallback() (ssh.HostKeyCallback, error) {
+	if config.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	if len(config.KnownHostsFiles) == 0 && len(config.Fingerprints) == 0 {
+		return nil, fmt.Errorf("%w: no known_hosts files or fingerprints to verify host keys against", ErrInvalidConfig)
+	}
+
+	fingerprints, knownHosts, err := config.pinnedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		for _, fp := range fingerprints {
+			if fp.Match(key) {
+				return nil
+			}
+		}
+
+		expected := []string{}
+		for _, fp := range fingerprints {
+			expected = append(expected, fp.String())
+		}
+		if knownHosts != nil {
+			err := knownHosts(hostname, remote, key)
+			if err == nil {
+				return nil
+			}
+			var revokedErr *knownhosts.RevokedError
+			if errors.As(err, &revokedErr) {
+				return fmt.Errorf("%w: %s presented %s %s, which is revoked in %s:%d", ErrHostKeyRevoked, hostname, key.Type(), ssh.FingerprintSHA256(key), revokedErr.Revoked.Filename, revokedErr.Revoked.Line)
+			}
+			var keyErr *knownhosts.KeyError
+			if !errors.As(err, &keyErr) {
+				return err
+			}
+			for _, want := range keyErr.Want {
+				expected = append(expected, fmt.Sprintf("%s %s (%s:%d)", want.Key.Type(), ssh.FingerprintSHA256(want.Key), want.Filename, want.Line))
+			}
+		}
+
+		if len(expected) == 0 {
+			return fmt.Errorf("%w: %s presented %s %s, but has no keys in %s", ErrUnknownHost, hostname, key.Type(), ssh.FingerprintSHA256(key), strings.Join(config.KnownHostsFiles, ", "))
+		}
+		return fmt.Errorf("%w: %s presented %s %s, expected any of %s", ErrHostKeyMismatch, hostname, key.Type(), ssh.FingerprintSHA256(key), strings.Join(expected, ", "))
+	}, nil
+}
+
+// HostKeyAlgorithms returns the host key algorithms to negotiate with the
+// server at addr, which are those of the keys pinned for it. A server with
+// several host keys then presents one that can be verified instead of the
+// one it prefers. It returns nil, i.e. the defaults of ssh.ClientConfig, if
+// host keys aren't verified, a fingerprint applies to keys of any type or no
+// keys are pinned for addr.
+func (config Config) HostKeyAlgorithms(addr string) ([]string, error) {
+	if config.InsecureIgnoreHostKey {
+		return nil, nil
+	}
+	fingerprints, knownHosts, err := config.pinnedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	keyTypes := []string{}
+	for _, fp := range fingerprints {
+		if fp.Algorithm == 0 {
+			return nil, nil
+		}
+		keyTypes = append(keyTypes, sshfpAlgorithms[fp.Algorithm]...)
+	}
+	if knownHosts != nil {
+		// known_hosts files can only be searched by checking a key, so check
+		// one that can't be in them and take the keys they expected instead
+		probe, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+		if err != nil {
+			return nil, err
+		}
+		var keyErr *knownhosts.KeyError
+		if errors.As(knownHosts(addr, &net.TCPAddr{}, probe), &keyErr) {
+			for _, want := range keyErr.Want {
+				keyTypes = append(keyTypes, want.Key.Type())
+			}
+		}
+	}
+
+	algorithms := []string{}
+	for _, keyType := range keyTypes {
+		keyAlgorithms := []string{keyType}
+		if keyType == ssh.KeyAlgoRSA {
+			// RSA keys are used with SHA-2 signatures (RFC 8332)
+			keyAlgorithms = []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256}
+		}
+		for _, algorithm := range keyAlgorithms {
+			if !slices.Contains(algorithms, algorithm) {
+				algorithms = append(algorithms, algorithm)
+			}
+		}
+	}
+	if len(algorithms) == 0 {
+		return nil, nil
+	}
+	return algorithms, nil
+}
+
+// ClientConfig returns the configuration to connect to the server at addr. The returned
+// function closes the connection to the ssh-agent, if one is used, and has
+// to be called once the handshake is done.
+func (config Config) ClientConfig(addr string) (*ssh.ClientConfig, func(), error) {
+	closeAgent := func() {}
+
+	hostKeyCallback, err := config.HostKeyCallback()
+	if err != nil {
+		return nil, closeAgent, err
+	}
+	hostKeyAlgorithms, err := config.HostKeyAlgorithms(addr)
+	if err != nil {
+		return nil, closeAgent, err
+	}
+
+	auth := []ssh.AuthMethod{}
+	if len(config.PrivateKey) > 0 {
+		var signer ssh.Signer
+		if config.PrivateKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(config.PrivateKey, []byte(config.PrivateKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(config.PrivateKey)
+		}
+		var missingErr *ssh.PassphraseMissingError
+		if errors.As(err, &missingErr) {
+			return nil, closeAgent, fmt.Errorf("%w: private key is encrypted, but no passphrase is set", ErrInvalidConfig)
+		}
+		if err != nil {
+			return nil, closeAgent, fmt.Errorf("error parsing private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if config.AgentSocket != "" {
+		conn, err := net.Dial("unix", config.AgentSocket)
+		if err != nil {
+			return nil, closeAgent, fmt.Errorf("error connecting to ssh-agent at '%s': %w", config.AgentSocket, err)
+		}
+		closeAgent = func() {
+			conn.Close()
+		}
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	if config.Password != "" {
+		auth = append(auth, ssh.Password(config.Password))
+	}
+	if len(auth) == 0 {
+		return nil, closeAgent, fmt.Errorf("%w: no authentication method", ErrInvalidConfig)
+	}
+
+	return &ssh.ClientConfig{
+		User:              config.User,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+		Timeout:           config.Timeout,
+	}, closeAgent, nil
+}
+
+// Dial connects to the SSH server at addr, given as host:port.
+func Dial(addr string, config Config) (*ssh.Client, error) {
+	clientConfig, closeAgent, err := config.ClientConfig(addr)
+	defer closeAgent()
+	if err != nil {
+		return nil, err
+	}
+	client, err := ssh.Dial("tcp", addr, clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", addr, err)
+	}
+	return client, nil
+}
@@ -0,0 +1,494 @@
+package sshclient_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/sshclient"
+)
+
+const (
+	testUser     = "shimiko"
+	testPassword = "hunter2"
+)
+
+func newKey(t *testing.T) (ed25519.PrivateKey, ssh.Signer) {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(private)
+	require.NoError(t, err)
+	return private, signer
+}
+
+func marshalKey(t *testing.T, private ed25519.PrivateKey, passphrase string) []byte {
+	t.Helper()
+	var block *pem.Block
+	var err error
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(private, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(private, "", []byte(passphrase))
+	}
+	require.NoError(t, err)
+	return pem.EncodeToMemory(block)
+}
+
+// newECDSAKey returns a signer for a new ECDSA P-256 key.
+func newECDSAKey(t *testing.T) ssh.Signer {
+	t.Helper()
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(private)
+	require.NoError(t, err)
+	return signer
+}
+
+// startServer runs an SSH server with hostKeys that accepts testUser with
+// testPassword or authorizedKey, and returns its address.
+func startServer(t *testing.T, authorizedKey ssh.PublicKey, hostKeys ...ssh.Signer) string {
+	t.Helper()
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == testUser && string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == testUser && authorizedKey != nil && bytes.Equal(key.Marshal(), authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized key")
+		},
+	}
+	for _, hostKey := range hostKeys {
+		config.AddHostKey(hostKey)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serverConn, channels, requests, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				defer serverConn.Close()
+				go ssh.DiscardRequests(requests)
+				for channel := range channels {
+					channel.Reject(ssh.Prohibited, "no channels")
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// startAgent serves an ssh-agent holding key and returns its socket path.
+func startAgent(t *testing.T, key ed25519.PrivateKey) string {
+	t.Helper()
+	keyring := agent.NewKeyring()
+	require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: key}))
+
+	// t.TempDir can be too long for a socket path
+	dir, err := os.MkdirTemp("", "agent")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	listener, err := net.Listen("unix", filepath.Join(dir, "agent.sock"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func writeKnownHosts(t *testing.T, addr string, keys ...ssh.PublicKey) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	lines := ""
+	for _, key := range keys {
+		lines += knownhosts.Line([]string{knownhosts.Normalize(addr)}, key) + "\n"
+	}
+	require.NoError(t, os.WriteFile(path, []byte(lines), 0o600))
+	return path
+}
+
+func sshfp(algorithm int, digestType int, key ssh.PublicKey) string {
+	var digest []byte
+	if digestType == 1 {
+		sum := sha1.Sum(key.Marshal())
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256(key.Marshal())
+		digest = sum[:]
+	}
+	return fmt.Sprintf("%d %d %s", algorithm, digestType, hex.EncodeToString(digest))
+}
+
+func TestParseFingerprint(t *testing.T) {
+	t.Parallel()
+
+	_, signer := newKey(t)
+	key := signer.PublicKey()
+
+	tests := map[string]struct {
+		text  string
+		match bool
+		err   error
+	}{
+		"OpenSSH": {
+			text:  ssh.FingerprintSHA256(key),
+			match: true,
+		},
+		"SSHFP SHA-256": {
+			text:  sshfp(4, 2, key),
+			match: true,
+		},
+		"SSHFP SHA-1": {
+			text:  sshfp(4, 1, key),
+			match: true,
+		},
+		"SSHFP of another key type": {
+			text:  sshfp(1, 2, key),
+			match: false,
+		},
+		"bad base64": {
+			text: "SHA256:not base64!",
+			err:  sshclient.ErrInvalidFingerprint,
+		},
+		"short digest": {
+			text: "4 2 0123456789ABCDEF",
+			err:  sshclient.ErrInvalidFingerprint,
+		},
+		"unknown digest type": {
+			text: "4 3 0123456789ABCDEF",
+			err:  sshclient.ErrInvalidFingerprint,
+		},
+		"unknown key algorithm": {
+			text: "9 2 0123456789ABCDEF",
+			err:  sshclient.ErrInvalidFingerprint,
+		},
+		"garbage": {
+			text: "nope",
+			err:  sshclient.ErrInvalidFingerprint,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fp, err := sshclient.ParseFingerprint(tc.text)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.match, fp.Match(key))
+			assert.Equal(t, tc.text, fp.String())
+		})
+	}
+}
+
+func TestDial(t *testing.T) {
+	t.Parallel()
+
+	_, hostKey := newKey(t)
+	_, otherHostKey := newKey(t)
+	clientKey, clientSigner := newKey(t)
+	otherClientKey, _ := newKey(t)
+	addr := startServer(t, clientSigner.PublicKey(), hostKey)
+	knownHosts := writeKnownHosts(t, addr, hostKey.PublicKey())
+	changedKnownHosts := writeKnownHosts(t, addr, otherHostKey.PublicKey())
+	otherKnownHosts := writeKnownHosts(t, "192.0.2.1:22", hostKey.PublicKey())
+	agentSocket := startAgent(t, clientKey)
+
+	tests := map[string]struct {
+		config sshclient.Config
+		err    error
+		// authFails is set if the server is expected to reject the client
+		authFails bool
+	}{
+		"OpenSSH fingerprint": {
+			config: sshclient.Config{
+				Fingerprints: []string{ssh.FingerprintSHA256(hostKey.PublicKey())},
+				Password:     testPassword,
+			},
+		},
+		"SSHFP fingerprint": {
+			config: sshclient.Config{
+				Fingerprints: []string{
+					ssh.FingerprintSHA256(otherHostKey.PublicKey()),
+					sshfp(4, 2, hostKey.PublicKey()),
+				},
+				Password: testPassword,
+			},
+		},
+		"known_hosts": {
+			config: sshclient.Config{
+				KnownHostsFiles: []string{knownHosts},
+				Password:        testPassword,
+			},
+		},
+		"fingerprint mismatch": {
+			config: sshclient.Config{
+				Fingerprints: []string{ssh.FingerprintSHA256(otherHostKey.PublicKey())},
+				Password:     testPassword,
+			},
+			err: sshclient.ErrHostKeyMismatch,
+		},
+		"changed key in known_hosts": {
+			config: sshclient.Config{
+				KnownHostsFiles: []string{changedKnownHosts},
+				Password:        testPassword,
+			},
+			err: sshclient.ErrHostKeyMismatch,
+		},
+		"host missing from known_hosts": {
+			config: sshclient.Config{
+				KnownHostsFiles: []string{otherKnownHosts},
+				Password:        testPassword,
+			},
+			err: sshclient.ErrUnknownHost,
+		},
+		"no pinned host keys": {
+			config: sshclient.Config{
+				Password: testPassword,
+			},
+			err: sshclient.ErrInvalidConfig,
+		},
+		"insecure": {
+			config: sshclient.Config{
+				InsecureIgnoreHostKey: true,
+				Password:              testPassword,
+			},
+		},
+		"wrong password": {
+			config: sshclient.Config{
+				KnownHostsFiles: []string{knownHosts},
+				Password:        "wrong",
+			},
+			authFails: true,
+		},
+		"private key": {
+			config: sshclient.Config{
+				KnownHostsFiles: []string{knownHosts},
+				PrivateKey:      marshalKey(t, clientKey, ""),
+			},
+		},
+		"encrypted private key": {
+			config: sshclient.Config{
+				KnownHostsFiles:      []string{knownHosts},
+				PrivateKey:           marshalKey(t, clientKey, "secret"),
+				PrivateKeyPassphrase: "secret",
+			},
+		},
+		"encrypted private key without passphrase": {
+			config: sshclient.Config{
+				KnownHostsFiles: []string{knownHosts},
+				PrivateKey:      marshalKey(t, clientKey, "secret"),
+			},
+			err: sshclient.ErrInvalidConfig,
+		},
+		"unauthorized private key": {
+			config: sshclient.Config{
+				KnownHostsFiles: []string{knownHosts},
+				PrivateKey:      marshalKey(t, otherClientKey, ""),
+			},
+			authFails: true,
+		},
+		"agent": {
+			config: sshclient.Config{
+				KnownHostsFiles: []string{knownHosts},
+				AgentSocket:     agentSocket,
+			},
+		},
+		"no authentication method": {
+			config: sshclient.Config{
+				KnownHostsFiles: []string{knownHosts},
+			},
+			err: sshclient.ErrInvalidConfig,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := tc.config
+			config.User = testUser
+			client, err := sshclient.Dial(addr, config)
+			switch {
+			case tc.err != nil:
+				assert.ErrorIs(t, err, tc.err)
+			case tc.authFails:
+				assert.ErrorContains(t, err, "unable to authenticate")
+			default:
+				require.NoError(t, err)
+				assert.NoError(t, client.Close())
+			}
+		})
+	}
+}
+
+func TestHostKeyAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	_, ed25519Key := newKey(t)
+	ecdsaKey := newECDSAKey(t)
+	addr := "192.0.2.1:22"
+	knownHosts := writeKnownHosts(t, addr, ed25519Key.PublicKey(), ecdsaKey.PublicKey())
+
+	tests := map[string]struct {
+		config   sshclient.Config
+		expected []string
+	}{
+		"SSHFP fingerprint": {
+			config: sshclient.Config{
+				Fingerprints: []string{sshfp(4, 2, ed25519Key.PublicKey())},
+			},
+			expected: []string{ssh.KeyAlgoED25519},
+		},
+		"SSHFP fingerprints of several types": {
+			config: sshclient.Config{
+				Fingerprints: []string{
+					sshfp(3, 2, ecdsaKey.PublicKey()),
+					sshfp(4, 1, ed25519Key.PublicKey()),
+					sshfp(4, 2, ed25519Key.PublicKey()),
+				},
+			},
+			expected: []string{ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521, ssh.KeyAlgoED25519},
+		},
+		"SSHFP fingerprint of an RSA key": {
+			config: sshclient.Config{
+				Fingerprints: []string{"1 2 0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF"},
+			},
+			expected: []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256},
+		},
+		"known_hosts": {
+			config: sshclient.Config{
+				KnownHostsFiles: []string{knownHosts},
+			},
+			expected: []string{ssh.KeyAlgoED25519, ssh.KeyAlgoECDSA256},
+		},
+		"fingerprint and known_hosts": {
+			config: sshclient.Config{
+				KnownHostsFiles: []string{writeKnownHosts(t, addr, ecdsaKey.PublicKey())},
+				Fingerprints:    []string{sshfp(4, 2, ed25519Key.PublicKey())},
+			},
+			expected: []string{ssh.KeyAlgoED25519, ssh.KeyAlgoECDSA256},
+		},
+		"OpenSSH fingerprint of any type": {
+			config: sshclient.Config{
+				Fingerprints: []string{
+					sshfp(4, 2, ed25519Key.PublicKey()),
+					ssh.FingerprintSHA256(ecdsaKey.PublicKey()),
+				},
+			},
+		},
+		"host missing from known_hosts": {
+			config: sshclient.Config{
+				KnownHostsFiles: []string{writeKnownHosts(t, "192.0.2.2:22", ed25519Key.PublicKey())},
+			},
+		},
+		"insecure": {
+			config: sshclient.Config{
+				InsecureIgnoreHostKey: true,
+				Fingerprints:          []string{sshfp(4, 2, ed25519Key.PublicKey())},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			algorithms, err := tc.config.HostKeyAlgorithms(addr)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, algorithms)
+		})
+	}
+}
+
+func TestDialMultipleHostKeys(t *testing.T) {
+	t.Parallel()
+
+	_, ed25519Key := newKey(t)
+	ecdsaKey := newECDSAKey(t)
+	// ssh.ClientConfig prefers ECDSA over Ed25519 host keys by default
+	addr := startServer(t, nil, ed25519Key, ecdsaKey)
+
+	tests := map[string]sshclient.Config{
+		"SSHFP fingerprint of the Ed25519 key": {
+			Fingerprints: []string{sshfp(4, 2, ed25519Key.PublicKey())},
+		},
+		"SSHFP fingerprint of the ECDSA key": {
+			Fingerprints: []string{sshfp(3, 2, ecdsaKey.PublicKey())},
+		},
+		"Ed25519 key in known_hosts": {
+			KnownHostsFiles: []string{writeKnownHosts(t, addr, ed25519Key.PublicKey())},
+		},
+		"both keys in known_hosts": {
+			KnownHostsFiles: []string{writeKnownHosts(t, addr, ed25519Key.PublicKey(), ecdsaKey.PublicKey())},
+		},
+	}
+
+	for name, config := range tests {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config.User = testUser
+			config.Password = testPassword
+			client, err := sshclient.Dial(addr, config)
+			require.NoError(t, err)
+			assert.NoError(t, client.Close())
+		})
+	}
+}
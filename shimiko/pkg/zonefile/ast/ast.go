@@ -13,7 +13,12 @@ const (
 	NodeTypeOriginControlEntry  NodeType = "NodeTypeOriginControlEntry"
 	NodeTypeTTLControlEntry     NodeType = "NodeTypeTTLControlEntry"
 	NodeTypeIncludeControlEntry NodeType = "NodeTypeIncludeControlEntry"
-	NodeTypeRREntry             NodeType = "NodeTypeRREntry"
+	// NodeTypeGenerateControlEntry is the BIND $GENERATE extension.
+	NodeTypeGenerateControlEntry NodeType = "NodeTypeGenerateControlEntry"
+	NodeTypeRREntry              NodeType = "NodeTypeRREntry"
+	// NodeTypeRaw is an entry that couldn't be parsed, kept verbatim in
+	// SourceTokens by parser.ParseReaderTolerant.
+	NodeTypeRaw NodeType = "NodeTypeRaw"
 )
 
 type Entry any
@@ -24,6 +29,10 @@ type Node struct {
 	LeadComments []string
 	LineComment  string
 	Entry        Entry
+	// SourceFile is the name of the file the node was loaded from. It is only
+	// set when the node came through parser.ParseFile or
+	// parser.ResolveIncludes.
+	SourceFile string
 }
 
 type OriginControlEntry struct {
@@ -92,6 +101,32 @@ func (n Node) IncludeControlEntry() IncludeControlEntry {
 
 type RData struct {
 	Value string
+	// NewLine is set when the field starts a new line inside a parenthesized
+	// group.
+	NewLine bool
+	// LeadComments are comment lines directly above the field inside a
+	// parenthesized group.
+	LeadComments []string
+	// Comment is the comment following the field on its line inside a
+	// parenthesized group.
+	Comment string
+}
+
+// RDataGroup describes the parenthesized part of a multi-line record, e.g. the
+// timers of an SOA record or the key material of a DNSKEY record.
+type RDataGroup struct {
+	// Start is the index of the first RData inside the parentheses.
+	Start int
+	// End is the index one past the last RData inside the parentheses.
+	End int
+	// Comment is the comment following the opening parenthesis on its line.
+	Comment string
+	// TrailingComments are comment lines between the last field and the
+	// closing parenthesis.
+	TrailingComments []string
+	// CloseOnNewLine is set when the closing parenthesis is on a line of its
+	// own.
+	CloseOnNewLine bool
 }
 
 type RRecord struct {
@@ -99,11 +134,32 @@ type RRecord struct {
 	Class string
 	Type  string
 	RData []RData
+	// Group is set when part of the RDATA was wrapped in parentheses.
+	Group *RDataGroup
 }
 
 type RREntry struct {
 	DomainName string
 	RRecord    RRecord
+	// Resolved holds the effective owner, TTL and class of the entry. It is
+	// only set after running parser.Resolve; DomainName and RRecord keep the
+	// values as written for rendering.
+	Resolved *ResolvedRR
+}
+
+// ResolvedRR holds the values of an RREntry after applying the zone file
+// defaults from RFC 1035 and RFC 2308: a blank owner repeats the previous
+// owner, relative names are relative to $ORIGIN and a missing TTL or class is
+// inherited.
+type ResolvedRR struct {
+	// Owner is the absolute owner name.
+	Owner string
+	// TTL is the effective TTL.
+	TTL time.Duration
+	// Class is the effective class.
+	Class string
+	// Origin is the $ORIGIN that was in effect for the entry.
+	Origin string
 }
 
 func IsRREntry(entry Entry) bool {
@@ -122,3 +178,28 @@ func (n Node) IsRREntry() bool {
 func (n Node) RREntry() RREntry {
 	return ToRREntry(n.Entry)
 }
+
+// RawEntry is the entry of a NodeTypeRaw node.
+type RawEntry struct {
+	// Text is the source of the entry without the line break ending it.
+	Text string
+	// Err is the reason the entry couldn't be parsed.
+	Err error
+}
+
+func IsRawEntry(entry Entry) bool {
+	_, ok := entry.(RawEntry)
+	return ok
+}
+
+func ToRawEntry(entry Entry) RawEntry {
+	return entry.(RawEntry)
+}
+
+func (n Node) IsRawEntry() bool {
+	return IsRawEntry(n.Entry)
+}
+
+func (n Node) RawEntry() RawEntry {
+	return ToRawEntry(n.Entry)
+}
@@ -0,0 +1,152 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errBadEscape = errors.New("bad escape sequence")
+
+// MaxCharacterStringLength is the maximum length in bytes of a single
+// character-string on the wire (RFC 1035 section 3.3).
+const MaxCharacterStringLength = 255
+
+// IsQuoted reports whether the field is a quoted character-string.
+func (rdata RData) IsQuoted() bool {
+	return strings.HasPrefix(rdata.Value, `"`)
+}
+
+// Bytes decodes the field as a character-string in presentation form (RFC
+// 1035 section 5.1), quoted or not, and returns its bytes on the wire.
+func (rdata RData) Bytes() ([]byte, error) {
+	return ParseCharacterString(rdata.Value)
+}
+
+// ParseCharacterString decodes a presentation-form character-string. The
+// surrounding quotes are removed if there are any, `\DDD` is replaced with
+// the byte with the decimal value DDD and `\X` with X. The length is not
+// checked; strings longer than MaxCharacterStringLength are split when
+// rendered with CharacterStrings.
+func ParseCharacterString(value string) ([]byte, error) {
+	if strings.HasPrefix(value, `"`) {
+		if len(value) < 2 || !strings.HasSuffix(value, `"`) || isEscaped(value, len(value)-1) {
+			return nil, errUnterminatedQuote
+		}
+		value = value[1 : len(value)-1]
+	}
+
+	data := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		if ch != '\\' {
+			data = append(data, ch)
+			continue
+		}
+		if i+1 >= len(value) {
+			return nil, fmt.Errorf("%w: trailing backslash", errBadEscape)
+		}
+		if !isDigitByte(value[i+1]) {
+			data = append(data, value[i+1])
+			i++
+			continue
+		}
+		if i+3 >= len(value) || !isDigitByte(value[i+2]) || !isDigitByte(value[i+3]) {
+			return nil, fmt.Errorf("%w: '%s' is not \\DDD", errBadEscape, value[i:min(i+4, len(value))])
+		}
+		decimal := int(value[i+1]-'0')*100 + int(value[i+2]-'0')*10 + int(value[i+3]-'0')
+		if decimal > 255 {
+			return nil, fmt.Errorf("%w: '%s' is greater than 255", errBadEscape, value[i:i+4])
+		}
+		data = append(data, byte(decimal))
+		i += 3
+	}
+	return data, nil
+}
+
+// FormatCharacterString renders data as a quoted presentation-form
+// character-string. `"` and `\` are escaped with a backslash and bytes that
+// aren't printable ASCII are written as `\DDD`.
+func FormatCharacterString(data []byte) string {
+	var sb strings.Builder
+	sb.Grow(len(data) + 2)
+	sb.WriteByte('"')
+	for _, ch := range data {
+		switch {
+		case ch == '"' || ch == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(ch)
+		case ch < ' ' || ch > '~':
+			fmt.Fprintf(&sb, "\\%03d", ch)
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// CharacterStrings renders data as quoted character-strings of at most
+// MaxCharacterStringLength bytes each. Empty data yields a single empty
+// string.
+func CharacterStrings(data []byte) []RData {
+	rdata := []RData{}
+	for len(data) > MaxCharacterStringLength {
+		rdata = append(rdata, RData{Value: FormatCharacterString(data[:MaxCharacterStringLength])})
+		data = data[MaxCharacterStringLength:]
+	}
+	return append(rdata, RData{Value: FormatCharacterString(data)})
+}
+
+// TXT is the typed RDATA of a TXT record (RFC 1035 section 3.3.14). Strings
+// holds the decoded character-strings. Strings longer than
+// MaxCharacterStringLength are split into several when rendered, which is
+// what e.g. DKIM keys expect.
+type TXT struct {
+	Strings [][]byte
+}
+
+// NewTXT returns a TXT record holding text as a single logical string.
+func NewTXT(text string) TXT {
+	return TXT{Strings: [][]byte{[]byte(text)}}
+}
+
+func ParseTXT(rdata []RData) (TXT, error) {
+	txt := TXT{}
+	if err := checkFieldCount("TXT", rdata, 1, -1); err != nil {
+		return txt, err
+	}
+	for _, field := range rdata {
+		data, err := field.Bytes()
+		if err != nil {
+			return txt, newFieldError("TXT", "TXT-DATA", field.Value, err)
+		}
+		txt.Strings = append(txt.Strings, data)
+	}
+	return txt, nil
+}
+
+// Text returns the concatenation of all strings.
+func (txt TXT) Text() string {
+	var sb strings.Builder
+	for _, data := range txt.Strings {
+		sb.Write(data)
+	}
+	return sb.String()
+}
+
+func (txt TXT) RRType() string {
+	return "TXT"
+}
+
+func (txt TXT) RData() []RData {
+	rdata := []RData{}
+	for _, data := range txt.Strings {
+		rdata = append(rdata, CharacterStrings(data)...)
+	}
+	return rdata
+}
+
+func (txt TXT) Validate() error {
+	return nil
+}
@@ -0,0 +1,133 @@
+package ast_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+)
+
+func TestParseCharacterString(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input       string
+		expected    []byte
+		errContains string
+	}{
+		"quoted": {
+			input:    `"hello world"`,
+			expected: []byte("hello world"),
+		},
+		"unquoted": {
+			input:    `hello`,
+			expected: []byte("hello"),
+		},
+		"empty": {
+			input:    `""`,
+			expected: []byte{},
+		},
+		"escaped quote and backslash": {
+			input:    `"a\"b\\c"`,
+			expected: []byte(`a"b\c`),
+		},
+		"escaped semicolon and parenthesis": {
+			input:    `v=DKIM1\;\(k\)`,
+			expected: []byte("v=DKIM1;(k)"),
+		},
+		"decimal escapes": {
+			input:    `"\000\065\255"`,
+			expected: []byte{0, 'A', 255},
+		},
+		"decimal escape followed by digit": {
+			input:    `\0491`,
+			expected: []byte("11"),
+		},
+		"decimal escape out of range": {
+			input:       `"\256"`,
+			errContains: "greater than 255",
+		},
+		"short decimal escape": {
+			input:       `"\12"`,
+			errContains: "is not \\DDD",
+		},
+		"trailing backslash": {
+			input:       `abc\`,
+			errContains: "trailing backslash",
+		},
+		"unterminated quote": {
+			input:       `"abc`,
+			errContains: "unterminated quoted string",
+		},
+		"escaped closing quote": {
+			input:       `"abc\"`,
+			errContains: "unterminated quoted string",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ast.RData{Value: tc.input}.Bytes()
+
+			if tc.errContains != "" {
+				assert.ErrorContains(t, err, tc.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestFormatCharacterString(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input    []byte
+		expected string
+	}{
+		"plain":         {input: []byte("v=spf1 -all"), expected: `"v=spf1 -all"`},
+		"empty":         {input: []byte{}, expected: `""`},
+		"specials":      {input: []byte(`a"b\c;d(e)`), expected: `"a\"b\\c;d(e)"`},
+		"non-printable": {input: []byte{0, '\t', 127, 200}, expected: `"\000\009\127\200"`},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := ast.FormatCharacterString(tc.input)
+			assert.Equal(t, tc.expected, got)
+
+			// formatting is lossless
+			decoded, err := ast.ParseCharacterString(got)
+			require.NoError(t, err)
+			assert.Equal(t, tc.input, decoded)
+		})
+	}
+}
+
+func TestCharacterStrings(t *testing.T) {
+	t.Parallel()
+
+	key := strings.Repeat("k", 300)
+	got := ast.NewTXT("v=DKIM1; p=" + key).RData()
+	require.Len(t, got, 2)
+	assert.Equal(t, `"v=DKIM1; p=`+key[:244]+`"`, got[0].Value)
+	assert.Equal(t, `"`+key[244:]+`"`, got[1].Value)
+
+	txt, err := ast.ParseTXT(got)
+	require.NoError(t, err)
+	assert.Equal(t, "v=DKIM1; p="+key, txt.Text())
+
+	assert.Equal(t, []ast.RData{{Value: `""`}}, ast.CharacterStrings(nil))
+	assert.True(t, got[0].IsQuoted())
+	assert.False(t, ast.RData{Value: "10"}.IsQuoted())
+}
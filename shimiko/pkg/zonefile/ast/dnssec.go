@@ -0,0 +1,216 @@
+package ast
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ttl"
+)
+
+// DNSKEY flags (RFC 4034 section 2.1.1).
+const (
+	DNSKEYFlagZone uint16 = 1 << 8
+	DNSKEYFlagSEP  uint16 = 1
+)
+
+// DNSKEY is the typed RDATA of a DNSKEY record (RFC 4034 section 2).
+type DNSKEY struct {
+	Flags     uint16
+	Protocol  uint8
+	Algorithm uint8
+	PublicKey []byte
+}
+
+func ParseDNSKEY(rdata []RData) (DNSKEY, error) {
+	dnskey := DNSKEY{}
+	if err := checkFieldCount("DNSKEY", rdata, 4, -1); err != nil {
+		return dnskey, err
+	}
+	var err error
+	if dnskey.Flags, err = parseUint16("DNSKEY", "FLAGS", rdata[0].Value); err != nil {
+		return dnskey, err
+	}
+	if dnskey.Protocol, err = parseUint8("DNSKEY", "PROTOCOL", rdata[1].Value); err != nil {
+		return dnskey, err
+	}
+	if dnskey.Algorithm, err = parseUint8("DNSKEY", "ALGORITHM", rdata[2].Value); err != nil {
+		return dnskey, err
+	}
+	if dnskey.PublicKey, err = parseBase64("DNSKEY", "PUBLIC-KEY", rdata[3:]); err != nil {
+		return dnskey, err
+	}
+	return dnskey, nil
+}
+
+func (dnskey DNSKEY) RRType() string {
+	return "DNSKEY"
+}
+
+func (dnskey DNSKEY) RData() []RData {
+	return toRData(
+		strconv.FormatUint(uint64(dnskey.Flags), 10),
+		strconv.FormatUint(uint64(dnskey.Protocol), 10),
+		strconv.FormatUint(uint64(dnskey.Algorithm), 10),
+		base64.StdEncoding.EncodeToString(dnskey.PublicKey),
+	)
+}
+
+func (dnskey DNSKEY) Validate() error {
+	if dnskey.Protocol != 3 {
+		return newFieldError("DNSKEY", "PROTOCOL", strconv.Itoa(int(dnskey.Protocol)), errors.New("protocol must be 3"))
+	}
+	if len(dnskey.PublicKey) == 0 {
+		return newFieldError("DNSKEY", "PUBLIC-KEY", "", errors.New("public key is empty"))
+	}
+	return nil
+}
+
+// RRSIG is the typed RDATA of an RRSIG record (RFC 4034 section 3).
+type RRSIG struct {
+	TypeCovered string
+	Algorithm   uint8
+	Labels      uint8
+	OriginalTTL time.Duration
+	Expiration  time.Time
+	Inception   time.Time
+	KeyTag      uint16
+	SignerName  string
+	Signature   []byte
+}
+
+// rrsigTimeLayout is the YYYYMMDDHHmmSS form of RRSIG timestamps.
+const rrsigTimeLayout = "20060102150405"
+
+func ParseRRSIG(rdata []RData) (RRSIG, error) {
+	rrsig := RRSIG{}
+	if err := checkFieldCount("RRSIG", rdata, 9, -1); err != nil {
+		return rrsig, err
+	}
+	var err error
+	rrsig.TypeCovered = strings.ToUpper(rdata[0].Value)
+	if rrsig.Algorithm, err = parseUint8("RRSIG", "ALGORITHM", rdata[1].Value); err != nil {
+		return rrsig, err
+	}
+	if rrsig.Labels, err = parseUint8("RRSIG", "LABELS", rdata[2].Value); err != nil {
+		return rrsig, err
+	}
+	if rrsig.OriginalTTL, err = parseSeconds("RRSIG", "ORIGINAL-TTL", rdata[3].Value); err != nil {
+		return rrsig, err
+	}
+	if rrsig.Expiration, err = parseRRSIGTime("EXPIRATION", rdata[4].Value); err != nil {
+		return rrsig, err
+	}
+	if rrsig.Inception, err = parseRRSIGTime("INCEPTION", rdata[5].Value); err != nil {
+		return rrsig, err
+	}
+	if rrsig.KeyTag, err = parseUint16("RRSIG", "KEY-TAG", rdata[6].Value); err != nil {
+		return rrsig, err
+	}
+	if rrsig.SignerName, err = parseName("RRSIG", "SIGNER", rdata[7].Value); err != nil {
+		return rrsig, err
+	}
+	if rrsig.Signature, err = parseBase64("RRSIG", "SIGNATURE", rdata[8:]); err != nil {
+		return rrsig, err
+	}
+	return rrsig, nil
+}
+
+// parseRRSIGTime parses an RRSIG timestamp, either YYYYMMDDHHmmSS or seconds
+// since the epoch (RFC 4034 section 3.2).
+func parseRRSIGTime(field string, value string) (time.Time, error) {
+	if len(value) == len(rrsigTimeLayout) {
+		t, err := time.Parse(rrsigTimeLayout, value)
+		if err != nil {
+			return time.Time{}, newFieldError("RRSIG", field, value, err)
+		}
+		return t, nil
+	}
+	seconds, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return time.Time{}, newFieldError("RRSIG", field, value, err)
+	}
+	return time.Unix(int64(seconds), 0).UTC(), nil
+}
+
+func (rrsig RRSIG) RRType() string {
+	return "RRSIG"
+}
+
+func (rrsig RRSIG) RData() []RData {
+	return toRData(
+		rrsig.TypeCovered,
+		strconv.FormatUint(uint64(rrsig.Algorithm), 10),
+		strconv.FormatUint(uint64(rrsig.Labels), 10),
+		ttl.FormatSeconds(rrsig.OriginalTTL),
+		rrsig.Expiration.UTC().Format(rrsigTimeLayout),
+		rrsig.Inception.UTC().Format(rrsigTimeLayout),
+		strconv.FormatUint(uint64(rrsig.KeyTag), 10),
+		rrsig.SignerName,
+		base64.StdEncoding.EncodeToString(rrsig.Signature),
+	)
+}
+
+func (rrsig RRSIG) Validate() error {
+	if rrsig.TypeCovered == "" {
+		return newFieldError("RRSIG", "TYPE-COVERED", "", errors.New("type covered is empty"))
+	}
+	if !rrsig.Expiration.After(rrsig.Inception) {
+		return newFieldError("RRSIG", "EXPIRATION", rrsig.Expiration.UTC().Format(rrsigTimeLayout), errors.New("expiration must be after inception"))
+	}
+	if err := ValidateDomainName(rrsig.SignerName); err != nil {
+		return newFieldError("RRSIG", "SIGNER", rrsig.SignerName, err)
+	}
+	return nil
+}
+
+// NSEC is the typed RDATA of an NSEC record (RFC 4034 section 4). Types holds
+// the mnemonics of the types present at the owner name.
+type NSEC struct {
+	NextDomain string
+	Types      []string
+}
+
+func ParseNSEC(rdata []RData) (NSEC, error) {
+	nsec := NSEC{
+		Types: []string{},
+	}
+	if err := checkFieldCount("NSEC", rdata, 1, -1); err != nil {
+		return nsec, err
+	}
+	var err error
+	if nsec.NextDomain, err = parseName("NSEC", "NEXT-DOMAIN", rdata[0].Value); err != nil {
+		return nsec, err
+	}
+	for _, field := range rdata[1:] {
+		nsec.Types = append(nsec.Types, strings.ToUpper(field.Value))
+	}
+	return nsec, nil
+}
+
+func (nsec NSEC) RRType() string {
+	return "NSEC"
+}
+
+func (nsec NSEC) RData() []RData {
+	return toRData(append([]string{nsec.NextDomain}, nsec.Types...)...)
+}
+
+func (nsec NSEC) Validate() error {
+	if err := ValidateDomainName(nsec.NextDomain); err != nil {
+		return newFieldError("NSEC", "NEXT-DOMAIN", nsec.NextDomain, err)
+	}
+	return nil
+}
+
+func parseBase64(rrtype string, field string, rdata []RData) ([]byte, error) {
+	value := strings.Join(rdataValues(rdata), "")
+	b, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, newFieldError(rrtype, field, value, fmt.Errorf("invalid base64: %w", err))
+	}
+	return b, nil
+}
@@ -0,0 +1,246 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidGenerate = errors.New("invalid $GENERATE")
+
+// MaxGenerateIterations is the largest number of records a single $GENERATE
+// entry may expand to. It matches the limit used by BIND.
+const MaxGenerateIterations = 65536
+
+// GenerateControlEntry is the BIND $GENERATE extension:
+//
+//	$GENERATE start-stop[/step] lhs [ttl] [class] type rhs
+//
+// DomainName and the RDATA of RRecord are templates in which "$" is replaced
+// with the iterator and "${offset[,width[,base]]}" with a modified iterator.
+// A literal "$" is written as "\$" or "$$".
+type GenerateControlEntry struct {
+	Start int
+	Stop  int
+	Step  int
+	// DomainName is the owner name template ("lhs").
+	DomainName string
+	RRecord    RRecord
+}
+
+func IsGenerateControlEntry(entry Entry) bool {
+	_, ok := entry.(GenerateControlEntry)
+	return ok
+}
+
+func ToGenerateControlEntry(entry Entry) GenerateControlEntry {
+	return entry.(GenerateControlEntry)
+}
+
+func (n Node) IsGenerateControlEntry() bool {
+	return IsGenerateControlEntry(n.Entry)
+}
+
+func (n Node) GenerateControlEntry() GenerateControlEntry {
+	return ToGenerateControlEntry(n.Entry)
+}
+
+// ParseGenerateRange parses the "start-stop[/step]" range of a $GENERATE
+// entry. The step defaults to 1.
+func ParseGenerateRange(s string) (start int, stop int, step int, err error) {
+	step = 1
+	rng := s
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		step, err = strconv.Atoi(s[i+1:])
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("%w: bad step in range '%s'", ErrInvalidGenerate, s)
+		}
+		rng = s[:i]
+	}
+
+	startStr, stopStr, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("%w: range '%s' is not start-stop[/step]", ErrInvalidGenerate, s)
+	}
+	start, err = strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("%w: bad start in range '%s'", ErrInvalidGenerate, s)
+	}
+	stop, err = strconv.Atoi(stopStr)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("%w: bad stop in range '%s'", ErrInvalidGenerate, s)
+	}
+
+	return start, stop, step, validateGenerateRange(start, stop, step)
+}
+
+func validateGenerateRange(start int, stop int, step int) error {
+	switch {
+	case start < 0 || stop < 0:
+		return fmt.Errorf("%w: range %d-%d must not be negative", ErrInvalidGenerate, start, stop)
+	case start > stop:
+		return fmt.Errorf("%w: start %d is greater than stop %d", ErrInvalidGenerate, start, stop)
+	case step <= 0:
+		return fmt.Errorf("%w: step %d must be positive", ErrInvalidGenerate, step)
+	case (stop-start)/step+1 > MaxGenerateIterations:
+		return fmt.Errorf("%w: range %d-%d/%d expands to more than %d records", ErrInvalidGenerate, start, stop, step, MaxGenerateIterations)
+	}
+	return nil
+}
+
+// Range renders the range of entry as written in a zone file. The step is
+// left out if it is 1.
+func (entry GenerateControlEntry) Range() string {
+	rng := strconv.Itoa(entry.Start) + "-" + strconv.Itoa(entry.Stop)
+	if entry.Step != 1 {
+		rng += "/" + strconv.Itoa(entry.Step)
+	}
+	return rng
+}
+
+// Expand returns the records entry stands for, one per value of the iterator.
+// The records keep the TTL, class and type of entry.
+func (entry GenerateControlEntry) Expand() ([]RREntry, error) {
+	if err := validateGenerateRange(entry.Start, entry.Stop, entry.Step); err != nil {
+		return nil, err
+	}
+
+	entries := make([]RREntry, 0, (entry.Stop-entry.Start)/entry.Step+1)
+	for i := entry.Start; i <= entry.Stop; i += entry.Step {
+		owner, err := ExpandGenerateTemplate(entry.DomainName, i)
+		if err != nil {
+			return nil, err
+		}
+		rdata := make([]RData, len(entry.RRecord.RData))
+		for j, field := range entry.RRecord.RData {
+			value, err := ExpandGenerateTemplate(field.Value, i)
+			if err != nil {
+				return nil, err
+			}
+			rdata[j] = RData{Value: value}
+		}
+		entries = append(entries, RREntry{
+			DomainName: owner,
+			RRecord: RRecord{
+				TTL:   entry.RRecord.TTL,
+				Class: entry.RRecord.Class,
+				Type:  entry.RRecord.Type,
+				RData: rdata,
+			},
+		})
+	}
+	return entries, nil
+}
+
+// ExpandGenerateTemplate substitutes the iterator i into a $GENERATE
+// template. "$" is replaced with i, "${offset[,width[,base]]}" with i+offset
+// formatted in base (d, o, x, X, n or N) and zero padded to width, and "\$"
+// and "$$" with a literal "$". Other escapes are kept as they are.
+func ExpandGenerateTemplate(template string, i int) (string, error) {
+	var sb strings.Builder
+	for pos := 0; pos < len(template); pos++ {
+		ch := template[pos]
+		switch {
+		case ch == '\\' && pos+1 < len(template):
+			if template[pos+1] == '$' {
+				sb.WriteByte('$')
+			} else {
+				sb.WriteByte(ch)
+				sb.WriteByte(template[pos+1])
+			}
+			pos++
+
+		case ch != '$':
+			sb.WriteByte(ch)
+
+		case pos+1 < len(template) && template[pos+1] == '$':
+			sb.WriteByte('$')
+			pos++
+
+		case pos+1 < len(template) && template[pos+1] == '{':
+			end := strings.IndexByte(template[pos:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("%w: unterminated modifier in '%s'", ErrInvalidGenerate, template)
+			}
+			value, err := generateModifier(template[pos+2:pos+end], i)
+			if err != nil {
+				return "", fmt.Errorf("%w in '%s'", err, template)
+			}
+			sb.WriteString(value)
+			pos += end
+
+		default:
+			sb.WriteString(strconv.Itoa(i))
+		}
+	}
+	return sb.String(), nil
+}
+
+// generateModifier formats i according to the "offset[,width[,base]]"
+// modifier.
+func generateModifier(modifier string, i int) (string, error) {
+	fields := strings.Split(modifier, ",")
+	if len(fields) > 3 {
+		return "", fmt.Errorf("%w: bad modifier '${%s}'", ErrInvalidGenerate, modifier)
+	}
+
+	offset, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return "", fmt.Errorf("%w: bad offset in modifier '${%s}'", ErrInvalidGenerate, modifier)
+	}
+	width := 0
+	if len(fields) > 1 {
+		width, err = strconv.Atoi(fields[1])
+		if err != nil || width < 0 || width > 255 {
+			return "", fmt.Errorf("%w: bad width in modifier '${%s}'", ErrInvalidGenerate, modifier)
+		}
+	}
+	base := "d"
+	if len(fields) > 2 {
+		base = fields[2]
+	}
+
+	value := i + offset
+	if value < 0 {
+		return "", fmt.Errorf("%w: modifier '${%s}' makes the iterator negative", ErrInvalidGenerate, modifier)
+	}
+
+	switch base {
+	case "d":
+		return fmt.Sprintf("%0*d", width, value), nil
+	case "o":
+		return fmt.Sprintf("%0*o", width, value), nil
+	case "x":
+		return fmt.Sprintf("%0*x", width, value), nil
+	case "X":
+		return fmt.Sprintf("%0*X", width, value), nil
+	case "n":
+		return nibbles(value, width, "0123456789abcdef"), nil
+	case "N":
+		return nibbles(value, width, "0123456789ABCDEF"), nil
+	default:
+		return "", fmt.Errorf("%w: bad base in modifier '${%s}'", ErrInvalidGenerate, modifier)
+	}
+}
+
+// nibbles renders value as reversed, dot separated hex digits for use in
+// ip6.arpa names, e.g. 0x1a becomes "a.1". Like in BIND, width counts the
+// dots as well and the output is padded with zero nibbles up to it.
+func nibbles(value int, width int, digits string) string {
+	var sb strings.Builder
+	for {
+		sb.WriteByte(digits[value&0xf])
+		value >>= 4
+		width--
+		if value == 0 && width <= 0 {
+			break
+		}
+		sb.WriteByte('.')
+		width--
+		if value == 0 && width <= 0 {
+			break
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,240 @@
+package ast_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+)
+
+func TestParseGenerateRange(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input       string
+		start       int
+		stop        int
+		step        int
+		errContains string
+	}{
+		"without step": {
+			input: "1-10",
+			start: 1,
+			stop:  10,
+			step:  1,
+		},
+		"with step": {
+			input: "0-254/2",
+			start: 0,
+			stop:  254,
+			step:  2,
+		},
+		"single value": {
+			input: "5-5",
+			start: 5,
+			stop:  5,
+			step:  1,
+		},
+		"missing stop": {
+			input:       "1",
+			errContains: "is not start-stop[/step]",
+		},
+		"start after stop": {
+			input:       "10-1",
+			errContains: "start 10 is greater than stop 1",
+		},
+		"zero step": {
+			input:       "1-10/0",
+			errContains: "step 0 must be positive",
+		},
+		"bad step": {
+			input:       "1-10/x",
+			errContains: "bad step",
+		},
+		"negative": {
+			input:       "-1-10",
+			errContains: "bad start",
+		},
+		"too many iterations": {
+			input:       "0-65536",
+			errContains: "more than 65536 records",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			start, stop, step, err := ast.ParseGenerateRange(tc.input)
+
+			if tc.errContains != "" {
+				assert.ErrorIs(t, err, ast.ErrInvalidGenerate)
+				assert.ErrorContains(t, err, tc.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.start, start)
+			assert.Equal(t, tc.stop, stop)
+			assert.Equal(t, tc.step, step)
+		})
+	}
+}
+
+func TestExpandGenerateTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		template    string
+		i           int
+		expected    string
+		errContains string
+	}{
+		"plain": {
+			template: "host-$",
+			i:        7,
+			expected: "host-7",
+		},
+		"no substitution": {
+			template: "example.com.",
+			i:        7,
+			expected: "example.com.",
+		},
+		"multiple": {
+			template: "$.$",
+			i:        3,
+			expected: "3.3",
+		},
+		"escaped dollar": {
+			template: `\$-$`,
+			i:        3,
+			expected: "$-3",
+		},
+		"double dollar": {
+			template: "$$-$",
+			i:        3,
+			expected: "$-3",
+		},
+		"other escapes are kept": {
+			template: `a\.b$`,
+			i:        3,
+			expected: `a\.b3`,
+		},
+		"offset": {
+			template: "${10}",
+			i:        5,
+			expected: "15",
+		},
+		"negative offset": {
+			template: "${-1}",
+			i:        5,
+			expected: "4",
+		},
+		"width": {
+			template: "host-${0,3}",
+			i:        5,
+			expected: "host-005",
+		},
+		"octal": {
+			template: "${0,0,o}",
+			i:        8,
+			expected: "10",
+		},
+		"hex": {
+			template: "${0,4,x}",
+			i:        255,
+			expected: "00ff",
+		},
+		"upper hex": {
+			template: "${0,0,X}",
+			i:        255,
+			expected: "FF",
+		},
+		"nibble": {
+			template: "${0,0,n}.ip6.arpa.",
+			i:        0x1a,
+			expected: "a.1.ip6.arpa.",
+		},
+		"nibble with width": {
+			template: "${0,7,N}",
+			i:        0xab,
+			expected: "B.A.0.0",
+		},
+		"unterminated modifier": {
+			template:    "${0,3",
+			errContains: "unterminated modifier",
+		},
+		"bad base": {
+			template:    "${0,3,z}",
+			errContains: "bad base",
+		},
+		"bad offset": {
+			template:    "${x}",
+			errContains: "bad offset",
+		},
+		"negative result": {
+			template:    "${-10}",
+			i:           5,
+			errContains: "negative",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ast.ExpandGenerateTemplate(tc.template, tc.i)
+
+			if tc.errContains != "" {
+				assert.ErrorIs(t, err, ast.ErrInvalidGenerate)
+				assert.ErrorContains(t, err, tc.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestGenerateControlEntryExpand(t *testing.T) {
+	t.Parallel()
+
+	entry := ast.GenerateControlEntry{
+		Start:      10,
+		Stop:       14,
+		Step:       2,
+		DomainName: "$",
+		RRecord: ast.RRecord{
+			TTL:   time.Hour,
+			Class: "IN",
+			Type:  "PTR",
+			RData: []ast.RData{{Value: "dhcp-${0,3}.example.com."}},
+		},
+	}
+
+	got, err := entry.Expand()
+	require.NoError(t, err)
+
+	expected := []ast.RREntry{}
+	for _, i := range []string{"10", "12", "14"} {
+		expected = append(expected, ast.RREntry{
+			DomainName: i,
+			RRecord: ast.RRecord{
+				TTL:   time.Hour,
+				Class: "IN",
+				Type:  "PTR",
+				RData: []ast.RData{{Value: "dhcp-0" + i + ".example.com."}},
+			},
+		})
+	}
+	assert.Equal(t, expected, got)
+	assert.Equal(t, "10-14/2", entry.Range())
+
+	entry.Step = 0
+	_, err = entry.Expand()
+	assert.ErrorIs(t, err, ast.ErrInvalidGenerate)
+}
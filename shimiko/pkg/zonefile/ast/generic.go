@@ -0,0 +1,95 @@
+package ast
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GenericRDataMarker starts RDATA in the generic form of RFC 3597 section 5:
+// `\# length hex...`.
+const GenericRDataMarker = `\#`
+
+// ParseGenericType parses a type in the generic "TYPEnnn" form of RFC 3597
+// and returns its number.
+func ParseGenericType(s string) (uint16, bool) {
+	return parseGenericMnemonic(s, "TYPE")
+}
+
+// ParseGenericClass parses a class in the generic "CLASSnnn" form of RFC 3597
+// and returns its number.
+func ParseGenericClass(s string) (uint16, bool) {
+	return parseGenericMnemonic(s, "CLASS")
+}
+
+func parseGenericMnemonic(s string, prefix string) (uint16, bool) {
+	if len(s) <= len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return 0, false
+	}
+	digits := s[len(prefix):]
+	for i := 0; i < len(digits); i++ {
+		if !isDigitByte(digits[i]) {
+			return 0, false
+		}
+	}
+	n, err := strconv.ParseUint(digits, 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return uint16(n), true
+}
+
+// GenericType renders a type number in the generic "TYPEnnn" form.
+func GenericType(n uint16) string {
+	return "TYPE" + strconv.FormatUint(uint64(n), 10)
+}
+
+// GenericClass renders a class number in the generic "CLASSnnn" form.
+func GenericClass(n uint16) string {
+	return "CLASS" + strconv.FormatUint(uint64(n), 10)
+}
+
+// IsGenericRData reports whether rdata is written in the generic form of RFC
+// 3597.
+func IsGenericRData(rdata []RData) bool {
+	return len(rdata) > 0 && rdata[0].Value == GenericRDataMarker
+}
+
+// ParseGenericRData decodes RDATA in the generic form `\# length hex...` of a
+// record of type rrtype. The hex digits may be split into several fields and
+// must add up to exactly length bytes.
+func ParseGenericRData(rrtype string, rdata []RData) ([]byte, error) {
+	if !IsGenericRData(rdata) {
+		return nil, newFieldError(rrtype, "RDATA", strings.Join(rdataValues(rdata), " "), fmt.Errorf("does not start with %s", GenericRDataMarker))
+	}
+	if len(rdata) < 2 {
+		return nil, newFieldError(rrtype, "RDLENGTH", "", errors.New("missing RDATA length"))
+	}
+	length, err := strconv.ParseUint(rdata[1].Value, 10, 16)
+	if err != nil {
+		return nil, newFieldError(rrtype, "RDLENGTH", rdata[1].Value, err)
+	}
+	data, err := hex.DecodeString(strings.Join(rdataValues(rdata[2:]), ""))
+	if err != nil {
+		return nil, newFieldError(rrtype, "RDATA", strings.Join(rdataValues(rdata[2:]), " "), err)
+	}
+	if len(data) != int(length) {
+		return nil, newFieldError(
+			rrtype,
+			"RDATA",
+			strings.Join(rdataValues(rdata[2:]), " "),
+			fmt.Errorf("length is %d bytes but RDLENGTH is %d", len(data), length),
+		)
+	}
+	return data, nil
+}
+
+// GenericRData renders data in the generic form of RFC 3597.
+func GenericRData(data []byte) []RData {
+	if len(data) == 0 {
+		return toRData(GenericRDataMarker, "0")
+	}
+	return toRData(GenericRDataMarker, strconv.Itoa(len(data)), formatHex(data))
+}
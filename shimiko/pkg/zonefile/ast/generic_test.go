@@ -0,0 +1,110 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+)
+
+func TestParseGenericType(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		expected uint16
+		ok       bool
+	}{
+		"TYPE65534": {expected: 65534, ok: true},
+		"type1":     {expected: 1, ok: true},
+		"TYPE0":     {expected: 0, ok: true},
+		"TYPE65536": {},
+		"TYPE":      {},
+		"TYPE1a":    {},
+		"TYPE-1":    {},
+		"A":         {},
+		"CLASS1":    {},
+	}
+
+	for input, tc := range tests {
+		got, ok := ast.ParseGenericType(input)
+		assert.Equal(t, tc.ok, ok, input)
+		assert.Equal(t, tc.expected, got, input)
+	}
+
+	class, ok := ast.ParseGenericClass("CLASS255")
+	assert.True(t, ok)
+	assert.Equal(t, uint16(255), class)
+	assert.Equal(t, "TYPE731", ast.GenericType(731))
+	assert.Equal(t, "CLASS3", ast.GenericClass(3))
+}
+
+func TestParseGenericRData(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input    string
+		expected []byte
+		errField string
+	}{
+		"single field": {
+			input:    `\# 4 C0000201`,
+			expected: []byte{192, 0, 2, 1},
+		},
+		"split hex": {
+			input:    `\# 4 c000 02 01`,
+			expected: []byte{192, 0, 2, 1},
+		},
+		"empty": {
+			input:    `\# 0`,
+			expected: []byte{},
+		},
+		"length mismatch": {
+			input:    `\# 3 C0000201`,
+			errField: "RDATA",
+		},
+		"bad hex": {
+			input:    `\# 1 ZZ`,
+			errField: "RDATA",
+		},
+		"missing length": {
+			input:    `\#`,
+			errField: "RDLENGTH",
+		},
+		"bad length": {
+			input:    `\# 65536`,
+			errField: "RDLENGTH",
+		},
+		"not generic": {
+			input:    `192.0.2.1`,
+			errField: "RDATA",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ast.ParseGenericRData("TYPE65534", ast.SplitRData(tc.input))
+
+			if tc.errField != "" {
+				var fieldErr *ast.RDataFieldError
+				if assert.ErrorAs(t, err, &fieldErr) {
+					assert.Equal(t, tc.errField, fieldErr.Field)
+				}
+				assert.ErrorIs(t, err, ast.ErrInvalidRData)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+
+			rendered, err := ast.ParseGenericRData("TYPE65534", ast.GenericRData(got))
+			require.NoError(t, err)
+			assert.Equal(t, got, rendered)
+		})
+	}
+
+	assert.Equal(t, []ast.RData{{Value: `\#`}, {Value: "2"}, {Value: "0AFF"}}, ast.GenericRData([]byte{10, 255}))
+}
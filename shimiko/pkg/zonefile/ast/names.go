@@ -0,0 +1,27 @@
+package ast
+
+// IsAbsoluteName reports whether name is fully qualified, i.e. ends with a
+// dot that is not escaped.
+func IsAbsoluteName(name string) bool {
+	if len(name) == 0 || name[len(name)-1] != '.' {
+		return false
+	}
+	return !isEscaped(name, len(name)-1)
+}
+
+// AbsoluteName resolves name against origin the same way a zone file does:
+// "@" is the origin itself, absolute names are returned as-is and relative
+// names have the origin appended. If origin is empty a relative name is
+// returned unchanged.
+func AbsoluteName(name string, origin string) string {
+	if name == "@" {
+		return origin
+	}
+	if IsAbsoluteName(name) || origin == "" {
+		return name
+	}
+	if origin == "." {
+		return name + "."
+	}
+	return name + "." + origin
+}
@@ -0,0 +1,936 @@
+package ast
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ttl"
+)
+
+var (
+	ErrInvalidRData         = errors.New("invalid RDATA")
+	ErrUnsupportedRDataType = errors.New("unsupported RDATA type")
+)
+
+// RDataFieldError is returned when a single RDATA field fails to parse or
+// validate. It unwraps to both ErrInvalidRData and the underlying cause.
+type RDataFieldError struct {
+	Type  string
+	Field string
+	Value string
+	Err   error
+}
+
+func (err *RDataFieldError) Error() string {
+	return fmt.Sprintf("invalid %s RDATA field %s '%s': %v", err.Type, err.Field, err.Value, err.Err)
+}
+
+func (err *RDataFieldError) Unwrap() []error {
+	return []error{ErrInvalidRData, err.Err}
+}
+
+func newFieldError(rrtype string, field string, value string, err error) *RDataFieldError {
+	return &RDataFieldError{
+		Type:  rrtype,
+		Field: field,
+		Value: value,
+		Err:   err,
+	}
+}
+
+// TypedRData is a parsed, typed view of the RDATA of a record.
+type TypedRData interface {
+	// RRType is the record type the RDATA belongs to, e.g. "MX".
+	RRType() string
+	// RData renders the typed RDATA back into presentation form.
+	RData() []RData
+	// Validate checks the semantic constraints of the RDATA that can't be
+	// expressed in its Go type, e.g. value ranges and digest lengths.
+	Validate() error
+}
+
+var typedRDataParsers = map[string]func([]RData) (TypedRData, error){
+	"SOA":    func(rdata []RData) (TypedRData, error) { return ParseSOA(rdata) },
+	"MX":     func(rdata []RData) (TypedRData, error) { return ParseMX(rdata) },
+	"SRV":    func(rdata []RData) (TypedRData, error) { return ParseSRV(rdata) },
+	"CAA":    func(rdata []RData) (TypedRData, error) { return ParseCAA(rdata) },
+	"TLSA":   func(rdata []RData) (TypedRData, error) { return ParseTLSA(rdata) },
+	"SSHFP":  func(rdata []RData) (TypedRData, error) { return ParseSSHFP(rdata) },
+	"SVCB":   func(rdata []RData) (TypedRData, error) { return ParseSVCB(rdata) },
+	"HTTPS":  func(rdata []RData) (TypedRData, error) { return ParseHTTPS(rdata) },
+	"NAPTR":  func(rdata []RData) (TypedRData, error) { return ParseNAPTR(rdata) },
+	"DS":     func(rdata []RData) (TypedRData, error) { return ParseDS(rdata) },
+	"TXT":    func(rdata []RData) (TypedRData, error) { return ParseTXT(rdata) },
+	"DNSKEY": func(rdata []RData) (TypedRData, error) { return ParseDNSKEY(rdata) },
+	"RRSIG":  func(rdata []RData) (TypedRData, error) { return ParseRRSIG(rdata) },
+	"NSEC":   func(rdata []RData) (TypedRData, error) { return ParseNSEC(rdata) },
+}
+
+// HasTypedRData reports whether ParseTypedRData supports rrtype.
+func HasTypedRData(rrtype string) bool {
+	_, ok := typedRDataParsers[strings.ToUpper(rrtype)]
+	return ok
+}
+
+// ParseTypedRData parses and validates rdata as the RDATA of a record of type
+// rrtype. ErrUnsupportedRDataType is returned for types without a typed view.
+func ParseTypedRData(rrtype string, rdata []RData) (TypedRData, error) {
+	parse, ok := typedRDataParsers[strings.ToUpper(rrtype)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedRDataType, rrtype)
+	}
+	typed, err := parse(rdata)
+	if err != nil {
+		return nil, err
+	}
+	return typed, typed.Validate()
+}
+
+// Typed parses the RDATA of the record into its typed view.
+func (rrecord RRecord) Typed() (TypedRData, error) {
+	return ParseTypedRData(rrecord.Type, rrecord.RData)
+}
+
+// WithTypedRData returns a copy of the record with its RDATA replaced by the
+// rendered typed RDATA. If the number of fields is unchanged the layout of
+// the existing fields (line breaks, comments and grouping) is kept.
+func (rrecord RRecord) WithTypedRData(typed TypedRData) RRecord {
+	rdata := typed.RData()
+	if len(rdata) == len(rrecord.RData) {
+		updated := slices.Clone(rrecord.RData)
+		for i := range updated {
+			updated[i].Value = rdata[i].Value
+		}
+		rrecord.RData = updated
+		return rrecord
+	}
+	rrecord.RData = rdata
+	rrecord.Group = nil
+	return rrecord
+}
+
+// SplitRData splits a single presentation-form RDATA string such as
+// `10 mail.example.com.` into its fields. Quoted strings and escaped
+// characters are kept intact. Like in the lexer a field that starts with a
+// quote ends with the closing quote.
+func SplitRData(value string) []RData {
+	rdata := []RData{}
+	field := []byte{}
+	inQuote := false
+	escaped := false
+	inField := false
+
+	flush := func() {
+		if inField {
+			rdata = append(rdata, RData{Value: string(field)})
+		}
+		field = []byte{}
+		inField = false
+	}
+
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case ch == '"':
+			inQuote = !inQuote
+			if !inQuote && field[0] == '"' {
+				field = append(field, ch)
+				flush()
+				continue
+			}
+		case !inQuote && (ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'):
+			flush()
+			continue
+		}
+		field = append(field, ch)
+		inField = true
+	}
+	flush()
+
+	return rdata
+}
+
+func rdataValues(rdata []RData) []string {
+	values := make([]string, len(rdata))
+	for i, r := range rdata {
+		values[i] = r.Value
+	}
+	return values
+}
+
+func toRData(values ...string) []RData {
+	rdata := make([]RData, len(values))
+	for i, value := range values {
+		rdata[i] = RData{Value: value}
+	}
+	return rdata
+}
+
+func checkFieldCount(rrtype string, rdata []RData, minimum int, maximum int) error {
+	if len(rdata) < minimum || (maximum >= 0 && len(rdata) > maximum) {
+		expected := strconv.Itoa(minimum)
+		if maximum < 0 {
+			expected = "at least " + expected
+		} else if maximum != minimum {
+			expected = fmt.Sprintf("%d-%d", minimum, maximum)
+		}
+		return fmt.Errorf("%w: %s record needs %s fields, got %d", ErrInvalidRData, rrtype, expected, len(rdata))
+	}
+	return nil
+}
+
+func parseUint(rrtype string, field string, value string, bits int) (uint64, error) {
+	n, err := strconv.ParseUint(value, 10, bits)
+	if err != nil {
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) {
+			err = numErr.Err
+		}
+		return 0, newFieldError(rrtype, field, value, err)
+	}
+	return n, nil
+}
+
+func parseUint8(rrtype string, field string, value string) (uint8, error) {
+	n, err := parseUint(rrtype, field, value, 8)
+	return uint8(n), err
+}
+
+func parseUint16(rrtype string, field string, value string) (uint16, error) {
+	n, err := parseUint(rrtype, field, value, 16)
+	return uint16(n), err
+}
+
+func parseUint32(rrtype string, field string, value string) (uint32, error) {
+	n, err := parseUint(rrtype, field, value, 32)
+	return uint32(n), err
+}
+
+// parseSeconds parses a time field in plain seconds or with BIND units.
+func parseSeconds(rrtype string, field string, value string) (time.Duration, error) {
+	d, err := ttl.Parse(value)
+	if err != nil {
+		return 0, newFieldError(rrtype, field, value, err)
+	}
+	return d, nil
+}
+
+func formatSeconds(d time.Duration) string {
+	return ttl.FormatSeconds(d)
+}
+
+func parseHex(rrtype string, field string, rdata []RData) ([]byte, error) {
+	value := strings.Join(rdataValues(rdata), "")
+	b, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, newFieldError(rrtype, field, value, err)
+	}
+	return b, nil
+}
+
+func formatHex(b []byte) string {
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+func parseName(rrtype string, field string, value string) (string, error) {
+	if err := ValidateDomainName(value); err != nil {
+		return "", newFieldError(rrtype, field, value, err)
+	}
+	return value, nil
+}
+
+var (
+	errEmptyName         = errors.New("domain name is empty")
+	errEmptyLabel        = errors.New("domain name has an empty label")
+	errLabelTooLong      = errors.New("domain name label exceeds 63 octets")
+	errNameTooLong       = errors.New("domain name exceeds 255 octets")
+	errUnterminatedQuote = errors.New("unterminated quoted string")
+)
+
+// ValidateDomainName checks that name is a syntactically valid presentation
+// form domain name, absolute or relative.
+func ValidateDomainName(name string) error {
+	if name == "" {
+		return errEmptyName
+	}
+	if name == "@" || name == "." {
+		return nil
+	}
+	trimmed := name
+	if IsAbsoluteName(trimmed) {
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+	total := 1
+	label := 0
+	for i := 0; i < len(trimmed); i++ {
+		ch := trimmed[i]
+		if ch == '\\' && i+1 < len(trimmed) {
+			i++
+			if i+2 < len(trimmed) && isDigitByte(trimmed[i]) && isDigitByte(trimmed[i+1]) && isDigitByte(trimmed[i+2]) {
+				i += 2
+			}
+			label++
+			continue
+		}
+		if ch == '.' {
+			if label == 0 {
+				return errEmptyLabel
+			}
+			total += label + 1
+			label = 0
+			continue
+		}
+		label++
+		if label > 63 {
+			return errLabelTooLong
+		}
+	}
+	if label == 0 {
+		return errEmptyLabel
+	}
+	if label > 63 {
+		return errLabelTooLong
+	}
+	total += label + 1
+	if total > 255 {
+		return errNameTooLong
+	}
+	return nil
+}
+
+// isEscaped reports whether the byte at index i of s is preceded by an odd
+// number of backslashes.
+func isEscaped(s string, i int) bool {
+	backslashes := 0
+	for j := i - 1; j >= 0 && s[j] == '\\'; j-- {
+		backslashes++
+	}
+	return backslashes%2 == 1
+}
+
+func isDigitByte(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// Unquote returns the contents of a presentation-form character-string with
+// the surrounding quotes removed and its escapes decoded, see
+// ParseCharacterString.
+func Unquote(value string) (string, error) {
+	data, err := ParseCharacterString(value)
+	return string(data), err
+}
+
+// Quote renders s as a quoted presentation-form character-string, see
+// FormatCharacterString.
+func Quote(s string) string {
+	return FormatCharacterString([]byte(s))
+}
+
+// SOA is the typed RDATA of an SOA record (RFC 1035 section 3.3.13).
+type SOA struct {
+	MName   string
+	RName   string
+	Serial  uint32
+	Refresh time.Duration
+	Retry   time.Duration
+	Expire  time.Duration
+	Minimum time.Duration
+}
+
+func ParseSOA(rdata []RData) (SOA, error) {
+	soa := SOA{}
+	if err := checkFieldCount("SOA", rdata, 7, 7); err != nil {
+		return soa, err
+	}
+	var err error
+	if soa.MName, err = parseName("SOA", "MNAME", rdata[0].Value); err != nil {
+		return soa, err
+	}
+	if soa.RName, err = parseName("SOA", "RNAME", rdata[1].Value); err != nil {
+		return soa, err
+	}
+	if soa.Serial, err = parseUint32("SOA", "SERIAL", rdata[2].Value); err != nil {
+		return soa, err
+	}
+	if soa.Refresh, err = parseSeconds("SOA", "REFRESH", rdata[3].Value); err != nil {
+		return soa, err
+	}
+	if soa.Retry, err = parseSeconds("SOA", "RETRY", rdata[4].Value); err != nil {
+		return soa, err
+	}
+	if soa.Expire, err = parseSeconds("SOA", "EXPIRE", rdata[5].Value); err != nil {
+		return soa, err
+	}
+	if soa.Minimum, err = parseSeconds("SOA", "MINIMUM", rdata[6].Value); err != nil {
+		return soa, err
+	}
+	return soa, nil
+}
+
+func (soa SOA) RRType() string {
+	return "SOA"
+}
+
+func (soa SOA) RData() []RData {
+	return toRData(
+		soa.MName,
+		soa.RName,
+		strconv.FormatUint(uint64(soa.Serial), 10),
+		formatSeconds(soa.Refresh),
+		formatSeconds(soa.Retry),
+		formatSeconds(soa.Expire),
+		formatSeconds(soa.Minimum),
+	)
+}
+
+func (soa SOA) Validate() error {
+	if err := ValidateDomainName(soa.MName); err != nil {
+		return newFieldError("SOA", "MNAME", soa.MName, err)
+	}
+	if err := ValidateDomainName(soa.RName); err != nil {
+		return newFieldError("SOA", "RNAME", soa.RName, err)
+	}
+	return nil
+}
+
+// MX is the typed RDATA of an MX record (RFC 1035 section 3.3.9).
+type MX struct {
+	Preference uint16
+	Exchange   string
+}
+
+func ParseMX(rdata []RData) (MX, error) {
+	mx := MX{}
+	if err := checkFieldCount("MX", rdata, 2, 2); err != nil {
+		return mx, err
+	}
+	var err error
+	if mx.Preference, err = parseUint16("MX", "PREFERENCE", rdata[0].Value); err != nil {
+		return mx, err
+	}
+	if mx.Exchange, err = parseName("MX", "EXCHANGE", rdata[1].Value); err != nil {
+		return mx, err
+	}
+	return mx, nil
+}
+
+func (mx MX) RRType() string {
+	return "MX"
+}
+
+func (mx MX) RData() []RData {
+	return toRData(strconv.FormatUint(uint64(mx.Preference), 10), mx.Exchange)
+}
+
+func (mx MX) Validate() error {
+	if err := ValidateDomainName(mx.Exchange); err != nil {
+		return newFieldError("MX", "EXCHANGE", mx.Exchange, err)
+	}
+	return nil
+}
+
+// SRV is the typed RDATA of an SRV record (RFC 2782).
+type SRV struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+func ParseSRV(rdata []RData) (SRV, error) {
+	srv := SRV{}
+	if err := checkFieldCount("SRV", rdata, 4, 4); err != nil {
+		return srv, err
+	}
+	var err error
+	if srv.Priority, err = parseUint16("SRV", "PRIORITY", rdata[0].Value); err != nil {
+		return srv, err
+	}
+	if srv.Weight, err = parseUint16("SRV", "WEIGHT", rdata[1].Value); err != nil {
+		return srv, err
+	}
+	if srv.Port, err = parseUint16("SRV", "PORT", rdata[2].Value); err != nil {
+		return srv, err
+	}
+	if srv.Target, err = parseName("SRV", "TARGET", rdata[3].Value); err != nil {
+		return srv, err
+	}
+	return srv, nil
+}
+
+func (srv SRV) RRType() string {
+	return "SRV"
+}
+
+func (srv SRV) RData() []RData {
+	return toRData(
+		strconv.FormatUint(uint64(srv.Priority), 10),
+		strconv.FormatUint(uint64(srv.Weight), 10),
+		strconv.FormatUint(uint64(srv.Port), 10),
+		srv.Target,
+	)
+}
+
+func (srv SRV) Validate() error {
+	if err := ValidateDomainName(srv.Target); err != nil {
+		return newFieldError("SRV", "TARGET", srv.Target, err)
+	}
+	return nil
+}
+
+var caaTagRegex = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+// CAA is the typed RDATA of a CAA record (RFC 8659).
+type CAA struct {
+	Flags uint8
+	Tag   string
+	Value string
+}
+
+func ParseCAA(rdata []RData) (CAA, error) {
+	caa := CAA{}
+	if err := checkFieldCount("CAA", rdata, 3, 3); err != nil {
+		return caa, err
+	}
+	var err error
+	if caa.Flags, err = parseUint8("CAA", "FLAGS", rdata[0].Value); err != nil {
+		return caa, err
+	}
+	caa.Tag = rdata[1].Value
+	if caa.Value, err = Unquote(rdata[2].Value); err != nil {
+		return caa, newFieldError("CAA", "VALUE", rdata[2].Value, err)
+	}
+	return caa, nil
+}
+
+func (caa CAA) RRType() string {
+	return "CAA"
+}
+
+func (caa CAA) RData() []RData {
+	return toRData(strconv.FormatUint(uint64(caa.Flags), 10), caa.Tag, Quote(caa.Value))
+}
+
+func (caa CAA) Validate() error {
+	if !caaTagRegex.MatchString(caa.Tag) || len(caa.Tag) > 15 {
+		return newFieldError("CAA", "TAG", caa.Tag, errors.New("tag must be 1-15 alphanumeric characters"))
+	}
+	return nil
+}
+
+// TLSA is the typed RDATA of a TLSA record (RFC 6698).
+type TLSA struct {
+	Usage        uint8
+	Selector     uint8
+	MatchingType uint8
+	Certificate  []byte
+}
+
+func ParseTLSA(rdata []RData) (TLSA, error) {
+	tlsa := TLSA{}
+	if err := checkFieldCount("TLSA", rdata, 4, -1); err != nil {
+		return tlsa, err
+	}
+	var err error
+	if tlsa.Usage, err = parseUint8("TLSA", "USAGE", rdata[0].Value); err != nil {
+		return tlsa, err
+	}
+	if tlsa.Selector, err = parseUint8("TLSA", "SELECTOR", rdata[1].Value); err != nil {
+		return tlsa, err
+	}
+	if tlsa.MatchingType, err = parseUint8("TLSA", "MATCHING-TYPE", rdata[2].Value); err != nil {
+		return tlsa, err
+	}
+	if tlsa.Certificate, err = parseHex("TLSA", "CERTIFICATE", rdata[3:]); err != nil {
+		return tlsa, err
+	}
+	return tlsa, nil
+}
+
+func (tlsa TLSA) RRType() string {
+	return "TLSA"
+}
+
+func (tlsa TLSA) RData() []RData {
+	return toRData(
+		strconv.FormatUint(uint64(tlsa.Usage), 10),
+		strconv.FormatUint(uint64(tlsa.Selector), 10),
+		strconv.FormatUint(uint64(tlsa.MatchingType), 10),
+		formatHex(tlsa.Certificate),
+	)
+}
+
+func (tlsa TLSA) Validate() error {
+	if tlsa.Usage > 3 {
+		return newFieldError("TLSA", "USAGE", strconv.Itoa(int(tlsa.Usage)), errors.New("must be 0-3"))
+	}
+	if tlsa.Selector > 1 {
+		return newFieldError("TLSA", "SELECTOR", strconv.Itoa(int(tlsa.Selector)), errors.New("must be 0 or 1"))
+	}
+	if tlsa.MatchingType > 2 {
+		return newFieldError("TLSA", "MATCHING-TYPE", strconv.Itoa(int(tlsa.MatchingType)), errors.New("must be 0-2"))
+	}
+	expected := map[uint8]int{1: 32, 2: 64}[tlsa.MatchingType]
+	if expected != 0 && len(tlsa.Certificate) != expected {
+		return newFieldError("TLSA", "CERTIFICATE", formatHex(tlsa.Certificate), fmt.Errorf(
+			"digest for matching type %d must be %d octets, got %d",
+			tlsa.MatchingType,
+			expected,
+			len(tlsa.Certificate),
+		))
+	}
+	if len(tlsa.Certificate) == 0 {
+		return newFieldError("TLSA", "CERTIFICATE", "", errors.New("must not be empty"))
+	}
+	return nil
+}
+
+// SSHFP is the typed RDATA of an SSHFP record (RFC 4255).
+type SSHFP struct {
+	Algorithm   uint8
+	Type        uint8
+	Fingerprint []byte
+}
+
+func ParseSSHFP(rdata []RData) (SSHFP, error) {
+	sshfp := SSHFP{}
+	if err := checkFieldCount("SSHFP", rdata, 3, -1); err != nil {
+		return sshfp, err
+	}
+	var err error
+	if sshfp.Algorithm, err = parseUint8("SSHFP", "ALGORITHM", rdata[0].Value); err != nil {
+		return sshfp, err
+	}
+	if sshfp.Type, err = parseUint8("SSHFP", "TYPE", rdata[1].Value); err != nil {
+		return sshfp, err
+	}
+	if sshfp.Fingerprint, err = parseHex("SSHFP", "FINGERPRINT", rdata[2:]); err != nil {
+		return sshfp, err
+	}
+	return sshfp, nil
+}
+
+func (sshfp SSHFP) RRType() string {
+	return "SSHFP"
+}
+
+func (sshfp SSHFP) RData() []RData {
+	return toRData(
+		strconv.FormatUint(uint64(sshfp.Algorithm), 10),
+		strconv.FormatUint(uint64(sshfp.Type), 10),
+		formatHex(sshfp.Fingerprint),
+	)
+}
+
+func (sshfp SSHFP) Validate() error {
+	if !slices.Contains([]uint8{1, 2, 3, 4, 6}, sshfp.Algorithm) {
+		return newFieldError("SSHFP", "ALGORITHM", strconv.Itoa(int(sshfp.Algorithm)), errors.New("unknown algorithm"))
+	}
+	expected, ok := map[uint8]int{1: 20, 2: 32}[sshfp.Type]
+	if !ok {
+		return newFieldError("SSHFP", "TYPE", strconv.Itoa(int(sshfp.Type)), errors.New("unknown fingerprint type"))
+	}
+	if len(sshfp.Fingerprint) != expected {
+		return newFieldError("SSHFP", "FINGERPRINT", formatHex(sshfp.Fingerprint), fmt.Errorf(
+			"fingerprint for type %d must be %d octets, got %d",
+			sshfp.Type,
+			expected,
+			len(sshfp.Fingerprint),
+		))
+	}
+	return nil
+}
+
+// SVCParam is a single SvcParam of an SVCB or HTTPS record. Value is the
+// unquoted value and is empty for keys without a value.
+type SVCParam struct {
+	Key   string
+	Value string
+}
+
+var svcbKeyRegex = regexp.MustCompile(`^key[0-9]+$`)
+
+var svcbKeys = []string{
+	"mandatory",
+	"alpn",
+	"no-default-alpn",
+	"port",
+	"ipv4hint",
+	"ech",
+	"ipv6hint",
+	"dohpath",
+	"ohttp",
+}
+
+// SVCB is the typed RDATA of an SVCB record (RFC 9460).
+type SVCB struct {
+	Priority uint16
+	Target   string
+	Params   []SVCParam
+}
+
+func ParseSVCB(rdata []RData) (SVCB, error) {
+	return parseSVCB("SVCB", rdata)
+}
+
+func parseSVCB(rrtype string, rdata []RData) (SVCB, error) {
+	svcb := SVCB{
+		Params: []SVCParam{},
+	}
+	if err := checkFieldCount(rrtype, rdata, 2, -1); err != nil {
+		return svcb, err
+	}
+	var err error
+	if svcb.Priority, err = parseUint16(rrtype, "SVCPRIORITY", rdata[0].Value); err != nil {
+		return svcb, err
+	}
+	if svcb.Target, err = parseName(rrtype, "TARGETNAME", rdata[1].Value); err != nil {
+		return svcb, err
+	}
+	for _, field := range rdata[2:] {
+		key, value, hasValue := strings.Cut(field.Value, "=")
+		param := SVCParam{
+			Key: key,
+		}
+		if hasValue {
+			param.Value, err = Unquote(value)
+			if err != nil {
+				return svcb, newFieldError(rrtype, key, value, err)
+			}
+		}
+		svcb.Params = append(svcb.Params, param)
+	}
+	return svcb, nil
+}
+
+func (svcb SVCB) RRType() string {
+	return "SVCB"
+}
+
+func (svcb SVCB) RData() []RData {
+	values := []string{
+		strconv.FormatUint(uint64(svcb.Priority), 10),
+		svcb.Target,
+	}
+	for _, param := range svcb.Params {
+		if param.Value == "" {
+			values = append(values, param.Key)
+			continue
+		}
+		value := param.Value
+		if strings.ContainsAny(value, " \t\";") {
+			value = Quote(value)
+		}
+		values = append(values, param.Key+"="+value)
+	}
+	return toRData(values...)
+}
+
+func (svcb SVCB) Validate() error {
+	return svcb.validate("SVCB")
+}
+
+func (svcb SVCB) validate(rrtype string) error {
+	if err := ValidateDomainName(svcb.Target); err != nil {
+		return newFieldError(rrtype, "TARGETNAME", svcb.Target, err)
+	}
+	if svcb.Priority == 0 && len(svcb.Params) > 0 {
+		return newFieldError(rrtype, "SVCPARAMS", svcb.Params[0].Key, errors.New("AliasMode records (priority 0) must not have SvcParams"))
+	}
+	seen := map[string]bool{}
+	for _, param := range svcb.Params {
+		if !slices.Contains(svcbKeys, param.Key) && !svcbKeyRegex.MatchString(param.Key) {
+			return newFieldError(rrtype, param.Key, param.Value, errors.New("unknown SvcParamKey"))
+		}
+		if seen[param.Key] {
+			return newFieldError(rrtype, param.Key, param.Value, errors.New("duplicate SvcParamKey"))
+		}
+		seen[param.Key] = true
+		switch param.Key {
+		case "port":
+			if _, err := strconv.ParseUint(param.Value, 10, 16); err != nil {
+				return newFieldError(rrtype, param.Key, param.Value, errors.New("must be a port number"))
+			}
+		case "ipv4hint", "ipv6hint":
+			for _, hint := range strings.Split(param.Value, ",") {
+				addr, err := netip.ParseAddr(hint)
+				if err != nil {
+					return newFieldError(rrtype, param.Key, param.Value, err)
+				}
+				if param.Key == "ipv4hint" != addr.Is4() {
+					return newFieldError(rrtype, param.Key, param.Value, fmt.Errorf("address family mismatch for %s", hint))
+				}
+			}
+		case "no-default-alpn":
+			if param.Value != "" {
+				return newFieldError(rrtype, param.Key, param.Value, errors.New("must not have a value"))
+			}
+		case "mandatory", "alpn":
+			if param.Value == "" {
+				return newFieldError(rrtype, param.Key, param.Value, errors.New("must have a value"))
+			}
+		case "ech":
+			if _, err := base64.StdEncoding.DecodeString(param.Value); err != nil {
+				return newFieldError(rrtype, param.Key, param.Value, err)
+			}
+		}
+	}
+	return nil
+}
+
+// HTTPS is the typed RDATA of an HTTPS record, which shares its format with
+// SVCB (RFC 9460).
+type HTTPS struct {
+	SVCB
+}
+
+func ParseHTTPS(rdata []RData) (HTTPS, error) {
+	svcb, err := parseSVCB("HTTPS", rdata)
+	return HTTPS{SVCB: svcb}, err
+}
+
+func (https HTTPS) RRType() string {
+	return "HTTPS"
+}
+
+func (https HTTPS) Validate() error {
+	return https.validate("HTTPS")
+}
+
+// NAPTR is the typed RDATA of a NAPTR record (RFC 3403). Flags, Services and
+// Regexp hold the unquoted character-strings.
+type NAPTR struct {
+	Order       uint16
+	Preference  uint16
+	Flags       string
+	Services    string
+	Regexp      string
+	Replacement string
+}
+
+func ParseNAPTR(rdata []RData) (NAPTR, error) {
+	naptr := NAPTR{}
+	if err := checkFieldCount("NAPTR", rdata, 6, 6); err != nil {
+		return naptr, err
+	}
+	var err error
+	if naptr.Order, err = parseUint16("NAPTR", "ORDER", rdata[0].Value); err != nil {
+		return naptr, err
+	}
+	if naptr.Preference, err = parseUint16("NAPTR", "PREFERENCE", rdata[1].Value); err != nil {
+		return naptr, err
+	}
+	if naptr.Flags, err = Unquote(rdata[2].Value); err != nil {
+		return naptr, newFieldError("NAPTR", "FLAGS", rdata[2].Value, err)
+	}
+	if naptr.Services, err = Unquote(rdata[3].Value); err != nil {
+		return naptr, newFieldError("NAPTR", "SERVICES", rdata[3].Value, err)
+	}
+	if naptr.Regexp, err = Unquote(rdata[4].Value); err != nil {
+		return naptr, newFieldError("NAPTR", "REGEXP", rdata[4].Value, err)
+	}
+	if naptr.Replacement, err = parseName("NAPTR", "REPLACEMENT", rdata[5].Value); err != nil {
+		return naptr, err
+	}
+	return naptr, nil
+}
+
+func (naptr NAPTR) RRType() string {
+	return "NAPTR"
+}
+
+func (naptr NAPTR) RData() []RData {
+	return toRData(
+		strconv.FormatUint(uint64(naptr.Order), 10),
+		strconv.FormatUint(uint64(naptr.Preference), 10),
+		Quote(naptr.Flags),
+		Quote(naptr.Services),
+		Quote(naptr.Regexp),
+		naptr.Replacement,
+	)
+}
+
+func (naptr NAPTR) Validate() error {
+	for _, flag := range naptr.Flags {
+		if !(flag >= 'a' && flag <= 'z' || flag >= 'A' && flag <= 'Z' || flag >= '0' && flag <= '9') {
+			return newFieldError("NAPTR", "FLAGS", naptr.Flags, errors.New("flags must be alphanumeric"))
+		}
+	}
+	if naptr.Regexp != "" && naptr.Replacement != "." {
+		return newFieldError("NAPTR", "REPLACEMENT", naptr.Replacement, errors.New("must be '.' when REGEXP is set"))
+	}
+	if err := ValidateDomainName(naptr.Replacement); err != nil {
+		return newFieldError("NAPTR", "REPLACEMENT", naptr.Replacement, err)
+	}
+	return nil
+}
+
+// DS is the typed RDATA of a DS record (RFC 4034 section 5).
+type DS struct {
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     []byte
+}
+
+func ParseDS(rdata []RData) (DS, error) {
+	ds := DS{}
+	if err := checkFieldCount("DS", rdata, 4, -1); err != nil {
+		return ds, err
+	}
+	var err error
+	if ds.KeyTag, err = parseUint16("DS", "KEY-TAG", rdata[0].Value); err != nil {
+		return ds, err
+	}
+	if ds.Algorithm, err = parseUint8("DS", "ALGORITHM", rdata[1].Value); err != nil {
+		return ds, err
+	}
+	if ds.DigestType, err = parseUint8("DS", "DIGEST-TYPE", rdata[2].Value); err != nil {
+		return ds, err
+	}
+	if ds.Digest, err = parseHex("DS", "DIGEST", rdata[3:]); err != nil {
+		return ds, err
+	}
+	return ds, nil
+}
+
+func (ds DS) RRType() string {
+	return "DS"
+}
+
+func (ds DS) RData() []RData {
+	return toRData(
+		strconv.FormatUint(uint64(ds.KeyTag), 10),
+		strconv.FormatUint(uint64(ds.Algorithm), 10),
+		strconv.FormatUint(uint64(ds.DigestType), 10),
+		formatHex(ds.Digest),
+	)
+}
+
+func (ds DS) Validate() error {
+	expected, ok := map[uint8]int{1: 20, 2: 32, 4: 48}[ds.DigestType]
+	if !ok {
+		return newFieldError("DS", "DIGEST-TYPE", strconv.Itoa(int(ds.DigestType)), errors.New("unknown digest type"))
+	}
+	if len(ds.Digest) != expected {
+		return newFieldError("DS", "DIGEST", formatHex(ds.Digest), fmt.Errorf(
+			"digest for type %d must be %d octets, got %d",
+			ds.DigestType,
+			expected,
+			len(ds.Digest),
+		))
+	}
+	return nil
+}
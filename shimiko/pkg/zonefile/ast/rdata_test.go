@@ -0,0 +1,395 @@
+package ast_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+)
+
+func TestParseTypedRData(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		rrtype     string
+		input      string
+		expected   ast.TypedRData
+		rendered   string
+		errField   string
+		errContain string
+	}{
+		"soa": {
+			rrtype: "SOA",
+			input:  "ns1.example.com. hostmaster.example.com. 2024010101 3600 600 604800 86400",
+			expected: ast.SOA{
+				MName:   "ns1.example.com.",
+				RName:   "hostmaster.example.com.",
+				Serial:  2024010101,
+				Refresh: 3600 * time.Second,
+				Retry:   600 * time.Second,
+				Expire:  604800 * time.Second,
+				Minimum: 86400 * time.Second,
+			},
+		},
+		"soa with ttl units": {
+			rrtype: "SOA",
+			input:  "ns1.example.com. hostmaster.example.com. 1 1h 10M 1w 1D",
+			expected: ast.SOA{
+				MName:   "ns1.example.com.",
+				RName:   "hostmaster.example.com.",
+				Serial:  1,
+				Refresh: 3600 * time.Second,
+				Retry:   600 * time.Second,
+				Expire:  604800 * time.Second,
+				Minimum: 86400 * time.Second,
+			},
+			rendered: "ns1.example.com. hostmaster.example.com. 1 3600 600 604800 86400",
+		},
+		"soa bad timer": {
+			rrtype:   "SOA",
+			input:    "ns1.example.com. hostmaster.example.com. 1 1h30 600 604800 86400",
+			errField: "REFRESH",
+		},
+		"soa bad serial": {
+			rrtype:   "SOA",
+			input:    "ns1.example.com. hostmaster.example.com. 99999999999 3600 600 604800 86400",
+			errField: "SERIAL",
+		},
+		"soa missing fields": {
+			rrtype:     "SOA",
+			input:      "ns1.example.com. hostmaster.example.com.",
+			errContain: "SOA record needs 7 fields, got 2",
+		},
+		"mx": {
+			rrtype: "MX",
+			input:  "10 mail.example.com.",
+			expected: ast.MX{
+				Preference: 10,
+				Exchange:   "mail.example.com.",
+			},
+		},
+		"mx bad preference": {
+			rrtype:   "MX",
+			input:    "high mail.example.com.",
+			errField: "PREFERENCE",
+		},
+		"mx empty label": {
+			rrtype:   "MX",
+			input:    "10 mail..example.com.",
+			errField: "EXCHANGE",
+		},
+		"srv": {
+			rrtype: "SRV",
+			input:  "0 5 5060 sip.example.com.",
+			expected: ast.SRV{
+				Priority: 0,
+				Weight:   5,
+				Port:     5060,
+				Target:   "sip.example.com.",
+			},
+		},
+		"srv bad port": {
+			rrtype:   "SRV",
+			input:    "0 5 65536 sip.example.com.",
+			errField: "PORT",
+		},
+		"caa": {
+			rrtype: "CAA",
+			input:  `0 issue "letsencrypt.org"`,
+			expected: ast.CAA{
+				Flags: 0,
+				Tag:   "issue",
+				Value: "letsencrypt.org",
+			},
+		},
+		"caa with escaped quote": {
+			rrtype: "CAA",
+			input:  `128 iodef "mailto:\"dns\"@example.com"`,
+			expected: ast.CAA{
+				Flags: 128,
+				Tag:   "iodef",
+				Value: `mailto:"dns"@example.com`,
+			},
+		},
+		"caa bad tag": {
+			rrtype:   "CAA",
+			input:    `0 is-sue "letsencrypt.org"`,
+			errField: "TAG",
+		},
+		"tlsa": {
+			rrtype: "TLSA",
+			input:  "3 1 1 0C72AC70B745AC19998811B131D662C9AC69DBDBE7CB23E5B514B56664C5D3D6",
+			expected: ast.TLSA{
+				Usage:        3,
+				Selector:     1,
+				MatchingType: 1,
+				Certificate: []byte{
+					0x0c, 0x72, 0xac, 0x70, 0xb7, 0x45, 0xac, 0x19, 0x99, 0x88, 0x11, 0xb1, 0x31, 0xd6, 0x62, 0xc9,
+					0xac, 0x69, 0xdb, 0xdb, 0xe7, 0xcb, 0x23, 0xe5, 0xb5, 0x14, 0xb5, 0x66, 0x64, 0xc5, 0xd3, 0xd6,
+				},
+			},
+		},
+		"tlsa split digest": {
+			rrtype:   "TLSA",
+			input:    "3 1 1 0C72AC70B745AC19998811B131D662C9 AC69DBDBE7CB23E5B514B56664C5D3D6",
+			rendered: "3 1 1 0C72AC70B745AC19998811B131D662C9AC69DBDBE7CB23E5B514B56664C5D3D6",
+			expected: ast.TLSA{
+				Usage:        3,
+				Selector:     1,
+				MatchingType: 1,
+				Certificate: []byte{
+					0x0c, 0x72, 0xac, 0x70, 0xb7, 0x45, 0xac, 0x19, 0x99, 0x88, 0x11, 0xb1, 0x31, 0xd6, 0x62, 0xc9,
+					0xac, 0x69, 0xdb, 0xdb, 0xe7, 0xcb, 0x23, 0xe5, 0xb5, 0x14, 0xb5, 0x66, 0x64, 0xc5, 0xd3, 0xd6,
+				},
+			},
+		},
+		"tlsa short digest": {
+			rrtype:   "TLSA",
+			input:    "3 1 1 0C72AC70",
+			errField: "CERTIFICATE",
+		},
+		"tlsa bad usage": {
+			rrtype:   "TLSA",
+			input:    "4 1 1 0C72AC70B745AC19998811B131D662C9AC69DBDBE7CB23E5B514B56664C5D3D6",
+			errField: "USAGE",
+		},
+		"sshfp": {
+			rrtype: "SSHFP",
+			input:  "4 2 9C4B7DB3B8A0B1F4A7E6C9B3D6A8E3F1C2B4A6D8E0F2A4C6E8A0B2C4D6E8F0A2",
+			expected: ast.SSHFP{
+				Algorithm: 4,
+				Type:      2,
+				Fingerprint: []byte{
+					0x9c, 0x4b, 0x7d, 0xb3, 0xb8, 0xa0, 0xb1, 0xf4, 0xa7, 0xe6, 0xc9, 0xb3, 0xd6, 0xa8, 0xe3, 0xf1,
+					0xc2, 0xb4, 0xa6, 0xd8, 0xe0, 0xf2, 0xa4, 0xc6, 0xe8, 0xa0, 0xb2, 0xc4, 0xd6, 0xe8, 0xf0, 0xa2,
+				},
+			},
+		},
+		"sshfp bad hex": {
+			rrtype:   "SSHFP",
+			input:    "4 2 XYZ",
+			errField: "FINGERPRINT",
+		},
+		"sshfp bad algorithm": {
+			rrtype:   "SSHFP",
+			input:    "5 1 9C4B7DB3B8A0B1F4A7E6C9B3D6A8E3F1C2B4A6D8",
+			errField: "ALGORITHM",
+		},
+		"svcb": {
+			rrtype: "SVCB",
+			input:  `1 svc.example.com. alpn=h2,h3 port=8443 ipv4hint=192.0.2.1,192.0.2.2`,
+			expected: ast.SVCB{
+				Priority: 1,
+				Target:   "svc.example.com.",
+				Params: []ast.SVCParam{
+					{Key: "alpn", Value: "h2,h3"},
+					{Key: "port", Value: "8443"},
+					{Key: "ipv4hint", Value: "192.0.2.1,192.0.2.2"},
+				},
+			},
+		},
+		"https alias mode": {
+			rrtype: "HTTPS",
+			input:  `0 www.example.com.`,
+			expected: ast.HTTPS{
+				SVCB: ast.SVCB{
+					Priority: 0,
+					Target:   "www.example.com.",
+					Params:   []ast.SVCParam{},
+				},
+			},
+		},
+		"https quoted value": {
+			rrtype:   "HTTPS",
+			input:    `1 . alpn="h2,h3" no-default-alpn`,
+			rendered: `1 . alpn=h2,h3 no-default-alpn`,
+			expected: ast.HTTPS{
+				SVCB: ast.SVCB{
+					Priority: 1,
+					Target:   ".",
+					Params: []ast.SVCParam{
+						{Key: "alpn", Value: "h2,h3"},
+						{Key: "no-default-alpn"},
+					},
+				},
+			},
+		},
+		"https alias mode with params": {
+			rrtype:   "HTTPS",
+			input:    `0 www.example.com. alpn=h2`,
+			errField: "SVCPARAMS",
+		},
+		"https bad ipv6hint": {
+			rrtype:   "HTTPS",
+			input:    `1 . ipv6hint=192.0.2.1`,
+			errField: "ipv6hint",
+		},
+		"svcb unknown key": {
+			rrtype:   "SVCB",
+			input:    `1 . foo=bar`,
+			errField: "foo",
+		},
+		"naptr": {
+			rrtype: "NAPTR",
+			input:  `100 10 "U" "E2U+sip" "!^.*$!sip:info@example.com!" .`,
+			expected: ast.NAPTR{
+				Order:       100,
+				Preference:  10,
+				Flags:       "U",
+				Services:    "E2U+sip",
+				Regexp:      "!^.*$!sip:info@example.com!",
+				Replacement: ".",
+			},
+		},
+		"naptr replacement with regexp": {
+			rrtype:   "NAPTR",
+			input:    `100 10 "U" "E2U+sip" "!^.*$!sip:info@example.com!" sip.example.com.`,
+			errField: "REPLACEMENT",
+		},
+		"ds": {
+			rrtype: "DS",
+			input:  "60485 5 1 2BB183AF5F22588179A53B0A98631FAD1A292118",
+			expected: ast.DS{
+				KeyTag:     60485,
+				Algorithm:  5,
+				DigestType: 1,
+				Digest: []byte{
+					0x2b, 0xb1, 0x83, 0xaf, 0x5f, 0x22, 0x58, 0x81, 0x79, 0xa5,
+					0x3b, 0x0a, 0x98, 0x63, 0x1f, 0xad, 0x1a, 0x29, 0x21, 0x18,
+				},
+			},
+		},
+		"ds wrong digest length": {
+			rrtype:   "DS",
+			input:    "60485 5 2 2BB183AF5F22588179A53B0A98631FAD1A292118",
+			errField: "DIGEST",
+		},
+		"txt": {
+			rrtype: "TXT",
+			input:  `"v=spf1 -all"`,
+			expected: ast.TXT{
+				Strings: [][]byte{[]byte("v=spf1 -all")},
+			},
+		},
+		"txt multiple strings and escapes": {
+			rrtype: "TXT",
+			input:  `"a\"b" c\;d "\255\010"`,
+			expected: ast.TXT{
+				Strings: [][]byte{[]byte(`a"b`), []byte("c;d"), {255, 10}},
+			},
+			rendered: `"a\"b" "c;d" "\255\010"`,
+		},
+		"txt bad escape": {
+			rrtype:   "TXT",
+			input:    `"\256"`,
+			errField: "TXT-DATA",
+		},
+		"unsupported type": {
+			rrtype:     "A",
+			input:      "192.0.2.1",
+			errContain: "unsupported RDATA type: A",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ast.ParseTypedRData(tc.rrtype, ast.SplitRData(tc.input))
+
+			if tc.errField != "" {
+				var fieldErr *ast.RDataFieldError
+				if assert.ErrorAs(t, err, &fieldErr) {
+					assert.Equal(t, tc.rrtype, fieldErr.Type)
+					assert.Equal(t, tc.errField, fieldErr.Field)
+				}
+				assert.ErrorIs(t, err, ast.ErrInvalidRData)
+				return
+			}
+			if tc.errContain != "" {
+				assert.ErrorContains(t, err, tc.errContain)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+			assert.Equal(t, tc.rrtype, got.RRType())
+
+			rendered := tc.rendered
+			if rendered == "" {
+				rendered = tc.input
+			}
+			assert.Equal(t, ast.SplitRData(rendered), got.RData())
+		})
+	}
+}
+
+func TestSplitRData(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]string{
+		"10 mail.example.com.":              {"10", "mail.example.com."},
+		`0 issue "letsencrypt.org"`:         {"0", "issue", `"letsencrypt.org"`},
+		`"v=spf1 -all"`:                     {`"v=spf1 -all"`},
+		`"foo \"bar baz\"" qux`:             {`"foo \"bar baz\""`, "qux"},
+		"  leading\tand trailing  ":         {"leading", "and", "trailing"},
+		`escaped\ space next`:               {`escaped\ space`, "next"},
+		`"a" "b"`:                           {`"a"`, `"b"`},
+		`"a""b"`:                            {`"a"`, `"b"`},
+		`"a;b" c\;d`:                        {`"a;b"`, `c\;d`},
+		"":                                  {},
+		`100 10 "U" "E2U+sip" "!^.*$!x!" .`: {"100", "10", `"U"`, `"E2U+sip"`, `"!^.*$!x!"`, "."},
+	}
+
+	for input, expected := range tests {
+		got := []string{}
+		for _, rdata := range ast.SplitRData(input) {
+			got = append(got, rdata.Value)
+		}
+		assert.Equal(t, expected, got, input)
+	}
+}
+
+func TestRRecordWithTypedRData(t *testing.T) {
+	t.Parallel()
+
+	rrecord := ast.RRecord{
+		Type: "SOA",
+		RData: []ast.RData{
+			{Value: "ns."},
+			{Value: "host."},
+			{Value: "41", NewLine: true, Comment: "; serial"},
+			{Value: "1", NewLine: true},
+			{Value: "2"},
+			{Value: "3"},
+			{Value: "4"},
+		},
+		Group: &ast.RDataGroup{Start: 2, End: 7},
+	}
+
+	typed, err := rrecord.Typed()
+	assert.NoError(t, err)
+	soa := typed.(ast.SOA)
+	soa.Serial++
+
+	updated := rrecord.WithTypedRData(soa)
+	assert.Equal(t, ast.RData{Value: "42", NewLine: true, Comment: "; serial"}, updated.RData[2])
+	assert.Equal(t, rrecord.Group, updated.Group)
+	// the original record must not be modified
+	assert.Equal(t, "41", rrecord.RData[2].Value)
+
+	mx := rrecord.WithTypedRData(ast.MX{Preference: 10, Exchange: "mail."})
+	assert.Nil(t, mx.Group)
+	assert.Equal(t, []ast.RData{{Value: "10"}, {Value: "mail."}}, mx.RData)
+}
+
+func TestRDataFieldErrorUnwrap(t *testing.T) {
+	t.Parallel()
+
+	_, err := ast.ParseMX(ast.SplitRData("x mail."))
+	assert.True(t, errors.Is(err, ast.ErrInvalidRData))
+	assert.ErrorContains(t, err, "invalid MX RDATA field PREFERENCE 'x'")
+}
@@ -0,0 +1,297 @@
+// Package diff compares two zones RRset by RRset. Only the data served by the
+// zone is compared, so formatting, comments, the order of entries and the way
+// names and TTLs are written don't show up as changes.
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+)
+
+type ChangeType string
+
+const (
+	ChangeTypeAdded   ChangeType = "added"
+	ChangeTypeRemoved ChangeType = "removed"
+	ChangeTypeChanged ChangeType = "changed"
+)
+
+// Key identifies an RRset. Owner is the lowercased absolute owner name and
+// Class and Type are uppercased.
+type Key struct {
+	Owner string
+	Class string
+	Type  string
+}
+
+func (key Key) String() string {
+	return key.Owner + " " + key.Class + " " + key.Type
+}
+
+// RRset is the data of all records in a zone sharing a Key.
+type RRset struct {
+	Key
+	// TTL is the TTL of the first record of the RRset in the zone.
+	TTL time.Duration
+	// Records holds the normalized RDATA of each record in presentation form,
+	// sorted and without duplicates.
+	Records []string
+}
+
+// Change is the difference between the old and the new version of an RRset.
+type Change struct {
+	Type ChangeType
+	Key  Key
+	// Old is nil for added RRsets.
+	Old *RRset
+	// New is nil for removed RRsets.
+	New *RRset
+	// TTLOnly is set for changed RRsets whose records are the same in both
+	// versions.
+	TTLOnly bool
+}
+
+// AddedRecords returns the records of the new RRset that aren't in the old
+// one.
+func (change Change) AddedRecords() []string {
+	return difference(change.New, change.Old)
+}
+
+// RemovedRecords returns the records of the old RRset that aren't in the new
+// one.
+func (change Change) RemovedRecords() []string {
+	return difference(change.Old, change.New)
+}
+
+func difference(a *RRset, b *RRset) []string {
+	records := []string{}
+	if a == nil {
+		return records
+	}
+	for _, record := range a.Records {
+		if b == nil || !slices.Contains(b.Records, record) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// String renders the change the way Format does.
+func (change Change) String() string {
+	var sb strings.Builder
+	if change.TTLOnly {
+		fmt.Fprintf(
+			&sb,
+			"~ %s TTL %s -> %s\n",
+			change.Key,
+			parser.DurationToSeconds(change.Old.TTL),
+			parser.DurationToSeconds(change.New.TTL),
+		)
+		return sb.String()
+	}
+	for _, record := range change.RemovedRecords() {
+		writeRecord(&sb, "-", change.Old, record)
+	}
+	for _, record := range change.AddedRecords() {
+		writeRecord(&sb, "+", change.New, record)
+	}
+	if change.Type == ChangeTypeChanged && change.Old.TTL != change.New.TTL {
+		// records that stayed still changed their TTL
+		for _, record := range change.New.Records {
+			if slices.Contains(change.Old.Records, record) {
+				writeRecord(&sb, "~", change.New, record)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func writeRecord(sb *strings.Builder, prefix string, rrset *RRset, record string) {
+	fmt.Fprintf(
+		sb,
+		"%s %s %s %s %s %s\n",
+		prefix,
+		rrset.Owner,
+		parser.DurationToSeconds(rrset.TTL),
+		rrset.Class,
+		rrset.Type,
+		record,
+	)
+}
+
+// Format renders changes as lines prefixed with "+" for added records, "-"
+// for removed records and "~" for records whose TTL changed.
+func Format(changes []Change) string {
+	var sb strings.Builder
+	for _, change := range changes {
+		sb.WriteString(change.String())
+	}
+	return sb.String()
+}
+
+// Options tweak what Compare considers a change.
+type Options struct {
+	// IgnoreSOASerial leaves the serial out of the comparison of SOA
+	// records, e.g. when the serial is bumped on every save anyway.
+	IgnoreSOASerial bool
+}
+
+// Compare returns the changes needed to turn the old zone into the new one,
+// sorted by Key. Both zones are resolved against origin (see parser.Resolve)
+// with $GENERATE entries expanded; $INCLUDE entries have to be resolved by
+// the caller.
+func Compare(old []ast.Node, new []ast.Node, origin string, opts Options) ([]Change, error) {
+	oldRRsets, err := collect(old, origin, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error reading old zone: %w", err)
+	}
+	newRRsets, err := collect(new, origin, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error reading new zone: %w", err)
+	}
+
+	keys := []Key{}
+	for key := range oldRRsets {
+		keys = append(keys, key)
+	}
+	for key := range newRRsets {
+		if _, ok := oldRRsets[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, compareKeys)
+
+	changes := []Change{}
+	for _, key := range keys {
+		oldRRset, inOld := oldRRsets[key]
+		newRRset, inNew := newRRsets[key]
+		switch {
+		case !inNew:
+			changes = append(changes, Change{Type: ChangeTypeRemoved, Key: key, Old: oldRRset})
+		case !inOld:
+			changes = append(changes, Change{Type: ChangeTypeAdded, Key: key, New: newRRset})
+		default:
+			sameRecords := slices.Equal(oldRRset.Records, newRRset.Records)
+			if sameRecords && oldRRset.TTL == newRRset.TTL {
+				continue
+			}
+			changes = append(changes, Change{
+				Type:    ChangeTypeChanged,
+				Key:     key,
+				Old:     oldRRset,
+				New:     newRRset,
+				TTLOnly: sameRecords,
+			})
+		}
+	}
+	return changes, nil
+}
+
+// Equal reports whether the two zones serve the same data.
+func Equal(old []ast.Node, new []ast.Node, origin string, opts Options) (bool, error) {
+	changes, err := Compare(old, new, origin, opts)
+	return len(changes) == 0, err
+}
+
+func compareKeys(a Key, b Key) int {
+	if n := strings.Compare(a.Owner, b.Owner); n != 0 {
+		return n
+	}
+	if n := strings.Compare(a.Class, b.Class); n != 0 {
+		return n
+	}
+	return strings.Compare(a.Type, b.Type)
+}
+
+// collect groups the records of entries into RRsets.
+func collect(entries []ast.Node, origin string, opts Options) (map[Key]*RRset, error) {
+	expanded, err := parser.ExpandGenerates(entries)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := parser.Resolve(expanded, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	rrsets := map[Key]*RRset{}
+	for _, node := range resolved {
+		if !node.IsRREntry() {
+			continue
+		}
+		entry := node.RREntry()
+		key := Key{
+			Owner: strings.ToLower(entry.Resolved.Owner),
+			Class: entry.Resolved.Class,
+			Type:  strings.ToUpper(entry.RRecord.Type),
+		}
+		rrset, ok := rrsets[key]
+		if !ok {
+			rrset = &RRset{
+				Key:     key,
+				TTL:     entry.Resolved.TTL,
+				Records: []string{},
+			}
+			rrsets[key] = rrset
+		}
+		rrset.Records = append(rrset.Records, NormalizeRData(key.Type, entry.RRecord.RData, entry.Resolved.Origin, opts))
+	}
+
+	for _, rrset := range rrsets {
+		slices.Sort(rrset.Records)
+		rrset.Records = slices.Compact(rrset.Records)
+	}
+	return rrsets, nil
+}
+
+// NormalizeRData renders rdata in a canonical form. Records with a typed view
+// are normalized through it so e.g. the case of hex digits or the way TXT
+// strings are quoted doesn't matter, and relative names of the most common
+// types are made absolute.
+func NormalizeRData(rrtype string, rdata []ast.RData, origin string, opts Options) string {
+	if ast.HasTypedRData(rrtype) {
+		typed, err := ast.ParseTypedRData(rrtype, rdata)
+		if err == nil {
+			typed = absoluteNames(typed, origin)
+			if soa, ok := typed.(ast.SOA); ok && opts.IgnoreSOASerial {
+				soa.Serial = 0
+				typed = soa
+			}
+			rdata = typed.RData()
+		}
+	} else if len(rdata) == 1 && slices.Contains([]string{"CNAME", "NS", "PTR", "DNAME"}, rrtype) {
+		rdata = []ast.RData{{Value: strings.ToLower(ast.AbsoluteName(rdata[0].Value, origin))}}
+	}
+
+	values := make([]string, len(rdata))
+	for i, field := range rdata {
+		values[i] = field.Value
+	}
+	return strings.Join(values, " ")
+}
+
+// absoluteNames makes the domain names in the typed RDATA absolute and
+// lowercase.
+func absoluteNames(typed ast.TypedRData, origin string) ast.TypedRData {
+	name := func(name string) string {
+		return strings.ToLower(ast.AbsoluteName(name, origin))
+	}
+	switch rdata := typed.(type) {
+	case ast.SOA:
+		rdata.MName = name(rdata.MName)
+		rdata.RName = name(rdata.RName)
+		return rdata
+	case ast.MX:
+		rdata.Exchange = name(rdata.Exchange)
+		return rdata
+	case ast.SRV:
+		rdata.Target = name(rdata.Target)
+		return rdata
+	}
+	return typed
+}
@@ -0,0 +1,179 @@
+package diff_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/diff"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lexer"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+)
+
+const soa = "$ORIGIN example.com.\n" +
+	"$TTL 1h\n" +
+	"@ IN SOA ns hostmaster 1 7200 3600 1209600 3600\n"
+
+func parse(t *testing.T, input string) []ast.Node {
+	t.Helper()
+	entries, err := parser.ParseEntries(lexer.LexBytes([]byte(input)).AllTokens())
+	require.NoError(t, err)
+	return entries
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	type change struct {
+		Type    diff.ChangeType
+		Key     string
+		TTLOnly bool
+		Added   []string
+		Removed []string
+	}
+
+	tests := map[string]struct {
+		old      string
+		new      string
+		opts     diff.Options
+		expected []change
+	}{
+		"identical": {
+			old:      soa + "www A 192.0.2.1\n",
+			new:      soa + "www A 192.0.2.1\n",
+			expected: []change{},
+		},
+		"formatting, comments and order": {
+			old: soa +
+				"www A 192.0.2.1\n" +
+				"www A 192.0.2.2\n" +
+				"mail MX 10 mx\n",
+			new: soa +
+				"; mail\n" +
+				"mail.example.com.\t3600\tIN\tMX\t10 mx.example.com. ; primary\n" +
+				"WWW  IN A 192.0.2.2\n" +
+				"     IN A 192.0.2.1\n",
+			expected: []change{},
+		},
+		"added and removed": {
+			old: soa + "old A 192.0.2.1\n",
+			new: soa + "new A 192.0.2.1\n",
+			expected: []change{
+				{Type: diff.ChangeTypeAdded, Key: "new.example.com. IN A", Added: []string{"192.0.2.1"}, Removed: []string{}},
+				{Type: diff.ChangeTypeRemoved, Key: "old.example.com. IN A", Added: []string{}, Removed: []string{"192.0.2.1"}},
+			},
+		},
+		"changed records": {
+			old: soa + "www A 192.0.2.1\nwww A 192.0.2.2\n",
+			new: soa + "www A 192.0.2.1\nwww A 192.0.2.3\n",
+			expected: []change{
+				{Type: diff.ChangeTypeChanged, Key: "www.example.com. IN A", Added: []string{"192.0.2.3"}, Removed: []string{"192.0.2.2"}},
+			},
+		},
+		"ttl only": {
+			old: soa + "www A 192.0.2.1\n",
+			new: soa + "www 300 A 192.0.2.1\n",
+			expected: []change{
+				{Type: diff.ChangeTypeChanged, Key: "www.example.com. IN A", TTLOnly: true, Added: []string{}, Removed: []string{}},
+			},
+		},
+		"txt quoting": {
+			old:      soa + "@ TXT \"v=spf1 -all\"\n",
+			new:      soa + "@ TXT \"v=spf1\\032-all\"\n",
+			expected: []change{},
+		},
+		"soa serial": {
+			old: soa,
+			new: "$ORIGIN example.com.\n$TTL 1h\n@ IN SOA ns hostmaster 2 7200 3600 1209600 3600\n",
+			expected: []change{
+				{
+					Type:    diff.ChangeTypeChanged,
+					Key:     "example.com. IN SOA",
+					Added:   []string{"ns.example.com. hostmaster.example.com. 2 7200 3600 1209600 3600"},
+					Removed: []string{"ns.example.com. hostmaster.example.com. 1 7200 3600 1209600 3600"},
+				},
+			},
+		},
+		"soa serial ignored": {
+			old:      soa,
+			new:      "$ORIGIN example.com.\n$TTL 1h\n@ IN SOA ns hostmaster 2 7200 3600 1209600 3600\n",
+			opts:     diff.Options{IgnoreSOASerial: true},
+			expected: []change{},
+		},
+		"generated records": {
+			old:      soa + "$GENERATE 1-2 host$ A 192.0.2.$\n",
+			new:      soa + "host1 A 192.0.2.1\nhost2 A 192.0.2.2\n",
+			expected: []change{},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			changes, err := diff.Compare(parse(t, tc.old), parse(t, tc.new), "", tc.opts)
+			require.NoError(t, err)
+
+			got := []change{}
+			for _, c := range changes {
+				got = append(got, change{
+					Type:    c.Type,
+					Key:     c.Key.String(),
+					TTLOnly: c.TTLOnly,
+					Added:   c.AddedRecords(),
+					Removed: c.RemovedRecords(),
+				})
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	changes, err := diff.Compare(
+		parse(t, soa+"a A 192.0.2.1\nb A 192.0.2.2\nc A 192.0.2.3\nc A 192.0.2.4\nd A 192.0.2.5\n"),
+		parse(t, soa+"a 300 A 192.0.2.1\nc 300 A 192.0.2.3\nc A 192.0.2.6\nd A 192.0.2.5\ne A 192.0.2.7\n"),
+		"",
+		diff.Options{},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		"~ a.example.com. IN A TTL 3600 -> 300\n"+
+			"- b.example.com. 3600 IN A 192.0.2.2\n"+
+			"- c.example.com. 3600 IN A 192.0.2.4\n"+
+			"+ c.example.com. 300 IN A 192.0.2.6\n"+
+			"~ c.example.com. 300 IN A 192.0.2.3\n"+
+			"+ e.example.com. 3600 IN A 192.0.2.7\n",
+		diff.Format(changes),
+	)
+
+	assert.Equal(t, time.Hour, changes[1].Old.TTL)
+}
+
+func TestEqual(t *testing.T) {
+	t.Parallel()
+
+	equal, err := diff.Equal(parse(t, soa+"www A 192.0.2.1\n"), parse(t, soa+"www IN A 192.0.2.1\n"), "", diff.Options{})
+	require.NoError(t, err)
+	assert.True(t, equal)
+
+	equal, err = diff.Equal(parse(t, soa+"www A 192.0.2.1\n"), parse(t, soa+"www A 192.0.2.2\n"), "", diff.Options{})
+	require.NoError(t, err)
+	assert.False(t, equal)
+}
+
+func TestCompareError(t *testing.T) {
+	t.Parallel()
+
+	_, err := diff.Compare(parse(t, "www A 192.0.2.1\n"), parse(t, soa), "", diff.Options{})
+	assert.ErrorIs(t, err, parser.ErrResolveError)
+	assert.ErrorContains(t, err, "error reading old zone")
+}
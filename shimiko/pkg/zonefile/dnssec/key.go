@@ -0,0 +1,166 @@
+package dnssec
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/wire"
+)
+
+// AlgorithmECDSAP256SHA256 is the only signing algorithm supported (RFC 6605).
+const AlgorithmECDSAP256SHA256 uint8 = 13
+
+// DigestSHA256 is the digest type of the DS records returned by DS (RFC 4509).
+const DigestSHA256 uint8 = 2
+
+// Flags of key signing and zone signing keys.
+const (
+	FlagsKSK = ast.DNSKEYFlagZone | ast.DNSKEYFlagSEP
+	FlagsZSK = ast.DNSKEYFlagZone
+)
+
+var ErrInvalidKey = errors.New("invalid DNSSEC key")
+
+// Key is a DNSSEC signing key. Keys with the SEP flag set are treated as key
+// signing keys (KSK) and only sign the DNSKEY RRset, other keys are zone
+// signing keys (ZSK).
+type Key struct {
+	Flags      uint16
+	PrivateKey *ecdsa.PrivateKey
+}
+
+// GenerateKey generates a new ECDSA P-256 key with the given DNSKEY flags,
+// usually FlagsKSK or FlagsZSK.
+func GenerateKey(flags uint16) (*Key, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating ECDSA key: %w", err)
+	}
+	return &Key{
+		Flags:      flags,
+		PrivateKey: privateKey,
+	}, nil
+}
+
+// ParseKey parses a PEM encoded PKCS #8 ECDSA P-256 private key as written by
+// MarshalPrivateKey.
+func ParseKey(flags uint16, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM data found", ErrInvalidKey)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	privateKey, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok || privateKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: not an ECDSA P-256 key", ErrInvalidKey)
+	}
+	return &Key{
+		Flags:      flags,
+		PrivateKey: privateKey,
+	}, nil
+}
+
+// MarshalPrivateKey returns the private key PEM encoded in PKCS #8 form.
+func (key *Key) MarshalPrivateKey() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: der,
+	}), nil
+}
+
+// IsKSK reports whether the key is a key signing key.
+func (key *Key) IsKSK() bool {
+	return key.Flags&ast.DNSKEYFlagSEP != 0
+}
+
+// DNSKEY returns the public part of the key as a DNSKEY record.
+func (key *Key) DNSKEY() ast.DNSKEY {
+	// the public key is the concatenation of X and Y (RFC 6605 section 4)
+	publicKey := make([]byte, 64)
+	key.PrivateKey.X.FillBytes(publicKey[:32])
+	key.PrivateKey.Y.FillBytes(publicKey[32:])
+	return ast.DNSKEY{
+		Flags:     key.Flags,
+		Protocol:  3,
+		Algorithm: AlgorithmECDSAP256SHA256,
+		PublicKey: publicKey,
+	}
+}
+
+// KeyTag returns the key tag of the key.
+func (key *Key) KeyTag() uint16 {
+	return KeyTag(key.DNSKEY())
+}
+
+// DS returns the DS record for the key at owner that goes into the parent
+// zone.
+func (key *Key) DS(owner string) (ast.DS, error) {
+	return DS(owner, key.DNSKEY())
+}
+
+// sign signs data with the key and returns the signature in the form used by
+// RRSIG records (RFC 6605 section 4).
+func (key *Key) sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, key.PrivateKey, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("error signing: %w", err)
+	}
+	return append(fixedBytes(r), fixedBytes(s)...), nil
+}
+
+func fixedBytes(n *big.Int) []byte {
+	b := make([]byte, 32)
+	return n.FillBytes(b)
+}
+
+// KeyTag computes the key tag of a DNSKEY record (RFC 4034 appendix B).
+func KeyTag(dnskey ast.DNSKEY) uint16 {
+	// encoding a DNSKEY can't fail
+	rdata, _ := wire.EncodeTypedRData(dnskey, "")
+	var ac uint32
+	for i, b := range rdata {
+		if i&1 == 0 {
+			ac += uint32(b) << 8
+		} else {
+			ac += uint32(b)
+		}
+	}
+	ac += ac >> 16 & 0xffff
+	return uint16(ac & 0xffff)
+}
+
+// DS returns the SHA-256 DS record of the DNSKEY record at owner (RFC 4034
+// section 5.1.4).
+func DS(owner string, dnskey ast.DNSKEY) (ast.DS, error) {
+	name, err := wire.CanonicalName(owner)
+	if err != nil {
+		return ast.DS{}, err
+	}
+	rdata, err := wire.EncodeTypedRData(dnskey, "")
+	if err != nil {
+		return ast.DS{}, err
+	}
+	digest := sha256.Sum256(append(name, rdata...))
+	return ast.DS{
+		KeyTag:     KeyTag(dnskey),
+		Algorithm:  dnskey.Algorithm,
+		DigestType: DigestSHA256,
+		Digest:     digest[:],
+	}, nil
+}
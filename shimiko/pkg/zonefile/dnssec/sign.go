@@ -0,0 +1,462 @@
+// Package dnssec signs zones with DNSSEC (RFC 4033, RFC 4034 and RFC 4035).
+// Zones are signed with ECDSA P-256 keys and authenticated denial of
+// existence uses an NSEC chain.
+package dnssec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/wire"
+)
+
+var (
+	ErrNoKeys  = errors.New("no DNSSEC keys")
+	ErrNoSOA   = errors.New("zone has no SOA record at its apex")
+	ErrSigning = errors.New("error signing zone")
+)
+
+// Types are the record types maintained by the signer. Records of these types
+// are removed from a zone before it is signed.
+var Types = []string{
+	"DNSKEY",
+	"RRSIG",
+	"NSEC",
+	"NSEC3",
+	"NSEC3PARAM",
+}
+
+// IsDNSSECType reports whether rrtype is one of Types.
+func IsDNSSECType(rrtype string) bool {
+	return slices.Contains(Types, strings.ToUpper(rrtype))
+}
+
+// Options control the validity period of the signatures.
+type Options struct {
+	Inception  time.Time
+	Expiration time.Time
+}
+
+// Strip returns entries without records of the types in Types. An entry that
+// relied on the owner of a removed entry by leaving its own owner blank gets
+// that owner.
+func Strip(entries []ast.Node) []ast.Node {
+	kept := make([]ast.Node, 0, len(entries))
+	inheritedOwner := ""
+	for _, node := range entries {
+		if !node.IsRREntry() {
+			kept = append(kept, node)
+			continue
+		}
+		entry := node.RREntry()
+		if IsDNSSECType(entry.RRecord.Type) {
+			if entry.DomainName != "" {
+				inheritedOwner = entry.DomainName
+			}
+			continue
+		}
+		if entry.DomainName == "" && inheritedOwner != "" {
+			entry.DomainName = inheritedOwner
+			node.Entry = entry
+		}
+		inheritedOwner = ""
+		kept = append(kept, node)
+	}
+	return kept
+}
+
+// EarliestExpiration returns the earliest expiration of the RRSIG records in
+// entries, or the zero time if there are none.
+func EarliestExpiration(entries []ast.Node) time.Time {
+	earliest := time.Time{}
+	for _, node := range entries {
+		if !node.IsRREntry() || !strings.EqualFold(node.RREntry().RRecord.Type, "RRSIG") {
+			continue
+		}
+		rrsig, err := ast.ParseRRSIG(node.RREntry().RRecord.RData)
+		if err != nil {
+			continue
+		}
+		if earliest.IsZero() || rrsig.Expiration.Before(earliest) {
+			earliest = rrsig.Expiration
+		}
+	}
+	return earliest
+}
+
+// CompareNames compares two absolute names in canonical DNS name order (RFC
+// 4034 section 6.1): label by label starting with the rightmost one, ignoring
+// case. Names that can't be encoded sort after all valid names.
+func CompareNames(a string, b string) int {
+	aLabels, aErr := wire.Labels(a)
+	bLabels, bErr := wire.Labels(b)
+	switch {
+	case aErr != nil && bErr != nil:
+		return strings.Compare(a, b)
+	case aErr != nil:
+		return 1
+	case bErr != nil:
+		return -1
+	}
+	for i, j := len(aLabels)-1, len(bLabels)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if n := bytes.Compare(bytes.ToLower(aLabels[i]), bytes.ToLower(bLabels[j])); n != 0 {
+			return n
+		}
+	}
+	return len(aLabels) - len(bLabels)
+}
+
+// record is a single resource record in canonical form.
+type record struct {
+	rdata []ast.RData
+	// wire is the canonical wire form of the RDATA.
+	wire []byte
+}
+
+type rrset struct {
+	owner   string
+	rrtype  string
+	class   string
+	ttl     time.Duration
+	records []record
+}
+
+// SignZone signs the zone made up of entries. It returns a copy of entries
+// without the records of the types in Types and with a DNSKEY RRset for keys,
+// an NSEC chain and RRSIG records for all authoritative RRsets appended at
+// the end, all with absolute lowercase owner names.
+//
+// Key signing keys sign the DNSKEY RRset and zone signing keys everything
+// else; if there are only keys of one kind they sign everything. $GENERATE
+// entries are expanded for signing; $INCLUDE entries have to be resolved by
+// the caller.
+func SignZone(entries []ast.Node, origin string, keys []*Key, opts Options) ([]ast.Node, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	if !opts.Expiration.After(opts.Inception) {
+		return nil, fmt.Errorf("%w: expiration %s is not after inception %s", ErrSigning, opts.Expiration, opts.Inception)
+	}
+	apex := strings.ToLower(origin)
+	if !ast.IsAbsoluteName(apex) {
+		return nil, fmt.Errorf("%w: origin '%s' is not absolute", ErrSigning, origin)
+	}
+
+	stripped := Strip(entries)
+	rrsets, err := collect(stripped, apex)
+	if err != nil {
+		return nil, err
+	}
+
+	soaSet, ok := rrsets[rrsetKey{owner: apex, rrtype: "SOA"}]
+	if !ok {
+		return nil, ErrNoSOA
+	}
+	soa, err := ast.ParseSOA(soaSet.records[0].rdata)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSigning, err)
+	}
+	nsecTTL := min(soaSet.ttl, soa.Minimum)
+
+	dnskeySet := &rrset{
+		owner:  apex,
+		rrtype: "DNSKEY",
+		class:  soaSet.class,
+		ttl:    soaSet.ttl,
+	}
+	for _, key := range keys {
+		dnskey := key.DNSKEY()
+		rdata, err := wire.CanonicalTypedRData(dnskey, apex)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSigning, err)
+		}
+		dnskeySet.records = append(dnskeySet.records, record{rdata: dnskey.RData(), wire: rdata})
+	}
+	sortRecords(dnskeySet)
+	rrsets[rrsetKey{owner: apex, rrtype: "DNSKEY"}] = dnskeySet
+
+	// group the RRsets by owner and leave out glue, i.e. everything below a
+	// delegation
+	byOwner := map[string][]*rrset{}
+	for _, set := range rrsets {
+		byOwner[set.owner] = append(byOwner[set.owner], set)
+	}
+	delegations := map[string]bool{}
+	for owner, sets := range byOwner {
+		if owner != apex && slices.ContainsFunc(sets, func(set *rrset) bool { return set.rrtype == "NS" }) {
+			delegations[owner] = true
+		}
+	}
+	names := []string{}
+	for owner := range byOwner {
+		if !belowDelegation(owner, apex, delegations) {
+			names = append(names, owner)
+		}
+	}
+	slices.SortFunc(names, CompareNames)
+
+	kskSigners, zskSigners := signers(keys)
+
+	signed := slices.Clone(stripped)
+	if len(signed) > 0 && signed[len(signed)-1].NodeType != ast.NodeTypeEmpty {
+		signed = append(signed, ast.Node{
+			NodeType: ast.NodeTypeEmpty,
+		})
+	}
+	emit := func(set *rrset, rrtype string, rdata []ast.RData) {
+		signed = append(signed, ast.Node{
+			NodeType: ast.NodeTypeRREntry,
+			Entry: ast.RREntry{
+				DomainName: set.owner,
+				RRecord: ast.RRecord{
+					TTL:   set.ttl,
+					Class: set.class,
+					Type:  rrtype,
+					RData: rdata,
+				},
+			},
+		})
+	}
+
+	for i, owner := range names {
+		sets := byOwner[owner]
+
+		types := []string{"NSEC", "RRSIG"}
+		for _, set := range sets {
+			types = append(types, set.rrtype)
+		}
+		nsec := ast.NSEC{
+			NextDomain: names[(i+1)%len(names)],
+			Types:      sortTypes(types),
+		}
+		nsecWire, err := wire.CanonicalTypedRData(nsec, apex)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSigning, err)
+		}
+		nsecSet := &rrset{
+			owner:   owner,
+			rrtype:  "NSEC",
+			class:   soaSet.class,
+			ttl:     nsecTTL,
+			records: []record{{rdata: nsec.RData(), wire: nsecWire}},
+		}
+		sets = append(sets, nsecSet)
+		slices.SortFunc(sets, compareRRsets)
+
+		for _, set := range sets {
+			if set.rrtype == "DNSKEY" || set.rrtype == "NSEC" {
+				for _, rr := range set.records {
+					emit(set, set.rrtype, rr.rdata)
+				}
+			}
+		}
+		for _, set := range sets {
+			// only the DS and NSEC RRsets of a delegation are authoritative
+			if delegations[owner] && set.rrtype != "DS" && set.rrtype != "NSEC" {
+				continue
+			}
+			keys := zskSigners
+			if set.rrtype == "DNSKEY" {
+				keys = kskSigners
+			}
+			for _, key := range keys {
+				rrsig, err := signRRset(set, key, apex, opts)
+				if err != nil {
+					return nil, err
+				}
+				emit(set, "RRSIG", rrsig.RData())
+			}
+		}
+	}
+
+	return signed, nil
+}
+
+type rrsetKey struct {
+	owner  string
+	rrtype string
+}
+
+// collect groups the records of entries into RRsets with their RDATA in
+// canonical form.
+func collect(entries []ast.Node, apex string) (map[rrsetKey]*rrset, error) {
+	expanded, err := parser.ExpandGenerates(entries)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSigning, err)
+	}
+	resolved, err := parser.Resolve(expanded, apex)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSigning, err)
+	}
+
+	rrsets := map[rrsetKey]*rrset{}
+	for _, node := range resolved {
+		if node.IsIncludeControlEntry() {
+			return nil, fmt.Errorf("%w: $INCLUDE entries have to be resolved before signing", ErrSigning)
+		}
+		if !node.IsRREntry() {
+			continue
+		}
+		entry := node.RREntry()
+		owner := strings.ToLower(entry.Resolved.Owner)
+		if owner != apex && !strings.HasSuffix(owner, "."+apex) && apex != "." {
+			return nil, fmt.Errorf("%w: '%s' is outside of the zone '%s'", ErrSigning, entry.Resolved.Owner, apex)
+		}
+		rrtype := strings.ToUpper(entry.RRecord.Type)
+		rdata, err := wire.CanonicalRData(rrtype, entry.RRecord.RData, entry.Resolved.Origin)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %s: %w", ErrSigning, entry.Resolved.Owner, rrtype, err)
+		}
+
+		key := rrsetKey{owner: owner, rrtype: rrtype}
+		set, ok := rrsets[key]
+		if !ok {
+			set = &rrset{
+				owner:  owner,
+				rrtype: rrtype,
+				class:  entry.Resolved.Class,
+				ttl:    entry.Resolved.TTL,
+			}
+			rrsets[key] = set
+		}
+		set.records = append(set.records, record{rdata: entry.RRecord.RData, wire: rdata})
+	}
+
+	for _, set := range rrsets {
+		sortRecords(set)
+	}
+	return rrsets, nil
+}
+
+// sortRecords sorts the records of set by their canonical RDATA and removes
+// duplicates (RFC 4034 section 6.3).
+func sortRecords(set *rrset) {
+	slices.SortFunc(set.records, func(a record, b record) int {
+		return bytes.Compare(a.wire, b.wire)
+	})
+	set.records = slices.CompactFunc(set.records, func(a record, b record) bool {
+		return bytes.Equal(a.wire, b.wire)
+	})
+}
+
+func compareRRsets(a *rrset, b *rrset) int {
+	aCode, _ := wire.TypeCode(a.rrtype)
+	bCode, _ := wire.TypeCode(b.rrtype)
+	return int(aCode) - int(bCode)
+}
+
+// sortTypes sorts types by their numeric value and removes duplicates.
+func sortTypes(types []string) []string {
+	slices.SortFunc(types, func(a string, b string) int {
+		aCode, _ := wire.TypeCode(a)
+		bCode, _ := wire.TypeCode(b)
+		return int(aCode) - int(bCode)
+	})
+	return slices.Compact(types)
+}
+
+// belowDelegation reports whether owner is below one of the delegations of
+// the zone at apex, which makes its records glue.
+func belowDelegation(owner string, apex string, delegations map[string]bool) bool {
+	for name := owner; name != apex && name != "."; {
+		_, parent, ok := strings.Cut(name, ".")
+		if !ok {
+			return false
+		}
+		if parent == "" {
+			parent = "."
+		}
+		if parent != apex && delegations[parent] {
+			return true
+		}
+		name = parent
+	}
+	return false
+}
+
+func signers(keys []*Key) (ksks []*Key, zsks []*Key) {
+	for _, key := range keys {
+		if key.IsKSK() {
+			ksks = append(ksks, key)
+		} else {
+			zsks = append(zsks, key)
+		}
+	}
+	if len(ksks) == 0 {
+		ksks = zsks
+	}
+	if len(zsks) == 0 {
+		zsks = ksks
+	}
+	return ksks, zsks
+}
+
+// labels returns the number of labels of owner as counted by the RRSIG
+// Labels field, which leaves out the root and a leading wildcard label.
+func labels(owner string) (uint8, error) {
+	l, err := wire.Labels(owner)
+	if err != nil {
+		return 0, err
+	}
+	n := len(l)
+	if n > 0 && bytes.Equal(l[0], []byte("*")) {
+		n--
+	}
+	return uint8(n), nil
+}
+
+// signRRset signs set with key (RFC 4034 section 3.1.8.1).
+func signRRset(set *rrset, key *Key, apex string, opts Options) (ast.RRSIG, error) {
+	labelCount, err := labels(set.owner)
+	if err != nil {
+		return ast.RRSIG{}, fmt.Errorf("%w: %w", ErrSigning, err)
+	}
+	rrsig := ast.RRSIG{
+		TypeCovered: set.rrtype,
+		Algorithm:   AlgorithmECDSAP256SHA256,
+		Labels:      labelCount,
+		OriginalTTL: set.ttl,
+		Expiration:  opts.Expiration.UTC().Truncate(time.Second),
+		Inception:   opts.Inception.UTC().Truncate(time.Second),
+		KeyTag:      key.KeyTag(),
+		SignerName:  apex,
+	}
+
+	data, err := wire.CanonicalTypedRData(rrsig, apex)
+	if err != nil {
+		return rrsig, fmt.Errorf("%w: %w", ErrSigning, err)
+	}
+	owner, err := wire.CanonicalName(set.owner)
+	if err != nil {
+		return rrsig, fmt.Errorf("%w: %w", ErrSigning, err)
+	}
+	rrtype, ok := wire.TypeCode(set.rrtype)
+	if !ok {
+		return rrsig, fmt.Errorf("%w: unknown type %s", ErrSigning, set.rrtype)
+	}
+	class, ok := wire.ClassCode(set.class)
+	if !ok {
+		return rrsig, fmt.Errorf("%w: unknown class %s", ErrSigning, set.class)
+	}
+	for _, rr := range set.records {
+		data = append(data, owner...)
+		data = binary.BigEndian.AppendUint16(data, rrtype)
+		data = binary.BigEndian.AppendUint16(data, class)
+		data = binary.BigEndian.AppendUint32(data, uint32(set.ttl/time.Second))
+		data = binary.BigEndian.AppendUint16(data, uint16(len(rr.wire)))
+		data = append(data, rr.wire...)
+	}
+
+	rrsig.Signature, err = key.sign(data)
+	if err != nil {
+		return rrsig, fmt.Errorf("%w: %w", ErrSigning, err)
+	}
+	return rrsig, nil
+}
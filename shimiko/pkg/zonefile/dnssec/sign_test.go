@@ -0,0 +1,328 @@
+package dnssec_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/dnssec"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lexer"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+)
+
+const zone = `$ORIGIN Example.com.
+$TTL 1h
+@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+@ NS ns1
+@ MX 10 Mail
+ns1 A 192.0.2.53
+www 300 A 192.0.2.1
+    A 192.0.2.2
+WWW AAAA 2001:db8::1
+mail A 192.0.2.25
+@ TXT "v=spf1 mx -all"
+long TXT "` + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789" + `"
+_sip._tcp SRV 10 5 5060 sip
+sip CNAME www
+@ CAA 0 issue "letsencrypt.org"
+* A 192.0.2.99
+_443._tcp.www TLSA 3 1 1 0C72AC70B745AC19998811B131D662C9AC69DBDBE7CB23E5B514B56664C5D3D6
+www SSHFP 4 2 1E5E5D8A1EEBCF8E4E0C1A2C1F2FE59F1DA0D87D80B0D2EC7E5C2D1C4B8E0E3B
+$GENERATE 1-3 host-$ A 192.0.2.$
+sub NS ns.sub
+sub DS 60485 5 1 2BB183AF5F22588179A53B0A98631FAD1A292118
+ns.sub A 192.0.2.54
+insecure NS ns.other.net.
+`
+
+func parse(t *testing.T, input string) []ast.Node {
+	t.Helper()
+	entries, err := parser.ParseEntries(lexer.LexBytes([]byte(input)).AllTokens())
+	require.NoError(t, err)
+	return entries
+}
+
+func render(t *testing.T, entries []ast.Node) string {
+	t.Helper()
+	toks, err := parser.Tokenize(entries)
+	require.NoError(t, err)
+	return string(token.RenderTokens(toks))
+}
+
+// readZone parses a zone with miekg/dns and groups its records into RRsets.
+func readZone(t *testing.T, data string) map[string][]dns.RR {
+	t.Helper()
+	rrsets := map[string][]dns.RR{}
+	zp := dns.NewZoneParser(strings.NewReader(data), "example.com.", "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		key := rrsetKey(rr.Header().Name, rr.Header().Rrtype)
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			key = rrsetKey(rr.Header().Name, sig.TypeCovered) + " RRSIG"
+		}
+		rrsets[key] = append(rrsets[key], rr)
+	}
+	require.NoError(t, zp.Err())
+	return rrsets
+}
+
+func rrsetKey(name string, rrtype uint16) string {
+	return strings.ToLower(name) + " " + dns.TypeToString[rrtype]
+}
+
+func generateKeys(t *testing.T) []*dnssec.Key {
+	t.Helper()
+	ksk, err := dnssec.GenerateKey(dnssec.FlagsKSK)
+	require.NoError(t, err)
+	zsk, err := dnssec.GenerateKey(dnssec.FlagsZSK)
+	require.NoError(t, err)
+	return []*dnssec.Key{ksk, zsk}
+}
+
+func TestSignZone(t *testing.T) {
+	t.Parallel()
+
+	keys := generateKeys(t)
+	now := time.Now()
+	signed, err := dnssec.SignZone(parse(t, zone), "example.com.", keys, dnssec.Options{
+		Inception:  now.Add(-time.Hour),
+		Expiration: now.Add(14 * 24 * time.Hour),
+	})
+	require.NoError(t, err)
+
+	rrsets := readZone(t, render(t, signed))
+
+	dnskeys := map[uint16]*dns.DNSKEY{}
+	for _, rr := range rrsets[rrsetKey("example.com.", dns.TypeDNSKEY)] {
+		dnskey := rr.(*dns.DNSKEY)
+		dnskeys[dnskey.KeyTag()] = dnskey
+	}
+	require.Len(t, dnskeys, 2)
+	for _, key := range keys {
+		require.Contains(t, dnskeys, key.KeyTag())
+		assert.Equal(t, key.Flags, dnskeys[key.KeyTag()].Flags)
+	}
+
+	// every signature verifies and is made by the right key
+	for name, sigs := range rrsets {
+		if !strings.HasSuffix(name, " RRSIG") {
+			continue
+		}
+		rrset := rrsets[strings.TrimSuffix(name, " RRSIG")]
+		require.NotEmpty(t, rrset, "RRset signed by %s", name)
+		for _, rr := range sigs {
+			sig := rr.(*dns.RRSIG)
+			dnskey, ok := dnskeys[sig.KeyTag]
+			require.True(t, ok, "%s is signed by an unknown key", name)
+			assert.NoError(t, sig.Verify(dnskey, rrset), name)
+			assert.True(t, sig.ValidityPeriod(now), name)
+			if sig.TypeCovered == dns.TypeDNSKEY {
+				assert.Equal(t, dnssec.FlagsKSK, dnskey.Flags, name)
+			} else {
+				assert.Equal(t, dnssec.FlagsZSK, dnskey.Flags, name)
+			}
+		}
+	}
+
+	// authoritative RRsets are signed, delegations and glue are not
+	for name := range rrsets {
+		if strings.HasSuffix(name, " RRSIG") {
+			continue
+		}
+		_, signed := rrsets[name+" RRSIG"]
+		switch name {
+		case "sub.example.com. NS", "insecure.example.com. NS", "ns.sub.example.com. A":
+			assert.False(t, signed, "%s should not be signed", name)
+		default:
+			assert.True(t, signed, "%s should be signed", name)
+		}
+	}
+	assert.Contains(t, rrsets, "sub.example.com. DS RRSIG")
+	assert.Equal(t, uint8(2), rrsets["*.example.com. A RRSIG"][0].(*dns.RRSIG).Labels)
+
+	// the NSEC chain visits every authoritative name in canonical order
+	expectedNames := []string{
+		"example.com.",
+		"*.example.com.",
+		"_sip._tcp.example.com.",
+		"host-1.example.com.",
+		"host-2.example.com.",
+		"host-3.example.com.",
+		"insecure.example.com.",
+		"long.example.com.",
+		"mail.example.com.",
+		"ns1.example.com.",
+		"sip.example.com.",
+		"sub.example.com.",
+		"www.example.com.",
+		"_443._tcp.www.example.com.",
+	}
+	names := []string{}
+	for name := "example.com."; len(names) <= len(expectedNames); {
+		nsecs := rrsets[rrsetKey(name, dns.TypeNSEC)]
+		require.Len(t, nsecs, 1, "NSEC at %s", name)
+		nsec := nsecs[0].(*dns.NSEC)
+		assert.Equal(t, time.Duration(300)*time.Second, time.Duration(nsec.Hdr.Ttl)*time.Second)
+		names = append(names, name)
+
+		types := []uint16{}
+		for key := range rrsets {
+			owner, rrtype, _ := strings.Cut(key, " ")
+			if owner == name && !strings.HasSuffix(rrtype, " RRSIG") {
+				types = append(types, dns.StringToType[rrtype])
+			}
+		}
+		types = append(types, dns.TypeRRSIG)
+		slices.Sort(types)
+		assert.Equal(t, types, nsec.TypeBitMap, "NSEC types at %s", name)
+
+		name = strings.ToLower(nsec.NextDomain)
+		if name == "example.com." {
+			break
+		}
+	}
+	assert.Equal(t, expectedNames, names)
+}
+
+func TestSignZoneSingleKey(t *testing.T) {
+	t.Parallel()
+
+	key, err := dnssec.GenerateKey(dnssec.FlagsKSK)
+	require.NoError(t, err)
+	now := time.Now()
+	signed, err := dnssec.SignZone(parse(t, zone), "example.com.", []*dnssec.Key{key}, dnssec.Options{
+		Inception:  now.Add(-time.Hour),
+		Expiration: now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	rrsets := readZone(t, render(t, signed))
+	dnskey := rrsets[rrsetKey("example.com.", dns.TypeDNSKEY)][0].(*dns.DNSKEY)
+	for _, name := range []string{"example.com. DNSKEY", "example.com. SOA", "www.example.com. A"} {
+		sigs := rrsets[name+" RRSIG"]
+		require.Len(t, sigs, 1, name)
+		assert.NoError(t, sigs[0].(*dns.RRSIG).Verify(dnskey, rrsets[name]), name)
+	}
+}
+
+func TestSignZoneResign(t *testing.T) {
+	t.Parallel()
+
+	keys := generateKeys(t)
+	now := time.Now()
+	opts := dnssec.Options{
+		Inception:  now.Add(-time.Hour),
+		Expiration: now.Add(time.Hour),
+	}
+	signed, err := dnssec.SignZone(parse(t, zone), "example.com.", keys, opts)
+	require.NoError(t, err)
+
+	// signing an already signed zone replaces the old DNSSEC records
+	reparsed := parse(t, render(t, signed))
+	assert.Equal(t, opts.Expiration.UTC().Truncate(time.Second), dnssec.EarliestExpiration(reparsed))
+	resigned, err := dnssec.SignZone(reparsed, "example.com.", keys, opts)
+	require.NoError(t, err)
+	assert.Equal(t, len(signed), len(resigned))
+	assert.Equal(t, len(parse(t, zone)), len(dnssec.Strip(reparsed)))
+}
+
+func TestSignZoneError(t *testing.T) {
+	t.Parallel()
+
+	keys := generateKeys(t)
+	now := time.Now()
+	valid := dnssec.Options{
+		Inception:  now,
+		Expiration: now.Add(time.Hour),
+	}
+
+	tests := map[string]struct {
+		input    string
+		keys     []*dnssec.Key
+		opts     dnssec.Options
+		expected error
+	}{
+		"no keys": {
+			input:    zone,
+			opts:     valid,
+			expected: dnssec.ErrNoKeys,
+		},
+		"no SOA": {
+			input:    "$ORIGIN example.com.\nwww 3600 IN A 192.0.2.1\n",
+			keys:     keys,
+			opts:     valid,
+			expected: dnssec.ErrNoSOA,
+		},
+		"expiration before inception": {
+			input: zone,
+			keys:  keys,
+			opts: dnssec.Options{
+				Inception:  now,
+				Expiration: now.Add(-time.Hour),
+			},
+			expected: dnssec.ErrSigning,
+		},
+		"record outside of the zone": {
+			input:    zone + "www.example.net. A 192.0.2.1\n",
+			keys:     keys,
+			opts:     valid,
+			expected: dnssec.ErrSigning,
+		},
+		"unsupported type": {
+			input:    zone + `hinfo HINFO "PC" "Linux"` + "\n",
+			keys:     keys,
+			opts:     valid,
+			expected: dnssec.ErrSigning,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := dnssec.SignZone(parse(t, tc.input), "example.com.", tc.keys, tc.opts)
+			assert.ErrorIs(t, err, tc.expected)
+		})
+	}
+}
+
+func TestStrip(t *testing.T) {
+	t.Parallel()
+
+	input := "$ORIGIN example.com.\n" +
+		"www RRSIG A 13 3 3600 20240201000000 20240101000000 12345 example.com. c2lnbmF0dXJl\n" +
+		"    A 192.0.2.1\n" +
+		"@ DNSKEY 257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+KkxLbxILfDLUT0rAK9iUzy1L53eKGQ==\n" +
+		"mail A 192.0.2.25\n"
+
+	stripped := dnssec.Strip(parse(t, input))
+	assert.Equal(t, "$ORIGIN example.com.\nwww A 192.0.2.1\nmail A 192.0.2.25\n", render(t, stripped))
+}
+
+func TestCompareNames(t *testing.T) {
+	t.Parallel()
+
+	// the example from RFC 4034 section 6.1
+	expected := []string{
+		"example.",
+		"a.example.",
+		"yljkjljk.a.example.",
+		"Z.a.example.",
+		"zABC.a.EXAMPLE.",
+		"z.example.",
+		`\001.z.example.`,
+		"*.z.example.",
+		`\200.z.example.`,
+	}
+	names := slices.Clone(expected)
+	slices.Reverse(names)
+	slices.SortFunc(names, dnssec.CompareNames)
+	assert.Equal(t, expected, names)
+}
@@ -0,0 +1,384 @@
+// Package document converts zone files to and from a structured document that
+// can be written as JSON or YAML, for tooling that would rather not deal with
+// the zone file syntax. Comments, blank lines and control entries are kept,
+// so a zone survives the round trip with only its layout normalized.
+//
+// A document looks like this in JSON:
+//
+//	{
+//	  "version": 1,
+//	  "entries": [
+//	    {"type": "comment", "comments": ["; example.com"]},
+//	    {"type": "blank"},
+//	    {"type": "origin", "name": "example.com."},
+//	    {"type": "ttl", "ttl": 300},
+//	    {"type": "record", "name": "@", "rrtype": "SOA", "rdata": ["ns1", "hostmaster", "1", "7200", "3600", "1209600", "300"]},
+//	    {"type": "record", "name": "www", "ttl": 600, "class": "IN", "rrtype": "A", "rdata": ["192.0.2.1"], "comment": "; web"},
+//	    {"type": "generate", "range": "1-10", "name": "host$", "rrtype": "A", "rdata": ["192.0.2.$"]},
+//	    {"type": "include", "file": "hosts.zone", "name": "hosts.example.com."}
+//	  ]
+//	}
+//
+// Names, classes, types and RDATA fields are written the way they would be in
+// a zone file, e.g. relative names stay relative and TXT fields keep their
+// quotes. TTLs are in seconds.
+package document
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+)
+
+var ErrInvalidDocument = errors.New("invalid zone document")
+
+// Version is the version of the document format written by this package.
+const Version = 1
+
+type EntryType string
+
+const (
+	// EntryTypeBlank is an empty line.
+	EntryTypeBlank EntryType = "blank"
+	// EntryTypeComment is a block of comment lines that doesn't belong to an
+	// entry.
+	EntryTypeComment EntryType = "comment"
+	// EntryTypeOrigin is an $ORIGIN entry; Name is the origin.
+	EntryTypeOrigin EntryType = "origin"
+	// EntryTypeTTL is a $TTL entry; TTL is the default TTL.
+	EntryTypeTTL EntryType = "ttl"
+	// EntryTypeInclude is an $INCLUDE entry; File is the included file and
+	// Name the optional origin for it.
+	EntryTypeInclude EntryType = "include"
+	// EntryTypeGenerate is a $GENERATE entry; Name and RData are the
+	// templates for the owner and RDATA of the generated records.
+	EntryTypeGenerate EntryType = "generate"
+	// EntryTypeRecord is a resource record.
+	EntryTypeRecord EntryType = "record"
+	// EntryTypeRaw is an entry that couldn't be parsed; Text is its source.
+	EntryTypeRaw EntryType = "raw"
+)
+
+type Document struct {
+	Version int     `json:"version" yaml:"version"`
+	Entries []Entry `json:"entries" yaml:"entries"`
+}
+
+// Entry is a single line, or a record spanning several lines, of a zone file.
+// Which fields are used depends on Type.
+type Entry struct {
+	Type EntryType `json:"type" yaml:"type"`
+	// Comments are the comment lines above the entry, or the lines of a
+	// comment entry. A ";" is added to lines that don't start with one.
+	Comments []string `json:"comments,omitempty" yaml:"comments,omitempty"`
+	// Comment is the comment at the end of the line of the entry.
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
+	File    string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Text is the source of a raw entry.
+	Text string `json:"text,omitempty" yaml:"text,omitempty"`
+	// Range is the range of a $GENERATE entry, "start-stop[/step]".
+	Range string `json:"range,omitempty" yaml:"range,omitempty"`
+	// TTL is in seconds. It is left out for records that inherit their TTL.
+	TTL    *uint32  `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	Class  string   `json:"class,omitempty" yaml:"class,omitempty"`
+	RRType string   `json:"rrtype,omitempty" yaml:"rrtype,omitempty"`
+	RData  []string `json:"rdata,omitempty" yaml:"rdata,omitempty"`
+	// Layout is only set for records written over several lines.
+	Layout *Layout `json:"layout,omitempty" yaml:"layout,omitempty"`
+}
+
+// Layout describes how a record is spread over several lines with
+// parentheses, see ast.RDataGroup.
+type Layout struct {
+	// Start and End delimit the RDATA fields inside the parentheses.
+	Start            int      `json:"start" yaml:"start"`
+	End              int      `json:"end" yaml:"end"`
+	Comment          string   `json:"comment,omitempty" yaml:"comment,omitempty"`
+	TrailingComments []string `json:"trailing_comments,omitempty" yaml:"trailing_comments,omitempty"`
+	CloseOnNewLine   bool     `json:"close_on_new_line,omitempty" yaml:"close_on_new_line,omitempty"`
+	// Fields holds the layout of each RDATA field.
+	Fields []FieldLayout `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+type FieldLayout struct {
+	NewLine  bool     `json:"new_line,omitempty" yaml:"new_line,omitempty"`
+	Comments []string `json:"comments,omitempty" yaml:"comments,omitempty"`
+	Comment  string   `json:"comment,omitempty" yaml:"comment,omitempty"`
+}
+
+func seconds(d time.Duration) *uint32 {
+	s := uint32(d / time.Second)
+	return &s
+}
+
+// FromNodes converts entries to a document.
+func FromNodes(entries []ast.Node) Document {
+	doc := Document{
+		Version: Version,
+		Entries: make([]Entry, 0, len(entries)),
+	}
+	for _, node := range entries {
+		entry := Entry{
+			Comments: node.LeadComments,
+			Comment:  node.LineComment,
+		}
+		switch {
+		case node.IsOriginControlEntry():
+			entry.Type = EntryTypeOrigin
+			entry.Name = node.OriginControlEntry().DomainName
+		case node.IsTTLControlEntry():
+			entry.Type = EntryTypeTTL
+			entry.TTL = seconds(node.TTLControlEntry().TTL)
+		case node.IsIncludeControlEntry():
+			include := node.IncludeControlEntry()
+			entry.Type = EntryTypeInclude
+			entry.File = include.FileName
+			entry.Name = include.DomainName
+		case node.IsGenerateControlEntry():
+			generate := node.GenerateControlEntry()
+			entry.Type = EntryTypeGenerate
+			entry.Range = generate.Range()
+			entry.Name = generate.DomainName
+			setRRecord(&entry, generate.RRecord)
+		case node.IsRREntry():
+			rr := node.RREntry()
+			entry.Type = EntryTypeRecord
+			entry.Name = rr.DomainName
+			setRRecord(&entry, rr.RRecord)
+		case node.IsRawEntry():
+			entry.Type = EntryTypeRaw
+			entry.Text = node.RawEntry().Text
+		case len(node.LeadComments) > 0 || node.LineComment != "":
+			entry.Type = EntryTypeComment
+		default:
+			entry.Type = EntryTypeBlank
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return doc
+}
+
+func setRRecord(entry *Entry, rrecord ast.RRecord) {
+	if rrecord.TTL != 0 {
+		entry.TTL = seconds(rrecord.TTL)
+	}
+	entry.Class = rrecord.Class
+	entry.RRType = rrecord.Type
+	entry.RData = make([]string, len(rrecord.RData))
+	for i, field := range rrecord.RData {
+		entry.RData[i] = field.Value
+	}
+	if rrecord.Group == nil {
+		return
+	}
+	entry.Layout = &Layout{
+		Start:            rrecord.Group.Start,
+		End:              rrecord.Group.End,
+		Comment:          rrecord.Group.Comment,
+		TrailingComments: rrecord.Group.TrailingComments,
+		CloseOnNewLine:   rrecord.Group.CloseOnNewLine,
+		Fields:           make([]FieldLayout, len(rrecord.RData)),
+	}
+	for i, field := range rrecord.RData {
+		entry.Layout.Fields[i] = FieldLayout{
+			NewLine:  field.NewLine,
+			Comments: field.LeadComments,
+			Comment:  field.Comment,
+		}
+	}
+}
+
+// Nodes converts the document back to entries. The entries have no source
+// tokens, so they are rendered in the canonical layout.
+func (doc Document) Nodes() ([]ast.Node, error) {
+	if doc.Version != Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidDocument, doc.Version)
+	}
+	entries := make([]ast.Node, 0, len(doc.Entries))
+	for i, entry := range doc.Entries {
+		node, err := entry.node()
+		if err != nil {
+			return entries, fmt.Errorf("%w: entry %d: %w", ErrInvalidDocument, i, err)
+		}
+		entries = append(entries, node)
+	}
+	return entries, nil
+}
+
+func (entry Entry) node() (ast.Node, error) {
+	node := ast.Node{
+		NodeType:     ast.NodeTypeEmpty,
+		LeadComments: comments(entry.Comments),
+		LineComment:  comment(entry.Comment),
+	}
+	switch entry.Type {
+	case EntryTypeBlank, EntryTypeComment:
+	case EntryTypeOrigin:
+		if entry.Name == "" {
+			return node, errors.New("$ORIGIN without name")
+		}
+		node.NodeType = ast.NodeTypeOriginControlEntry
+		node.Entry = ast.OriginControlEntry{
+			DomainName: entry.Name,
+		}
+	case EntryTypeTTL:
+		if entry.TTL == nil {
+			return node, errors.New("$TTL without ttl")
+		}
+		node.NodeType = ast.NodeTypeTTLControlEntry
+		node.Entry = ast.TTLControlEntry{
+			TTL: time.Duration(*entry.TTL) * time.Second,
+		}
+	case EntryTypeInclude:
+		if entry.File == "" {
+			return node, errors.New("$INCLUDE without file")
+		}
+		node.NodeType = ast.NodeTypeIncludeControlEntry
+		node.Entry = ast.IncludeControlEntry{
+			FileName:   entry.File,
+			DomainName: entry.Name,
+		}
+	case EntryTypeGenerate:
+		generate := ast.GenerateControlEntry{
+			DomainName: entry.Name,
+		}
+		var err error
+		generate.Start, generate.Stop, generate.Step, err = ast.ParseGenerateRange(entry.Range)
+		if err != nil {
+			return node, err
+		}
+		if entry.Name == "" {
+			return node, errors.New("$GENERATE without name")
+		}
+		generate.RRecord, err = entry.rrecord()
+		if err != nil {
+			return node, err
+		}
+		node.NodeType = ast.NodeTypeGenerateControlEntry
+		node.Entry = generate
+	case EntryTypeRecord:
+		rrecord, err := entry.rrecord()
+		if err != nil {
+			return node, err
+		}
+		node.NodeType = ast.NodeTypeRREntry
+		node.Entry = ast.RREntry{
+			DomainName: entry.Name,
+			RRecord:    rrecord,
+		}
+	case EntryTypeRaw:
+		if entry.Text == "" {
+			return node, errors.New("raw entry without text")
+		}
+		node.NodeType = ast.NodeTypeRaw
+		node.Entry = ast.RawEntry{
+			Text: entry.Text,
+		}
+	default:
+		return node, fmt.Errorf("unknown type '%s'", entry.Type)
+	}
+	return node, nil
+}
+
+func (entry Entry) rrecord() (ast.RRecord, error) {
+	rrecord := ast.RRecord{
+		Class: entry.Class,
+		Type:  entry.RRType,
+		RData: make([]ast.RData, len(entry.RData)),
+	}
+	if entry.RRType == "" {
+		return rrecord, fmt.Errorf("%s without rrtype", entry.Type)
+	}
+	if len(entry.RData) == 0 {
+		return rrecord, fmt.Errorf("%s without rdata", entry.Type)
+	}
+	if entry.TTL != nil {
+		rrecord.TTL = time.Duration(*entry.TTL) * time.Second
+	}
+	for i, value := range entry.RData {
+		rrecord.RData[i] = ast.RData{
+			Value:        value,
+			LeadComments: []string{},
+		}
+	}
+	if entry.Layout == nil {
+		return rrecord, nil
+	}
+
+	layout := entry.Layout
+	if layout.Start < 0 || layout.End < layout.Start || layout.End > len(rrecord.RData) {
+		return rrecord, fmt.Errorf("layout range %d-%d out of bounds", layout.Start, layout.End)
+	}
+	if len(layout.Fields) > len(rrecord.RData) {
+		return rrecord, errors.New("layout has more fields than rdata")
+	}
+	rrecord.Group = &ast.RDataGroup{
+		Start:            layout.Start,
+		End:              layout.End,
+		Comment:          comment(layout.Comment),
+		TrailingComments: comments(layout.TrailingComments),
+		CloseOnNewLine:   layout.CloseOnNewLine,
+	}
+	for i, field := range layout.Fields {
+		rrecord.RData[i].NewLine = field.NewLine
+		rrecord.RData[i].LeadComments = comments(field.Comments)
+		rrecord.RData[i].Comment = comment(field.Comment)
+	}
+	return rrecord, nil
+}
+
+// comment makes sure a non-empty comment starts with a ";".
+func comment(s string) string {
+	if s == "" || s[0] == ';' {
+		return s
+	}
+	return "; " + s
+}
+
+func comments(lines []string) []string {
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = comment(line)
+	}
+	return result
+}
+
+// ToJSON converts entries to an indented JSON document.
+func ToJSON(entries []ast.Node) ([]byte, error) {
+	data, err := json.MarshalIndent(FromNodes(entries), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// FromJSON converts a JSON document to entries.
+func FromJSON(data []byte) ([]ast.Node, error) {
+	var doc Document
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDocument, err)
+	}
+	return doc.Nodes()
+}
+
+// ToYAML converts entries to a YAML document.
+func ToYAML(entries []ast.Node) ([]byte, error) {
+	return yaml.Marshal(FromNodes(entries))
+}
+
+// FromYAML converts a YAML document to entries.
+func FromYAML(data []byte) ([]ast.Node, error) {
+	var doc Document
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDocument, err)
+	}
+	return doc.Nodes()
+}
@@ -0,0 +1,205 @@
+package document_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/document"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+)
+
+const zone = `; example.com
+$ORIGIN example.com.
+$TTL 300
+
+@ IN SOA ns1 hostmaster (
+	2024010101 ; serial
+	7200       ; refresh
+	3600 1209600 300 )
+@ NS ns1
+; the web server
+www 600 IN A 192.0.2.1 ; primary
+    TXT "v=spf1 -all" "second string"
+$GENERATE 1-10/2 host$ A 192.0.2.$
+$INCLUDE hosts.zone hosts.example.com.
+mail 1 2 A 192.0.2.2
+`
+
+func parse(t *testing.T, input string) []ast.Node {
+	t.Helper()
+	entries, _, err := parser.ParseReaderTolerant(strings.NewReader(input))
+	require.NoError(t, err)
+	return entries
+}
+
+func render(t *testing.T, entries []ast.Node) string {
+	t.Helper()
+	// force the canonical layout so that parsed and converted entries are
+	// rendered the same way
+	for i := range entries {
+		entries[i].SourceTokens = nil
+	}
+	toks, err := parser.Tokenize(entries)
+	require.NoError(t, err)
+	return string(token.RenderTokens(toks))
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		marshal   func([]ast.Node) ([]byte, error)
+		unmarshal func([]byte) ([]ast.Node, error)
+	}{
+		"JSON": {
+			marshal:   document.ToJSON,
+			unmarshal: document.FromJSON,
+		},
+		"YAML": {
+			marshal:   document.ToYAML,
+			unmarshal: document.FromYAML,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := tc.marshal(parse(t, zone))
+			require.NoError(t, err)
+			entries, err := tc.unmarshal(data)
+			require.NoError(t, err)
+			assert.Equal(t, render(t, parse(t, zone)), render(t, entries))
+		})
+	}
+}
+
+func TestFromNodes(t *testing.T) {
+	t.Parallel()
+
+	doc := document.FromNodes(parse(t, zone))
+	assert.Equal(t, document.Version, doc.Version)
+
+	types := []document.EntryType{}
+	for _, entry := range doc.Entries {
+		types = append(types, entry.Type)
+	}
+	assert.Equal(t, []document.EntryType{
+		document.EntryTypeComment,
+		document.EntryTypeOrigin,
+		document.EntryTypeTTL,
+		document.EntryTypeBlank,
+		document.EntryTypeRecord,
+		document.EntryTypeRecord,
+		document.EntryTypeComment,
+		document.EntryTypeRecord,
+		document.EntryTypeRecord,
+		document.EntryTypeGenerate,
+		document.EntryTypeInclude,
+		document.EntryTypeRaw,
+		document.EntryTypeBlank,
+	}, types)
+
+	www := doc.Entries[7]
+	assert.Equal(t, "www", www.Name)
+	require.NotNil(t, www.TTL)
+	assert.Equal(t, uint32(600), *www.TTL)
+	assert.Equal(t, "IN", www.Class)
+	assert.Equal(t, "A", www.RRType)
+	assert.Equal(t, []string{"192.0.2.1"}, www.RData)
+	assert.Equal(t, "; primary", www.Comment)
+
+	txt := doc.Entries[8]
+	assert.Equal(t, "", txt.Name)
+	assert.Nil(t, txt.TTL)
+	assert.Equal(t, []string{`"v=spf1 -all"`, `"second string"`}, txt.RData)
+
+	soa := doc.Entries[4]
+	require.NotNil(t, soa.Layout)
+	assert.Equal(t, 2, soa.Layout.Start)
+	assert.Equal(t, 7, soa.Layout.End)
+	assert.Equal(t, "; serial", soa.Layout.Fields[2].Comment)
+
+	generate := doc.Entries[9]
+	assert.Equal(t, "1-10/2", generate.Range)
+	assert.Equal(t, "host$", generate.Name)
+	assert.Equal(t, []string{"192.0.2.$"}, generate.RData)
+
+	include := doc.Entries[10]
+	assert.Equal(t, "hosts.zone", include.File)
+	assert.Equal(t, "hosts.example.com.", include.Name)
+
+	raw := doc.Entries[11]
+	assert.Equal(t, "mail 1 2 A 192.0.2.2", raw.Text)
+}
+
+func TestFromJSON(t *testing.T) {
+	t.Parallel()
+
+	entries, err := document.FromJSON([]byte(`{
+		"version": 1,
+		"entries": [
+			{"type": "origin", "name": "example.com."},
+			{"type": "ttl", "ttl": 300},
+			{"type": "blank"},
+			{"type": "record", "name": "www", "rrtype": "A", "rdata": ["192.0.2.1"], "comments": ["generated"], "comment": "web"},
+			{"type": "raw", "text": "mail 1 2 A 192.0.2.2"}
+		]
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"$ORIGIN example.com.",
+		"$TTL 300",
+		"",
+		"; generated",
+		"www A 192.0.2.1 ; web",
+		"mail 1 2 A 192.0.2.2",
+		"",
+	}, "\n"), render(t, entries))
+}
+
+func TestFromJSONError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input string
+	}{
+		"not JSON": {
+			input: `entries:`,
+		},
+		"unsupported version": {
+			input: `{"version": 2, "entries": []}`,
+		},
+		"unknown type": {
+			input: `{"version": 1, "entries": [{"type": "bogus"}]}`,
+		},
+		"record without rrtype": {
+			input: `{"version": 1, "entries": [{"type": "record", "name": "www", "rdata": ["192.0.2.1"]}]}`,
+		},
+		"record without rdata": {
+			input: `{"version": 1, "entries": [{"type": "record", "name": "www", "rrtype": "A"}]}`,
+		},
+		"bad generate range": {
+			input: `{"version": 1, "entries": [{"type": "generate", "range": "10-1", "name": "h$", "rrtype": "A", "rdata": ["192.0.2.$"]}]}`,
+		},
+		"layout out of bounds": {
+			input: `{"version": 1, "entries": [{"type": "record", "name": "www", "rrtype": "A", "rdata": ["192.0.2.1"], "layout": {"start": 0, "end": 2}}]}`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := document.FromJSON([]byte(tc.input))
+			assert.ErrorIs(t, err, document.ErrInvalidDocument)
+		})
+	}
+}
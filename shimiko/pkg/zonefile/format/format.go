@@ -0,0 +1,244 @@
+// Package format puts zone files into a canonical layout: the leading $ORIGIN
+// and $TTL entries first, then the SOA and NS records of the apex and then
+// the remaining records in a stable, total order. Control entries and
+// standalone comment blocks stay where they are relative to the records
+// around them, and comments directly above a record move with it.
+package format
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/dnssec"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+)
+
+// unit is an entry together with the comment lines directly above it, or a
+// block of comment lines that isn't attached to any entry.
+type unit struct {
+	nodes []ast.Node
+	// entry is the index of the entry in nodes, or -1 for comment blocks.
+	entry int
+}
+
+func (u unit) node() ast.Node {
+	return u.nodes[u.entry]
+}
+
+func (u unit) isRREntry() bool {
+	return u.entry >= 0 && u.node().IsRREntry()
+}
+
+func (u unit) isContextEntry() bool {
+	return u.entry >= 0 && (u.node().IsOriginControlEntry() || u.node().IsTTLControlEntry())
+}
+
+func isComment(node ast.Node) bool {
+	return node.NodeType == ast.NodeTypeEmpty && (len(node.LeadComments) > 0 || node.LineComment != "")
+}
+
+// Format returns entries in the canonical layout. origin is the origin in
+// effect before the first entry, as for parser.Resolve.
+//
+// Records are sorted by owner name in canonical DNS order, then by class,
+// type, RDATA and TTL. Since that moves records away from their neighbours,
+// blank owners are filled in and TTLs inherited from the previous record
+// (when there is no $TTL) are made explicit. Records are only sorted between
+// control entries and comment blocks, so a record never moves to a place
+// where a different $ORIGIN or $TTL applies.
+func Format(entries []ast.Node, origin string) ([]ast.Node, error) {
+	resolved, err := parser.Resolve(entries, origin)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving entries: %w", err)
+	}
+
+	units := []unit{}
+	comments := []ast.Node{}
+	flushComments := func() {
+		if len(comments) > 0 {
+			units = append(units, unit{nodes: comments, entry: -1})
+			comments = []ast.Node{}
+		}
+	}
+	var (
+		previousOwner  string
+		previousOrigin string
+		hasTTL         bool
+	)
+	for i, node := range entries {
+		switch {
+		case isComment(node):
+			comments = append(comments, node)
+			continue
+		case node.NodeType == ast.NodeTypeEmpty:
+			flushComments()
+			continue
+		case node.IsTTLControlEntry():
+			hasTTL = true
+		case node.IsRREntry():
+			entry := node.RREntry()
+			info := resolved[i].RREntry().Resolved
+			if entry.DomainName == "" {
+				if previousOrigin == info.Origin {
+					entry.DomainName = previousOwner
+				} else {
+					entry.DomainName = info.Owner
+				}
+			}
+			if entry.RRecord.TTL == 0 && !hasTTL {
+				entry.RRecord.TTL = info.TTL
+			}
+			previousOwner = entry.DomainName
+			previousOrigin = info.Origin
+			entry.Resolved = info
+			node.Entry = entry
+		}
+		units = append(units, unit{nodes: append(comments, node), entry: len(comments)})
+		comments = []ast.Node{}
+	}
+	flushComments()
+
+	formatted := []ast.Node{}
+	appendEmpty := func() {
+		if len(formatted) == 0 {
+			return
+		}
+		last := formatted[len(formatted)-1]
+		if last.NodeType != ast.NodeTypeEmpty || isComment(last) {
+			formatted = append(formatted, ast.Node{
+				NodeType: ast.NodeTypeEmpty,
+			})
+		}
+	}
+	// comment blocks keep the blank line below them so that they don't end
+	// up attached to the next entry
+	afterComments := false
+	appendUnits := func(units []unit) {
+		for _, u := range units {
+			if afterComments {
+				appendEmpty()
+			}
+			afterComments = u.entry < 0
+			for _, node := range u.nodes {
+				if node.IsRREntry() {
+					entry := node.RREntry()
+					entry.Resolved = nil
+					node.Entry = entry
+				}
+				formatted = append(formatted, node)
+			}
+		}
+	}
+
+	// leading comments, $ORIGIN and $TTL
+	start := 0
+	for start < len(units) && (units[start].entry < 0 || units[start].isContextEntry()) {
+		start++
+	}
+	appendUnits(units[:start])
+	appendEmpty()
+	units = units[start:]
+
+	// the SOA and NS records of the apex, as long as they come before
+	// anything that changes the context of the records
+	apex := map[string][]unit{}
+	rest := []unit{}
+	hoisting := true
+	for _, u := range units {
+		if u.isContextEntry() {
+			hoisting = false
+		}
+		if hoisting && u.isRREntry() {
+			entry := u.node().RREntry()
+			rrtype := strings.ToUpper(entry.RRecord.Type)
+			if (rrtype == "SOA" || rrtype == "NS") && strings.EqualFold(entry.Resolved.Owner, entry.Resolved.Origin) {
+				apex[rrtype] = append(apex[rrtype], u)
+				continue
+			}
+		}
+		rest = append(rest, u)
+	}
+	for _, rrtype := range []string{"SOA", "NS"} {
+		slices.SortStableFunc(apex[rrtype], compareUnits)
+		appendUnits(apex[rrtype])
+	}
+
+	group := []unit{}
+	flushGroup := func() {
+		if len(group) == 0 {
+			return
+		}
+		appendEmpty()
+		slices.SortStableFunc(group, compareUnits)
+		appendUnits(group)
+		group = []unit{}
+	}
+	for _, u := range rest {
+		if u.isRREntry() {
+			group = append(group, u)
+			continue
+		}
+		flushGroup()
+		appendEmpty()
+		appendUnits([]unit{u})
+	}
+	flushGroup()
+
+	return formatted, nil
+}
+
+// compareUnits orders two units holding resolved records.
+func compareUnits(a unit, b unit) int {
+	return Compare(a.node().RREntry(), b.node().RREntry())
+}
+
+// Compare orders two resolved records by owner name in canonical DNS order,
+// then by class, type, RDATA and TTL, and finally by the owner name as
+// written.
+func Compare(a ast.RREntry, b ast.RREntry) int {
+	n := dnssec.CompareNames(a.Resolved.Owner, b.Resolved.Owner)
+	if n != 0 {
+		return n
+	}
+	n = strings.Compare(a.Resolved.Class, b.Resolved.Class)
+	if n != 0 {
+		return n
+	}
+	n = strings.Compare(strings.ToUpper(a.RRecord.Type), strings.ToUpper(b.RRecord.Type))
+	if n != 0 {
+		return n
+	}
+	n = slices.CompareFunc(a.RRecord.RData, b.RRecord.RData, func(a ast.RData, b ast.RData) int {
+		return strings.Compare(a.Value, b.Value)
+	})
+	if n != 0 {
+		return n
+	}
+	n = cmp.Compare(a.Resolved.TTL, b.Resolved.TTL)
+	if n != 0 {
+		return n
+	}
+	return strings.Compare(a.DomainName, b.DomainName)
+}
+
+// Source formats the zone file in data and returns it rendered again.
+func Source(data []byte, origin string) ([]byte, error) {
+	entries, err := parser.ParseReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	entries, err = Format(entries, origin)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := parser.Tokenize(entries)
+	if err != nil {
+		return nil, fmt.Errorf("error tokenizing entries: %w", err)
+	}
+	return token.RenderTokens(tokens), nil
+}
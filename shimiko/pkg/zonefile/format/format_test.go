@@ -0,0 +1,213 @@
+package format_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/format"
+)
+
+func TestSource(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input    string
+		origin   string
+		expected string
+	}{
+		"apex records first": {
+			input: strings.Join([]string{
+				"$ORIGIN example.com.",
+				"$TTL 300",
+				"www A 192.0.2.1",
+				"@ NS ns2",
+				"@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300",
+				"@ NS ns1",
+				"",
+			}, "\n"),
+			expected: strings.Join([]string{
+				"$ORIGIN example.com.",
+				"$TTL 300",
+				"",
+				"@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300",
+				"@ NS ns1",
+				"@ NS ns2",
+				"",
+				"www A 192.0.2.1",
+				"",
+			}, "\n"),
+		},
+		"canonical name order": {
+			input: strings.Join([]string{
+				"b A 192.0.2.2",
+				"a.b A 192.0.2.3",
+				"A MX 10 mail",
+				"a A 192.0.2.1",
+				"a AAAA 2001:db8::1",
+				"",
+			}, "\n"),
+			origin: "example.com.",
+			expected: strings.Join([]string{
+				"a A 192.0.2.1",
+				"a AAAA 2001:db8::1",
+				"A MX 10 mail",
+				"b A 192.0.2.2",
+				"a.b A 192.0.2.3",
+				"",
+			}, "\n"),
+		},
+		"records with the same name and type are ordered by RDATA": {
+			input: strings.Join([]string{
+				"www A 192.0.2.2",
+				"www A 192.0.2.1",
+				"",
+			}, "\n"),
+			origin: "example.com.",
+			expected: strings.Join([]string{
+				"www A 192.0.2.1",
+				"www A 192.0.2.2",
+				"",
+			}, "\n"),
+		},
+		"comments": {
+			input: strings.Join([]string{
+				"; example.com",
+				"",
+				"; the web server",
+				"www A 192.0.2.1 ; primary",
+				"; the mail server",
+				"mail A 192.0.2.2",
+				"",
+				"; static hosts",
+				"",
+				"b A 192.0.2.4",
+				"a A 192.0.2.3",
+				"; end",
+				"",
+			}, "\n"),
+			origin: "example.com.",
+			expected: strings.Join([]string{
+				"; example.com",
+				"",
+				"; the mail server",
+				"mail A 192.0.2.2",
+				"; the web server",
+				"www A 192.0.2.1 ; primary",
+				"",
+				"; static hosts",
+				"",
+				"a A 192.0.2.3",
+				"b A 192.0.2.4",
+				"",
+				"; end",
+				"",
+			}, "\n"),
+		},
+		"blank owners are filled in": {
+			input: strings.Join([]string{
+				"www A 192.0.2.1",
+				"    TXT \"web\"",
+				"mail A 192.0.2.2",
+				"",
+			}, "\n"),
+			origin: "example.com.",
+			expected: strings.Join([]string{
+				"mail A 192.0.2.2",
+				"www A 192.0.2.1",
+				"www TXT \"web\"",
+				"",
+			}, "\n"),
+		},
+		"inherited TTLs are made explicit without $TTL": {
+			input: strings.Join([]string{
+				"www 600 A 192.0.2.1",
+				"mail A 192.0.2.2",
+				"",
+			}, "\n"),
+			origin: "example.com.",
+			expected: strings.Join([]string{
+				"mail 600 A 192.0.2.2",
+				"www 600 A 192.0.2.1",
+				"",
+			}, "\n"),
+		},
+		"records don't cross control entries": {
+			input: strings.Join([]string{
+				"$ORIGIN example.com.",
+				"b A 192.0.2.2",
+				"a A 192.0.2.1",
+				"$GENERATE 1-2 host$ A 192.0.2.$",
+				"$ORIGIN sub.example.com.",
+				"@ NS ns1",
+				"d A 192.0.2.4",
+				"c A 192.0.2.3",
+				"",
+			}, "\n"),
+			expected: strings.Join([]string{
+				"$ORIGIN example.com.",
+				"",
+				"a A 192.0.2.1",
+				"b A 192.0.2.2",
+				"",
+				"$GENERATE 1-2 host$ A 192.0.2.$",
+				"",
+				"$ORIGIN sub.example.com.",
+				"",
+				"@ NS ns1",
+				"c A 192.0.2.3",
+				"d A 192.0.2.4",
+				"",
+			}, "\n"),
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := format.Source([]byte(tc.input), tc.origin)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(got))
+
+			again, err := format.Source(got, tc.origin)
+			require.NoError(t, err)
+			assert.Equal(t, string(got), string(again), "formatting is not idempotent")
+		})
+	}
+}
+
+func TestSourceOrderIndependent(t *testing.T) {
+	t.Parallel()
+
+	lines := []string{
+		"www 300 A 192.0.2.1",
+		"www 300 AAAA 2001:db8::1",
+		"www 600 A 192.0.2.1",
+		"WWW 300 A 192.0.2.1",
+		"mail 300 MX 10 mx1",
+		"mail 300 MX 20 mx2",
+		"*.dev 300 CNAME www",
+		"_dmarc 300 TXT \"v=DMARC1; p=none\"",
+	}
+
+	expected, err := format.Source([]byte(strings.Join(lines, "\n")+"\n"), "example.com.")
+	require.NoError(t, err)
+
+	for i := range lines {
+		rotated := append(append([]string{}, lines[i:]...), lines[:i]...)
+		got, err := format.Source([]byte(strings.Join(rotated, "\n")+"\n"), "example.com.")
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(got))
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	t.Parallel()
+
+	_, err := format.Source([]byte("www A 192.0.2.1\n"), "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,292 @@
+package lexer
+
+import (
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ttl"
+)
+
+// scanner lexes single tokens out of buf. It is shared by LexerState, which
+// scans a whole file held in memory, and Lexer, which scans one line at a
+// time. Literals and whitespace of the returned tokens are sub-slices of buf
+// with their capacity capped so appending to them never clobbers buf.
+type scanner struct {
+	buf                []byte
+	pos                int
+	previous           token.Token
+	inLineContinuation bool
+
+	// literalStart is the offset in buf of the literal of the last token
+	// returned by next.
+	literalStart int
+}
+
+var (
+	dnsTypeSet  = stringSet(DNSTypes)
+	dnsClassSet = stringSet(DNSClasses)
+)
+
+func stringSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		set[value] = struct{}{}
+	}
+	return set
+}
+
+// isDNSType reports whether b is a known type or a type in the generic
+// "TYPEnnn" form of RFC 3597.
+func isDNSType(b []byte) bool {
+	if _, ok := dnsTypeSet[string(b)]; ok {
+		return true
+	}
+	_, ok := ast.ParseGenericType(string(b))
+	return ok
+}
+
+// isDNSClass reports whether b is a known class or a class in the generic
+// "CLASSnnn" form of RFC 3597.
+func isDNSClass(b []byte) bool {
+	if _, ok := dnsClassSet[string(b)]; ok {
+		return true
+	}
+	_, ok := ast.ParseGenericClass(string(b))
+	return ok
+}
+
+// slice returns buf[start:end] with its capacity capped, or an empty non-nil
+// slice if buf is nil.
+func (s *scanner) slice(start int, end int) []byte {
+	if s.buf == nil {
+		return []byte{}
+	}
+	return s.buf[start:end:end]
+}
+
+// next scans the token starting at pos. It returns an EOF token once only
+// whitespace is left in buf.
+func (s *scanner) next() token.Token {
+	buf := s.buf
+	i := s.pos
+	for i < len(buf) && IsSpace(buf[i]) {
+		i++
+	}
+	tok := token.Token{
+		Type:             token.ILLEGAL,
+		WhiteSpaceBefore: s.slice(s.pos, i),
+	}
+	s.literalStart = i
+
+	if i >= len(buf) {
+		s.pos = i
+		tok.Type = token.EOF
+		tok.Literal = s.slice(i, i)
+		return tok
+	}
+
+	switch ch := buf[i]; {
+	case ch == '\n':
+		i++
+		tok.Type = token.NEWLINE
+
+	case ch == '\r':
+		i++
+		if i < len(buf) && buf[i] == '\n' {
+			i++
+		}
+		tok.Type = token.NEWLINE
+
+	case ch == ';':
+		for i < len(buf) && !IsNewline(buf[i]) {
+			i++
+		}
+		tok.Type = token.COMMENT
+
+	default:
+		i, tok.Type = s.scanLiteral(i)
+	}
+
+	tok.Literal = s.slice(s.literalStart, i)
+	s.pos = i
+	return tok
+}
+
+// scanLiteral finds the end of the literal starting at i and classifies it.
+// Following RFC 1035 section 5.1 a backslash escapes the next character, so
+// escaped whitespace, quotes, semicolons and parentheses don't end the
+// literal, and nothing but a line break ends a quoted string. A literal that
+// starts with a quote ends with the closing quote, so quoted strings without
+// whitespace in between are still separate literals.
+func (s *scanner) scanLiteral(i int) (int, token.TokenType) {
+	buf := s.buf
+	start := i
+	inQuote := false
+
+	for i < len(buf) {
+		ch := buf[i]
+		if ch == '\\' && i+1 < len(buf) && !IsNewline(buf[i+1]) {
+			i += 2
+			continue
+		}
+		if IsNewline(ch) {
+			break
+		}
+		if ch == '"' {
+			inQuote = !inQuote
+			i++
+			if !inQuote && buf[start] == '"' {
+				break
+			}
+			continue
+		}
+		if inQuote {
+			i++
+			continue
+		}
+		// comments don't need any whitespace before them
+		if IsSpace(ch) || ch == ';' {
+			break
+		}
+		// a closing parenthesis outside of a group is still one, so that the
+		// parser can reject it instead of taking it for RDATA
+		if ch == ')' {
+			if i == start {
+				s.inLineContinuation = false
+				return i + 1, token.RDATA_CPAREN
+			}
+			break
+		}
+		if !s.inLineContinuation && ch == '(' && (s.previous.Type == token.RDATA || s.previous.Type == token.TYPE) {
+			if i == start {
+				s.inLineContinuation = true
+				return i + 1, token.RDATA_OPAREN
+			}
+			break
+		}
+		i++
+	}
+
+	return i, s.classify(buf[start:i], i)
+}
+
+// classify determines the type of a complete literal based on the previous
+// token. end is the offset just past the literal and is used to look at the
+// rest of the line when the first field of a line is ambiguous.
+func (s *scanner) classify(literal []byte, end int) token.TokenType {
+	previous := s.previous
+
+	switch {
+	// If we're in a line continuation, assume RDATA until we hit RDATA_CPAREN
+	case s.inLineContinuation:
+		return token.RDATA
+
+	case previous.Type == token.CONTROL_ENTRY && string(previous.Literal) == "$TTL":
+		return token.TTL
+
+	case previous.Type == token.CONTROL_ENTRY && string(previous.Literal) == "$ORIGIN":
+		return token.DOMAIN_NAME
+
+	case previous.Type == token.CONTROL_ENTRY && string(previous.Literal) == "$INCLUDE":
+		return token.FILE_NAME
+
+	case previous.Type == token.CONTROL_ENTRY && string(previous.Literal) == "$GENERATE":
+		return token.RANGE
+
+	case previous.Type == token.FILE_NAME || previous.Type == token.RANGE:
+		return token.DOMAIN_NAME
+
+	case previous.Type == token.NEWLINE && literal[0] == '$':
+		return token.CONTROL_ENTRY
+
+	// The owner can only be left out by starting the line with whitespace, so
+	// the first field of any other line is the owner, even if it looks like a
+	// TTL, class or type.
+	case previous.Type == token.NEWLINE && s.atLineStart(end-len(literal)):
+		return token.DOMAIN_NAME
+
+	case previous.Type == token.TYPE || previous.Type == token.RDATA:
+		return token.RDATA
+	}
+
+	tokType := token.ILLEGAL
+	switch {
+	case isDNSType(literal):
+		tokType = token.TYPE
+
+	case isDNSClass(literal):
+		tokType = token.CLASS
+
+	// TTL and class can be swapped and might not have a domain name in front
+	// of it, so try to figure out which it is based on the previous token.
+	case previous.Type == token.CLASS || previous.Type == token.DOMAIN_NAME || previous.Type == token.NEWLINE:
+		if ttl.Valid(literal) {
+			tokType = token.TTL
+		} else {
+			tokType = token.DOMAIN_NAME
+		}
+	}
+
+	// "Fix" the first field in a line because sometimes a TTL or type might
+	// actually be a domain name.
+	if previous.Type == token.NEWLINE &&
+		(tokType == token.TYPE || tokType == token.TTL) &&
+		end < len(s.buf) && IsSpace(s.buf[end]) {
+		next, _ := s.field(end)
+		if len(next) > 0 {
+			// If a future field is a type but we already think the current token
+			// is a type, it probably is actually a domain name.
+			if tokType == token.TYPE && isDNSType(next) {
+				return token.DOMAIN_NAME
+			}
+
+			// If we think this record is a PTR then this token is more likely to
+			// be a domain name than a TTL.
+			if tokType == token.TTL && s.restOfLineContains(end, "PTR") {
+				return token.DOMAIN_NAME
+			}
+
+			// If the next field is a number it is probably a TTL and so this token
+			// is actually a domain name.
+			if tokType == token.TTL && ttl.Valid(next) {
+				return token.DOMAIN_NAME
+			}
+		}
+	}
+
+	return tokType
+}
+
+// atLineStart reports whether offset i is at the start of a line of buf.
+func (s *scanner) atLineStart(i int) bool {
+	return i == 0 || IsNewline(s.buf[i-1])
+}
+
+// field returns the whitespace separated field starting at or after i on the
+// current line and the offset just past it. It returns an empty field at the
+// end of the line.
+func (s *scanner) field(i int) ([]byte, int) {
+	buf := s.buf
+	for i < len(buf) && IsSpace(buf[i]) {
+		i++
+	}
+	start := i
+	for i < len(buf) && !IsSpaceOrNewline(buf[i]) {
+		i++
+	}
+	return buf[start:i], i
+}
+
+// restOfLineContains reports whether any field after i on the current line
+// is value.
+func (s *scanner) restOfLineContains(i int, value string) bool {
+	for {
+		field, end := s.field(i)
+		if len(field) == 0 {
+			return false
+		}
+		if string(field) == value {
+			return true
+		}
+		i = end
+	}
+}
@@ -0,0 +1,148 @@
+package lexer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+)
+
+// Lexer lexes a zone file from an io.Reader one line at a time. Unlike
+// LexerState it never holds more than the current line and the previous
+// token, so memory use doesn't grow with the size of the zone.
+type Lexer struct {
+	reader  *bufio.Reader
+	scanner scanner
+
+	// offset of the current line in the input and its 1-based number
+	lineOffset int
+	line       int
+
+	readAll bool
+	eof     *token.Token
+}
+
+func NewLexer(r io.Reader) *Lexer {
+	return &Lexer{
+		reader: bufio.NewReader(r),
+		scanner: scanner{
+			// Assume infinite 0-byte newlines before the start of the file.
+			previous: token.Token{
+				Type:             token.NEWLINE,
+				Literal:          []byte{},
+				WhiteSpaceBefore: []byte{},
+			},
+		},
+	}
+}
+
+// NewLexerAt is like NewLexer, but the positions of the tokens are as if r
+// started at start, which must be at the beginning of a line.
+func NewLexerAt(r io.Reader, start token.Position) *Lexer {
+	lexer := NewLexer(r)
+	lexer.lineOffset = start.Offset
+	lexer.line = start.Line - 1
+	return lexer
+}
+
+// Next returns the next token. Once the input is exhausted it keeps returning
+// an EOF token. An error is only returned if reading from the underlying
+// reader fails.
+func (lexer *Lexer) Next() (token.Token, error) {
+	if lexer.eof != nil {
+		return *lexer.eof, nil
+	}
+
+	for lexer.scanner.pos >= len(lexer.scanner.buf) {
+		if lexer.readAll {
+			return lexer.finish(token.Token{
+				Type:             token.EOF,
+				Literal:          []byte{},
+				WhiteSpaceBefore: []byte{},
+			}), nil
+		}
+		err := lexer.readLine()
+		if err != nil {
+			return token.Token{}, err
+		}
+	}
+
+	tok := lexer.scanner.next()
+	tok.Position = lexer.position(lexer.scanner.literalStart)
+	if tok.Type == token.EOF {
+		// only whitespace was left on the last line
+		return lexer.finish(tok), nil
+	}
+	lexer.scanner.previous = tok
+	return tok, nil
+}
+
+// NextLine returns the tokens up to and including the next NEWLINE or EOF
+// token.
+func (lexer *Lexer) NextLine() ([]token.Token, error) {
+	toks := []token.Token{}
+	for {
+		tok, err := lexer.Next()
+		if err != nil {
+			return toks, err
+		}
+		toks = append(toks, tok)
+		if tok.Type == token.NEWLINE || tok.Type == token.EOF {
+			return toks, nil
+		}
+	}
+}
+
+// AllTokens returns all remaining tokens including the final EOF token.
+func (lexer *Lexer) AllTokens() ([]token.Token, error) {
+	toks := []token.Token{}
+	for {
+		tok, err := lexer.Next()
+		if err != nil {
+			return toks, err
+		}
+		toks = append(toks, tok)
+		if tok.Type == token.EOF {
+			return toks, nil
+		}
+	}
+}
+
+// readLine replaces the scanner's buffer with the next line of input. Every
+// line gets a buffer of its own since the tokens returned for it refer to it.
+func (lexer *Lexer) readLine() error {
+	line, err := lexer.reader.ReadBytes('\n')
+	if errors.Is(err, io.EOF) {
+		lexer.readAll = true
+	} else if err != nil {
+		return fmt.Errorf("error reading zone file: %w", err)
+	}
+
+	// ReadBytes only returns a chunk without a line break at the end of the
+	// input, so every chunk is a line of its own; at worst an empty one.
+	lexer.lineOffset += len(lexer.scanner.buf)
+	lexer.line++
+	lexer.scanner.buf = line
+	lexer.scanner.pos = 0
+	return nil
+}
+
+// position returns the position of offset in the current line.
+func (lexer *Lexer) position(offset int) token.Position {
+	return token.Position{
+		Offset: lexer.lineOffset + offset,
+		Line:   lexer.line,
+		Column: offset + 1,
+	}
+}
+
+// finish records the EOF token so that later calls return it again.
+func (lexer *Lexer) finish(tok token.Token) token.Token {
+	if !tok.Position.IsValid() {
+		tok.Position = lexer.position(lexer.scanner.pos)
+	}
+	lexer.eof = &tok
+	return tok
+}
@@ -0,0 +1,163 @@
+package lexer_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lexer"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+)
+
+func TestLexer(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"empty":                        "",
+		"single record":                "www IN A 192.0.2.1\n",
+		"no trailing newline":          "www IN A 192.0.2.1",
+		"trailing whitespace":          "www IN A 192.0.2.1   ",
+		"blank lines":                  "\n\n  \n",
+		"crlf":                         "a A 192.0.2.1\r\nb A 192.0.2.2\r\n",
+		"comments":                     "; header\nwww A 192.0.2.1 ; web\n\t; indented\n",
+		"control entries":              "$ORIGIN example.com.\n$TTL 1h\n$INCLUDE db.sub sub\n",
+		"ttl and class swapped":        "a 300 IN A 192.0.2.1\nb IN 1D A 192.0.2.2\n\t600 AAAA 2001:db8::1\n",
+		"owner that looks like a type": "MX A 192.0.2.1\nA 300 A 192.0.2.2\n",
+		"owners that look like ttls":   "www A 192.0.2.1\n2h A 192.0.2.2\n1w IN A 192.0.2.3\n",
+		"multi-line soa": "@ IN SOA ns host (\n" +
+			"\t1 ; serial\n" +
+			"\t2 3 4\n" +
+			"\t5 )\n",
+		"txt":  "txt TXT \"v=spf1 -all\" \"second\\\"quoted\\\"\"\n",
+		"long": strings.Repeat("x", 10000) + " A 192.0.2.1\n",
+	}
+
+	for name, input := range tests {
+		input := input
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			expected := lexer.LexBytes([]byte(input)).AllTokens()
+
+			lex := lexer.NewLexer(strings.NewReader(input))
+			got, err := lex.AllTokens()
+			require.NoError(t, err)
+			assert.Equal(t, expected, got)
+			assert.Equal(t, input, string(token.RenderTokens(got)))
+
+			// keeps returning EOF
+			tok, err := lex.Next()
+			require.NoError(t, err)
+			assert.Equal(t, token.EOF, tok.Type)
+		})
+	}
+}
+
+func TestLexerOneByteReads(t *testing.T) {
+	t.Parallel()
+
+	input := "$TTL 1h\n@ SOA ns host ( 1 2\n 3 4 5 )\nwww A 192.0.2.1 ; web"
+
+	lex := lexer.NewLexer(iotest.OneByteReader(strings.NewReader(input)))
+	got, err := lex.AllTokens()
+	require.NoError(t, err)
+	assert.Equal(t, lexer.LexBytes([]byte(input)).AllTokens(), got)
+}
+
+func TestLexerNextLine(t *testing.T) {
+	t.Parallel()
+
+	lex := lexer.NewLexer(strings.NewReader("a A 192.0.2.1\nb A 192.0.2.2"))
+
+	line, err := lex.NextLine()
+	require.NoError(t, err)
+	assert.Equal(t, "a A 192.0.2.1\n", string(token.RenderTokens(line)))
+
+	line, err = lex.NextLine()
+	require.NoError(t, err)
+	assert.Equal(t, "b A 192.0.2.2", string(token.RenderTokens(line)))
+	assert.Equal(t, token.EOF, line[len(line)-1].Type)
+	assert.Equal(t, token.Position{Offset: 27, Line: 2, Column: 14}, line[len(line)-1].Position)
+}
+
+func TestNewLexerAt(t *testing.T) {
+	t.Parallel()
+
+	head := "$TTL 1h\n@ SOA ns host 1 2 3 4 5\n"
+	input := "www A 192.0.2.1\n\tTXT ( \"a\"\n\t\"b\" )\n"
+
+	expected := lexer.LexBytes([]byte(head + input)).AllTokens()
+	expected = expected[len(lexer.LexBytes([]byte(head)).AllTokens())-1:]
+
+	lex := lexer.NewLexerAt(strings.NewReader(input), token.Position{Offset: len(head), Line: 3, Column: 1})
+	got, err := lex.AllTokens()
+	require.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestLexerReadError(t *testing.T) {
+	t.Parallel()
+
+	readErr := errors.New("connection reset")
+	lex := lexer.NewLexer(iotest.ErrReader(readErr))
+
+	_, err := lex.AllTokens()
+	assert.ErrorIs(t, err, readErr)
+}
+
+// benchmarkZone returns a zone file with n A records plus a few other record
+// types, roughly what a large zone looks like.
+func benchmarkZone(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("$ORIGIN example.com.\n$TTL 1h\n")
+	buf.WriteString("@ IN SOA ns1 hostmaster (\n\t2024010101 ; serial\n\t3600 600 604800 300 )\n")
+	buf.WriteString("@ IN NS ns1\n@ IN MX 10 mail\n")
+	for i := 0; i < n; i++ {
+		switch i % 10 {
+		case 0:
+			fmt.Fprintf(&buf, "host%d 300 IN TXT \"record %d\" ; comment\n", i, i)
+		case 1:
+			fmt.Fprintf(&buf, "host%d IN AAAA 2001:db8::%x\n", i, i)
+		default:
+			fmt.Fprintf(&buf, "host%d IN A 10.%d.%d.%d\n", i, (i>>16)&0xff, (i>>8)&0xff, i&0xff)
+		}
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkLexer(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		zone := benchmarkZone(n)
+
+		b.Run(fmt.Sprintf("stream/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(zone)))
+			for i := 0; i < b.N; i++ {
+				lex := lexer.NewLexer(bytes.NewReader(zone))
+				for {
+					tok, err := lex.Next()
+					if err != nil {
+						b.Fatal(err)
+					}
+					if tok.Type == token.EOF {
+						break
+					}
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("bytes/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(zone)))
+			for i := 0; i < b.N; i++ {
+				lexer.LexBytes(zone).AllTokens()
+			}
+		})
+	}
+}
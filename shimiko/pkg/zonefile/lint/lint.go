@@ -0,0 +1,416 @@
+// Package lint checks parsed zone files for semantic problems that the parser
+// can't catch, such as a CNAME sharing its name with other data or an in-zone
+// name server without address records.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+)
+
+var ErrProblems = errors.New("zone has problems")
+
+type Severity string
+
+const (
+	// SeverityError is used for problems that make name servers reject the
+	// zone or serve wrong answers.
+	SeverityError Severity = "error"
+	// SeverityWarning is used for problems that are technically allowed or
+	// tolerated by name servers but almost certainly a mistake.
+	SeverityWarning Severity = "warning"
+)
+
+type Check string
+
+const (
+	CheckCNAMEAndOtherData Check = "cname-and-other-data"
+	CheckDuplicateRR       Check = "duplicate-rr"
+	CheckTTLMismatch       Check = "ttl-mismatch"
+	CheckSOACount          Check = "soa-count"
+	CheckMissingGlue       Check = "missing-glue"
+	CheckTargetIsCNAME     Check = "target-is-cname"
+	CheckOutOfZone         Check = "out-of-zone"
+	// CheckUnparseable reports the raw entries kept by
+	// parser.ParseReaderTolerant. It is only a warning so that a broken line
+	// doesn't block changes to the rest of the zone.
+	CheckUnparseable Check = "unparseable"
+)
+
+// Problem is a single finding of Lint.
+type Problem struct {
+	Check    Check
+	Severity Severity
+	// Owner is the absolute owner name of the record the problem was found
+	// on, if any.
+	Owner string
+	// Type is the type of the record the problem was found on, if any.
+	Type string
+	// Node is the index of the offending node in the linted entries, or -1
+	// if the problem is about the zone as a whole.
+	Node int
+	// SourceFile is the file the offending node was loaded from, if known.
+	SourceFile string
+	// Position is where the offending node starts in SourceFile, if known.
+	Position token.Position
+	Message  string
+}
+
+func (problem Problem) String() string {
+	location := ""
+	if problem.SourceFile != "" {
+		location = problem.SourceFile + ":"
+	}
+	if problem.Position.IsValid() {
+		location += problem.Position.String() + ":"
+	}
+	if location != "" {
+		location += " "
+	}
+	return fmt.Sprintf("%s%s: %s [%s]", location, problem.Severity, problem.Message, problem.Check)
+}
+
+// HasErrors reports whether any of problems has SeverityError.
+func HasErrors(problems []Problem) bool {
+	for _, problem := range problems {
+		if problem.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate runs Lint and turns problems with SeverityError into an error
+// wrapping ErrProblems. Warnings are returned alongside.
+func Validate(entries []ast.Node, origin string) ([]Problem, error) {
+	problems, err := Lint(entries, origin)
+	if err != nil {
+		return problems, err
+	}
+	errs := []string{}
+	for _, problem := range problems {
+		if problem.Severity == SeverityError {
+			errs = append(errs, problem.String())
+		}
+	}
+	if len(errs) > 0 {
+		return problems, fmt.Errorf("%w: %s", ErrProblems, strings.Join(errs, "; "))
+	}
+	return problems, nil
+}
+
+// record is a resolved RREntry together with its position in the entries.
+type record struct {
+	// id is the index of the record in the zone; node is not unique since a
+	// $GENERATE entry yields many records.
+	id     int
+	node   int
+	file   string
+	pos    token.Position
+	owner  string
+	class  string
+	rrtype string
+	ttl    time.Duration
+	origin string
+	rdata  []ast.RData
+}
+
+func (r record) problem(check Check, severity Severity, format string, args ...any) Problem {
+	return Problem{
+		Check:      check,
+		Severity:   severity,
+		Owner:      r.owner,
+		Type:       r.rrtype,
+		Node:       r.node,
+		SourceFile: r.file,
+		Position:   r.pos,
+		Message:    fmt.Sprintf(format, args...),
+	}
+}
+
+// target returns the absolute form of the domain name in an RDATA field.
+func (r record) target(name string) string {
+	return canonicalName(ast.AbsoluteName(name, r.origin))
+}
+
+// rrsetKey identifies an RRset: all records with the same owner, class and
+// type.
+type rrsetKey struct {
+	owner  string
+	class  string
+	rrtype string
+}
+
+func (r record) rrsetKey() rrsetKey {
+	return rrsetKey{owner: r.owner, class: r.class, rrtype: r.rrtype}
+}
+
+// Lint resolves entries against origin (see parser.Resolve) and checks them
+// for semantic problems. The zone apex is the owner of the SOA record if
+// there is exactly one, otherwise origin. $GENERATE entries are expanded and
+// problems with the generated records are reported on the $GENERATE entry.
+// Problems are returned in the order of the entries they were found on. An
+// error is only returned if the entries can't be expanded or resolved.
+func Lint(entries []ast.Node, origin string) ([]Problem, error) {
+	expanded := make([]ast.Node, 0, len(entries))
+	// sources maps the index of an expanded node to its index in entries
+	sources := make([]int, 0, len(entries))
+	for i, node := range entries {
+		nodes, err := parser.ExpandGenerates([]ast.Node{node})
+		if err != nil {
+			return nil, fmt.Errorf("error expanding zone entries: %w", err)
+		}
+		for range nodes {
+			sources = append(sources, i)
+		}
+		expanded = append(expanded, nodes...)
+	}
+
+	resolved, err := parser.Resolve(expanded, origin)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving zone entries: %w", err)
+	}
+
+	records := []record{}
+	for i, node := range resolved {
+		if !node.IsRREntry() {
+			continue
+		}
+		entry := node.RREntry()
+		source := entries[sources[i]]
+		pos := token.Position{}
+		if len(source.SourceTokens) > 0 {
+			pos = source.SourceTokens[0].Position
+		}
+		records = append(records, record{
+			id:     len(records),
+			node:   sources[i],
+			file:   node.SourceFile,
+			pos:    pos,
+			owner:  canonicalName(entry.Resolved.Owner),
+			class:  entry.Resolved.Class,
+			rrtype: strings.ToUpper(entry.RRecord.Type),
+			ttl:    entry.Resolved.TTL,
+			origin: entry.Resolved.Origin,
+			rdata:  entry.RRecord.RData,
+		})
+	}
+
+	problems := []Problem{}
+
+	soas := []record{}
+	for _, r := range records {
+		if r.rrtype == "SOA" {
+			soas = append(soas, r)
+		}
+	}
+	apex := canonicalName(origin)
+	if len(soas) == 1 {
+		apex = soas[0].owner
+	}
+	switch {
+	case len(soas) == 0:
+		problems = append(problems, Problem{
+			Check:    CheckSOACount,
+			Severity: SeverityError,
+			Node:     -1,
+			Message:  "zone has no SOA record",
+		})
+	case len(soas) > 1:
+		for _, soa := range soas[1:] {
+			problems = append(problems, soa.problem(
+				CheckSOACount,
+				SeverityError,
+				"zone has %d SOA records, expected exactly one",
+				len(soas),
+			))
+		}
+	}
+
+	// index the zone by name and by RRset
+	types := map[string]map[string]bool{}
+	rrsets := map[rrsetKey][]record{}
+	for _, r := range records {
+		if types[r.owner] == nil {
+			types[r.owner] = map[string]bool{}
+		}
+		types[r.owner][r.rrtype] = true
+		rrsets[r.rrsetKey()] = append(rrsets[r.rrsetKey()], r)
+	}
+
+	for _, r := range records {
+		if apex != "" && !inZone(r.owner, apex) {
+			problems = append(problems, r.problem(
+				CheckOutOfZone,
+				SeverityError,
+				"%s is outside of the zone %s",
+				r.owner,
+				apex,
+			))
+		}
+
+		if r.rrtype == "CNAME" {
+			for _, other := range slices.Sorted(maps.Keys(types[r.owner])) {
+				if other == "CNAME" || other == "RRSIG" || other == "NSEC" {
+					continue
+				}
+				problems = append(problems, r.problem(
+					CheckCNAMEAndOtherData,
+					SeverityError,
+					"%s has a CNAME record and other data (%s)",
+					r.owner,
+					other,
+				))
+				break
+			}
+		}
+
+		rrset := rrsets[r.rrsetKey()]
+		first := rrset[0]
+		if r.id != first.id {
+			if r.rrtype == "CNAME" {
+				problems = append(problems, r.problem(
+					CheckCNAMEAndOtherData,
+					SeverityError,
+					"%s has more than one CNAME record",
+					r.owner,
+				))
+			}
+			for _, earlier := range rrset {
+				if earlier.id == r.id {
+					break
+				}
+				if rdataKey(earlier) == rdataKey(r) {
+					problems = append(problems, r.problem(
+						CheckDuplicateRR,
+						SeverityWarning,
+						"duplicate %s record for %s",
+						r.rrtype,
+						r.owner,
+					))
+					break
+				}
+			}
+			if r.ttl != first.ttl {
+				problems = append(problems, r.problem(
+					CheckTTLMismatch,
+					SeverityWarning,
+					"TTL %s of %s %s record differs from TTL %s of the first record in the RRset",
+					parser.DurationToSeconds(r.ttl),
+					r.owner,
+					r.rrtype,
+					parser.DurationToSeconds(first.ttl),
+				))
+			}
+		}
+
+		switch r.rrtype {
+		case "NS":
+			if len(r.rdata) != 1 {
+				continue
+			}
+			target := r.target(r.rdata[0].Value)
+			if apex == "" || !inZone(target, apex) {
+				continue
+			}
+			if !types[target]["A"] && !types[target]["AAAA"] {
+				problems = append(problems, r.problem(
+					CheckMissingGlue,
+					SeverityWarning,
+					"name server %s of %s is inside the zone but has no A or AAAA records",
+					target,
+					r.owner,
+				))
+			}
+		case "MX":
+			mx, err := ast.ParseMX(r.rdata)
+			if err != nil {
+				continue
+			}
+			target := r.target(mx.Exchange)
+			if types[target]["CNAME"] {
+				problems = append(problems, r.problem(
+					CheckTargetIsCNAME,
+					SeverityWarning,
+					"MX exchange %s of %s is a CNAME",
+					target,
+					r.owner,
+				))
+			}
+		case "SRV":
+			srv, err := ast.ParseSRV(r.rdata)
+			if err != nil || srv.Target == "." {
+				continue
+			}
+			target := r.target(srv.Target)
+			if types[target]["CNAME"] {
+				problems = append(problems, r.problem(
+					CheckTargetIsCNAME,
+					SeverityWarning,
+					"SRV target %s of %s is a CNAME",
+					target,
+					r.owner,
+				))
+			}
+		}
+	}
+
+	for i, node := range entries {
+		if !node.IsRawEntry() {
+			continue
+		}
+		pos := token.Position{}
+		if len(node.SourceTokens) > 0 {
+			pos = node.SourceTokens[0].Position
+		}
+		problems = append(problems, Problem{
+			Check:      CheckUnparseable,
+			Severity:   SeverityWarning,
+			Node:       i,
+			SourceFile: node.SourceFile,
+			Position:   pos,
+			Message:    fmt.Sprintf("entry can't be parsed and is kept as-is: %v", node.RawEntry().Err),
+		})
+	}
+
+	return problems, nil
+}
+
+// canonicalName lowercases name so names can be compared as map keys.
+func canonicalName(name string) string {
+	return strings.ToLower(name)
+}
+
+// inZone reports whether the canonical absolute name is apex or below it.
+func inZone(name string, apex string) bool {
+	if apex == "." || name == apex {
+		return true
+	}
+	return strings.HasSuffix(name, "."+apex)
+}
+
+// rdataKey returns a comparable representation of the RDATA of r. Typed
+// records are normalized first so that e.g. hex digits in a different case
+// still compare equal.
+func rdataKey(r record) string {
+	rdata := r.rdata
+	if ast.HasTypedRData(r.rrtype) {
+		typed, err := ast.ParseTypedRData(r.rrtype, rdata)
+		if err == nil {
+			rdata = typed.RData()
+		}
+	}
+	values := make([]string, len(rdata))
+	for i, field := range rdata {
+		values[i] = field.Value
+	}
+	return strings.Join(values, " ")
+}
@@ -0,0 +1,187 @@
+package lint_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lexer"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lint"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+)
+
+const soa = "@ IN SOA ns1 hostmaster 1 3600 600 604800 300\n" +
+	"@ IN NS ns1\n" +
+	"ns1 IN A 192.0.2.53\n"
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	type problem struct {
+		Check lint.Check
+		Owner string
+		Node  int
+	}
+
+	tests := map[string]struct {
+		input    string
+		expected []problem
+	}{
+		"clean zone": {
+			input: soa +
+				"www IN A 192.0.2.1\n" +
+				"    IN AAAA 2001:db8::1\n" +
+				"alias IN CNAME www\n" +
+				"@ IN MX 10 mail\n" +
+				"mail IN A 192.0.2.25\n" +
+				"_sip._tcp IN SRV 10 5 5060 sip.example.net.\n",
+			expected: []problem{},
+		},
+		"cname and other data": {
+			input: soa +
+				"www IN CNAME example.net.\n" +
+				"www IN TXT \"hello\"\n",
+			expected: []problem{
+				{Check: lint.CheckCNAMEAndOtherData, Owner: "www.example.com.", Node: 3},
+			},
+		},
+		"multiple cnames": {
+			input: soa +
+				"www IN CNAME a.example.net.\n" +
+				"www IN CNAME b.example.net.\n",
+			expected: []problem{
+				{Check: lint.CheckCNAMEAndOtherData, Owner: "www.example.com.", Node: 4},
+			},
+		},
+		"duplicate rr": {
+			input: soa +
+				"www IN A 192.0.2.1\n" +
+				"WWW.example.com. IN A 192.0.2.1\n" +
+				"www IN A 192.0.2.2\n",
+			expected: []problem{
+				{Check: lint.CheckDuplicateRR, Owner: "www.example.com.", Node: 4},
+			},
+		},
+		"mixed ttls": {
+			input: soa +
+				"www 300 IN A 192.0.2.1\n" +
+				"www 600 IN A 192.0.2.2\n",
+			expected: []problem{
+				{Check: lint.CheckTTLMismatch, Owner: "www.example.com.", Node: 4},
+			},
+		},
+		"no soa": {
+			input: "@ IN NS ns1.example.net.\n",
+			expected: []problem{
+				{Check: lint.CheckSOACount, Node: -1},
+			},
+		},
+		"two soas": {
+			input: soa + soa,
+			expected: []problem{
+				{Check: lint.CheckSOACount, Owner: "example.com.", Node: 3},
+				{Check: lint.CheckDuplicateRR, Owner: "example.com.", Node: 3},
+				{Check: lint.CheckDuplicateRR, Owner: "example.com.", Node: 4},
+				{Check: lint.CheckDuplicateRR, Owner: "ns1.example.com.", Node: 5},
+			},
+		},
+		"in-zone name server without glue": {
+			input: soa +
+				"@ IN NS ns2\n" +
+				"sub IN NS ns.sub\n" +
+				"sub IN NS ns.example.net.\n",
+			expected: []problem{
+				{Check: lint.CheckMissingGlue, Owner: "example.com.", Node: 3},
+				{Check: lint.CheckMissingGlue, Owner: "sub.example.com.", Node: 4},
+			},
+		},
+		"mx and srv targets are cnames": {
+			input: soa +
+				"@ IN MX 10 mail\n" +
+				"mail IN CNAME mail.example.net.\n" +
+				"_xmpp._tcp IN SRV 10 5 5222 xmpp.example.com.\n" +
+				"xmpp IN CNAME mail.example.net.\n",
+			expected: []problem{
+				{Check: lint.CheckTargetIsCNAME, Owner: "example.com.", Node: 3},
+				{Check: lint.CheckTargetIsCNAME, Owner: "_xmpp._tcp.example.com.", Node: 5},
+			},
+		},
+		"records outside the origin": {
+			input: soa +
+				"www.example.net. IN A 192.0.2.1\n" +
+				"$ORIGIN example.org.\n" +
+				"www IN A 192.0.2.2\n",
+			expected: []problem{
+				{Check: lint.CheckOutOfZone, Owner: "www.example.net.", Node: 3},
+				{Check: lint.CheckOutOfZone, Owner: "www.example.org.", Node: 5},
+			},
+		},
+		"generated records": {
+			input: soa +
+				"$GENERATE 1-3 host$ IN A 192.0.2.$\n" +
+				"host2 IN CNAME example.net.\n",
+			expected: []problem{
+				{Check: lint.CheckCNAMEAndOtherData, Owner: "host2.example.com.", Node: 4},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			entries, err := parser.ParseEntries(lexer.LexBytes([]byte(tc.input)).AllTokens())
+			require.NoError(t, err)
+
+			problems, err := lint.Lint(entries, "example.com.")
+			require.NoError(t, err)
+
+			got := []problem{}
+			for _, p := range problems {
+				got = append(got, problem{Check: p.Check, Owner: p.Owner, Node: p.Node})
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	entries, err := parser.ParseEntries(lexer.LexBytes([]byte(soa + "www 300 A 192.0.2.1\nwww 600 A 192.0.2.2\n")).AllTokens())
+	require.NoError(t, err)
+	problems, err := lint.Validate(entries, "example.com.")
+	assert.NoError(t, err)
+	require.Len(t, problems, 1)
+	assert.False(t, lint.HasErrors(problems))
+	assert.Equal(t, 5, problems[0].Position.Line)
+	assert.Equal(t, 1, problems[0].Position.Column)
+	assert.Contains(t, problems[0].String(), "5:1: warning: TTL 600 of www.example.com. A record differs")
+
+	entries, err = parser.ParseEntries(lexer.LexBytes([]byte("www A 192.0.2.1\n")).AllTokens())
+	require.NoError(t, err)
+	problems, err = lint.Validate(entries, "example.com.")
+	assert.ErrorIs(t, err, lint.ErrProblems)
+	assert.ErrorContains(t, err, "zone has no SOA record")
+	assert.True(t, lint.HasErrors(problems))
+
+	_, err = lint.Validate(entries, "")
+	assert.ErrorIs(t, err, parser.ErrResolveError)
+}
+
+func TestLintUnparseable(t *testing.T) {
+	t.Parallel()
+
+	entries, _, err := parser.ParseReaderTolerant(strings.NewReader(soa + "www 1 2 A 192.0.2.1\n"))
+	require.NoError(t, err)
+	problems, err := lint.Validate(entries, "example.com.")
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	assert.Equal(t, lint.CheckUnparseable, problems[0].Check)
+	assert.Equal(t, lint.SeverityWarning, problems[0].Severity)
+	assert.Equal(t, 3, problems[0].Node)
+	assert.Equal(t, 4, problems[0].Position.Line)
+}
@@ -0,0 +1,342 @@
+// Package merge merges several zones into one RRset by RRset. The first zone
+// is the base of the result, so its entries, comments and layout are kept;
+// RRsets only found in the other zones are added at the end, and RRsets found
+// in more than one zone with different data are resolved by a Policy.
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/diff"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/zone"
+)
+
+var (
+	ErrConflict      = errors.New("conflicting RRsets")
+	ErrUnknownPolicy = errors.New("unknown merge policy")
+)
+
+// Policy decides what happens to an RRset that is in more than one zone with
+// different records or TTLs.
+type Policy string
+
+const (
+	// PolicyPreferLeft keeps the RRset of the zone that comes first.
+	PolicyPreferLeft Policy = "prefer-left"
+	// PolicyPreferRight keeps the RRset of the zone that comes last.
+	PolicyPreferRight Policy = "prefer-right"
+	// PolicyUnion keeps the records of all zones, with the TTL of the zone
+	// that comes first.
+	PolicyUnion Policy = "union"
+	// PolicyError fails the merge.
+	PolicyError Policy = "error"
+)
+
+// Policies are all known policies.
+var Policies = []Policy{PolicyPreferLeft, PolicyPreferRight, PolicyUnion, PolicyError}
+
+// ParsePolicy returns the policy named s.
+func ParsePolicy(s string) (Policy, error) {
+	policy := Policy(s)
+	if !slices.Contains(Policies, policy) {
+		return "", fmt.Errorf("%w: %q", ErrUnknownPolicy, s)
+	}
+	return policy, nil
+}
+
+// Input is a zone to merge.
+type Input struct {
+	// Name identifies the zone in conflicts, e.g. its file name.
+	Name    string
+	Entries []ast.Node
+}
+
+// Conflict is an RRset that is in more than one zone with different data.
+type Conflict struct {
+	Key diff.Key
+	// Left is the RRset as merged from the zones before Source.
+	Left *diff.RRset
+	// Right is the RRset in Source.
+	Right *diff.RRset
+	// Source is the name of the zone the conflicting RRset is from.
+	Source string
+	// Policy is the policy the conflict was resolved by.
+	Policy Policy
+}
+
+// String renders the conflict as a header line followed by the difference
+// between the two versions of the RRset in the format of diff.Format.
+func (conflict Conflict) String() string {
+	change := diff.Change{
+		Type:    diff.ChangeTypeChanged,
+		Key:     conflict.Key,
+		Old:     conflict.Left,
+		New:     conflict.Right,
+		TTLOnly: slices.Equal(conflict.Left.Records, conflict.Right.Records),
+	}
+	return fmt.Sprintf("! %s conflicts in %s (%s)\n%s", conflict.Key, conflict.Source, conflict.Policy, change)
+}
+
+// Format renders conflicts one after the other.
+func Format(conflicts []Conflict) string {
+	var sb strings.Builder
+	for _, conflict := range conflicts {
+		sb.WriteString(conflict.String())
+	}
+	return sb.String()
+}
+
+// rrset is an RRset of one of the zones together with its records as written.
+type rrset struct {
+	diff.RRset
+	// owner is the absolute owner name as written.
+	owner   string
+	records []record
+}
+
+type record struct {
+	ast.RRecord
+	// origin is the $ORIGIN relative names in the RDATA are relative to.
+	origin string
+	// normalized is the RDATA as normalized by diff.NormalizeRData.
+	normalized string
+}
+
+// Merge merges inputs, which all use origin as the origin before their first
+// entry, into the first of them. $GENERATE entries are expanded; $INCLUDE
+// entries must have been resolved with parser.ResolveIncludes. Only the
+// records of the inputs after the first are merged, their comments and raw
+// entries are not.
+//
+// The conflicts are returned in the order they were found, along with the
+// merged zone unless policy is PolicyError, in which case any conflict fails
+// the merge with ErrConflict.
+func Merge(inputs []Input, origin string, policy Policy) ([]ast.Node, []Conflict, error) {
+	if !slices.Contains(Policies, policy) {
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnknownPolicy, policy)
+	}
+	if len(inputs) == 0 {
+		return []ast.Node{}, nil, nil
+	}
+
+	base, err := parser.ExpandGenerates(inputs[0].Entries)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error expanding $GENERATE in %s: %w", inputs[0].Name, err)
+	}
+	merged, err := zone.New(base, origin)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error indexing %s: %w", inputs[0].Name, err)
+	}
+	rrsets, _, err := collect(inputs[0], origin, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conflicts := []Conflict{}
+	for _, input := range inputs[1:] {
+		others, keys, err := collect(input, origin, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, key := range keys {
+			right := others[key]
+			left, ok := rrsets[key]
+			if !ok {
+				err = set(merged, right, origin)
+				if err != nil {
+					return nil, nil, err
+				}
+				rrsets[key] = right
+				continue
+			}
+			if left.TTL == right.TTL && slices.Equal(left.Records, right.Records) {
+				continue
+			}
+
+			leftRRset := left.RRset
+			rightRRset := right.RRset
+			conflicts = append(conflicts, Conflict{
+				Key:    key,
+				Left:   &leftRRset,
+				Right:  &rightRRset,
+				Source: input.Name,
+				Policy: policy,
+			})
+			switch policy {
+			case PolicyPreferRight:
+				err = set(merged, right, origin)
+				rrsets[key] = right
+			case PolicyUnion:
+				union := unite(left, right)
+				err = set(merged, union, origin)
+				rrsets[key] = union
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if policy == PolicyError && len(conflicts) > 0 {
+		return nil, conflicts, fmt.Errorf("%w: %d RRsets differ", ErrConflict, len(conflicts))
+	}
+	return merged.Nodes(), conflicts, nil
+}
+
+// collect groups the records of input into RRsets and returns them along
+// with their keys in the order they first appear. The records of the base
+// zone are kept as written, those of the other zones get explicit TTLs.
+func collect(input Input, origin string, base bool) (map[diff.Key]*rrset, []diff.Key, error) {
+	for _, node := range input.Entries {
+		if node.IsIncludeControlEntry() {
+			return nil, nil, fmt.Errorf("error merging %s: $INCLUDE entries must be resolved first", input.Name)
+		}
+	}
+	expanded, err := parser.ExpandGenerates(input.Entries)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error expanding $GENERATE in %s: %w", input.Name, err)
+	}
+	resolved, err := parser.Resolve(expanded, origin)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error resolving %s: %w", input.Name, err)
+	}
+
+	rrsets := map[diff.Key]*rrset{}
+	keys := []diff.Key{}
+	for _, node := range resolved {
+		if !node.IsRREntry() {
+			continue
+		}
+		entry := node.RREntry()
+		key := diff.Key{
+			Owner: strings.ToLower(entry.Resolved.Owner),
+			Class: entry.Resolved.Class,
+			Type:  strings.ToUpper(entry.RRecord.Type),
+		}
+		set, ok := rrsets[key]
+		if !ok {
+			set = &rrset{
+				RRset: diff.RRset{
+					Key:     key,
+					TTL:     entry.Resolved.TTL,
+					Records: []string{},
+				},
+				owner: entry.Resolved.Owner,
+			}
+			rrsets[key] = set
+			keys = append(keys, key)
+		}
+
+		normalized := diff.NormalizeRData(key.Type, entry.RRecord.RData, entry.Resolved.Origin, diff.Options{})
+		if slices.Contains(set.Records, normalized) {
+			continue
+		}
+		set.Records = append(set.Records, normalized)
+		rr := entry.RRecord
+		if !base {
+			// the $TTL and the TTL of the previous record may be different
+			// where the record ends up, so make the TTL explicit
+			rr.TTL = entry.Resolved.TTL
+		}
+		set.records = append(set.records, record{
+			RRecord:    rr,
+			origin:     entry.Resolved.Origin,
+			normalized: normalized,
+		})
+	}
+
+	for _, set := range rrsets {
+		slices.Sort(set.Records)
+	}
+	return rrsets, keys, nil
+}
+
+// unite returns the records of left followed by those of right that left
+// doesn't have, all with the TTL of left.
+func unite(left *rrset, right *rrset) *rrset {
+	union := &rrset{
+		RRset: diff.RRset{
+			Key:     left.Key,
+			TTL:     left.TTL,
+			Records: slices.Clone(left.Records),
+		},
+		owner:   left.owner,
+		records: slices.Clone(left.records),
+	}
+	for _, rec := range right.records {
+		if slices.Contains(union.Records, rec.normalized) {
+			continue
+		}
+		rec.TTL = left.TTL
+		union.Records = append(union.Records, rec.normalized)
+		union.records = append(union.records, rec)
+	}
+	slices.Sort(union.Records)
+	return union
+}
+
+// set replaces the RRset in merged with the records of rrset.
+func set(merged *zone.Zone, rrset *rrset, origin string) error {
+	name := relativeName(rrset.owner, origin)
+	target := merged.OriginAt(name, rrset.Type)
+	records := make([]ast.RRecord, len(rrset.records))
+	for i, rec := range rrset.records {
+		records[i] = rec.RRecord
+		if !strings.EqualFold(rec.origin, target) {
+			records[i].RData = absoluteNames(rec.Type, rec.RData, rec.origin)
+			records[i].Group = nil
+		}
+	}
+	err := merged.Set(name, rrset.Type, records)
+	if err != nil {
+		return fmt.Errorf("error merging %s: %w", rrset.Key, err)
+	}
+	return nil
+}
+
+// relativeName returns name relative to origin if it is in the zone of
+// origin.
+func relativeName(name string, origin string) string {
+	if strings.EqualFold(name, origin) {
+		return "@"
+	}
+	if origin != "." && strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(origin)) {
+		return name[:len(name)-len(origin)-1]
+	}
+	return name
+}
+
+// absoluteNames makes the domain names in rdata of the most common types
+// absolute, so that the record can be written where a different $ORIGIN is in
+// effect.
+func absoluteNames(rrtype string, rdata []ast.RData, origin string) []ast.RData {
+	rrtype = strings.ToUpper(rrtype)
+	if len(rdata) == 1 && slices.Contains([]string{"CNAME", "NS", "PTR", "DNAME"}, rrtype) {
+		return []ast.RData{{Value: ast.AbsoluteName(rdata[0].Value, origin)}}
+	}
+	if !ast.HasTypedRData(rrtype) {
+		return rdata
+	}
+	typed, err := ast.ParseTypedRData(rrtype, rdata)
+	if err != nil {
+		return rdata
+	}
+	switch typed := typed.(type) {
+	case ast.SOA:
+		typed.MName = ast.AbsoluteName(typed.MName, origin)
+		typed.RName = ast.AbsoluteName(typed.RName, origin)
+		return typed.RData()
+	case ast.MX:
+		typed.Exchange = ast.AbsoluteName(typed.Exchange, origin)
+		return typed.RData()
+	case ast.SRV:
+		typed.Target = ast.AbsoluteName(typed.Target, origin)
+		return typed.RData()
+	}
+	return rdata
+}
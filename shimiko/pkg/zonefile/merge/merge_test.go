@@ -0,0 +1,158 @@
+package merge_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/merge"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+)
+
+const managed = `$ORIGIN example.com.
+$TTL 300
+@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+; web servers
+www   A 192.0.2.1
+mail  MX 10 mx1
+`
+
+const generated = `$ORIGIN example.com.
+$TTL 60
+www   A 192.0.2.2
+mail  MX 10 mx1
+host1 A 192.0.2.10
+$ORIGIN lab.example.com.
+nas   CNAME storage
+`
+
+func input(t *testing.T, name string, data string) merge.Input {
+	t.Helper()
+	entries, err := parser.ParseReader(strings.NewReader(data))
+	require.NoError(t, err)
+	return merge.Input{Name: name, Entries: entries}
+}
+
+func render(t *testing.T, entries []ast.Node) string {
+	t.Helper()
+	toks, err := parser.Tokenize(entries)
+	require.NoError(t, err)
+	return string(token.RenderTokens(toks))
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		policy   merge.Policy
+		expected string
+	}{
+		"prefer-left": {
+			policy: merge.PolicyPreferLeft,
+			expected: managed + `host1 60 A 192.0.2.10
+nas.lab 60 CNAME storage.lab.example.com.
+`,
+		},
+		"prefer-right": {
+			policy: merge.PolicyPreferRight,
+			expected: `$ORIGIN example.com.
+$TTL 300
+@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+; web servers
+www 60 A 192.0.2.2
+mail 60 MX 10 mx1
+host1 60 A 192.0.2.10
+nas.lab 60 CNAME storage.lab.example.com.
+`,
+		},
+		"union": {
+			policy: merge.PolicyUnion,
+			expected: `$ORIGIN example.com.
+$TTL 300
+@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+; web servers
+www   A 192.0.2.1
+www 300 A 192.0.2.2
+mail  MX 10 mx1
+host1 60 A 192.0.2.10
+nas.lab 60 CNAME storage.lab.example.com.
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			entries, conflicts, err := merge.Merge([]merge.Input{
+				input(t, "managed", managed),
+				input(t, "generated", generated),
+			}, "example.com.", tc.policy)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, render(t, entries))
+
+			// the MX RRsets only differ in their TTL
+			require.Len(t, conflicts, 2)
+			assert.Equal(t, "www.example.com.", conflicts[0].Key.Owner)
+			assert.Equal(t, "mail.example.com.", conflicts[1].Key.Owner)
+			assert.Equal(t, "generated", conflicts[0].Source)
+			assert.Equal(t, tc.policy, conflicts[0].Policy)
+		})
+	}
+}
+
+func TestMergeError(t *testing.T) {
+	t.Parallel()
+
+	entries, conflicts, err := merge.Merge([]merge.Input{
+		input(t, "managed", managed),
+		input(t, "generated", generated),
+	}, "example.com.", merge.PolicyError)
+	assert.ErrorIs(t, err, merge.ErrConflict)
+	assert.Nil(t, entries)
+	assert.Equal(t, `! www.example.com. IN A conflicts in generated (error)
+- www.example.com. 300 IN A 192.0.2.1
++ www.example.com. 60 IN A 192.0.2.2
+! mail.example.com. IN MX conflicts in generated (error)
+~ mail.example.com. IN MX TTL 300 -> 60
+`, merge.Format(conflicts))
+}
+
+func TestMergeWithoutConflicts(t *testing.T) {
+	t.Parallel()
+
+	entries, conflicts, err := merge.Merge([]merge.Input{
+		input(t, "managed", managed),
+		input(t, "fragment", "$ORIGIN example.com.\n$TTL 300\nwww A 192.0.2.1\nftp CNAME www\n"),
+	}, "example.com.", merge.PolicyError)
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.Equal(t, managed+"ftp 300 CNAME www\n", render(t, entries))
+}
+
+func TestMergeInclude(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := merge.Merge([]merge.Input{
+		input(t, "managed", managed),
+		input(t, "fragment", "$INCLUDE hosts.zone\n"),
+	}, "example.com.", merge.PolicyUnion)
+	assert.Error(t, err)
+}
+
+func TestParsePolicy(t *testing.T) {
+	t.Parallel()
+
+	for _, policy := range merge.Policies {
+		parsed, err := merge.ParsePolicy(string(policy))
+		require.NoError(t, err)
+		assert.Equal(t, policy, parsed)
+	}
+	_, err := merge.ParsePolicy("prefer-middle")
+	assert.ErrorIs(t, err, merge.ErrUnknownPolicy)
+}
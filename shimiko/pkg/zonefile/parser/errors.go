@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+)
+
+// ParseError is returned by ParseEntries and the functions built on it when
+// an entry can't be parsed. It unwraps to the underlying error, which in turn
+// wraps ErrParseError.
+type ParseError struct {
+	// SourceFile is the file the entry was read from, if known.
+	SourceFile string
+	// Entry is the index of the entry that failed to parse.
+	Entry int
+	// Token is the token the parser stopped at.
+	Token token.Token
+	// Excerpt is the source line containing Token, without the line break.
+	Excerpt string
+	Err     error
+}
+
+// Position is the position of the token the parser stopped at. It is the zero
+// value if the tokens didn't come from the lexer.
+func (err *ParseError) Position() token.Position {
+	return err.Token.Position
+}
+
+func (err *ParseError) Error() string {
+	var sb strings.Builder
+	if err.SourceFile != "" {
+		sb.WriteString(err.SourceFile)
+		sb.WriteString(":")
+	}
+	pos := err.Position()
+	if pos.IsValid() {
+		fmt.Fprintf(&sb, "%s: ", pos)
+	} else if err.SourceFile != "" {
+		sb.WriteString(" ")
+	}
+	fmt.Fprintf(&sb, "error while parsing entry %d: %v", err.Entry, err.Err)
+	if err.Excerpt != "" {
+		sb.WriteString("\n\t")
+		sb.WriteString(err.Excerpt)
+		if pos.IsValid() {
+			sb.WriteString("\n\t")
+			sb.WriteString(caretLine(err.Excerpt, pos.Column))
+		}
+	}
+	return sb.String()
+}
+
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+// newParseError builds a ParseError for a failure in entry while parsing
+// toks, the tokens of that entry. The parser always stops at the last token
+// it consumed.
+func newParseError(entry int, toks []token.Token, consumed []token.Token, err error) *ParseError {
+	parseErr := &ParseError{
+		Entry: entry,
+		Err:   err,
+	}
+	if len(consumed) == 0 {
+		return parseErr
+	}
+	parseErr.Token = consumed[len(consumed)-1]
+	parseErr.Excerpt = excerpt(toks, parseErr.Token)
+	return parseErr
+}
+
+// excerpt renders the source line of toks that contains tok. Without
+// positions the first line of the entry is rendered instead.
+func excerpt(toks []token.Token, tok token.Token) string {
+	line := []token.Token{}
+	for _, candidate := range toks {
+		if tok.Position.IsValid() && candidate.Position.Line != tok.Position.Line {
+			continue
+		}
+		line = append(line, candidate)
+	}
+	rendered := string(token.RenderTokens(line))
+	if i := strings.IndexAny(rendered, "\r\n"); i >= 0 {
+		rendered = rendered[:i]
+	}
+	return strings.TrimRight(rendered, " \t")
+}
+
+// caretLine returns a line pointing at column of excerpt, keeping tabs so the
+// caret lines up however wide they are displayed.
+func caretLine(excerpt string, column int) string {
+	var sb strings.Builder
+	for i := 0; i < column-1 && i < len(excerpt); i++ {
+		if excerpt[i] == '\t' {
+			sb.WriteByte('\t')
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+	sb.WriteByte('^')
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+package parser_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lexer"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+)
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input    string
+		entry    int
+		position token.Position
+		excerpt  string
+		message  string
+	}{
+		"invalid ttl": {
+			input:    "a A 192.0.2.1\n$TTL 1.5h ; comment\n",
+			entry:    1,
+			position: token.Position{Offset: 19, Line: 2, Column: 6},
+			excerpt:  "$TTL 1.5h ; comment",
+			message:  "2:6: error while parsing entry 1: parse error",
+		},
+		"second group on a continuation line": {
+			input: "a A 192.0.2.1\n" +
+				"\n" +
+				"@ TXT ( \"one\"\n" +
+				"\t\"two\" ) ( \"three\"\n",
+			entry:    2,
+			position: token.Position{Offset: 38, Line: 4, Column: 10},
+			excerpt:  "\t\"two\" ) ( \"three\"",
+			message:  "4:10: error while parsing entry 2: parse error: encountered ILLEGAL token",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parser.ParseEntries(lexer.LexBytes([]byte(tc.input)).AllTokens())
+			assert.ErrorIs(t, err, parser.ErrParseError)
+
+			var parseErr *parser.ParseError
+			require.ErrorAs(t, err, &parseErr)
+			assert.Equal(t, tc.entry, parseErr.Entry)
+			assert.Equal(t, tc.position, parseErr.Position())
+			assert.Equal(t, tc.excerpt, parseErr.Excerpt)
+			assert.ErrorContains(t, err, tc.message)
+		})
+	}
+}
+
+func TestParseErrorFormat(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"db.example": {
+			Data: []byte("www A 192.0.2.1\n\t$BOGUS\n"),
+		},
+	}
+
+	_, err := parser.ParseFile(fsys, "db.example")
+	assert.EqualError(
+		t,
+		err,
+		"db.example:2:2: error while parsing entry 1: parse error: unknown control entry '$BOGUS': "+
+			`Token{Type:CONTROL_ENTRY, Literal:"$BOGUS", WhiteSpaceBefore:"	", Position:2:2}`+"\n"+
+			"\t\t$BOGUS\n"+
+			"\t\t^",
+	)
+}
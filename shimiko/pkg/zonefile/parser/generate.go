@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+)
+
+// ExpandGenerates replaces every $GENERATE entry in entries with the RREntry
+// nodes it stands for. The generated nodes have no SourceTokens, so they are
+// always rendered canonically; the comments of the $GENERATE entry go to the
+// first of them. All other nodes are returned as-is.
+func ExpandGenerates(entries []ast.Node) ([]ast.Node, error) {
+	expanded := make([]ast.Node, 0, len(entries))
+	for i, node := range entries {
+		if !node.IsGenerateControlEntry() {
+			expanded = append(expanded, node)
+			continue
+		}
+
+		rrentries, err := node.GenerateControlEntry().Expand()
+		if err != nil {
+			return expanded, fmt.Errorf("error expanding entry %d: %w", i, err)
+		}
+		for j, rrentry := range rrentries {
+			generated := ast.Node{
+				NodeType:     ast.NodeTypeRREntry,
+				LeadComments: []string{},
+				Entry:        rrentry,
+				SourceFile:   node.SourceFile,
+			}
+			if j == 0 {
+				generated.LeadComments = node.LeadComments
+				generated.LineComment = node.LineComment
+			}
+			expanded = append(expanded, generated)
+		}
+	}
+	return expanded, nil
+}
@@ -0,0 +1,147 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lexer"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+)
+
+func TestParseGenerate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input       string
+		expected    ast.GenerateControlEntry
+		rendered    string
+		err         error
+		errContains string
+	}{
+		"minimal": {
+			input: "$GENERATE 1-10 host-$ A 192.0.2.$\n",
+			expected: ast.GenerateControlEntry{
+				Start:      1,
+				Stop:       10,
+				Step:       1,
+				DomainName: "host-$",
+				RRecord: ast.RRecord{
+					Type:  "A",
+					RData: []ast.RData{{Value: "192.0.2.$"}},
+				},
+			},
+			rendered: "$GENERATE 1-10 host-$ A 192.0.2.$\n",
+		},
+		"step, ttl and class": {
+			input: "$GENERATE 0-254/2 $ IN 1h PTR dhcp-${0,3,d}.example.com.\n",
+			expected: ast.GenerateControlEntry{
+				Start:      0,
+				Stop:       254,
+				Step:       2,
+				DomainName: "$",
+				RRecord: ast.RRecord{
+					TTL:   time.Hour,
+					Class: "IN",
+					Type:  "PTR",
+					RData: []ast.RData{{Value: "dhcp-${0,3,d}.example.com."}},
+				},
+			},
+			rendered: "$GENERATE 0-254/2 $ 3600 IN PTR dhcp-${0,3,d}.example.com.\n",
+		},
+		"bad range": {
+			input:       "$GENERATE 10-1 $ A 192.0.2.$\n",
+			err:         ast.ErrInvalidGenerate,
+			errContains: "start 10 is greater than stop 1",
+		},
+		"parentheses": {
+			input:       "$GENERATE 1-2 $ TXT ( \"a\" )\n",
+			err:         parser.ErrParseError,
+			errContains: "unexpected RDATA_OPAREN",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			entries, err := parser.ParseEntries(lexer.LexBytes([]byte(tc.input)).AllTokens())
+
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				assert.ErrorContains(t, err, tc.errContains)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+			require.Equal(t, ast.NodeTypeGenerateControlEntry, entries[0].NodeType)
+			assert.Equal(t, tc.expected, entries[0].GenerateControlEntry())
+
+			// render canonically
+			entries[0].SourceTokens = nil
+			toks, err := parser.Tokenize(entries[:1])
+			require.NoError(t, err)
+			assert.Equal(t, tc.rendered, string(token.RenderTokens(toks)))
+		})
+	}
+}
+
+func TestExpandGenerates(t *testing.T) {
+	t.Parallel()
+
+	input := "$ORIGIN 2.0.192.in-addr.arpa.\n" +
+		"; DHCP pool\n" +
+		"$GENERATE 100-102 $ PTR dhcp-$.example.com. ; placeholders\n" +
+		"1 PTR gateway.example.com.\n"
+
+	entries, err := parser.ParseEntries(lexer.LexBytes([]byte(input)).AllTokens())
+	require.NoError(t, err)
+
+	expanded, err := parser.ExpandGenerates(entries)
+	require.NoError(t, err)
+
+	toks, err := parser.Tokenize(expanded)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"$ORIGIN 2.0.192.in-addr.arpa.\n"+
+			"; DHCP pool\n"+
+			"100 PTR dhcp-100.example.com. ; placeholders\n"+
+			"101 PTR dhcp-101.example.com.\n"+
+			"102 PTR dhcp-102.example.com.\n"+
+			"1 PTR gateway.example.com.\n",
+		string(token.RenderTokens(toks)),
+	)
+
+	// the input entries must be left untouched
+	assert.True(t, entries[2].IsGenerateControlEntry())
+}
+
+func TestExpandGeneratesError(t *testing.T) {
+	t.Parallel()
+
+	entries := []ast.Node{
+		{
+			NodeType: ast.NodeTypeGenerateControlEntry,
+			Entry: ast.GenerateControlEntry{
+				Start:      1,
+				Stop:       2,
+				Step:       1,
+				DomainName: "${0,3,q}",
+				RRecord: ast.RRecord{
+					Type:  "A",
+					RData: []ast.RData{{Value: "192.0.2.$"}},
+				},
+			},
+		},
+	}
+
+	_, err := parser.ExpandGenerates(entries)
+	assert.ErrorIs(t, err, ast.ErrInvalidGenerate)
+	assert.ErrorContains(t, err, "error expanding entry 0")
+}
@@ -0,0 +1,216 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+)
+
+var ErrIncludeCycle = errors.New("include cycle")
+
+// ParseFile reads, lexes and parses the zone file name from fsys. Every
+// returned node has its SourceFile set to name. $INCLUDE entries are left
+// as-is; use LoadFile or ResolveIncludes to pull them in.
+func ParseFile(fsys fs.FS, name string) ([]ast.Node, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading zone file '%s': %w", name, err)
+	}
+	defer file.Close()
+
+	entries, err := ParseReader(file)
+	if err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			parseErr.SourceFile = name
+			return entries, err
+		}
+		return entries, fmt.Errorf("error parsing zone file '%s': %w", name, err)
+	}
+
+	for i := range entries {
+		entries[i].SourceFile = name
+	}
+
+	return entries, nil
+}
+
+// LoadFile parses the zone file name from fsys and resolves all of its
+// $INCLUDE entries. origin is the origin in effect at the top of the file and
+// may be empty.
+func LoadFile(fsys fs.FS, name string, origin string) ([]ast.Node, error) {
+	entries, err := ParseFile(fsys, name)
+	if err != nil {
+		return entries, err
+	}
+	return ResolveIncludes(fsys, name, entries, origin)
+}
+
+// ResolveIncludes replaces every $INCLUDE entry in entries with the entries of
+// the file it references, recursively. name is the file entries were parsed
+// from and is used for cycle detection and for SourceFile on nodes that don't
+// have one yet. origin is the origin in effect at the top of entries.
+//
+// Included file names are resolved relative to the root of fsys; a leading
+// "/" is ignored so absolute paths work with os.DirFS("/").
+//
+// When an $INCLUDE entry has an origin argument an $ORIGIN entry is inserted
+// before the included entries. As per RFC 1035 an include never changes the
+// origin of the including file, so an $ORIGIN entry restoring the previous
+// origin is inserted after the included entries whenever it differs. If the
+// including file has no origin there is nothing to restore, so relative
+// names after the include, which would be resolved against the origin left
+// by the included file, are an ErrResolveError until the including file sets
+// an absolute $ORIGIN.
+func ResolveIncludes(fsys fs.FS, name string, entries []ast.Node, origin string) ([]ast.Node, error) {
+	resolver := &includeResolver{
+		fsys:  fsys,
+		stack: []string{},
+	}
+	resolved, _, err := resolver.resolve(name, entries, origin)
+	return resolved, err
+}
+
+type includeResolver struct {
+	fsys  fs.FS
+	stack []string
+	// leaked is the origin an included file left in effect in the resolved
+	// entries although the including file has none.
+	leaked string
+}
+
+// checkRelative returns an error if name is relative while the resolved
+// entries have an origin in effect that the file being resolved doesn't.
+func (resolver *includeResolver) checkRelative(name string, fileName string, origin string) error {
+	if resolver.leaked == "" || origin != "" || name == "" || ast.IsAbsoluteName(name) {
+		return nil
+	}
+	return fmt.Errorf(
+		"%w: relative name '%s' in '%s' with no origin in effect, but an included file set $ORIGIN %s",
+		ErrResolveError,
+		name,
+		fileName,
+		resolver.leaked,
+	)
+}
+
+func (resolver *includeResolver) resolve(name string, entries []ast.Node, origin string) ([]ast.Node, string, error) {
+	if slices.Contains(resolver.stack, name) {
+		return nil, origin, fmt.Errorf(
+			"%w: %s",
+			ErrIncludeCycle,
+			strings.Join(append(slices.Clone(resolver.stack), name), " -> "),
+		)
+	}
+	resolver.stack = append(resolver.stack, name)
+	defer func() {
+		resolver.stack = resolver.stack[:len(resolver.stack)-1]
+	}()
+
+	resolved := make([]ast.Node, 0, len(entries))
+
+	for _, node := range entries {
+		if node.SourceFile == "" {
+			node.SourceFile = name
+		}
+
+		if node.IsOriginControlEntry() {
+			domainName := node.OriginControlEntry().DomainName
+			if err := resolver.checkRelative(domainName, name, origin); err != nil {
+				return resolved, origin, err
+			}
+			origin = ast.AbsoluteName(domainName, origin)
+			if ast.IsAbsoluteName(origin) {
+				resolver.leaked = ""
+			}
+			resolved = append(resolved, node)
+			continue
+		}
+
+		if !node.IsIncludeControlEntry() {
+			if node.IsRREntry() {
+				if err := resolver.checkRelative(node.RREntry().DomainName, name, origin); err != nil {
+					return resolved, origin, err
+				}
+			}
+			resolved = append(resolved, node)
+			continue
+		}
+
+		entry := node.IncludeControlEntry()
+		if err := resolver.checkRelative(entry.DomainName, name, origin); err != nil {
+			return resolved, origin, err
+		}
+		includeName := IncludePath(entry.FileName)
+
+		// keep any comments that were attached to the $INCLUDE line
+		comments := slices.Clone(node.LeadComments)
+		if node.LineComment != "" {
+			comments = append(comments, node.LineComment)
+		}
+		if len(comments) > 0 {
+			resolved = append(resolved, ast.Node{
+				NodeType:     ast.NodeTypeEmpty,
+				LeadComments: comments,
+				SourceFile:   name,
+			})
+		}
+
+		includeOrigin := origin
+		if entry.DomainName != "" {
+			includeOrigin = ast.AbsoluteName(entry.DomainName, origin)
+			resolved = append(resolved, ast.Node{
+				NodeType: ast.NodeTypeOriginControlEntry,
+				Entry: ast.OriginControlEntry{
+					DomainName: includeOrigin,
+				},
+				SourceFile: name,
+			})
+			if ast.IsAbsoluteName(includeOrigin) {
+				resolver.leaked = ""
+			}
+		}
+
+		included, err := ParseFile(resolver.fsys, includeName)
+		if err != nil {
+			return resolved, origin, fmt.Errorf("error including '%s' from '%s': %w", includeName, name, err)
+		}
+
+		included, includeOrigin, err = resolver.resolve(includeName, included, includeOrigin)
+		if err != nil {
+			return resolved, origin, err
+		}
+		resolved = append(resolved, included...)
+
+		switch {
+		case includeOrigin == origin:
+		case origin != "":
+			resolved = append(resolved, ast.Node{
+				NodeType: ast.NodeTypeOriginControlEntry,
+				Entry: ast.OriginControlEntry{
+					DomainName: origin,
+				},
+				SourceFile: name,
+			})
+			resolver.leaked = ""
+		default:
+			// there is no $ORIGIN entry for "no origin"
+			resolver.leaked = includeOrigin
+		}
+	}
+
+	return resolved, origin, nil
+}
+
+// IncludePath converts the file name argument of an $INCLUDE entry into a
+// name usable with fs.FS.
+func IncludePath(fileName string) string {
+	fileName = strings.Trim(fileName, `"`)
+	fileName = strings.TrimLeft(fileName, "/")
+	return path.Clean(fileName)
+}
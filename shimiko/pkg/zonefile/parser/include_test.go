@@ -0,0 +1,222 @@
+package parser_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+)
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	type summary struct {
+		NodeType   ast.NodeType
+		SourceFile string
+		Value      string
+	}
+
+	summarize := func(nodes []ast.Node) []summary {
+		result := []summary{}
+		for _, node := range nodes {
+			s := summary{
+				NodeType:   node.NodeType,
+				SourceFile: node.SourceFile,
+			}
+			switch {
+			case node.IsOriginControlEntry():
+				s.Value = node.OriginControlEntry().DomainName
+			case node.IsRREntry():
+				s.Value = node.RREntry().DomainName
+			case node.IsIncludeControlEntry():
+				s.Value = node.IncludeControlEntry().FileName
+			}
+			if node.NodeType == ast.NodeTypeEmpty && len(node.LeadComments) == 0 {
+				continue
+			}
+			result = append(result, s)
+		}
+		return result
+	}
+
+	tests := map[string]struct {
+		fsys        fstest.MapFS
+		name        string
+		origin      string
+		expected    []summary
+		err         error
+		errContains string
+	}{
+		"no includes": {
+			fsys: fstest.MapFS{
+				"db.zone": {Data: []byte("$ORIGIN example.com.\nwww A 192.0.2.1\n")},
+			},
+			name: "db.zone",
+			expected: []summary{
+				{ast.NodeTypeOriginControlEntry, "db.zone", "example.com."},
+				{ast.NodeTypeRREntry, "db.zone", "www"},
+			},
+		},
+		"simple include": {
+			fsys: fstest.MapFS{
+				"db.zone":    {Data: []byte("$ORIGIN example.com.\n$INCLUDE hosts.zone\nmail A 192.0.2.2\n")},
+				"hosts.zone": {Data: []byte("www A 192.0.2.1\n")},
+			},
+			name: "db.zone",
+			expected: []summary{
+				{ast.NodeTypeOriginControlEntry, "db.zone", "example.com."},
+				{ast.NodeTypeRREntry, "hosts.zone", "www"},
+				{ast.NodeTypeRREntry, "db.zone", "mail"},
+			},
+		},
+		"include with origin is restored afterwards": {
+			fsys: fstest.MapFS{
+				"db.zone":    {Data: []byte("$ORIGIN example.com.\n$INCLUDE hosts.zone lab\nmail A 192.0.2.2\n")},
+				"hosts.zone": {Data: []byte("www A 192.0.2.1\n")},
+			},
+			name: "db.zone",
+			expected: []summary{
+				{ast.NodeTypeOriginControlEntry, "db.zone", "example.com."},
+				{ast.NodeTypeOriginControlEntry, "db.zone", "lab.example.com."},
+				{ast.NodeTypeRREntry, "hosts.zone", "www"},
+				{ast.NodeTypeOriginControlEntry, "db.zone", "example.com."},
+				{ast.NodeTypeRREntry, "db.zone", "mail"},
+			},
+		},
+		"origin change inside include does not leak": {
+			fsys: fstest.MapFS{
+				"db.zone":    {Data: []byte("$INCLUDE hosts.zone\nmail A 192.0.2.2\n")},
+				"hosts.zone": {Data: []byte("$ORIGIN lab.example.com.\nwww A 192.0.2.1\n")},
+			},
+			name:   "db.zone",
+			origin: "example.com.",
+			expected: []summary{
+				{ast.NodeTypeOriginControlEntry, "hosts.zone", "lab.example.com."},
+				{ast.NodeTypeRREntry, "hosts.zone", "www"},
+				{ast.NodeTypeOriginControlEntry, "db.zone", "example.com."},
+				{ast.NodeTypeRREntry, "db.zone", "mail"},
+			},
+		},
+		"origin change inside include without outer origin": {
+			fsys: fstest.MapFS{
+				"db.zone":    {Data: []byte("$INCLUDE hosts.zone\nmail.example.net. A 192.0.2.2\n$ORIGIN example.net.\nftp A 192.0.2.3\n")},
+				"hosts.zone": {Data: []byte("$ORIGIN lab.example.com.\nwww A 192.0.2.1\n")},
+			},
+			name: "db.zone",
+			expected: []summary{
+				{ast.NodeTypeOriginControlEntry, "hosts.zone", "lab.example.com."},
+				{ast.NodeTypeRREntry, "hosts.zone", "www"},
+				{ast.NodeTypeRREntry, "db.zone", "mail.example.net."},
+				{ast.NodeTypeOriginControlEntry, "db.zone", "example.net."},
+				{ast.NodeTypeRREntry, "db.zone", "ftp"},
+			},
+		},
+		"relative name after include without outer origin": {
+			fsys: fstest.MapFS{
+				"db.zone":    {Data: []byte("$INCLUDE hosts.zone\nmail A 192.0.2.2\n")},
+				"hosts.zone": {Data: []byte("$ORIGIN lab.example.com.\nwww A 192.0.2.1\n")},
+			},
+			name:        "db.zone",
+			err:         parser.ErrResolveError,
+			errContains: "relative name 'mail' in 'db.zone'",
+		},
+		"relative origin after include without outer origin": {
+			fsys: fstest.MapFS{
+				"db.zone":    {Data: []byte("$INCLUDE hosts.zone lab.example.com.\n$ORIGIN example.net\n")},
+				"hosts.zone": {Data: []byte("www A 192.0.2.1\n")},
+			},
+			name:        "db.zone",
+			err:         parser.ErrResolveError,
+			errContains: "but an included file set $ORIGIN lab.example.com.",
+		},
+		"nested includes with absolute path": {
+			fsys: fstest.MapFS{
+				"etc/coredns/db.zone": {Data: []byte("$INCLUDE /etc/coredns/a.zone\n")},
+				"etc/coredns/a.zone":  {Data: []byte("a A 192.0.2.1\n$INCLUDE etc/coredns/b.zone\n")},
+				"etc/coredns/b.zone":  {Data: []byte("b A 192.0.2.2\n")},
+			},
+			name: "etc/coredns/db.zone",
+			expected: []summary{
+				{ast.NodeTypeRREntry, "etc/coredns/a.zone", "a"},
+				{ast.NodeTypeRREntry, "etc/coredns/b.zone", "b"},
+			},
+		},
+		"include comments are kept": {
+			fsys: fstest.MapFS{
+				"db.zone":    {Data: []byte("$INCLUDE hosts.zone ; by zonepop\n")},
+				"hosts.zone": {Data: []byte("www A 192.0.2.1\n")},
+			},
+			name: "db.zone",
+			expected: []summary{
+				{ast.NodeTypeEmpty, "db.zone", ""},
+				{ast.NodeTypeRREntry, "hosts.zone", "www"},
+			},
+		},
+		"include cycle": {
+			fsys: fstest.MapFS{
+				"db.zone": {Data: []byte("$INCLUDE a.zone\n")},
+				"a.zone":  {Data: []byte("$INCLUDE b.zone\n")},
+				"b.zone":  {Data: []byte("$INCLUDE a.zone\n")},
+			},
+			name:        "db.zone",
+			err:         parser.ErrIncludeCycle,
+			errContains: "db.zone -> a.zone -> b.zone -> a.zone",
+		},
+		"self include": {
+			fsys: fstest.MapFS{
+				"db.zone": {Data: []byte("$INCLUDE db.zone\n")},
+			},
+			name: "db.zone",
+			err:  parser.ErrIncludeCycle,
+		},
+		"missing include": {
+			fsys: fstest.MapFS{
+				"db.zone": {Data: []byte("$INCLUDE missing.zone\n")},
+			},
+			name:        "db.zone",
+			errContains: "error including 'missing.zone' from 'db.zone'",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parser.LoadFile(tc.fsys, tc.name, tc.origin)
+
+			if tc.err != nil || tc.errContains != "" {
+				if tc.err != nil {
+					assert.ErrorIs(t, err, tc.err)
+				}
+				if tc.errContains != "" {
+					assert.ErrorContains(t, err, tc.errContains)
+				}
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, summarize(got))
+		})
+	}
+}
+
+func TestIncludePath(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"hosts.zone":                "hosts.zone",
+		"/etc/coredns/hosts.zone":   "etc/coredns/hosts.zone",
+		`"hosts.zone"`:              "hosts.zone",
+		"./zones/../hosts.zone":     "hosts.zone",
+		"<SUBSYS>ISI-MAILBOXES.TXT": "<SUBSYS>ISI-MAILBOXES.TXT",
+	}
+
+	for input, expected := range tests {
+		assert.Equal(t, expected, parser.IncludePath(input), input)
+	}
+}
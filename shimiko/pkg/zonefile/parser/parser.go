@@ -1,14 +1,18 @@
 package parser
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"strconv"
+	"io"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lexer"
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ttl"
 )
 
 var (
@@ -19,37 +23,163 @@ var (
 func SplitTokensLines(toks []token.Token) [][]token.Token {
 	lines := [][]token.Token{}
 
-	inLineContinuation := false
+	splitter := lineSplitter{}
 	line := []token.Token{}
 
 	for _, tok := range toks {
 		line = append(line, tok)
 
-		if inLineContinuation {
-			if tok.Type == token.RDATA_CPAREN {
-				inLineContinuation = false
-			}
+		if splitter.endsLine(tok) {
+			lines = append(lines, line)
+			line = []token.Token{}
+		}
+	}
+
+	return lines
+}
+
+// lineSplitter finds the ends of entries in a stream of tokens: a NEWLINE
+// token outside of parentheses, or the EOF token. A group that is still open
+// at the end of the input is left for ParseEntry to reject.
+type lineSplitter struct {
+	inLineContinuation bool
+}
+
+func (splitter *lineSplitter) endsLine(tok token.Token) bool {
+	if tok.Type == token.EOF {
+		return true
+	}
+	if splitter.inLineContinuation {
+		if tok.Type == token.RDATA_CPAREN {
+			splitter.inLineContinuation = false
+		}
+		return false
+	}
+
+	if tok.Type == token.RDATA_OPAREN {
+		splitter.inLineContinuation = true
+	}
+
+	return tok.Type == token.NEWLINE
+}
+
+// ParseReader lexes and parses a zone file from r without holding more than
+// one entry's worth of tokens at a time besides the parsed nodes. Errors are
+// reported the same way as by ParseEntries.
+func ParseReader(r io.Reader) ([]ast.Node, error) {
+	entries, parseErrs, err := parseReader(r, false)
+	if err != nil {
+		return entries, err
+	}
+	if len(parseErrs) > 0 {
+		return entries, parseErrs[0]
+	}
+	return entries, nil
+}
+
+// ParseReaderTolerant is like ParseReader, but instead of stopping at the
+// first entry that can't be parsed it keeps every such entry as a NodeTypeRaw
+// node, which Tokenize renders back verbatim, and returns the errors for all
+// of them. A group that is still open at the end of the file only turns the
+// line that opened it into a raw node, and the lines after it are parsed as
+// if it wasn't there. The error is only set if reading from r fails.
+func ParseReaderTolerant(r io.Reader) ([]ast.Node, []*ParseError, error) {
+	return parseReader(r, true)
+}
+
+func parseReader(r io.Reader, tolerant bool) ([]ast.Node, []*ParseError, error) {
+	lex := lexer.NewLexer(r)
+	splitter := lineSplitter{}
+	entries := []ast.Node{}
+	parseErrs := []*ParseError{}
+	line := []token.Token{}
+
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			return entries, parseErrs, err
+		}
+		line = append(line, tok)
 
+		if !splitter.endsLine(tok) {
 			continue
 		}
 
-		if tok.Type == token.RDATA_OPAREN {
-			inLineContinuation = true
+		entry, err := ParseEntry(line)
+		if err != nil {
+			parseErrs = append(parseErrs, newParseError(len(entries), line, entry.SourceTokens, err))
+			if !tolerant {
+				return entries, parseErrs, nil
+			}
+			if splitter.inLineContinuation {
+				// A group that is never closed swallows the rest of the file, so
+				// only the line that opened it is kept verbatim and the lines
+				// after it are lexed again from scratch.
+				if head, rest, ok := splitFirstLine(line); ok {
+					entries = append(entries, rawNode(head, err))
+					lex = lexer.NewLexerAt(bytes.NewReader(token.RenderTokens(rest)), lineStart(rest[0]))
+					splitter = lineSplitter{}
+					line = line[:0]
+					continue
+				}
+			}
+			entry = rawNode(line, err)
 		}
+		entries = append(entries, entry)
+		// ParseEntry copies the tokens into SourceTokens, so the buffer can be
+		// reused for the next entry.
+		line = line[:0]
 
-		if tok.Type == token.NEWLINE || tok.Type == token.EOF {
-			lines = append(lines, line)
-			line = []token.Token{}
+		if tok.Type == token.EOF {
+			return entries, parseErrs, nil
+		}
+	}
+}
+
+// splitFirstLine splits toks after the first NEWLINE token. ok is false if
+// there is nothing but the EOF token after it.
+func splitFirstLine(toks []token.Token) (head []token.Token, rest []token.Token, ok bool) {
+	for i, tok := range toks {
+		if tok.Type == token.NEWLINE {
+			head = toks[:i+1]
+			rest = toks[i+1:]
+			return head, rest, len(rest) > 1
 		}
 	}
+	return toks, nil, false
+}
 
-	return lines
+// lineStart returns the position of the start of the line of tok, which must
+// be the first token of its line.
+func lineStart(tok token.Token) token.Position {
+	return token.Position{
+		Offset: tok.Position.Offset - len(tok.WhiteSpaceBefore),
+		Line:   tok.Position.Line,
+		Column: 1,
+	}
+}
+
+// rawNode keeps the tokens of an entry that failed to parse with err.
+func rawNode(toks []token.Token, err error) ast.Node {
+	source := make([]token.Token, len(toks))
+	copy(source, toks)
+	text := string(token.RenderTokens(toks))
+	text = strings.TrimRight(text, "\r\n")
+	return ast.Node{
+		NodeType:     ast.NodeTypeRaw,
+		SourceTokens: source,
+		LeadComments: []string{},
+		Entry: ast.RawEntry{
+			Text: text,
+			Err:  err,
+		},
+	}
 }
 
 func ParseEntry(toks []token.Token) (ast.Node, error) {
 	node := ast.Node{
 		NodeType:     ast.NodeTypeEmpty,
-		SourceTokens: []token.Token{},
+		SourceTokens: make([]token.Token, 0, len(toks)),
 		LeadComments: []string{},
 		LineComment:  "",
 	}
@@ -93,6 +223,30 @@ func ParseEntry(toks []token.Token) (ast.Node, error) {
 		return node.TTLControlEntry(), nil
 	}
 
+	getGenerateControlEntry := func(tok token.Token) (ast.GenerateControlEntry, error) {
+		if !node.IsGenerateControlEntry() {
+			return ast.GenerateControlEntry{}, fmt.Errorf(
+				"%w: NodeType is GenerateControlEntry but the entry data is invalid: entry=%#v, tok=%v",
+				ErrParseError,
+				node.Entry,
+				tok,
+			)
+		}
+		return node.GenerateControlEntry(), nil
+	}
+
+	// updateGenerateRRecord applies update to the record template of a
+	// $GENERATE entry.
+	updateGenerateRRecord := func(tok token.Token, update func(rrecord *ast.RRecord)) error {
+		entry, err := getGenerateControlEntry(tok)
+		if err != nil {
+			return err
+		}
+		update(&entry.RRecord)
+		node.Entry = entry
+		return nil
+	}
+
 	getRREntry := func(tok token.Token) (ast.RREntry, error) {
 		if node.Entry == nil {
 			node.Entry = ast.RREntry{
@@ -112,12 +266,27 @@ func ParseEntry(toks []token.Token) (ast.Node, error) {
 		return node.RREntry(), nil
 	}
 
+	// state for parenthesized RDATA groups
+	inGroup := false
+	groupNewLine := false
+	groupComments := []string{}
+	// groupStart is the index of the RDATA_OPAREN token of the open group in
+	// SourceTokens
+	groupStart := 0
+
 	for _, tok := range toks {
 		if len(node.SourceTokens) > 0 {
 			previous = node.SourceTokens[len(node.SourceTokens)-1]
 		}
 		node.SourceTokens = append(node.SourceTokens, tok)
 
+		switch tok.Type {
+		case token.RDATA, token.DOMAIN_NAME, token.FILE_NAME:
+			if unterminatedQuote(tok.Literal) {
+				return node, fmt.Errorf("%w: unterminated quoted string: %v", ErrParseError, tok)
+			}
+		}
+
 		switch tok.Type {
 		case token.ILLEGAL:
 			return node, fmt.Errorf("%w: encountered ILLEGAL token: %v", ErrParseError, tok)
@@ -126,9 +295,28 @@ func ParseEntry(toks []token.Token) (ast.Node, error) {
 			continue
 
 		case token.NEWLINE:
+			if inGroup {
+				groupNewLine = true
+			}
 			continue
 
 		case token.COMMENT:
+			if inGroup {
+				entry, err := getRREntry(tok)
+				if err != nil {
+					return node, err
+				}
+				switch previous.Type {
+				case token.RDATA_OPAREN:
+					entry.RRecord.Group.Comment = string(tok.Literal)
+				case token.RDATA:
+					entry.RRecord.RData[len(entry.RRecord.RData)-1].Comment = string(tok.Literal)
+				default:
+					groupComments = append(groupComments, string(tok.Literal))
+				}
+				node.Entry = entry
+				continue
+			}
 			if previous.Type == token.NEWLINE {
 				node.LeadComments = append(node.LeadComments, string(tok.Literal))
 			} else {
@@ -147,6 +335,14 @@ func ParseEntry(toks []token.Token) (ast.Node, error) {
 			case "$TTL":
 				node.NodeType = ast.NodeTypeTTLControlEntry
 				node.Entry = ast.TTLControlEntry{}
+			case "$GENERATE":
+				node.NodeType = ast.NodeTypeGenerateControlEntry
+				node.Entry = ast.GenerateControlEntry{
+					Step: 1,
+					RRecord: ast.RRecord{
+						RData: []ast.RData{},
+					},
+				}
 			default:
 				return node, fmt.Errorf("%w: unknown control entry '%s': %v", ErrParseError, controlEntry, tok)
 			}
@@ -167,6 +363,16 @@ func ParseEntry(toks []token.Token) (ast.Node, error) {
 				}
 				entry.DomainName = string(tok.Literal)
 				node.Entry = entry
+			case ast.NodeTypeGenerateControlEntry:
+				entry, err := getGenerateControlEntry(tok)
+				if err != nil {
+					return node, err
+				}
+				if entry.DomainName != "" {
+					return node, fmt.Errorf("%w: unexpected DOMAIN_NAME for NodeType %s: %v", ErrParseError, node.NodeType, tok)
+				}
+				entry.DomainName = string(tok.Literal)
+				node.Entry = entry
 			case ast.NodeTypeEmpty:
 				node.NodeType = ast.NodeTypeRREntry
 				entry, err := getRREntry(tok)
@@ -190,12 +396,22 @@ func ParseEntry(toks []token.Token) (ast.Node, error) {
 			entry.FileName = string(tok.Literal)
 			node.Entry = entry
 
-		case token.TTL:
-			literal := string(tok.Literal)
-			if lexer.IsDigit(tok.Literal) {
-				literal += "s"
+		case token.RANGE:
+			if node.NodeType != ast.NodeTypeGenerateControlEntry {
+				return node, fmt.Errorf("%w: unexpected RANGE for NodeType %s: %v", ErrParseError, node.NodeType, tok)
+			}
+			entry, err := getGenerateControlEntry(tok)
+			if err != nil {
+				return node, err
+			}
+			entry.Start, entry.Stop, entry.Step, err = ast.ParseGenerateRange(string(tok.Literal))
+			if err != nil {
+				return node, errors.Join(ErrParseError, err)
 			}
-			duration, err := time.ParseDuration(literal)
+			node.Entry = entry
+
+		case token.TTL:
+			duration, err := ttl.Parse(string(tok.Literal))
 			if err != nil {
 				return node, errors.Join(ErrParseError, fmt.Errorf("could not parse TTL: %w", err))
 			}
@@ -210,6 +426,16 @@ func ParseEntry(toks []token.Token) (ast.Node, error) {
 				continue
 			}
 
+			if node.NodeType == ast.NodeTypeGenerateControlEntry {
+				err := updateGenerateRRecord(tok, func(rrecord *ast.RRecord) {
+					rrecord.TTL = duration
+				})
+				if err != nil {
+					return node, err
+				}
+				continue
+			}
+
 			if node.NodeType == ast.NodeTypeEmpty {
 				node.NodeType = ast.NodeTypeRREntry
 			}
@@ -224,6 +450,15 @@ func ParseEntry(toks []token.Token) (ast.Node, error) {
 			node.Entry = entry
 
 		case token.CLASS:
+			if node.NodeType == ast.NodeTypeGenerateControlEntry {
+				err := updateGenerateRRecord(tok, func(rrecord *ast.RRecord) {
+					rrecord.Class = string(tok.Literal)
+				})
+				if err != nil {
+					return node, err
+				}
+				continue
+			}
 			if node.NodeType == ast.NodeTypeEmpty {
 				node.NodeType = ast.NodeTypeRREntry
 			}
@@ -238,6 +473,15 @@ func ParseEntry(toks []token.Token) (ast.Node, error) {
 			node.Entry = entry
 
 		case token.TYPE:
+			if node.NodeType == ast.NodeTypeGenerateControlEntry {
+				err := updateGenerateRRecord(tok, func(rrecord *ast.RRecord) {
+					rrecord.Type = string(tok.Literal)
+				})
+				if err != nil {
+					return node, err
+				}
+				continue
+			}
 			if node.NodeType == ast.NodeTypeEmpty {
 				node.NodeType = ast.NodeTypeRREntry
 			}
@@ -252,6 +496,15 @@ func ParseEntry(toks []token.Token) (ast.Node, error) {
 			node.Entry = entry
 
 		case token.RDATA:
+			if node.NodeType == ast.NodeTypeGenerateControlEntry {
+				err := updateGenerateRRecord(tok, func(rrecord *ast.RRecord) {
+					rrecord.RData = append(rrecord.RData, ast.RData{Value: string(tok.Literal)})
+				})
+				if err != nil {
+					return node, err
+				}
+				continue
+			}
 			if node.NodeType != ast.NodeTypeRREntry {
 				return node, fmt.Errorf("%w: unexpected RDATA for NodeType %s: %v", ErrParseError, node.NodeType, tok)
 			}
@@ -259,30 +512,102 @@ func ParseEntry(toks []token.Token) (ast.Node, error) {
 			if err != nil {
 				return node, err
 			}
-			entry.RRecord.RData = append(entry.RRecord.RData, ast.RData{
+			rdata := ast.RData{
 				Value: string(tok.Literal),
-			})
+			}
+			if inGroup {
+				rdata.NewLine = groupNewLine
+				if len(groupComments) > 0 {
+					rdata.LeadComments = groupComments
+					groupComments = []string{}
+				}
+				groupNewLine = false
+			}
+			entry.RRecord.RData = append(entry.RRecord.RData, rdata)
 			node.Entry = entry
 
 		case token.RDATA_OPAREN:
 			if node.NodeType != ast.NodeTypeRREntry {
 				return node, fmt.Errorf("%w: unexpected RDATA_OPAREN for NodeType %s: %v", ErrParseError, node.NodeType, tok)
 			}
-			// TODO: do something with RDATA_OPAREN
+			entry, err := getRREntry(tok)
+			if err != nil {
+				return node, err
+			}
+			if entry.RRecord.Group != nil {
+				return node, fmt.Errorf("%w: only one parenthesized group is supported per record: %v", ErrParseError, tok)
+			}
+			entry.RRecord.Group = &ast.RDataGroup{
+				Start: len(entry.RRecord.RData),
+				End:   len(entry.RRecord.RData),
+			}
+			node.Entry = entry
+			inGroup = true
+			groupNewLine = false
+			groupStart = len(node.SourceTokens) - 1
 
 		case token.RDATA_CPAREN:
 			if node.NodeType != ast.NodeTypeRREntry {
 				return node, fmt.Errorf("%w: unexpected RDATA_CPAREN for NodeType %s: %v", ErrParseError, node.NodeType, tok)
 			}
-			// TODO: do something with RDATA_CPAREN
+			if !inGroup {
+				return node, fmt.Errorf("%w: unbalanced RDATA_CPAREN: %v", ErrParseError, tok)
+			}
+			entry, err := getRREntry(tok)
+			if err != nil {
+				return node, err
+			}
+			entry.RRecord.Group.End = len(entry.RRecord.RData)
+			entry.RRecord.Group.CloseOnNewLine = groupNewLine
+			if len(groupComments) > 0 {
+				entry.RRecord.Group.TrailingComments = groupComments
+				groupComments = []string{}
+			}
+			node.Entry = entry
+			inGroup = false
 
 		default:
 			return node, fmt.Errorf("%w: unexpected TokenType %s for token: %v", ErrParseError, tok.Type, tok)
 		}
 	}
+
+	if inGroup {
+		// report the error at the opening parenthesis, which is where the
+		// entry went wrong
+		node.SourceTokens = node.SourceTokens[:groupStart+1]
+		return node, fmt.Errorf("%w: unclosed parenthesis: %v", ErrParseError, node.SourceTokens[groupStart])
+	}
+
+	if node.IsRREntry() && ast.IsGenericRData(node.RREntry().RRecord.RData) {
+		entry := node.RREntry()
+		_, err := ast.ParseGenericRData(entry.RRecord.Type, entry.RRecord.RData)
+		if err != nil {
+			return node, errors.Join(ErrParseError, err)
+		}
+	}
+
 	return node, nil
 }
 
+// unterminatedQuote reports whether literal opens a quoted string that it
+// doesn't close. The lexer only ends a quoted string early at a line break or
+// the end of the input.
+func unterminatedQuote(literal []byte) bool {
+	inQuote := false
+	for i := 0; i < len(literal); i++ {
+		switch literal[i] {
+		case '\\':
+			i++
+		case '"':
+			inQuote = !inQuote
+		}
+	}
+	return inQuote
+}
+
+// ParseEntries splits toks into lines and parses each of them into a node. If
+// an entry can't be parsed the entries parsed so far are returned together with
+// a *ParseError.
 func ParseEntries(toks []token.Token) ([]ast.Node, error) {
 	lines := SplitTokensLines(toks)
 
@@ -291,7 +616,7 @@ func ParseEntries(toks []token.Token) ([]ast.Node, error) {
 	for i, line := range lines {
 		entry, err := ParseEntry(line)
 		if err != nil {
-			return entries, fmt.Errorf("error while parsing entry %d: %w", i, err)
+			return entries, newParseError(i, line, entry.SourceTokens, err)
 		}
 		entries = append(entries, entry)
 	}
@@ -299,6 +624,10 @@ func ParseEntries(toks []token.Token) ([]ast.Node, error) {
 	return entries, nil
 }
 
+// Tokenize turns entries back into tokens. Nodes whose SourceTokens still
+// describe them exactly are emitted verbatim so that untouched parts of a zone
+// file keep their original formatting; every other node is re-synthesized with
+// the canonical layout. Clear SourceTokens to force the canonical layout.
 func Tokenize(entries []ast.Node) ([]token.Token, error) {
 	toks := []token.Token{}
 
@@ -315,12 +644,17 @@ func Tokenize(entries []ast.Node) ([]token.Token, error) {
 		toks = append(toks, tok)
 	}
 
-	for _, node := range entries {
+	for i, node := range entries {
+		if node.IsRawEntry() {
+			tokenizeRaw(node, i == len(entries)-1, emit)
+			continue
+		}
+		if HasPristineSource(node) {
+			tokenizeSource(node.SourceTokens, i == len(entries)-1, emit)
+			continue
+		}
+
 		for _, comment := range node.LeadComments {
-			emit(token.Token{
-				Type:    token.COMMENT,
-				Literal: []byte(comment),
-			})
 			emit(token.Token{
 				Type:    token.COMMENT,
 				Literal: []byte(comment),
@@ -429,13 +763,51 @@ func Tokenize(entries []ast.Node) ([]token.Token, error) {
 				Literal:          []byte(entry.RRecord.Type),
 				WhiteSpaceBefore: []byte(" "),
 			})
-			for _, rdata := range entry.RRecord.RData {
+			TokenizeRData(entry.RRecord, emit)
+		case ast.NodeTypeGenerateControlEntry:
+			if !node.IsGenerateControlEntry() {
+				return toks, fmt.Errorf(
+					"%w: NodeType is GenerateControlEntry but the entry data is invalid: %#v",
+					ErrParseError,
+					node,
+				)
+			}
+			entry := node.GenerateControlEntry()
+			emit(token.Token{
+				Type:    token.CONTROL_ENTRY,
+				Literal: []byte("$GENERATE"),
+			})
+			emit(token.Token{
+				Type:             token.RANGE,
+				Literal:          []byte(entry.Range()),
+				WhiteSpaceBefore: []byte(" "),
+			})
+			emit(token.Token{
+				Type:             token.DOMAIN_NAME,
+				Literal:          []byte(entry.DomainName),
+				WhiteSpaceBefore: []byte(" "),
+			})
+			// BIND only accepts the TTL before the class here
+			if entry.RRecord.TTL.Nanoseconds() != 0 {
 				emit(token.Token{
-					Type:             token.RDATA,
-					Literal:          []byte(rdata.Value),
+					Type:             token.TTL,
+					Literal:          []byte(DurationToSeconds(entry.RRecord.TTL)),
 					WhiteSpaceBefore: []byte(" "),
 				})
 			}
+			if entry.RRecord.Class != "" {
+				emit(token.Token{
+					Type:             token.CLASS,
+					Literal:          []byte(entry.RRecord.Class),
+					WhiteSpaceBefore: []byte(" "),
+				})
+			}
+			emit(token.Token{
+				Type:             token.TYPE,
+				Literal:          []byte(entry.RRecord.Type),
+				WhiteSpaceBefore: []byte(" "),
+			})
+			TokenizeRData(ast.RRecord{RData: entry.RRecord.RData}, emit)
 		}
 
 		if node.LineComment != "" {
@@ -455,6 +827,194 @@ func Tokenize(entries []ast.Node) ([]token.Token, error) {
 	return toks, nil
 }
 
+// HasPristineSource reports whether node has SourceTokens and parsing them
+// again yields node itself, i.e. whether the node was left unmodified since it
+// was parsed.
+func HasPristineSource(node ast.Node) bool {
+	if len(node.SourceTokens) == 0 {
+		return false
+	}
+	reparsed, err := ParseEntry(node.SourceTokens)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(withoutSource(reparsed), withoutSource(node))
+}
+
+// withoutSource clears the fields of node that do not come from parsing its
+// tokens.
+func withoutSource(node ast.Node) ast.Node {
+	node.SourceTokens = nil
+	node.SourceFile = ""
+	if node.IsRREntry() {
+		entry := node.RREntry()
+		entry.Resolved = nil
+		node.Entry = entry
+	}
+	return node
+}
+
+// tokenizeSource emits the source tokens of an unmodified node. The EOF token
+// that ends the last line of a file is only kept if the node is still last;
+// otherwise it becomes a line break, or nothing if it was all the node held.
+func tokenizeSource(toks []token.Token, last bool, emit func(token.Token)) {
+	if len(toks) == 0 {
+		return
+	}
+	eof := toks[len(toks)-1]
+	if eof.Type != token.EOF {
+		for _, tok := range toks {
+			emit(tok)
+		}
+		return
+	}
+
+	toks = toks[:len(toks)-1]
+	for _, tok := range toks {
+		emit(tok)
+	}
+	if last {
+		emit(eof)
+	} else if len(toks) > 0 {
+		emit(token.Token{
+			Type:             token.NEWLINE,
+			Literal:          []byte("\n"),
+			WhiteSpaceBefore: eof.WhiteSpaceBefore,
+		})
+	}
+}
+
+// tokenizeRaw emits a NodeTypeRaw node verbatim. Without SourceTokens the
+// text of the entry is emitted as a single ILLEGAL token.
+func tokenizeRaw(node ast.Node, last bool, emit func(token.Token)) {
+	if len(node.SourceTokens) > 0 {
+		tokenizeSource(node.SourceTokens, last, emit)
+		return
+	}
+	emit(token.Token{
+		Type:    token.ILLEGAL,
+		Literal: []byte(node.RawEntry().Text),
+	})
+	emit(token.Token{
+		Type:    token.NEWLINE,
+		Literal: []byte("\n"),
+	})
+}
+
+// GroupIndent is the whitespace put in front of lines continued inside a
+// parenthesized RDATA group.
+const GroupIndent = "\t\t\t\t"
+
+// TokenizeRData emits the RDATA tokens for rrecord, including the parentheses,
+// line breaks and comments of its RDATA group if it has one.
+func TokenizeRData(rrecord ast.RRecord, emit func(token.Token)) {
+	group := rrecord.Group
+	if group != nil {
+		group = &ast.RDataGroup{
+			Start:            min(max(group.Start, 0), len(rrecord.RData)),
+			End:              min(max(group.End, group.Start), len(rrecord.RData)),
+			Comment:          group.Comment,
+			TrailingComments: group.TrailingComments,
+			CloseOnNewLine:   group.CloseOnNewLine,
+		}
+	}
+
+	// set whenever a comment was emitted, since anything following it on the
+	// same line would become part of the comment.
+	needNewLine := false
+
+	newLine := func() {
+		emit(token.Token{
+			Type:    token.NEWLINE,
+			Literal: []byte("\n"),
+		})
+		needNewLine = false
+	}
+
+	comment := func(literal string, whitespace string) {
+		emit(token.Token{
+			Type:             token.COMMENT,
+			Literal:          []byte(literal),
+			WhiteSpaceBefore: []byte(whitespace),
+		})
+		needNewLine = true
+	}
+
+	openGroup := func() {
+		emit(token.Token{
+			Type:             token.RDATA_OPAREN,
+			Literal:          []byte("("),
+			WhiteSpaceBefore: []byte(" "),
+		})
+		if group.Comment != "" {
+			comment(group.Comment, " ")
+		}
+	}
+
+	closeGroup := func() {
+		for _, trailing := range group.TrailingComments {
+			newLine()
+			comment(trailing, GroupIndent)
+		}
+		whitespace := " "
+		if group.CloseOnNewLine || needNewLine {
+			newLine()
+			whitespace = ""
+		}
+		emit(token.Token{
+			Type:             token.RDATA_CPAREN,
+			Literal:          []byte(")"),
+			WhiteSpaceBefore: []byte(whitespace),
+		})
+	}
+
+	for i, rdata := range rrecord.RData {
+		inGroup := group != nil && i >= group.Start && i < group.End
+
+		if group != nil && i == group.Start {
+			openGroup()
+		}
+		if group != nil && i == group.End {
+			closeGroup()
+		}
+
+		whitespace := " "
+		if inGroup {
+			for _, lead := range rdata.LeadComments {
+				newLine()
+				comment(lead, GroupIndent)
+			}
+			if rdata.NewLine || needNewLine {
+				newLine()
+				whitespace = GroupIndent
+			}
+		}
+
+		emit(token.Token{
+			Type:             token.RDATA,
+			Literal:          []byte(rdata.Value),
+			WhiteSpaceBefore: []byte(whitespace),
+		})
+
+		if inGroup && rdata.Comment != "" {
+			comment(rdata.Comment, " ")
+		}
+	}
+
+	if group != nil && group.Start == len(rrecord.RData) {
+		openGroup()
+	}
+	if group != nil && group.End == len(rrecord.RData) {
+		closeGroup()
+	}
+}
+
+// DurationToSeconds renders d as a TTL in plain seconds, e.g. "90000".
 func DurationToSeconds(d time.Duration) string {
-	return strconv.FormatFloat(d.Round(time.Second).Seconds(), 'f', 0, 64)
+	return ttl.FormatSeconds(d)
+}
+
+// DurationToUnits renders d as a TTL using BIND units, e.g. "1d1h".
+func DurationToUnits(d time.Duration) string {
+	return ttl.Format(d)
 }
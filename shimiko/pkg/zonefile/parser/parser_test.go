@@ -1,13 +1,19 @@
 package parser_test
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lexer"
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/rrtype"
 	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
 )
 
@@ -32,6 +38,64 @@ func TestParseEntry(t *testing.T) {
 				NodeType: ast.NodeTypeEmpty,
 			},
 		},
+		"ttl with bind units": {
+			inputTokens: []token.Token{
+				{
+					Type:             token.DOMAIN_NAME,
+					Literal:          []byte("host"),
+					WhiteSpaceBefore: []byte{},
+				},
+				{
+					Type:             token.TTL,
+					Literal:          []byte("1W2d"),
+					WhiteSpaceBefore: []byte(" "),
+				},
+				{
+					Type:             token.TYPE,
+					Literal:          []byte("A"),
+					WhiteSpaceBefore: []byte(" "),
+				},
+				{
+					Type:             token.RDATA,
+					Literal:          []byte("192.0.2.1"),
+					WhiteSpaceBefore: []byte(" "),
+				},
+			},
+			expected: ast.Node{
+				NodeType: ast.NodeTypeRREntry,
+				Entry: ast.RREntry{
+					DomainName: "host",
+					RRecord: ast.RRecord{
+						TTL:  9 * 24 * time.Hour,
+						Type: "A",
+						RData: []ast.RData{
+							{
+								Value: "192.0.2.1",
+							},
+						},
+					},
+				},
+			},
+		},
+		"invalid ttl": {
+			inputTokens: []token.Token{
+				{
+					Type:             token.CONTROL_ENTRY,
+					Literal:          []byte("$TTL"),
+					WhiteSpaceBefore: []byte{},
+				},
+				{
+					Type:             token.TTL,
+					Literal:          []byte("1.5h"),
+					WhiteSpaceBefore: []byte(" "),
+				},
+			},
+			expected: ast.Node{
+				NodeType: ast.NodeTypeTTLControlEntry,
+				Entry:    ast.TTLControlEntry{},
+			},
+			err: parser.ErrParseError,
+		},
 		"empty node with comments": {
 			inputTokens: []token.Token{
 				{
@@ -989,7 +1053,6 @@ func TestParseEntries(t *testing.T) {
 				{
 					NodeType:     ast.NodeTypeRREntry,
 					LeadComments: []string{},
-					LineComment:  ";minimum ttl",
 					Entry: ast.RREntry{
 						DomainName: "@",
 						RRecord: ast.RRecord{
@@ -1003,21 +1066,36 @@ func TestParseEntries(t *testing.T) {
 									Value: "HOSTMASTER.MYDOMAIN.COM.",
 								},
 								{
-									Value: "1406291485",
+									Value:   "1406291485",
+									NewLine: true,
+									Comment: ";serial",
 								},
 								{
-									Value: "3600",
+									Value:   "3600",
+									NewLine: true,
+									Comment: ";refresh",
 								},
 								{
-									Value: "600",
+									Value:   "600",
+									NewLine: true,
+									Comment: ";retry",
 								},
 								{
-									Value: "604800",
+									Value:   "604800",
+									NewLine: true,
+									Comment: ";expire",
 								},
 								{
-									Value: "86400",
+									Value:   "86400",
+									NewLine: true,
+									Comment: ";minimum ttl",
 								},
 							},
+							Group: &ast.RDataGroup{
+								Start:          2,
+								End:            7,
+								CloseOnNewLine: true,
+							},
 						},
 					},
 					SourceTokens: []token.Token{
@@ -1495,3 +1573,500 @@ func TestTokenize(t *testing.T) {
 		})
 	}
 }
+
+func TestMultiLineRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input    string
+		expected string
+	}{
+		"soa with comments": {
+			input: "@\tIN\tSOA\tNS1.NAMESERVER.NET.\tHOSTMASTER.MYDOMAIN.COM.\t(\n" +
+				"\t\t\t1406291485\t ;serial\n" +
+				"\t\t\t3600\t ;refresh\n" +
+				"\t\t\t600\t ;retry\n" +
+				"\t\t\t604800\t ;expire\n" +
+				"\t\t\t86400\t ;minimum ttl\n" +
+				")",
+			expected: "@ IN SOA NS1.NAMESERVER.NET. HOSTMASTER.MYDOMAIN.COM. (\n" +
+				"\t\t\t\t1406291485 ;serial\n" +
+				"\t\t\t\t3600 ;refresh\n" +
+				"\t\t\t\t600 ;retry\n" +
+				"\t\t\t\t604800 ;expire\n" +
+				"\t\t\t\t86400 ;minimum ttl\n" +
+				")\n",
+		},
+		"soa on a single line": {
+			input:    "example.com.  IN  SOA   ns.example.com. username.example.com. ( 2007120710 1d 2h 4w 1h )",
+			expected: "example.com. IN SOA ns.example.com. username.example.com. ( 2007120710 1d 2h 4w 1h )\n",
+		},
+		"long txt": {
+			input: "long\tTXT\t( \"v=DKIM1\\; k=rsa\\; \"\n" +
+				"\t\"p=MIIBIjANBgkqhkiG9w0BAQEFAAOC\" )",
+			expected: "long TXT ( \"v=DKIM1\\; k=rsa\\; \"\n" +
+				"\t\t\t\t\"p=MIIBIjANBgkqhkiG9w0BAQEFAAOC\" )\n",
+		},
+		"dnskey with line comment": {
+			input: "@ 3600 IN DNSKEY 257 3 13 (\n" +
+				"\tmdsswUyr3DPW132mOi8V9xESWE8jTo0d\n" +
+				"\txbjjnopKl+GqJxpVXckHAeF+KkxLbxIL\n" +
+				"\t) ; KSK",
+			expected: "@ IN 3600 DNSKEY 257 3 13 (\n" +
+				"\t\t\t\tmdsswUyr3DPW132mOi8V9xESWE8jTo0d\n" +
+				"\t\t\t\txbjjnopKl+GqJxpVXckHAeF+KkxLbxIL\n" +
+				") ; KSK\n",
+		},
+		"comment lines inside group": {
+			input: "@ SOA ns host (\n" +
+				"\t; timers follow\n" +
+				"\t1 ; serial\n" +
+				"\t2 3 4 5\n" +
+				"\t; end\n" +
+				")",
+			expected: "@ SOA ns host (\n" +
+				"\t\t\t\t; timers follow\n" +
+				"\t\t\t\t1 ; serial\n" +
+				"\t\t\t\t2 3 4 5\n" +
+				"\t\t\t\t; end\n" +
+				")\n",
+		},
+		"comment after opening parenthesis": {
+			input: "@ SOA ns host ( ; timers\n" +
+				"\t1 2 3 4 5 )",
+			expected: "@ SOA ns host ( ; timers\n" +
+				"\t\t\t\t1 2 3 4 5 )\n",
+		},
+	}
+
+	parse := func(t *testing.T, input string) []ast.Node {
+		t.Helper()
+		entries, err := parser.ParseEntries(lexer.LexBytes([]byte(input)).AllTokens())
+		assert.NoError(t, err)
+		for i := range entries {
+			entries[i].SourceTokens = nil
+		}
+		return entries
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			entries := parse(t, tc.input)
+
+			toks, err := parser.Tokenize(entries)
+			assert.NoError(t, err)
+			rendered := string(token.RenderTokens(toks))
+			assert.Equal(t, tc.expected, rendered)
+
+			// drop the final newline so the re-parse doesn't produce an extra empty
+			// node at EOF.
+			assert.Equal(t, entries, parse(t, strings.TrimSuffix(rendered, "\n")))
+		})
+	}
+}
+
+func TestTokenizeLossless(t *testing.T) {
+	t.Parallel()
+
+	zone := "$ORIGIN example.com.\t; origin\n" +
+		"$TTL    1h\n" +
+		"\n" +
+		"; name servers\n" +
+		"@\tIN  SOA ns  host (\n" +
+		"\t\t1 ; serial\n" +
+		"\t\t2 3 4 5 )\n" +
+		"   \n" +
+		"www   300\tIN A     192.0.2.1   \n" +
+		"      IN AAAA  2001:db8::1\r\n" +
+		"txt   TXT   \"hello world\" ; greeting"
+
+	tests := map[string]struct {
+		input    string
+		modify   func(entries []ast.Node) []ast.Node
+		expected string
+	}{
+		"unmodified": {
+			input:    zone,
+			expected: zone,
+		},
+		"unmodified with trailing newline": {
+			input:    zone + "\n",
+			expected: zone + "\n",
+		},
+		"empty": {
+			input:    "",
+			expected: "",
+		},
+		"modified node is re-synthesized": {
+			input: zone,
+			modify: func(entries []ast.Node) []ast.Node {
+				entry := entries[6].RREntry()
+				entry.RRecord.RData[0].Value = "192.0.2.2"
+				entries[6].Entry = entry
+				return entries
+			},
+			expected: strings.Replace(zone, "www   300\tIN A     192.0.2.1   \n", "www IN 300 A 192.0.2.2\n", 1),
+		},
+		"appended node after last line": {
+			input: zone,
+			modify: func(entries []ast.Node) []ast.Node {
+				return append(entries, ast.Node{
+					NodeType: ast.NodeTypeRREntry,
+					Entry: ast.RREntry{
+						DomainName: "new",
+						RRecord: ast.RRecord{
+							Type:  "A",
+							RData: []ast.RData{{Value: "192.0.2.3"}},
+						},
+					},
+				})
+			},
+			expected: zone + "\nnew A 192.0.2.3\n",
+		},
+		"appended node after trailing newline": {
+			input: "a A 192.0.2.1\n",
+			modify: func(entries []ast.Node) []ast.Node {
+				return append(entries, ast.Node{
+					NodeType: ast.NodeTypeRREntry,
+					Entry: ast.RREntry{
+						DomainName: "b",
+						RRecord: ast.RRecord{
+							Type:  "A",
+							RData: []ast.RData{{Value: "192.0.2.2"}},
+						},
+					},
+				})
+			},
+			expected: "a A 192.0.2.1\nb A 192.0.2.2\n",
+		},
+		"reordered nodes": {
+			input: "a A 192.0.2.1\nb  A  192.0.2.2",
+			modify: func(entries []ast.Node) []ast.Node {
+				return []ast.Node{entries[1], entries[0]}
+			},
+			expected: "b  A  192.0.2.2\na A 192.0.2.1\n",
+		},
+		"lead comments are written once": {
+			input: "a A 192.0.2.1\n",
+			modify: func(entries []ast.Node) []ast.Node {
+				entries[0].LeadComments = []string{"; first", "; second"}
+				return entries
+			},
+			expected: "; first\n; second\na A 192.0.2.1\n",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			entries, err := parser.ParseEntries(lexer.LexBytes([]byte(tc.input)).AllTokens())
+			require.NoError(t, err)
+			if tc.modify != nil {
+				entries = tc.modify(entries)
+			}
+
+			toks, err := parser.Tokenize(entries)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(token.RenderTokens(toks)))
+		})
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"empty":               "",
+		"records":             "$ORIGIN example.com.\n$TTL 1h\nwww IN A 192.0.2.1\n\tIN AAAA 2001:db8::1\n",
+		"no trailing newline": "www IN A 192.0.2.1 ; web",
+		"multi-line group": "@ IN SOA ns host (\n" +
+			"\t1 ; serial\n" +
+			"\t2 3 4 5 )\n" +
+			"@ NS ns\n",
+	}
+
+	for name, input := range tests {
+		input := input
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			expected, err := parser.ParseEntries(lexer.LexBytes([]byte(input)).AllTokens())
+			require.NoError(t, err)
+
+			got, err := parser.ParseReader(strings.NewReader(input))
+			require.NoError(t, err)
+			assert.Equal(t, expected, got)
+		})
+	}
+}
+
+func TestParseReaderUnbalanced(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input       string
+		position    token.Position
+		errContains string
+	}{
+		"unclosed group": {
+			input:       "a IN TXT ( \"x\"\nb IN A 1.1.1.1\nc IN A 2.2.2.2\n",
+			position:    token.Position{Offset: 9, Line: 1, Column: 10},
+			errContains: "unclosed parenthesis",
+		},
+		"unclosed group at the end of the file": {
+			input:       "www A 192.0.2.1\n@ TXT ( \"a\"\n\"b\"",
+			position:    token.Position{Offset: 22, Line: 2, Column: 7},
+			errContains: "unclosed parenthesis",
+		},
+		"stray closing parenthesis": {
+			input:       "a IN A 1.1.1.1 )\nb IN A 2.2.2.2\n",
+			position:    token.Position{Offset: 15, Line: 1, Column: 16},
+			errContains: "unbalanced RDATA_CPAREN",
+		},
+		"unterminated quote": {
+			input:       "a IN TXT \"unterminated",
+			position:    token.Position{Offset: 9, Line: 1, Column: 10},
+			errContains: "unterminated quoted string",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parser.ParseReader(strings.NewReader(tc.input))
+			var parseErr *parser.ParseError
+			require.ErrorAs(t, err, &parseErr)
+			assert.ErrorIs(t, err, parser.ErrParseError)
+			assert.ErrorContains(t, err, tc.errContains)
+			assert.Equal(t, tc.position, parseErr.Position())
+
+			_, err = parser.ParseEntries(lexer.LexBytes([]byte(tc.input)).AllTokens())
+			require.ErrorAs(t, err, &parseErr)
+			assert.Equal(t, tc.position, parseErr.Position())
+		})
+	}
+}
+
+func TestParseReaderTolerant(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input  string
+		raw    []int
+		errors []int
+	}{
+		"valid": {
+			input: "$TTL 300\nwww A 192.0.2.1\n",
+		},
+		"bad lines": {
+			input: "www A 192.0.2.1\n" +
+				"$TTL forever\n" +
+				"mail 300 300 A 192.0.2.2 ; twice\n" +
+				"ftp A 192.0.2.3\n" +
+				"@ BOGUS x",
+			raw:    []int{1, 2, 4},
+			errors: []int{1, 2, 4},
+		},
+		"bad group": {
+			input: "@ SOA ns host (\n" +
+				"\t1 2 3 4 5 )\n" +
+				"www 1 2 A 192.0.2.1\n" +
+				"\n",
+			raw:    []int{1},
+			errors: []int{1},
+		},
+		"unclosed group": {
+			input: "www A 192.0.2.1\n" +
+				"a IN TXT ( \"x\"\n" +
+				"b IN A 192.0.2.2\n" +
+				"\tIN TXT \"y\"\n" +
+				"c 1 2 A 192.0.2.3\n",
+			raw:    []int{1, 4},
+			errors: []int{1, 4},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			entries, parseErrs, err := parser.ParseReaderTolerant(strings.NewReader(tc.input))
+			require.NoError(t, err)
+
+			raw := []int{}
+			for i, node := range entries {
+				if node.IsRawEntry() {
+					assert.Equal(t, ast.NodeTypeRaw, node.NodeType)
+					assert.Error(t, node.RawEntry().Err)
+					raw = append(raw, i)
+				}
+			}
+			assert.Equal(t, tc.raw, nilIfEmpty(raw))
+
+			errs := []int{}
+			for _, parseErr := range parseErrs {
+				assert.ErrorIs(t, parseErr, parser.ErrParseError)
+				errs = append(errs, parseErr.Entry)
+			}
+			assert.Equal(t, tc.errors, nilIfEmpty(errs))
+
+			toks, err := parser.Tokenize(entries)
+			require.NoError(t, err)
+			assert.Equal(t, tc.input, string(token.RenderTokens(toks)))
+
+			_, err = parser.ParseReader(strings.NewReader(tc.input))
+			if len(tc.errors) > 0 {
+				var parseErr *parser.ParseError
+				require.ErrorAs(t, err, &parseErr)
+				assert.Equal(t, tc.errors[0], parseErr.Entry)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseReaderTolerantUnclosedGroup(t *testing.T) {
+	t.Parallel()
+
+	input := "a IN TXT ( \"x\"\nb IN A 1.1.1.1\nc IN A 2.2.2.2\n"
+	entries, parseErrs, err := parser.ParseReaderTolerant(strings.NewReader(input))
+	require.NoError(t, err)
+
+	require.Len(t, parseErrs, 1)
+	assert.ErrorContains(t, parseErrs[0], "unclosed parenthesis")
+	assert.Equal(t, token.Position{Offset: 9, Line: 1, Column: 10}, parseErrs[0].Position())
+
+	require.Len(t, entries, 4)
+	assert.Equal(t, `a IN TXT ( "x"`, entries[0].RawEntry().Text)
+	assert.Equal(t, "b", entries[1].RREntry().DomainName)
+	assert.Equal(t, "c", entries[2].RREntry().DomainName)
+	assert.Equal(t, token.Position{Offset: 30, Line: 3, Column: 1}, entries[2].SourceTokens[0].Position)
+
+	toks, err := parser.Tokenize(entries)
+	require.NoError(t, err)
+	assert.Equal(t, input, string(token.RenderTokens(toks)))
+}
+
+func nilIfEmpty(s []int) []int {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+func TestTokenizeRawWithoutSource(t *testing.T) {
+	t.Parallel()
+
+	toks, err := parser.Tokenize([]ast.Node{
+		{
+			NodeType: ast.NodeTypeRaw,
+			Entry: ast.RawEntry{
+				Text: "www 1 2 A 192.0.2.1",
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "www 1 2 A 192.0.2.1\n", string(token.RenderTokens(toks)))
+}
+
+func TestParseGenericSyntax(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input       string
+		expected    ast.RRecord
+		rendered    string
+		errContains string
+	}{
+		"unknown type": {
+			input: "host CLASS1 TYPE65534 \\# 4 0A000001\n",
+			expected: ast.RRecord{
+				Class: "CLASS1",
+				Type:  "TYPE65534",
+				RData: []ast.RData{{Value: `\#`}, {Value: "4"}, {Value: "0A000001"}},
+			},
+			rendered: "host CLASS1 TYPE65534 \\# 4 0A000001\n",
+		},
+		"empty rdata": {
+			input: "host TYPE731 \\# 0\n",
+			expected: ast.RRecord{
+				Type:  "TYPE731",
+				RData: []ast.RData{{Value: `\#`}, {Value: "0"}},
+			},
+			rendered: "host TYPE731 \\# 0\n",
+		},
+		"length mismatch": {
+			input:       "host TYPE65534 \\# 5 0A000001\n",
+			errContains: "length is 4 bytes but RDLENGTH is 5",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			entries, err := parser.ParseEntries(lexer.LexBytes([]byte(tc.input)).AllTokens())
+
+			if tc.errContains != "" {
+				assert.ErrorIs(t, err, parser.ErrParseError)
+				assert.ErrorIs(t, err, ast.ErrInvalidRData)
+				assert.ErrorContains(t, err, tc.errContains)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, entries[0].IsRREntry())
+			assert.Equal(t, tc.expected, entries[0].RREntry().RRecord)
+
+			entries[0].SourceTokens = nil
+			toks, err := parser.Tokenize(entries[:1])
+			require.NoError(t, err)
+			assert.Equal(t, tc.rendered, string(token.RenderTokens(toks)))
+		})
+	}
+}
+
+func BenchmarkParseReader(b *testing.B) {
+	var buf bytes.Buffer
+	buf.WriteString("$ORIGIN example.com.\n$TTL 1h\n@ IN SOA ns1 hostmaster 1 3600 600 604800 300\n")
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&buf, "host%d IN A 10.%d.%d.%d\n", i, (i>>16)&0xff, (i>>8)&0xff, i&0xff)
+	}
+	zone := buf.Bytes()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(zone)))
+	for i := 0; i < b.N; i++ {
+		_, err := parser.ParseReader(bytes.NewReader(zone))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseReaderRegisteredTypes(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range rrtype.Supported(rrtype.BackendCoreDNS) {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parser.ParseReader(strings.NewReader("host IN " + name + " \\# 0\n"))
+			require.NoError(t, err)
+			require.Len(t, got, 2)
+			entry := got[0].RREntry()
+			assert.Equal(t, "host", entry.DomainName)
+			assert.Equal(t, name, entry.RRecord.Type)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+)
+
+var ErrResolveError = errors.New("resolve error")
+
+// DefaultClass is the class used for records when no earlier record in the
+// zone specified one.
+const DefaultClass = "IN"
+
+// Resolve fills in the Resolved field of every RREntry in entries and returns
+// the updated entries. origin is the origin in effect before the first entry
+// and may be empty if the entries start with $ORIGIN or only use absolute
+// names.
+//
+// The effective TTL of an entry without one is the $TTL in effect, or the
+// last explicitly stated TTL if there is no $TTL (RFC 1035), or the SOA
+// MINIMUM as a last resort. $INCLUDE entries are not followed and $GENERATE
+// entries are not expanded; use ResolveIncludes and ExpandGenerates first.
+func Resolve(entries []ast.Node, origin string) ([]ast.Node, error) {
+	resolved := make([]ast.Node, len(entries))
+	copy(resolved, entries)
+
+	var (
+		owner         string
+		class         string
+		defaultTTL    time.Duration
+		hasTTL        bool
+		lastTTL       time.Duration
+		hasLastTTL    bool
+		soaMinimum    time.Duration
+		hasSOAMinimum bool
+	)
+
+	// Look for the SOA up front so that records before it (which is unusual
+	// but legal) still get the right fallback TTL.
+	for _, node := range entries {
+		if !node.IsRREntry() || !strings.EqualFold(node.RREntry().RRecord.Type, "SOA") {
+			continue
+		}
+		soa, err := ast.ParseSOA(node.RREntry().RRecord.RData)
+		if err == nil {
+			soaMinimum = soa.Minimum
+			hasSOAMinimum = true
+		}
+		break
+	}
+
+	for i, node := range resolved {
+		switch {
+		case node.IsOriginControlEntry():
+			name := node.OriginControlEntry().DomainName
+			if !ast.IsAbsoluteName(name) && origin == "" {
+				return resolved, fmt.Errorf("%w: entry %d: relative $ORIGIN '%s' with no origin in effect", ErrResolveError, i, name)
+			}
+			origin = ast.AbsoluteName(name, origin)
+
+		case node.IsTTLControlEntry():
+			defaultTTL = node.TTLControlEntry().TTL
+			hasTTL = true
+
+		case node.IsRREntry():
+			entry := node.RREntry()
+
+			if entry.DomainName != "" {
+				if !ast.IsAbsoluteName(entry.DomainName) && origin == "" {
+					return resolved, fmt.Errorf(
+						"%w: entry %d: relative owner '%s' with no origin in effect",
+						ErrResolveError,
+						i,
+						entry.DomainName,
+					)
+				}
+				owner = ast.AbsoluteName(entry.DomainName, origin)
+			}
+			if owner == "" {
+				return resolved, fmt.Errorf("%w: entry %d: blank owner with no previous owner", ErrResolveError, i)
+			}
+
+			if entry.RRecord.Class != "" {
+				class = strings.ToUpper(entry.RRecord.Class)
+			}
+			if class == "" {
+				class = DefaultClass
+			}
+
+			ttl := entry.RRecord.TTL
+			switch {
+			case ttl != 0:
+				lastTTL = ttl
+				hasLastTTL = true
+			case hasTTL:
+				ttl = defaultTTL
+			case hasLastTTL:
+				ttl = lastTTL
+			case hasSOAMinimum:
+				ttl = soaMinimum
+			}
+
+			entry.Resolved = &ast.ResolvedRR{
+				Owner:  owner,
+				TTL:    ttl,
+				Class:  class,
+				Origin: origin,
+			}
+			resolved[i].Entry = entry
+		}
+	}
+
+	return resolved, nil
+}
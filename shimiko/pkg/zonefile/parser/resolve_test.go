@@ -0,0 +1,136 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/lexer"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+)
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input       string
+		origin      string
+		expected    []ast.ResolvedRR
+		err         error
+		errContains string
+	}{
+		"owner inheritance and $TTL": {
+			input: "$ORIGIN example.com.\n" +
+				"$TTL 1h\n" +
+				"example.com.  IN  SOA   ns.example.com. username.example.com. ( 2007120710 86400 7200 2419200 3600 )\n" +
+				"              IN  NS    ns\n" +
+				"@             IN  A     192.0.2.1\n" +
+				"              IN  AAAA  2001:db8:10::1\n" +
+				"ns        300 IN  A     192.0.2.2\n" +
+				"                  AAAA  2001:db8:10::2\n",
+			expected: []ast.ResolvedRR{
+				{Owner: "example.com.", TTL: time.Hour, Class: "IN", Origin: "example.com."},
+				{Owner: "example.com.", TTL: time.Hour, Class: "IN", Origin: "example.com."},
+				{Owner: "example.com.", TTL: time.Hour, Class: "IN", Origin: "example.com."},
+				{Owner: "example.com.", TTL: time.Hour, Class: "IN", Origin: "example.com."},
+				{Owner: "ns.example.com.", TTL: 300 * time.Second, Class: "IN", Origin: "example.com."},
+				{Owner: "ns.example.com.", TTL: time.Hour, Class: "IN", Origin: "example.com."},
+			},
+		},
+		"last explicit TTL without $TTL": {
+			input: "a 600 IN A 192.0.2.1\n" +
+				"b A 192.0.2.2\n" +
+				"c 60 A 192.0.2.3\n" +
+				"  A 192.0.2.4\n",
+			origin: "example.com.",
+			expected: []ast.ResolvedRR{
+				{Owner: "a.example.com.", TTL: 600 * time.Second, Class: "IN", Origin: "example.com."},
+				{Owner: "b.example.com.", TTL: 600 * time.Second, Class: "IN", Origin: "example.com."},
+				{Owner: "c.example.com.", TTL: 60 * time.Second, Class: "IN", Origin: "example.com."},
+				{Owner: "c.example.com.", TTL: 60 * time.Second, Class: "IN", Origin: "example.com."},
+			},
+		},
+		"SOA minimum as last resort": {
+			input: "@ IN SOA ns hostmaster 1 2 3 4 900\n" +
+				"www A 192.0.2.1\n",
+			origin: "example.com.",
+			expected: []ast.ResolvedRR{
+				{Owner: "example.com.", TTL: 900 * time.Second, Class: "IN", Origin: "example.com."},
+				{Owner: "www.example.com.", TTL: 900 * time.Second, Class: "IN", Origin: "example.com."},
+			},
+		},
+		"class inheritance": {
+			input: "a CH TXT \"chaos\"\n" +
+				"b TXT \"still chaos\"\n",
+			origin: "example.com.",
+			expected: []ast.ResolvedRR{
+				{Owner: "a.example.com.", Class: "CH", Origin: "example.com."},
+				{Owner: "b.example.com.", Class: "CH", Origin: "example.com."},
+			},
+		},
+		"relative and nested origin": {
+			input: "$ORIGIN example.com.\n" +
+				"www A 192.0.2.1\n" +
+				"$ORIGIN lab\n" +
+				"host A 192.0.2.2\n" +
+				"@ A 192.0.2.3\n" +
+				"abs.example.net. A 192.0.2.4\n",
+			expected: []ast.ResolvedRR{
+				{Owner: "www.example.com.", Class: "IN", Origin: "example.com."},
+				{Owner: "host.lab.example.com.", Class: "IN", Origin: "lab.example.com."},
+				{Owner: "lab.example.com.", Class: "IN", Origin: "lab.example.com."},
+				{Owner: "abs.example.net.", Class: "IN", Origin: "lab.example.com."},
+			},
+		},
+		"blank owner at start": {
+			input:       "\tA 192.0.2.1\n",
+			origin:      "example.com.",
+			err:         parser.ErrResolveError,
+			errContains: "blank owner with no previous owner",
+		},
+		"relative owner without origin": {
+			input:       "www A 192.0.2.1\n",
+			err:         parser.ErrResolveError,
+			errContains: "relative owner 'www'",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			entries, err := parser.ParseEntries(lexer.LexBytes([]byte(tc.input)).AllTokens())
+			require.NoError(t, err)
+
+			got, err := parser.Resolve(entries, tc.origin)
+
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				assert.ErrorContains(t, err, tc.errContains)
+				return
+			}
+			require.NoError(t, err)
+
+			resolved := []ast.ResolvedRR{}
+			for _, node := range got {
+				if !node.IsRREntry() {
+					continue
+				}
+				require.NotNil(t, node.RREntry().Resolved)
+				resolved = append(resolved, *node.RREntry().Resolved)
+			}
+			assert.Equal(t, tc.expected, resolved)
+
+			// the input entries must be left untouched
+			for _, node := range entries {
+				if node.IsRREntry() {
+					assert.Nil(t, node.RREntry().Resolved)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+// Package rrtype is the registry of resource record types: their IANA
+// mnemonics and codes, the shape of their RDATA and the backends that can
+// serve them. The lexer, the wire format and the persistence layer all
+// consult it, so a type is either known everywhere or nowhere.
+package rrtype
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+)
+
+var (
+	ErrUnknownType     = errors.New("unknown record type")
+	ErrUnsupportedType = errors.New("unsupported record type")
+)
+
+// Shape is the rough form of the RDATA of a type.
+type Shape string
+
+const (
+	// ShapeOpaque RDATA has no presentation form this module understands and
+	// has to be written in the generic form of RFC 3597.
+	ShapeOpaque Shape = "opaque"
+	// ShapeAddress RDATA is a single IP address.
+	ShapeAddress Shape = "address"
+	// ShapeName RDATA is a single domain name.
+	ShapeName Shape = "name"
+	// ShapeText RDATA is one or more character-strings.
+	ShapeText Shape = "text"
+	// ShapeTyped RDATA has a typed view, see ast.ParseTypedRData.
+	ShapeTyped Shape = "typed"
+	// ShapeMeta types only appear in queries and messages, never in zones.
+	ShapeMeta Shape = "meta"
+)
+
+// Backend is a place shimiko publishes records to.
+type Backend string
+
+const (
+	BackendCoreDNS Backend = "coredns"
+	BackendRoute53 Backend = "route53"
+)
+
+// Type is a registered resource record type.
+type Type struct {
+	// Name is the mnemonic in uppercase.
+	Name string
+	Code uint16
+	// Shape is the form of the RDATA.
+	Shape Shape
+	// Compressible is set for the types of RFC 1035 whose RDATA names may be
+	// compressed (RFC 3597 section 4).
+	Compressible bool
+	// Backends are the backends that can serve records of the type.
+	Backends []Backend
+}
+
+// SupportedBy reports whether backend can serve records of the type.
+func (t Type) SupportedBy(backend Backend) bool {
+	return slices.Contains(t.Backends, backend)
+}
+
+var (
+	// zone file types are served by CoreDNS, which reads the zone with
+	// miekg/dns and accepts the generic form of RFC 3597 for anything else
+	coreDNSOnly = []Backend{BackendCoreDNS}
+	// the types Route53 lets records be created for
+	coreDNSAndRoute53 = []Backend{BackendCoreDNS, BackendRoute53}
+)
+
+// types are the types in the IANA "Resource Record (RR) TYPEs" registry,
+// ordered by code.
+var types = []Type{
+	{Name: "A", Code: 1, Shape: ShapeAddress, Backends: coreDNSAndRoute53},
+	{Name: "NS", Code: 2, Shape: ShapeName, Compressible: true, Backends: coreDNSAndRoute53},
+	{Name: "MD", Code: 3, Shape: ShapeName, Compressible: true, Backends: coreDNSOnly},
+	{Name: "MF", Code: 4, Shape: ShapeName, Compressible: true, Backends: coreDNSOnly},
+	{Name: "CNAME", Code: 5, Shape: ShapeName, Compressible: true, Backends: coreDNSAndRoute53},
+	{Name: "SOA", Code: 6, Shape: ShapeTyped, Compressible: true, Backends: coreDNSAndRoute53},
+	{Name: "MB", Code: 7, Shape: ShapeName, Compressible: true, Backends: coreDNSOnly},
+	{Name: "MG", Code: 8, Shape: ShapeName, Compressible: true, Backends: coreDNSOnly},
+	{Name: "MR", Code: 9, Shape: ShapeName, Compressible: true, Backends: coreDNSOnly},
+	{Name: "NULL", Code: 10, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "WKS", Code: 11, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "PTR", Code: 12, Shape: ShapeName, Compressible: true, Backends: coreDNSAndRoute53},
+	{Name: "HINFO", Code: 13, Shape: ShapeText, Backends: coreDNSOnly},
+	{Name: "MINFO", Code: 14, Shape: ShapeOpaque, Compressible: true, Backends: coreDNSOnly},
+	{Name: "MX", Code: 15, Shape: ShapeTyped, Compressible: true, Backends: coreDNSAndRoute53},
+	{Name: "TXT", Code: 16, Shape: ShapeTyped, Backends: coreDNSAndRoute53},
+	{Name: "RP", Code: 17, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "AFSDB", Code: 18, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "X25", Code: 19, Shape: ShapeText, Backends: coreDNSOnly},
+	{Name: "ISDN", Code: 20, Shape: ShapeText, Backends: coreDNSOnly},
+	{Name: "RT", Code: 21, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "NSAP", Code: 22, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "NSAP-PTR", Code: 23, Shape: ShapeName, Backends: coreDNSOnly},
+	{Name: "SIG", Code: 24, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "KEY", Code: 25, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "PX", Code: 26, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "GPOS", Code: 27, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "AAAA", Code: 28, Shape: ShapeAddress, Backends: coreDNSAndRoute53},
+	{Name: "LOC", Code: 29, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "NXT", Code: 30, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "EID", Code: 31, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "NIMLOC", Code: 32, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "SRV", Code: 33, Shape: ShapeTyped, Backends: coreDNSAndRoute53},
+	{Name: "ATMA", Code: 34, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "NAPTR", Code: 35, Shape: ShapeTyped, Backends: coreDNSAndRoute53},
+	{Name: "KX", Code: 36, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "CERT", Code: 37, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "A6", Code: 38, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "DNAME", Code: 39, Shape: ShapeName, Backends: coreDNSOnly},
+	{Name: "SINK", Code: 40, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "OPT", Code: 41, Shape: ShapeMeta},
+	{Name: "APL", Code: 42, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "DS", Code: 43, Shape: ShapeTyped, Backends: coreDNSAndRoute53},
+	{Name: "SSHFP", Code: 44, Shape: ShapeTyped, Backends: coreDNSAndRoute53},
+	{Name: "IPSECKEY", Code: 45, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "RRSIG", Code: 46, Shape: ShapeTyped, Backends: coreDNSOnly},
+	{Name: "NSEC", Code: 47, Shape: ShapeTyped, Backends: coreDNSOnly},
+	{Name: "DNSKEY", Code: 48, Shape: ShapeTyped, Backends: coreDNSOnly},
+	{Name: "DHCID", Code: 49, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "NSEC3", Code: 50, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "NSEC3PARAM", Code: 51, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "TLSA", Code: 52, Shape: ShapeTyped, Backends: coreDNSAndRoute53},
+	{Name: "SMIMEA", Code: 53, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "HIP", Code: 55, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "NINFO", Code: 56, Shape: ShapeText, Backends: coreDNSOnly},
+	{Name: "RKEY", Code: 57, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "TALINK", Code: 58, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "CDS", Code: 59, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "CDNSKEY", Code: 60, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "OPENPGPKEY", Code: 61, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "CSYNC", Code: 62, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "ZONEMD", Code: 63, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "SVCB", Code: 64, Shape: ShapeTyped, Backends: coreDNSAndRoute53},
+	{Name: "HTTPS", Code: 65, Shape: ShapeTyped, Backends: coreDNSAndRoute53},
+	{Name: "DSYNC", Code: 66, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "SPF", Code: 99, Shape: ShapeText, Backends: coreDNSAndRoute53},
+	{Name: "UINFO", Code: 100, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "UID", Code: 101, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "GID", Code: 102, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "UNSPEC", Code: 103, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "NID", Code: 104, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "L32", Code: 105, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "L64", Code: 106, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "LP", Code: 107, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "EUI48", Code: 108, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "EUI64", Code: 109, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "NXNAME", Code: 128, Shape: ShapeMeta},
+	{Name: "TKEY", Code: 249, Shape: ShapeMeta},
+	{Name: "TSIG", Code: 250, Shape: ShapeMeta},
+	{Name: "IXFR", Code: 251, Shape: ShapeMeta},
+	{Name: "AXFR", Code: 252, Shape: ShapeMeta},
+	{Name: "MAILB", Code: 253, Shape: ShapeMeta},
+	{Name: "MAILA", Code: 254, Shape: ShapeMeta},
+	{Name: "ANY", Code: 255, Shape: ShapeMeta},
+	{Name: "URI", Code: 256, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "CAA", Code: 257, Shape: ShapeTyped, Backends: coreDNSAndRoute53},
+	{Name: "AVC", Code: 258, Shape: ShapeText, Backends: coreDNSOnly},
+	{Name: "DOA", Code: 259, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "AMTRELAY", Code: 260, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "RESINFO", Code: 261, Shape: ShapeText, Backends: coreDNSOnly},
+	{Name: "WALLET", Code: 262, Shape: ShapeText, Backends: coreDNSOnly},
+	{Name: "CLA", Code: 263, Shape: ShapeText, Backends: coreDNSOnly},
+	{Name: "IPN", Code: 264, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "TA", Code: 32768, Shape: ShapeOpaque, Backends: coreDNSOnly},
+	{Name: "DLV", Code: 32769, Shape: ShapeOpaque, Backends: coreDNSOnly},
+}
+
+var (
+	byName = func() map[string]Type {
+		index := make(map[string]Type, len(types))
+		for _, t := range types {
+			index[t.Name] = t
+		}
+		return index
+	}()
+	byCode = func() map[uint16]Type {
+		index := make(map[uint16]Type, len(types))
+		for _, t := range types {
+			index[t.Code] = t
+		}
+		return index
+	}()
+)
+
+// All returns all registered types ordered by code.
+func All() []Type {
+	return slices.Clone(types)
+}
+
+// Names returns the mnemonics of all registered types ordered by code.
+func Names() []string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// ZoneNames returns the mnemonics of the types that can appear in zone files,
+// i.e. all registered types but the meta types, ordered by code.
+func ZoneNames() []string {
+	names := []string{}
+	for _, t := range types {
+		if t.Shape != ShapeMeta {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+// Lookup returns the type with the mnemonic name, which may be in any case
+// or in the generic "TYPEnnn" form of RFC 3597. Generic forms of codes that
+// aren't registered yield an opaque type served by CoreDNS only.
+func Lookup(name string) (Type, bool) {
+	if t, ok := byName[strings.ToUpper(name)]; ok {
+		return t, true
+	}
+	code, ok := ast.ParseGenericType(name)
+	if !ok {
+		return Type{}, false
+	}
+	return ByCode(code), true
+}
+
+// ByCode returns the type with code. Codes that aren't registered yield an
+// opaque type named in the generic "TYPEnnn" form, served by CoreDNS only.
+func ByCode(code uint16) Type {
+	if t, ok := byCode[code]; ok {
+		return t
+	}
+	return Type{
+		Name:     ast.GenericType(code),
+		Code:     code,
+		Shape:    ShapeOpaque,
+		Backends: coreDNSOnly,
+	}
+}
+
+// Supported returns the mnemonics of the types that all of backends can
+// serve, ordered by code.
+func Supported(backends ...Backend) []string {
+	names := []string{}
+	for _, t := range types {
+		supported := len(t.Backends) > 0
+		for _, backend := range backends {
+			supported = supported && t.SupportedBy(backend)
+		}
+		if supported {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+// Check looks up the type name and checks that all of backends can serve it.
+func Check(name string, backends ...Backend) (Type, error) {
+	t, ok := Lookup(name)
+	if !ok {
+		return t, fmt.Errorf("%w: %s", ErrUnknownType, name)
+	}
+	for _, backend := range backends {
+		if !t.SupportedBy(backend) {
+			return t, fmt.Errorf("%w: %s records can't be served by %s", ErrUnsupportedType, t.Name, backend)
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,164 @@
+package rrtype_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/rrtype"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	names := map[string]bool{}
+	previous := -1
+	for _, typ := range rrtype.All() {
+		assert.False(t, names[typ.Name], "%s is registered twice", typ.Name)
+		names[typ.Name] = true
+		assert.Greater(t, int(typ.Code), previous, "%s is out of order", typ.Name)
+		previous = int(typ.Code)
+
+		assert.Equal(t, typ.Shape == rrtype.ShapeTyped, ast.HasTypedRData(typ.Name), "shape of %s", typ.Name)
+		if typ.Shape == rrtype.ShapeMeta {
+			assert.Empty(t, typ.Backends, "%s can't be served", typ.Name)
+		}
+	}
+}
+
+func TestZoneNames(t *testing.T) {
+	t.Parallel()
+
+	names := rrtype.ZoneNames()
+	assert.Contains(t, names, "A")
+	assert.Contains(t, names, "SOA")
+	assert.Contains(t, names, "DLV")
+	for _, meta := range []string{"OPT", "NXNAME", "TKEY", "TSIG", "IXFR", "AXFR", "MAILB", "MAILA", "ANY"} {
+		assert.NotContains(t, names, meta)
+	}
+	assert.Len(t, names, len(rrtype.Names())-9)
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		name     string
+		expected string
+		code     uint16
+		ok       bool
+	}{
+		"mnemonic": {
+			name:     "HTTPS",
+			expected: "HTTPS",
+			code:     65,
+			ok:       true,
+		},
+		"lowercase": {
+			name:     "svcb",
+			expected: "SVCB",
+			code:     64,
+			ok:       true,
+		},
+		"generic form of a registered type": {
+			name:     "TYPE257",
+			expected: "CAA",
+			code:     257,
+			ok:       true,
+		},
+		"generic form of an unregistered type": {
+			name:     "TYPE731",
+			expected: "TYPE731",
+			code:     731,
+			ok:       true,
+		},
+		"unknown": {
+			name: "BOGUS",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			typ, ok := rrtype.Lookup(tc.name)
+			require.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, typ.Name)
+			assert.Equal(t, tc.code, typ.Code)
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		name     string
+		backends []rrtype.Backend
+		expected error
+	}{
+		"supported everywhere": {
+			name:     "HTTPS",
+			backends: []rrtype.Backend{rrtype.BackendCoreDNS, rrtype.BackendRoute53},
+		},
+		"CoreDNS only": {
+			name:     "HINFO",
+			backends: []rrtype.Backend{rrtype.BackendCoreDNS},
+		},
+		"not on Route53": {
+			name:     "HINFO",
+			backends: []rrtype.Backend{rrtype.BackendCoreDNS, rrtype.BackendRoute53},
+			expected: rrtype.ErrUnsupportedType,
+		},
+		"meta type": {
+			name:     "AXFR",
+			backends: []rrtype.Backend{rrtype.BackendCoreDNS},
+			expected: rrtype.ErrUnsupportedType,
+		},
+		"unknown": {
+			name:     "BOGUS",
+			expected: rrtype.ErrUnknownType,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := rrtype.Check(tc.name, tc.backends...)
+			if tc.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestSupported(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{
+		"A",
+		"NS",
+		"CNAME",
+		"SOA",
+		"PTR",
+		"MX",
+		"TXT",
+		"AAAA",
+		"SRV",
+		"NAPTR",
+		"DS",
+		"SSHFP",
+		"TLSA",
+		"SVCB",
+		"HTTPS",
+		"SPF",
+		"CAA",
+	}, rrtype.Supported(rrtype.BackendCoreDNS, rrtype.BackendRoute53))
+}
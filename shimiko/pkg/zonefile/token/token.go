@@ -4,13 +4,44 @@ import "fmt"
 
 type TokenType string
 
+// Position is the location of a token's literal in the source. Line and
+// Column are 1-based; Column counts bytes. The zero value means the position
+// is unknown, e.g. for synthesized tokens.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position is known.
+func (pos Position) IsValid() bool {
+	return pos.Line > 0
+}
+
+func (pos Position) String() string {
+	if !pos.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
 type Token struct {
 	Type             TokenType
 	Literal          []byte
 	WhiteSpaceBefore []byte
+	Position         Position
 }
 
 func (token Token) String() string {
+	if token.Position.IsValid() {
+		return fmt.Sprintf(
+			`Token{Type:%s, Literal:"%s", WhiteSpaceBefore:"%s", Position:%s}`,
+			token.Type,
+			string(token.Literal),
+			string(token.WhiteSpaceBefore),
+			token.Position,
+		)
+	}
 	return fmt.Sprintf(
 		`Token{Type:%s, Literal:"%s", WhiteSpaceBefore:"%s"}`,
 		token.Type,
@@ -33,6 +64,8 @@ const (
 	RDATA         TokenType = "RDATA"
 	RDATA_OPAREN  TokenType = "RDATA_OPAREN"
 	RDATA_CPAREN  TokenType = "RDATA_CPAREN"
+	// RANGE is the start-stop[/step] range of a $GENERATE control entry.
+	RANGE TokenType = "RANGE"
 )
 
 func RenderTokens(toks []Token) []byte {
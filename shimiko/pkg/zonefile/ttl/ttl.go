@@ -0,0 +1,123 @@
+// Package ttl implements the TTL syntax of BIND zone files: either a plain
+// number of seconds or one or more numbers each followed by a unit, e.g.
+// "86400", "1d", "1W2D" or "2h30m". Units are case-insensitive.
+package ttl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidTTL = errors.New("invalid TTL")
+
+// units in the order Format uses them.
+var units = []struct {
+	suffix   byte
+	duration time.Duration
+}{
+	{'w', 7 * 24 * time.Hour},
+	{'d', 24 * time.Hour},
+	{'h', time.Hour},
+	{'m', time.Minute},
+	{'s', time.Second},
+}
+
+func unitDuration(ch byte) (time.Duration, bool) {
+	if ch >= 'A' && ch <= 'Z' {
+		ch += 'a' - 'A'
+	}
+	for _, unit := range units {
+		if unit.suffix == ch {
+			return unit.duration, true
+		}
+	}
+	return 0, false
+}
+
+// Valid reports whether b is a syntactically valid TTL. It does not check the
+// range; use Parse for that.
+func Valid(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	digits := 0
+	hasUnit := false
+	for _, ch := range b {
+		if ch >= '0' && ch <= '9' {
+			digits++
+			continue
+		}
+		if _, ok := unitDuration(ch); !ok || digits == 0 {
+			return false
+		}
+		hasUnit = true
+		digits = 0
+	}
+	// a plain number is fine, but once units are used every number needs one
+	return !hasUnit || digits == 0
+}
+
+// Parse parses a TTL in BIND syntax. The result must fit in the 32 bits of
+// the TTL field on the wire.
+func Parse(s string) (time.Duration, error) {
+	if !Valid([]byte(s)) {
+		return 0, fmt.Errorf("%w: '%s'", ErrInvalidTTL, s)
+	}
+
+	var total uint64
+	start := 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			continue
+		}
+		if start == i {
+			break
+		}
+		n, err := strconv.ParseUint(s[start:i], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("%w: '%s': %w", ErrInvalidTTL, s, err)
+		}
+		seconds := uint64(1)
+		if i < len(s) {
+			unit, _ := unitDuration(s[i])
+			seconds = uint64(unit / time.Second)
+		}
+		total += n * seconds
+		if total > math.MaxUint32 {
+			return 0, fmt.Errorf("%w: '%s' is larger than %d seconds", ErrInvalidTTL, s, uint64(math.MaxUint32))
+		}
+		start = i + 1
+	}
+
+	return time.Duration(total) * time.Second, nil
+}
+
+// Format renders d in BIND syntax using the largest units that fit, e.g.
+// "1w2d" or "1h30m". d is rounded to whole seconds; zero is rendered as "0".
+func Format(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d <= 0 {
+		return "0"
+	}
+
+	var sb strings.Builder
+	for _, unit := range units {
+		if d < unit.duration {
+			continue
+		}
+		n := d / unit.duration
+		d -= n * unit.duration
+		sb.WriteString(strconv.FormatInt(int64(n), 10))
+		sb.WriteByte(unit.suffix)
+	}
+	return sb.String()
+}
+
+// FormatSeconds renders d as a plain number of seconds.
+func FormatSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d.Round(time.Second)/time.Second), 10)
+}
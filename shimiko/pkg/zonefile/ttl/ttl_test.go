@@ -0,0 +1,87 @@
+package ttl_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ttl"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input    string
+		expected time.Duration
+		err      error
+	}{
+		"seconds":            {input: "86400", expected: 24 * time.Hour},
+		"zero":               {input: "0", expected: 0},
+		"week":               {input: "1w", expected: 7 * 24 * time.Hour},
+		"upper case day":     {input: "1D", expected: 24 * time.Hour},
+		"upper case minutes": {input: "30M", expected: 30 * time.Minute},
+		"mixed units":        {input: "1w2d3h", expected: 9*24*time.Hour + 3*time.Hour},
+		"mixed case":         {input: "2H30m", expected: 2*time.Hour + 30*time.Minute},
+		"explicit seconds":   {input: "90s", expected: 90 * time.Second},
+		"max":                {input: "4294967295", expected: 4294967295 * time.Second},
+		"empty":              {input: "", err: ttl.ErrInvalidTTL},
+		"unit only":          {input: "h", err: ttl.ErrInvalidTTL},
+		"unknown unit":       {input: "1y", err: ttl.ErrInvalidTTL},
+		"trailing number":    {input: "1h30", err: ttl.ErrInvalidTTL},
+		"go duration":        {input: "1.5h", err: ttl.ErrInvalidTTL},
+		"negative":           {input: "-1", err: ttl.ErrInvalidTTL},
+		"too large":          {input: "4294967296", err: ttl.ErrInvalidTTL},
+		"too large in units": {input: "7102w", err: ttl.ErrInvalidTTL},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ttl.Parse(tc.input)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+			assert.True(t, ttl.Valid([]byte(tc.input)))
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input    time.Duration
+		expected string
+		seconds  string
+	}{
+		"zero":       {input: 0, expected: "0", seconds: "0"},
+		"seconds":    {input: 45 * time.Second, expected: "45s", seconds: "45"},
+		"hour":       {input: time.Hour, expected: "1h", seconds: "3600"},
+		"mixed":      {input: 9*24*time.Hour + 3*time.Hour, expected: "1w2d3h", seconds: "788400"},
+		"minutes":    {input: 2*time.Hour + 30*time.Minute, expected: "2h30m", seconds: "9000"},
+		"rounded":    {input: 1500 * time.Millisecond, expected: "2s", seconds: "2"},
+		"every unit": {input: 8*24*time.Hour + time.Hour + time.Minute + time.Second, expected: "1w1d1h1m1s", seconds: "694861"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := ttl.Format(tc.input)
+			assert.Equal(t, tc.expected, got)
+			assert.Equal(t, tc.seconds, ttl.FormatSeconds(tc.input))
+
+			parsed, err := ttl.Parse(got)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.input.Round(time.Second), parsed)
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package wire
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+)
+
+var ErrInvalidMessage = errors.New("invalid wire format")
+
+// rdataReader reads the fields of RDATA at off up to end in msg. The first
+// error is kept and makes all further reads return zero values.
+type rdataReader struct {
+	rrtype string
+	msg    []byte
+	off    int
+	end    int
+	err    error
+}
+
+func (r *rdataReader) fail(format string, args ...any) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: %s record: %s", ErrInvalidMessage, r.rrtype, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *rdataReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.off+n > r.end {
+		r.fail("RDATA is truncated")
+		return nil
+	}
+	b := r.msg[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *rdataReader) rest() []byte {
+	if r.err != nil {
+		return nil
+	}
+	return append([]byte{}, r.bytes(r.end-r.off)...)
+}
+
+func (r *rdataReader) uint8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *rdataReader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *rdataReader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *rdataReader) seconds() time.Duration {
+	return time.Duration(r.uint32()) * time.Second
+}
+
+func (r *rdataReader) name() string {
+	if r.err != nil {
+		return ""
+	}
+	name, next, err := DecodeName(r.msg, r.off)
+	if err != nil {
+		r.err = err
+		return ""
+	}
+	if next > r.end {
+		r.fail("name runs past the end of the RDATA")
+		return ""
+	}
+	r.off = next
+	return name
+}
+
+func (r *rdataReader) characterString() []byte {
+	return append([]byte{}, r.bytes(int(r.uint8()))...)
+}
+
+// DecodeRData decodes the RDATA of a record of type rrtype, which is length
+// octets at off in msg, into presentation form. msg is needed to follow
+// compression pointers; RDATA without compressed names can be passed on its
+// own with an off of 0. Types with a typed view are rendered through it, and
+// types the package doesn't know are rendered in the generic form of RFC
+// 3597.
+func DecodeRData(rrtype string, msg []byte, off int, length int) ([]ast.RData, error) {
+	rrtype = strings.ToUpper(rrtype)
+	if off+length > len(msg) {
+		return nil, fmt.Errorf("%w: %s record: RDATA is truncated", ErrInvalidMessage, rrtype)
+	}
+	r := &rdataReader{
+		rrtype: rrtype,
+		msg:    msg,
+		off:    off,
+		end:    off + length,
+	}
+
+	var rdata []ast.RData
+	switch rrtype {
+	case "A":
+		if addr, ok := netip.AddrFromSlice(r.bytes(4)); ok {
+			rdata = []ast.RData{{Value: addr.String()}}
+		}
+	case "AAAA":
+		if addr, ok := netip.AddrFromSlice(r.bytes(16)); ok {
+			rdata = []ast.RData{{Value: addr.String()}}
+		}
+	case "NS", "CNAME", "PTR", "DNAME":
+		rdata = []ast.RData{{Value: r.name()}}
+	case "TXT", "SPF":
+		txt := ast.TXT{}
+		for r.err == nil && r.off < r.end {
+			txt.Strings = append(txt.Strings, r.characterString())
+		}
+		if len(txt.Strings) == 0 {
+			r.fail("RDATA is empty")
+		}
+		rdata = txt.RData()
+	default:
+		typed, ok := r.typed()
+		if !ok {
+			return ast.GenericRData(r.rest()), nil
+		}
+		if typed != nil {
+			rdata = typed.RData()
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.off != r.end {
+		return nil, fmt.Errorf("%w: %s record: %d octets left over in RDATA", ErrInvalidMessage, rrtype, r.end-r.off)
+	}
+	return rdata, nil
+}
+
+// typed decodes the RDATA of the types with a typed view. It reports false
+// for other types.
+func (r *rdataReader) typed() (ast.TypedRData, bool) {
+	switch r.rrtype {
+	case "SOA":
+		return ast.SOA{
+			MName:   r.name(),
+			RName:   r.name(),
+			Serial:  r.uint32(),
+			Refresh: r.seconds(),
+			Retry:   r.seconds(),
+			Expire:  r.seconds(),
+			Minimum: r.seconds(),
+		}, true
+
+	case "MX":
+		return ast.MX{
+			Preference: r.uint16(),
+			Exchange:   r.name(),
+		}, true
+
+	case "SRV":
+		return ast.SRV{
+			Priority: r.uint16(),
+			Weight:   r.uint16(),
+			Port:     r.uint16(),
+			Target:   r.name(),
+		}, true
+
+	case "CAA":
+		return ast.CAA{
+			Flags: r.uint8(),
+			Tag:   string(r.characterString()),
+			Value: string(r.rest()),
+		}, true
+
+	case "TLSA":
+		return ast.TLSA{
+			Usage:        r.uint8(),
+			Selector:     r.uint8(),
+			MatchingType: r.uint8(),
+			Certificate:  r.rest(),
+		}, true
+
+	case "SSHFP":
+		return ast.SSHFP{
+			Algorithm:   r.uint8(),
+			Type:        r.uint8(),
+			Fingerprint: r.rest(),
+		}, true
+
+	case "DS":
+		return ast.DS{
+			KeyTag:     r.uint16(),
+			Algorithm:  r.uint8(),
+			DigestType: r.uint8(),
+			Digest:     r.rest(),
+		}, true
+
+	case "DNSKEY":
+		return ast.DNSKEY{
+			Flags:     r.uint16(),
+			Protocol:  r.uint8(),
+			Algorithm: r.uint8(),
+			PublicKey: r.rest(),
+		}, true
+
+	case "RRSIG":
+		return ast.RRSIG{
+			TypeCovered: TypeName(r.uint16()),
+			Algorithm:   r.uint8(),
+			Labels:      r.uint8(),
+			OriginalTTL: r.seconds(),
+			Expiration:  time.Unix(int64(r.uint32()), 0).UTC(),
+			Inception:   time.Unix(int64(r.uint32()), 0).UTC(),
+			KeyTag:      r.uint16(),
+			SignerName:  r.name(),
+			Signature:   r.rest(),
+		}, true
+
+	case "NSEC":
+		return ast.NSEC{
+			NextDomain: r.name(),
+			Types:      r.typeBitmap(),
+		}, true
+
+	case "NAPTR":
+		return ast.NAPTR{
+			Order:       r.uint16(),
+			Preference:  r.uint16(),
+			Flags:       string(r.characterString()),
+			Services:    string(r.characterString()),
+			Regexp:      string(r.characterString()),
+			Replacement: r.name(),
+		}, true
+
+	case "SVCB":
+		return r.svcb(), true
+
+	case "HTTPS":
+		return ast.HTTPS{SVCB: r.svcb()}, true
+	}
+	return nil, false
+}
+
+// typeBitmap reads the type bitmap of an NSEC record (RFC 4034 section
+// 4.1.2) up to the end of the RDATA.
+func (r *rdataReader) typeBitmap() []string {
+	types := []string{}
+	lastWindow := -1
+	for r.err == nil && r.off < r.end {
+		window := int(r.uint8())
+		length := int(r.uint8())
+		if window <= lastWindow || length == 0 || length > 32 {
+			r.fail("bad type bitmap window")
+			return nil
+		}
+		lastWindow = window
+		for i, octet := range r.bytes(length) {
+			for bit := 0; bit < 8; bit++ {
+				if octet&(0x80>>bit) != 0 {
+					types = append(types, TypeName(uint16(window<<8|i*8+bit)))
+				}
+			}
+		}
+	}
+	return types
+}
+
+// svcb reads the RDATA of an SVCB or HTTPS record (RFC 9460 section 2.2).
+func (r *rdataReader) svcb() ast.SVCB {
+	svcb := ast.SVCB{
+		Priority: r.uint16(),
+		Target:   r.name(),
+		Params:   []ast.SVCParam{},
+	}
+	for r.err == nil && r.off < r.end {
+		key := svcParamName(r.uint16())
+		value := r.bytes(int(r.uint16()))
+		if r.err != nil {
+			break
+		}
+		param, err := decodeSVCParam(key, value)
+		if err != nil {
+			r.fail("SvcParam '%s': %s", key, err)
+			break
+		}
+		svcb.Params = append(svcb.Params, param)
+	}
+	return svcb
+}
+
+func svcParamName(code uint16) string {
+	for name, c := range svcParamKeys {
+		if c == code {
+			return name
+		}
+	}
+	return "key" + strconv.FormatUint(uint64(code), 10)
+}
+
+// decodeSVCParam is the inverse of svcParamValue.
+func decodeSVCParam(key string, value []byte) (ast.SVCParam, error) {
+	param := ast.SVCParam{Key: key}
+	values := []string{}
+	switch key {
+	case "mandatory":
+		if len(value)%2 != 0 {
+			return param, errors.New("odd length")
+		}
+		for i := 0; i < len(value); i += 2 {
+			values = append(values, svcParamName(binary.BigEndian.Uint16(value[i:])))
+		}
+	case "alpn":
+		for len(value) > 0 {
+			n := int(value[0])
+			if n == 0 || 1+n > len(value) {
+				return param, errors.New("bad protocol id")
+			}
+			values = append(values, string(value[1:1+n]))
+			value = value[1+n:]
+		}
+	case "no-default-alpn", "ohttp":
+		if len(value) != 0 {
+			return param, errors.New("unexpected value")
+		}
+	case "port":
+		if len(value) != 2 {
+			return param, errors.New("bad length")
+		}
+		values = append(values, strconv.FormatUint(uint64(binary.BigEndian.Uint16(value)), 10))
+	case "ipv4hint", "ipv6hint":
+		size := 4
+		if key == "ipv6hint" {
+			size = 16
+		}
+		if len(value) == 0 || len(value)%size != 0 {
+			return param, errors.New("bad length")
+		}
+		for i := 0; i < len(value); i += size {
+			addr, _ := netip.AddrFromSlice(value[i : i+size])
+			values = append(values, addr.String())
+		}
+	case "ech":
+		values = append(values, base64.StdEncoding.EncodeToString(value))
+	default:
+		values = append(values, string(value))
+	}
+	param.Value = strings.Join(values, ",")
+	return param, nil
+}
@@ -0,0 +1,255 @@
+package wire_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/wire"
+)
+
+func rdataString(rdata []ast.RData) string {
+	values := []string{}
+	for _, field := range rdata {
+		values = append(values, field.Value)
+	}
+	return strings.Join(values, " ")
+}
+
+func TestDecodeRData(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		rrtype   string
+		rdata    string
+		expected string
+	}{
+		"A": {
+			rrtype: "A",
+			rdata:  "192.0.2.1",
+		},
+		"AAAA": {
+			rrtype: "AAAA",
+			rdata:  "2001:db8::1",
+		},
+		"relative CNAME": {
+			rrtype:   "CNAME",
+			rdata:    "www",
+			expected: "www.example.com.",
+		},
+		"MX": {
+			rrtype: "MX",
+			rdata:  "10 mail.example.com.",
+		},
+		"SOA": {
+			rrtype: "SOA",
+			rdata:  "ns1.example.com. hostmaster.example.com. 2024010101 7200 3600 1209600 3600",
+		},
+		"SRV": {
+			rrtype: "SRV",
+			rdata:  "10 5 5060 sip.example.com.",
+		},
+		"TXT": {
+			rrtype: "TXT",
+			rdata:  `"v=spf1 -all" "second \"string\"" "\010\255"`,
+		},
+		"SPF": {
+			rrtype: "SPF",
+			rdata:  `"v=spf1 -all"`,
+		},
+		"CAA": {
+			rrtype: "CAA",
+			rdata:  `0 issue "letsencrypt.org"`,
+		},
+		"TLSA": {
+			rrtype: "TLSA",
+			rdata:  "3 1 1 0C72AC70B745AC19998811B131D662C9AC69DBDBE7CB23E5B514B56664C5D3D6",
+		},
+		"DS": {
+			rrtype: "DS",
+			rdata:  "60485 5 1 2BB183AF5F22588179A53B0A98631FAD1A292118",
+		},
+		"DNSKEY": {
+			rrtype: "DNSKEY",
+			rdata:  "257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+KkxLbxILfDLUT0rAK9iUzy1L53eKGQ==",
+		},
+		"RRSIG": {
+			rrtype: "RRSIG",
+			rdata:  "A 13 3 3600 20240201000000 20240101000000 12345 example.com. c2lnbmF0dXJl",
+		},
+		"NSEC": {
+			rrtype: "NSEC",
+			rdata:  "host.example.com. A MX RRSIG NSEC CAA TYPE1234",
+		},
+		"NAPTR": {
+			rrtype: "NAPTR",
+			rdata:  `100 10 "S" "SIP+D2U" "" _sip._udp.example.com.`,
+		},
+		"HTTPS": {
+			rrtype: "HTTPS",
+			rdata:  `1 . alpn=h2,h3 port=8443 ipv4hint=192.0.2.1,192.0.2.2 ipv6hint=2001:db8::1`,
+		},
+		"SVCB params are sorted": {
+			rrtype:   "SVCB",
+			rdata:    `1 svc.example.com. port=53 mandatory=port,alpn alpn=dot`,
+			expected: `1 svc.example.com. mandatory=alpn,port alpn=dot port=53`,
+		},
+		"escaped name": {
+			rrtype: "PTR",
+			rdata:  `a\.b\032c.example.com.`,
+		},
+		"unknown type": {
+			rrtype: "TYPE731",
+			rdata:  `\# 4 0A000001`,
+		},
+		"known type without a typed view": {
+			rrtype:   "HINFO",
+			rdata:    `\# 4 01410142`,
+			expected: `\# 4 01410142`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			data := packRData(t, "$ORIGIN example.com.\n@ 3600 IN "+tc.rrtype+" "+tc.rdata)
+			rdata, err := wire.DecodeRData(tc.rrtype, data, 0, len(data))
+			require.NoError(t, err)
+			expected := tc.expected
+			if expected == "" {
+				expected = tc.rdata
+			}
+			assert.Equal(t, expected, rdataString(rdata))
+
+			encoded, err := wire.EncodeRData(tc.rrtype, rdata, "")
+			require.NoError(t, err)
+			assert.Equal(t, data, encoded)
+		})
+	}
+}
+
+func TestDecodeRDataError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		rrtype string
+		data   []byte
+	}{
+		"short A": {
+			rrtype: "A",
+			data:   []byte{192, 0, 2},
+		},
+		"long A": {
+			rrtype: "A",
+			data:   []byte{192, 0, 2, 1, 0},
+		},
+		"truncated MX": {
+			rrtype: "MX",
+			data:   []byte{0, 10, 4, 'm', 'a'},
+		},
+		"empty TXT": {
+			rrtype: "TXT",
+			data:   []byte{},
+		},
+		"truncated character-string": {
+			rrtype: "TXT",
+			data:   []byte{5, 'a', 'b'},
+		},
+		"bad port SvcParam": {
+			rrtype: "SVCB",
+			data:   []byte{0, 1, 0, 0, 3, 0, 1, 53},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := wire.DecodeRData(tc.rrtype, tc.data, 0, len(tc.data))
+			assert.ErrorIs(t, err, wire.ErrInvalidMessage)
+		})
+	}
+}
+
+func TestDecodeName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		msg      []byte
+		off      int
+		expected string
+		next     int
+		err      error
+	}{
+		"root": {
+			msg:      []byte{0},
+			expected: ".",
+			next:     1,
+		},
+		"name": {
+			msg:      []byte("\x03www\x07Example\x03com\x00"),
+			expected: "www.Example.com.",
+			next:     17,
+		},
+		"pointer": {
+			msg:      []byte("\x07example\x03com\x00\x03www\xc0\x00"),
+			off:      13,
+			expected: "www.example.com.",
+			next:     19,
+		},
+		"escapes": {
+			msg:      []byte("\x03a.b\x02\x00(\x00"),
+			expected: `a\.b.\000\(.`,
+			next:     8,
+		},
+		"truncated": {
+			msg: []byte("\x03ww"),
+			err: wire.ErrInvalidMessage,
+		},
+		"pointer to itself": {
+			msg: []byte("\xc0\x00"),
+			err: wire.ErrInvalidMessage,
+		},
+		"forward pointer": {
+			msg: []byte("\xc0\x02\x00"),
+			err: wire.ErrInvalidMessage,
+		},
+		"pointer loop": {
+			msg: []byte("\x01a\xc0\x00"),
+			off: 2,
+			err: wire.ErrInvalidName,
+		},
+		"reserved label type": {
+			msg: []byte("\x40"),
+			err: wire.ErrInvalidMessage,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, next, err := wire.DecodeName(tc.msg, tc.off)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+			assert.Equal(t, tc.next, next)
+
+			encoded, err := wire.EncodeName(got)
+			require.NoError(t, err)
+			decoded, _, err := wire.DecodeName(encoded, 0)
+			require.NoError(t, err)
+			assert.Equal(t, got, decoded)
+		})
+	}
+}
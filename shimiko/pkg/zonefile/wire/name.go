@@ -0,0 +1,214 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidName = errors.New("invalid domain name")
+
+const (
+	// MaxPointerOffset is the largest offset a compression pointer can
+	// point to (RFC 1035 section 4.1.4).
+	MaxPointerOffset = 0x3fff
+	// MaxLabelLength is the maximum length of a single label (RFC 1035
+	// section 2.3.4).
+	MaxLabelLength = 63
+	// MaxNameLength is the maximum length of a name on the wire, including
+	// the length octets and the root label (RFC 1035 section 2.3.4).
+	MaxNameLength = 255
+)
+
+// Labels splits an absolute name in presentation form into its labels with
+// escapes decoded. The root label is left out, so the root name has no labels.
+func Labels(name string) ([][]byte, error) {
+	if name == "." {
+		return [][]byte{}, nil
+	}
+	if len(name) == 0 || name[len(name)-1] != '.' {
+		return nil, fmt.Errorf("%w: '%s' is not absolute", ErrInvalidName, name)
+	}
+
+	labels := [][]byte{}
+	label := []byte{}
+	length := 1
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		switch {
+		case ch == '.':
+			if len(label) == 0 {
+				return nil, fmt.Errorf("%w: '%s' has an empty label", ErrInvalidName, name)
+			}
+			if len(label) > MaxLabelLength {
+				return nil, fmt.Errorf("%w: label '%s' in '%s' is longer than %d octets", ErrInvalidName, label, name, MaxLabelLength)
+			}
+			labels = append(labels, label)
+			length += len(label) + 1
+			label = []byte{}
+
+		case ch != '\\':
+			label = append(label, ch)
+
+		case i+1 >= len(name):
+			return nil, fmt.Errorf("%w: '%s' ends with a backslash", ErrInvalidName, name)
+
+		case isDigit(name[i+1]):
+			if i+3 >= len(name) || !isDigit(name[i+2]) || !isDigit(name[i+3]) {
+				return nil, fmt.Errorf("%w: bad escape in '%s'", ErrInvalidName, name)
+			}
+			decimal := int(name[i+1]-'0')*100 + int(name[i+2]-'0')*10 + int(name[i+3]-'0')
+			if decimal > 255 {
+				return nil, fmt.Errorf("%w: bad escape in '%s'", ErrInvalidName, name)
+			}
+			label = append(label, byte(decimal))
+			i += 3
+
+		default:
+			label = append(label, name[i+1])
+			i++
+		}
+	}
+
+	if length > MaxNameLength {
+		return nil, fmt.Errorf("%w: '%s' is longer than %d octets", ErrInvalidName, name, MaxNameLength)
+	}
+	return labels, nil
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// EncodeName returns the uncompressed wire form of an absolute name.
+func EncodeName(name string) ([]byte, error) {
+	return appendName(nil, name, false)
+}
+
+// CanonicalName returns the canonical wire form of an absolute name, which is
+// the uncompressed wire form with uppercase ASCII letters lowercased (RFC 4034
+// section 6.2).
+func CanonicalName(name string) ([]byte, error) {
+	return appendName(nil, name, true)
+}
+
+func appendName(b []byte, name string, lower bool) ([]byte, error) {
+	labels, err := Labels(name)
+	if err != nil {
+		return b, err
+	}
+	for _, label := range labels {
+		b = append(b, byte(len(label)))
+		for _, ch := range label {
+			if lower && ch >= 'A' && ch <= 'Z' {
+				ch += 'a' - 'A'
+			}
+			b = append(b, ch)
+		}
+	}
+	return append(b, 0), nil
+}
+
+// appendCompressedName appends the wire form of an absolute name to msg,
+// replacing its longest suffix that is in names with a pointer to it (RFC
+// 1035 section 4.1.4). The offsets of the suffixes that weren't in names yet
+// are added to it. names is keyed by the wire form of the suffixes; suffixes
+// only differing in case are not compressed, so that the case of names is
+// preserved (RFC 4343 section 4.1).
+func appendCompressedName(msg []byte, name string, names map[string]int) ([]byte, error) {
+	labels, err := Labels(name)
+	if err != nil {
+		return msg, err
+	}
+	for i, label := range labels {
+		key := suffixKey(labels[i:])
+		if off, ok := names[key]; ok {
+			return binary.BigEndian.AppendUint16(msg, 0xc000|uint16(off)), nil
+		}
+		if len(msg) <= MaxPointerOffset {
+			names[key] = len(msg)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	return append(msg, 0), nil
+}
+
+func suffixKey(labels [][]byte) string {
+	var sb strings.Builder
+	for _, label := range labels {
+		sb.WriteByte(byte(len(label)))
+		sb.Write(label)
+	}
+	return sb.String()
+}
+
+// DecodeName reads the possibly compressed name at off in msg and returns it
+// in presentation form along with the offset of the data following it.
+func DecodeName(msg []byte, off int) (string, int, error) {
+	var sb strings.Builder
+	next := -1
+	length := 1
+	for {
+		if off >= len(msg) {
+			return "", 0, fmt.Errorf("%w: name at offset %d is truncated", ErrInvalidMessage, off)
+		}
+		n := int(msg[off])
+		switch n & 0xc0 {
+		case 0x00:
+			if n == 0 {
+				if next < 0 {
+					next = off + 1
+				}
+				if sb.Len() == 0 {
+					return ".", next, nil
+				}
+				return sb.String(), next, nil
+			}
+			if off+1+n > len(msg) {
+				return "", 0, fmt.Errorf("%w: name at offset %d is truncated", ErrInvalidMessage, off)
+			}
+			length += n + 1
+			if length > MaxNameLength {
+				return "", 0, fmt.Errorf("%w: name at offset %d is longer than %d octets", ErrInvalidName, off, MaxNameLength)
+			}
+			writeLabel(&sb, msg[off+1:off+1+n])
+			sb.WriteByte('.')
+			off += n + 1
+
+		case 0xc0:
+			if off+2 > len(msg) {
+				return "", 0, fmt.Errorf("%w: name at offset %d is truncated", ErrInvalidMessage, off)
+			}
+			pointer := int(binary.BigEndian.Uint16(msg[off:]) & MaxPointerOffset)
+			// only following pointers backwards rules out loops
+			if pointer >= off {
+				return "", 0, fmt.Errorf("%w: compression pointer at offset %d doesn't point backwards", ErrInvalidMessage, off)
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = pointer
+
+		default:
+			return "", 0, fmt.Errorf("%w: unknown label type at offset %d", ErrInvalidMessage, off)
+		}
+	}
+}
+
+// writeLabel writes label in presentation form, escaping the characters that
+// have a special meaning in zone files and those that aren't printable.
+func writeLabel(sb *strings.Builder, label []byte) {
+	for _, ch := range label {
+		switch {
+		case ch < 0x21 || ch > 0x7e:
+			fmt.Fprintf(sb, "\\%03d", ch)
+		case strings.IndexByte(`."\();@$`, ch) >= 0:
+			sb.WriteByte('\\')
+			sb.WriteByte(ch)
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package wire
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/rrtype"
+)
+
+var ErrUnsupportedType = errors.New("unsupported type for wire format")
+
+// encoder appends RDATA in wire form. Relative names are made absolute with
+// origin. In canonical mode the names of the types listed in RFC 4034 section
+// 6.2, as amended by RFC 6840 section 5.1, are lowercased.
+//
+// If names is set, names in the RDATA of the types the registry marks as
+// compressible are compressed against the names recorded in it, and offsets
+// are relative to the start of the buffer RDATA is appended to.
+type encoder struct {
+	origin    string
+	canonical bool
+	names     map[string]int
+	// compress is set while encoding the RDATA of a type whose names may be
+	// compressed.
+	compress bool
+}
+
+// EncodeRData returns the wire form of the RDATA of a record of type rrType.
+// Relative names in rdata are relative to origin. RDATA in the generic form
+// of RFC 3597 is supported for any type.
+func EncodeRData(rrType string, rdata []ast.RData, origin string) ([]byte, error) {
+	return encoder{origin: origin}.rdata(nil, rrType, rdata)
+}
+
+// CanonicalRData is like EncodeRData but returns the canonical form used for
+// DNSSEC signatures (RFC 4034 section 6.2).
+func CanonicalRData(rrType string, rdata []ast.RData, origin string) ([]byte, error) {
+	return encoder{origin: origin, canonical: true}.rdata(nil, rrType, rdata)
+}
+
+// EncodeTypedRData returns the wire form of typed RDATA.
+func EncodeTypedRData(typed ast.TypedRData, origin string) ([]byte, error) {
+	return encoder{origin: origin}.typed(nil, typed)
+}
+
+// CanonicalTypedRData is like EncodeTypedRData but returns the canonical
+// form used for DNSSEC signatures (RFC 4034 section 6.2).
+func CanonicalTypedRData(typed ast.TypedRData, origin string) ([]byte, error) {
+	return encoder{origin: origin, canonical: true}.typed(nil, typed)
+}
+
+// rdata appends the RDATA to b.
+func (enc encoder) rdata(b []byte, rrType string, rdata []ast.RData) ([]byte, error) {
+	rrType = strings.ToUpper(rrType)
+	if t, ok := rrtype.Lookup(rrType); ok {
+		enc.compress = t.Compressible
+	}
+	if ast.IsGenericRData(rdata) {
+		data, err := ast.ParseGenericRData(rrType, rdata)
+		if err != nil {
+			return nil, err
+		}
+		return append(b, data...), nil
+	}
+
+	switch rrType {
+	case "A", "AAAA":
+		data, err := enc.address(rrType, rdata)
+		if err != nil {
+			return nil, err
+		}
+		return append(b, data...), nil
+	case "NS", "CNAME", "PTR", "DNAME":
+		if len(rdata) != 1 {
+			return nil, fmt.Errorf("%w: %s record needs 1 field, got %d", ast.ErrInvalidRData, rrType, len(rdata))
+		}
+		return enc.name(b, rdata[0].Value, enc.canonical)
+	case "SPF":
+		txt, err := ast.ParseTXT(rdata)
+		if err != nil {
+			return nil, err
+		}
+		return enc.typed(b, txt)
+	}
+
+	if !ast.HasTypedRData(rrType) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, rrType)
+	}
+	typed, err := ast.ParseTypedRData(rrType, rdata)
+	if err != nil {
+		return nil, err
+	}
+	return enc.typed(b, typed)
+}
+
+func (enc encoder) address(rrType string, rdata []ast.RData) ([]byte, error) {
+	if len(rdata) != 1 {
+		return nil, fmt.Errorf("%w: %s record needs 1 field, got %d", ast.ErrInvalidRData, rrType, len(rdata))
+	}
+	addr, err := netip.ParseAddr(rdata[0].Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s record: %w", ast.ErrInvalidRData, rrType, err)
+	}
+	if rrType == "A" {
+		if !addr.Is4() {
+			return nil, fmt.Errorf("%w: A record: '%s' is not an IPv4 address", ast.ErrInvalidRData, addr)
+		}
+		b := addr.As4()
+		return b[:], nil
+	}
+	if !addr.Is6() || addr.Zone() != "" {
+		return nil, fmt.Errorf("%w: AAAA record: '%s' is not an IPv6 address", ast.ErrInvalidRData, addr)
+	}
+	b := addr.As16()
+	return b[:], nil
+}
+
+// name appends the wire form of name, lowercased if lower is set. It is
+// compressed if the encoder compresses names and the type allows it.
+func (enc encoder) name(b []byte, name string, lower bool) ([]byte, error) {
+	absolute := ast.AbsoluteName(name, enc.origin)
+	if !ast.IsAbsoluteName(absolute) {
+		return b, fmt.Errorf("%w: '%s' is relative and there is no origin", ErrInvalidName, name)
+	}
+	if enc.names != nil && enc.compress && !enc.canonical {
+		return appendCompressedName(b, absolute, enc.names)
+	}
+	return appendName(b, absolute, lower)
+}
+
+func (enc encoder) typed(b []byte, typed ast.TypedRData) ([]byte, error) {
+	var err error
+	switch rdata := typed.(type) {
+	case ast.SOA:
+		if b, err = enc.name(b, rdata.MName, enc.canonical); err != nil {
+			return nil, err
+		}
+		if b, err = enc.name(b, rdata.RName, enc.canonical); err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint32(b, rdata.Serial)
+		b = binary.BigEndian.AppendUint32(b, uint32(rdata.Refresh/time.Second))
+		b = binary.BigEndian.AppendUint32(b, uint32(rdata.Retry/time.Second))
+		b = binary.BigEndian.AppendUint32(b, uint32(rdata.Expire/time.Second))
+		b = binary.BigEndian.AppendUint32(b, uint32(rdata.Minimum/time.Second))
+		return b, nil
+
+	case ast.MX:
+		b = binary.BigEndian.AppendUint16(b, rdata.Preference)
+		return enc.name(b, rdata.Exchange, enc.canonical)
+
+	case ast.SRV:
+		b = binary.BigEndian.AppendUint16(b, rdata.Priority)
+		b = binary.BigEndian.AppendUint16(b, rdata.Weight)
+		b = binary.BigEndian.AppendUint16(b, rdata.Port)
+		return enc.name(b, rdata.Target, enc.canonical)
+
+	case ast.TXT:
+		for _, data := range rdata.Strings {
+			// long strings are split the same way ast.TXT.RData does
+			for len(data) > ast.MaxCharacterStringLength {
+				b = appendCharacterString(b, data[:ast.MaxCharacterStringLength])
+				data = data[ast.MaxCharacterStringLength:]
+			}
+			b = appendCharacterString(b, data)
+		}
+		return b, nil
+
+	case ast.CAA:
+		b = append(b, rdata.Flags, byte(len(rdata.Tag)))
+		b = append(b, rdata.Tag...)
+		return append(b, rdata.Value...), nil
+
+	case ast.TLSA:
+		b = append(b, rdata.Usage, rdata.Selector, rdata.MatchingType)
+		return append(b, rdata.Certificate...), nil
+
+	case ast.SSHFP:
+		b = append(b, rdata.Algorithm, rdata.Type)
+		return append(b, rdata.Fingerprint...), nil
+
+	case ast.DS:
+		b = binary.BigEndian.AppendUint16(b, rdata.KeyTag)
+		b = append(b, rdata.Algorithm, rdata.DigestType)
+		return append(b, rdata.Digest...), nil
+
+	case ast.DNSKEY:
+		b = binary.BigEndian.AppendUint16(b, rdata.Flags)
+		b = append(b, rdata.Protocol, rdata.Algorithm)
+		return append(b, rdata.PublicKey...), nil
+
+	case ast.RRSIG:
+		code, ok := TypeCode(rdata.TypeCovered)
+		if !ok {
+			return nil, fmt.Errorf("%w: RRSIG covers unknown type %s", ErrUnsupportedType, rdata.TypeCovered)
+		}
+		b = binary.BigEndian.AppendUint16(b, code)
+		b = append(b, rdata.Algorithm, rdata.Labels)
+		b = binary.BigEndian.AppendUint32(b, uint32(rdata.OriginalTTL/time.Second))
+		b = binary.BigEndian.AppendUint32(b, uint32(rdata.Expiration.Unix()))
+		b = binary.BigEndian.AppendUint32(b, uint32(rdata.Inception.Unix()))
+		b = binary.BigEndian.AppendUint16(b, rdata.KeyTag)
+		if b, err = enc.name(b, rdata.SignerName, enc.canonical); err != nil {
+			return nil, err
+		}
+		return append(b, rdata.Signature...), nil
+
+	case ast.NSEC:
+		// the next domain name is not lowercased (RFC 6840 section 5.1)
+		if b, err = enc.name(b, rdata.NextDomain, false); err != nil {
+			return nil, err
+		}
+		return appendTypeBitmap(b, rdata.Types)
+
+	case ast.NAPTR:
+		b = binary.BigEndian.AppendUint16(b, rdata.Order)
+		b = binary.BigEndian.AppendUint16(b, rdata.Preference)
+		for _, s := range []string{rdata.Flags, rdata.Services, rdata.Regexp} {
+			if len(s) > ast.MaxCharacterStringLength {
+				return nil, fmt.Errorf("%w: NAPTR character-string is longer than %d octets", ast.ErrInvalidRData, ast.MaxCharacterStringLength)
+			}
+			b = appendCharacterString(b, []byte(s))
+		}
+		return enc.name(b, rdata.Replacement, enc.canonical)
+
+	case ast.SVCB:
+		return enc.svcb(b, "SVCB", rdata)
+
+	case ast.HTTPS:
+		return enc.svcb(b, "HTTPS", rdata.SVCB)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, typed.RRType())
+}
+
+func appendCharacterString(b []byte, s []byte) []byte {
+	b = append(b, byte(len(s)))
+	return append(b, s...)
+}
+
+// appendTypeBitmap appends the type bitmap of an NSEC record (RFC 4034
+// section 4.1.2).
+func appendTypeBitmap(b []byte, types []string) ([]byte, error) {
+	codes := make([]uint16, 0, len(types))
+	for _, rrType := range types {
+		code, ok := TypeCode(rrType)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s in type bitmap", ErrUnsupportedType, rrType)
+		}
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	codes = slices.Compact(codes)
+
+	for len(codes) > 0 {
+		window := codes[0] >> 8
+		bitmap := make([]byte, 32)
+		length := 0
+		for len(codes) > 0 && codes[0]>>8 == window {
+			low := codes[0] & 0xff
+			bitmap[low/8] |= 0x80 >> (low % 8)
+			length = int(low/8) + 1
+			codes = codes[1:]
+		}
+		b = append(b, byte(window), byte(length))
+		b = append(b, bitmap[:length]...)
+	}
+	return b, nil
+}
+
+var svcParamKeys = map[string]uint16{
+	"mandatory":       0,
+	"alpn":            1,
+	"no-default-alpn": 2,
+	"port":            3,
+	"ipv4hint":        4,
+	"ech":             5,
+	"ipv6hint":        6,
+	"dohpath":         7,
+	"ohttp":           8,
+}
+
+func svcParamKey(key string) (uint16, bool) {
+	if code, ok := svcParamKeys[key]; ok {
+		return code, true
+	}
+	if !strings.HasPrefix(key, "key") {
+		return 0, false
+	}
+	code, err := strconv.ParseUint(key[len("key"):], 10, 16)
+	return uint16(code), err == nil
+}
+
+// svcb appends the RDATA of an SVCB or HTTPS record (RFC 9460 section 2.2).
+// SvcParams are written in increasing order of their keys.
+func (enc encoder) svcb(b []byte, rrType string, svcb ast.SVCB) ([]byte, error) {
+	var err error
+	b = binary.BigEndian.AppendUint16(b, svcb.Priority)
+	// the target name is not lowercased (RFC 9460 section 2.2)
+	if b, err = enc.name(b, svcb.Target, false); err != nil {
+		return nil, err
+	}
+
+	type param struct {
+		key   uint16
+		value []byte
+	}
+	params := []param{}
+	for _, p := range svcb.Params {
+		key, ok := svcParamKey(p.Key)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s record has unknown SvcParamKey '%s'", ast.ErrInvalidRData, rrType, p.Key)
+		}
+		value, err := svcParamValue(p)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s record SvcParam '%s': %w", ast.ErrInvalidRData, rrType, p.Key, err)
+		}
+		params = append(params, param{key: key, value: value})
+	}
+	slices.SortFunc(params, func(a param, b param) int {
+		return int(a.key) - int(b.key)
+	})
+
+	for _, p := range params {
+		b = binary.BigEndian.AppendUint16(b, p.key)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(p.value)))
+		b = append(b, p.value...)
+	}
+	return b, nil
+}
+
+func svcParamValue(p ast.SVCParam) ([]byte, error) {
+	b := []byte{}
+	switch p.Key {
+	case "mandatory":
+		keys := []uint16{}
+		for _, name := range strings.Split(p.Value, ",") {
+			key, ok := svcParamKey(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown key '%s'", name)
+			}
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			b = binary.BigEndian.AppendUint16(b, key)
+		}
+	case "alpn":
+		for _, id := range strings.Split(p.Value, ",") {
+			if len(id) == 0 || len(id) > 255 {
+				return nil, fmt.Errorf("bad protocol id '%s'", id)
+			}
+			b = appendCharacterString(b, []byte(id))
+		}
+	case "no-default-alpn", "ohttp":
+	case "port":
+		port, err := strconv.ParseUint(p.Value, 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(port))
+	case "ipv4hint", "ipv6hint":
+		for _, hint := range strings.Split(p.Value, ",") {
+			addr, err := netip.ParseAddr(hint)
+			if err != nil {
+				return nil, err
+			}
+			if p.Key == "ipv4hint" != addr.Is4() {
+				return nil, fmt.Errorf("address family mismatch for %s", hint)
+			}
+			if addr.Is4() {
+				a := addr.As4()
+				b = append(b, a[:]...)
+			} else {
+				a := addr.As16()
+				b = append(b, a[:]...)
+			}
+		}
+	case "ech":
+		ech, err := base64.StdEncoding.DecodeString(p.Value)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, ech...)
+	default:
+		b = append(b, p.Value...)
+	}
+	return b, nil
+}
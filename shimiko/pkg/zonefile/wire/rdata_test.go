@@ -0,0 +1,270 @@
+package wire_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/wire"
+)
+
+// packRData returns the RDATA of the record in presentation form as packed by
+// miekg/dns.
+func packRData(t *testing.T, rr string) []byte {
+	t.Helper()
+	parsed, err := dns.NewRR(rr)
+	require.NoError(t, err)
+	msg := make([]byte, dns.Len(parsed))
+	off, err := dns.PackRR(parsed, msg, 0, nil, false)
+	require.NoError(t, err)
+	return msg[off-int(parsed.Header().Rdlength) : off]
+}
+
+func TestEncodeRData(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		rrtype string
+		rdata  string
+	}{
+		"A": {
+			rrtype: "A",
+			rdata:  "192.0.2.1",
+		},
+		"AAAA": {
+			rrtype: "AAAA",
+			rdata:  "2001:db8::1",
+		},
+		"NS": {
+			rrtype: "NS",
+			rdata:  "ns1.example.com.",
+		},
+		"relative CNAME": {
+			rrtype: "CNAME",
+			rdata:  "www",
+		},
+		"MX": {
+			rrtype: "MX",
+			rdata:  "10 mail.example.com.",
+		},
+		"SOA": {
+			rrtype: "SOA",
+			rdata:  "ns1.example.com. hostmaster.example.com. 2024010101 7200 3600 1209600 3600",
+		},
+		"SRV": {
+			rrtype: "SRV",
+			rdata:  "10 5 5060 sip.example.com.",
+		},
+		"TXT": {
+			rrtype: "TXT",
+			rdata:  `"v=spf1 -all" "second \"string\"" "\010\255"`,
+		},
+		"long TXT": {
+			rrtype: "TXT",
+			rdata:  `"` + strings.Repeat("a", 300) + `"`,
+		},
+		"CAA": {
+			rrtype: "CAA",
+			rdata:  `0 issue "letsencrypt.org"`,
+		},
+		"TLSA": {
+			rrtype: "TLSA",
+			rdata:  "3 1 1 0C72AC70B745AC19998811B131D662C9AC69DBDBE7CB23E5B514B56664C5D3D6",
+		},
+		"SSHFP": {
+			rrtype: "SSHFP",
+			rdata:  "4 2 1E5E5D8A1EEBCF8E4E0C1A2C1F2FE59F1DA0D87D80B0D2EC7E5C2D1C4B8E0E3B",
+		},
+		"DS": {
+			rrtype: "DS",
+			rdata:  "60485 5 1 2BB183AF5F22588179A53B0A98631FAD1A292118",
+		},
+		"DNSKEY": {
+			rrtype: "DNSKEY",
+			rdata:  "257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+KkxLbxILfDLUT0rAK9iUzy1L53eKGQ==",
+		},
+		"RRSIG": {
+			rrtype: "RRSIG",
+			rdata:  "A 13 3 3600 20240201000000 20240101000000 12345 example.com. c2lnbmF0dXJl",
+		},
+		"NSEC": {
+			rrtype: "NSEC",
+			rdata:  "host.example.com. A MX RRSIG NSEC CAA TYPE1234",
+		},
+		"NAPTR": {
+			rrtype: "NAPTR",
+			rdata:  `100 10 "S" "SIP+D2U" "" _sip._udp.example.com.`,
+		},
+		"HTTPS": {
+			rrtype: "HTTPS",
+			rdata:  `1 . alpn=h2,h3 port=8443 ipv4hint=192.0.2.1,192.0.2.2 ipv6hint=2001:db8::1`,
+		},
+		"SVCB with mandatory": {
+			rrtype: "SVCB",
+			rdata:  `1 svc.example.com. port=53 mandatory=port,alpn alpn=dot`,
+		},
+		"generic": {
+			rrtype: "TYPE731",
+			rdata:  `\# 4 0A000001`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := wire.EncodeRData(tc.rrtype, ast.SplitRData(tc.rdata), "example.com.")
+			require.NoError(t, err)
+			assert.Equal(t, packRData(t, "$ORIGIN example.com.\n@ 3600 IN "+tc.rrtype+" "+tc.rdata), got)
+		})
+	}
+}
+
+func TestCanonicalRData(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		rrtype   string
+		rdata    string
+		expected string
+	}{
+		"names are lowercased": {
+			rrtype:   "MX",
+			rdata:    "10 Mail.Example.COM.",
+			expected: "10 mail.example.com.",
+		},
+		"relative names are lowercased": {
+			rrtype:   "CNAME",
+			rdata:    "WWW",
+			expected: "www.example.com.",
+		},
+		"NSEC next domain keeps its case": {
+			rrtype:   "NSEC",
+			rdata:    "Host.Example.com. A",
+			expected: "Host.Example.com. A",
+		},
+		"NSEC types are sorted": {
+			rrtype:   "NSEC",
+			rdata:    "host.example.com. NSEC MX A A",
+			expected: "host.example.com. A MX NSEC",
+		},
+		"SVCB target keeps its case": {
+			rrtype:   "SVCB",
+			rdata:    "0 Svc.Example.com.",
+			expected: "0 Svc.Example.com.",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := wire.CanonicalRData(tc.rrtype, ast.SplitRData(tc.rdata), "Example.com.")
+			require.NoError(t, err)
+			expected, err := wire.EncodeRData(tc.rrtype, ast.SplitRData(tc.expected), "example.com.")
+			require.NoError(t, err)
+			assert.Equal(t, expected, got)
+		})
+	}
+}
+
+func TestEncodeRDataError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		rrtype   string
+		rdata    string
+		origin   string
+		expected error
+	}{
+		"IPv6 address in A record": {
+			rrtype:   "A",
+			rdata:    "2001:db8::1",
+			origin:   "example.com.",
+			expected: ast.ErrInvalidRData,
+		},
+		"relative name without origin": {
+			rrtype:   "CNAME",
+			rdata:    "www",
+			expected: wire.ErrInvalidName,
+		},
+		"unsupported type": {
+			rrtype:   "HINFO",
+			rdata:    `"PC" "Linux"`,
+			origin:   "example.com.",
+			expected: wire.ErrUnsupportedType,
+		},
+		"bad generic RDATA": {
+			rrtype:   "TYPE731",
+			rdata:    `\# 2 0A000001`,
+			origin:   "example.com.",
+			expected: ast.ErrInvalidRData,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := wire.EncodeRData(tc.rrtype, ast.SplitRData(tc.rdata), tc.origin)
+			assert.ErrorIs(t, err, tc.expected)
+		})
+	}
+}
+
+func TestEncodeName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		name     string
+		expected []byte
+		err      error
+	}{
+		"root": {
+			name:     ".",
+			expected: []byte{0},
+		},
+		"name": {
+			name:     "www.Example.com.",
+			expected: []byte("\x03www\x07Example\x03com\x00"),
+		},
+		"escapes": {
+			name:     `a\.b.\065.`,
+			expected: []byte("\x03a.b\x01A\x00"),
+		},
+		"relative": {
+			name: "www.example.com",
+			err:  wire.ErrInvalidName,
+		},
+		"empty label": {
+			name: "www..com.",
+			err:  wire.ErrInvalidName,
+		},
+		"long label": {
+			name: strings.Repeat("a", 64) + ".",
+			err:  wire.ErrInvalidName,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := wire.EncodeName(tc.name)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
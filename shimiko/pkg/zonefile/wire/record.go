@@ -0,0 +1,223 @@
+package wire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+)
+
+// Encoder appends resource records in wire form (RFC 1035 section 4.1.3) to
+// a message. If compression is enabled, owner names and the names in the
+// RDATA of the types of RFC 1035 are compressed against the names written
+// before them.
+type Encoder struct {
+	msg    []byte
+	origin string
+	names  map[string]int
+}
+
+// NewEncoder returns an Encoder appending to msg, which holds the part of the
+// message before the records, e.g. its header, so that compression pointers
+// are relative to the start of the message. Relative names of entries that
+// weren't resolved are relative to origin.
+func NewEncoder(msg []byte, origin string, compress bool) *Encoder {
+	enc := &Encoder{
+		msg:    msg,
+		origin: origin,
+	}
+	if compress {
+		enc.names = map[string]int{}
+	}
+	return enc
+}
+
+// Bytes returns the message so far.
+func (enc *Encoder) Bytes() []byte {
+	return enc.msg
+}
+
+// Name appends a name, e.g. of a question, compressed if compression is
+// enabled.
+func (enc *Encoder) Name(name string) error {
+	msg, err := encoder{origin: enc.origin, names: enc.names, compress: true}.name(enc.msg, name, false)
+	if err != nil {
+		return err
+	}
+	enc.msg = msg
+	return nil
+}
+
+// Record appends entry. The values of entry.Resolved are used if it is set,
+// so entries should go through parser.Resolve first; otherwise a blank owner
+// is an error and a blank class is IN.
+func (enc *Encoder) Record(entry ast.RREntry) error {
+	owner := entry.DomainName
+	ttl := entry.RRecord.TTL
+	class := entry.RRecord.Class
+	origin := enc.origin
+	if entry.Resolved != nil {
+		owner = entry.Resolved.Owner
+		ttl = entry.Resolved.TTL
+		class = entry.Resolved.Class
+		if entry.Resolved.Origin != "" {
+			origin = entry.Resolved.Origin
+		}
+	}
+	if owner == "" {
+		return fmt.Errorf("%w: record has no owner", ErrInvalidName)
+	}
+	if class == "" {
+		class = "IN"
+	}
+	rrtype, ok := TypeCode(entry.RRecord.Type)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, entry.RRecord.Type)
+	}
+	classCode, ok := ClassCode(class)
+	if !ok {
+		return fmt.Errorf("%w: unknown class %s", ErrUnsupportedType, class)
+	}
+	if ttl < 0 || ttl/time.Second > math.MaxUint32 {
+		return fmt.Errorf("%w: TTL %s is out of range", ast.ErrInvalidRData, ttl)
+	}
+
+	start := len(enc.msg)
+	rdataStart := 0
+	msg, err := encoder{origin: enc.origin, names: enc.names, compress: true}.name(enc.msg, owner, false)
+	if err == nil {
+		msg = binary.BigEndian.AppendUint16(msg, rrtype)
+		msg = binary.BigEndian.AppendUint16(msg, classCode)
+		msg = binary.BigEndian.AppendUint32(msg, uint32(ttl/time.Second))
+		// RDLENGTH is filled in once the RDATA is written
+		msg = append(msg, 0, 0)
+		rdataStart = len(msg)
+		msg, err = encoder{origin: origin, names: enc.names}.rdata(msg, entry.RRecord.Type, entry.RRecord.RData)
+	}
+	if err == nil && len(msg)-rdataStart > math.MaxUint16 {
+		err = fmt.Errorf("%w: RDATA of %s %s is longer than %d octets", ast.ErrInvalidRData, owner, entry.RRecord.Type, math.MaxUint16)
+	}
+	if err != nil {
+		// forget the names of the record that was left out
+		for key, off := range enc.names {
+			if off >= start {
+				delete(enc.names, key)
+			}
+		}
+		return err
+	}
+
+	binary.BigEndian.PutUint16(msg[rdataStart-2:], uint16(len(msg)-rdataStart))
+	enc.msg = msg
+	return nil
+}
+
+// EncodeRecords returns the wire form of the records among entries, one after
+// the other as in a section of a message, with names compressed if compress
+// is set. See Encoder.Record.
+func EncodeRecords(entries []ast.Node, origin string, compress bool) ([]byte, error) {
+	enc := NewEncoder(nil, origin, compress)
+	for _, node := range entries {
+		if !node.IsRREntry() {
+			continue
+		}
+		err := enc.Record(node.RREntry())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return enc.Bytes(), nil
+}
+
+// Decoder reads resource records in wire form from a message.
+type Decoder struct {
+	msg []byte
+	off int
+}
+
+// NewDecoder returns a Decoder reading the message msg from off, e.g. the
+// start of its answer section.
+func NewDecoder(msg []byte, off int) *Decoder {
+	return &Decoder{
+		msg: msg,
+		off: off,
+	}
+}
+
+// Offset returns the offset of the next read.
+func (dec *Decoder) Offset() int {
+	return dec.off
+}
+
+// Done reports whether the whole message has been read.
+func (dec *Decoder) Done() bool {
+	return dec.off >= len(dec.msg)
+}
+
+// Name reads a name, e.g. of a question.
+func (dec *Decoder) Name() (string, error) {
+	name, next, err := DecodeName(dec.msg, dec.off)
+	if err != nil {
+		return "", err
+	}
+	dec.off = next
+	return name, nil
+}
+
+// Record reads a resource record. The entry has absolute names and its
+// Resolved field set, so it can be encoded again as-is.
+func (dec *Decoder) Record() (ast.Node, error) {
+	owner, next, err := DecodeName(dec.msg, dec.off)
+	if err != nil {
+		return ast.Node{}, err
+	}
+	if next+10 > len(dec.msg) {
+		return ast.Node{}, fmt.Errorf("%w: record of %s is truncated", ErrInvalidMessage, owner)
+	}
+	rrtype := TypeName(binary.BigEndian.Uint16(dec.msg[next:]))
+	class := ClassName(binary.BigEndian.Uint16(dec.msg[next+2:]))
+	ttl := time.Duration(binary.BigEndian.Uint32(dec.msg[next+4:])) * time.Second
+	length := int(binary.BigEndian.Uint16(dec.msg[next+8:]))
+	rdata, err := DecodeRData(rrtype, dec.msg, next+10, length)
+	if err != nil {
+		return ast.Node{}, fmt.Errorf("error decoding %s %s: %w", owner, rrtype, err)
+	}
+	dec.off = next + 10 + length
+
+	return ast.Node{
+		NodeType: ast.NodeTypeRREntry,
+		Entry: ast.RREntry{
+			DomainName: owner,
+			RRecord: ast.RRecord{
+				TTL:   ttl,
+				Class: class,
+				Type:  rrtype,
+				RData: rdata,
+			},
+			Resolved: &ast.ResolvedRR{
+				Owner:  owner,
+				TTL:    ttl,
+				Class:  class,
+				Origin: ".",
+			},
+		},
+	}, nil
+}
+
+// DecodeRecords reads records until the end of data, which holds records one
+// after the other as returned by EncodeRecords. Compression pointers must
+// point into data.
+func DecodeRecords(data []byte) ([]ast.Node, error) {
+	dec := NewDecoder(data, 0)
+	entries := []ast.Node{}
+	for !dec.Done() {
+		node, err := dec.Record()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, node)
+	}
+	return entries, nil
+}
@@ -0,0 +1,155 @@
+package wire_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/diff"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/wire"
+)
+
+const zone = `$ORIGIN example.com.
+$TTL 300
+@     IN SOA ns1 hostmaster 2024010101 7200 3600 1209600 300
+@     NS ns1
+ns1   A 192.0.2.1
+www   600 A 192.0.2.2
+      AAAA 2001:db8::2
+mail  MX 10 www
+ftp   CNAME WWW
+_sip._udp SRV 10 5 5060 www
+@     TXT "v=spf1 -all"
+@     CAA 0 issue "letsencrypt.org"
+www   SSHFP 4 2 1E5E5D8A1EEBCF8E4E0C1A2C1F2FE59F1DA0D87D80B0D2EC7E5C2D1C4B8E0E3B
+svc   HTTPS 1 . alpn=h2
+`
+
+func resolve(t *testing.T, input string) []ast.Node {
+	t.Helper()
+	entries, err := parser.ParseReader(strings.NewReader(input))
+	require.NoError(t, err)
+	resolved, err := parser.Resolve(entries, "example.com.")
+	require.NoError(t, err)
+	return resolved
+}
+
+func TestEncodeRecords(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		compress bool
+	}{
+		"uncompressed": {
+			compress: false,
+		},
+		"compressed": {
+			compress: true,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			entries := resolve(t, zone)
+			msg := &dns.Msg{Compress: tc.compress}
+			for _, node := range entries {
+				if !node.IsRREntry() {
+					continue
+				}
+				entry := node.RREntry()
+				rr, err := dns.NewRR("$ORIGIN example.com.\n" + strings.Join([]string{
+					entry.Resolved.Owner,
+					parser.DurationToSeconds(entry.Resolved.TTL),
+					entry.Resolved.Class,
+					entry.RRecord.Type,
+					rdataString(entry.RRecord.RData),
+				}, " "))
+				require.NoError(t, err)
+				msg.Answer = append(msg.Answer, rr)
+			}
+			packed, err := msg.Pack()
+			require.NoError(t, err)
+
+			// the records follow the 12 octet header, which the encoder has to
+			// know about for the compression pointers to match
+			enc := wire.NewEncoder(append([]byte{}, packed[:12]...), "example.com.", tc.compress)
+			for _, node := range entries {
+				if node.IsRREntry() {
+					require.NoError(t, enc.Record(node.RREntry()))
+				}
+			}
+			assert.Equal(t, packed, enc.Bytes())
+		})
+	}
+}
+
+func TestDecodeRecords(t *testing.T) {
+	t.Parallel()
+
+	entries := resolve(t, zone)
+	for _, compress := range []bool{false, true} {
+		data, err := wire.EncodeRecords(entries, "example.com.", compress)
+		require.NoError(t, err)
+		decoded, err := wire.DecodeRecords(data)
+		require.NoError(t, err)
+
+		equal, err := diff.Equal(entries, decoded, "example.com.", diff.Options{})
+		require.NoError(t, err)
+		assert.True(t, equal)
+
+		again, err := wire.EncodeRecords(decoded, "", compress)
+		require.NoError(t, err)
+		assert.Equal(t, data, again)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	t.Parallel()
+
+	msg := &dns.Msg{Compress: true}
+	msg.SetQuestion("www.example.com.", dns.TypeA)
+	rr, err := dns.NewRR("www.example.com. 60 IN A 192.0.2.1")
+	require.NoError(t, err)
+	msg.Answer = append(msg.Answer, rr)
+	packed, err := msg.Pack()
+	require.NoError(t, err)
+
+	dec := wire.NewDecoder(packed, 12)
+	question, err := dec.Name()
+	require.NoError(t, err)
+	assert.Equal(t, "www.example.com.", question)
+
+	dec = wire.NewDecoder(packed, dec.Offset()+4)
+	node, err := dec.Record()
+	require.NoError(t, err)
+	assert.True(t, dec.Done())
+	entry := node.RREntry()
+	assert.Equal(t, "www.example.com.", entry.DomainName)
+	assert.Equal(t, "A", entry.RRecord.Type)
+	assert.Equal(t, "IN", entry.RRecord.Class)
+	assert.Equal(t, "60", parser.DurationToSeconds(entry.RRecord.TTL))
+	assert.Equal(t, "192.0.2.1", rdataString(entry.RRecord.RData))
+}
+
+func TestEncoderSkipsFailedRecord(t *testing.T) {
+	t.Parallel()
+
+	entries := resolve(t, "www A 192.0.2.1\nbad.www A 2001:db8::1\nhost CNAME bad.www\n")
+	enc := wire.NewEncoder(nil, "example.com.", true)
+	require.NoError(t, enc.Record(entries[0].RREntry()))
+	assert.ErrorIs(t, enc.Record(entries[1].RREntry()), ast.ErrInvalidRData)
+	require.NoError(t, enc.Record(entries[2].RREntry()))
+
+	decoded, err := wire.DecodeRecords(enc.Bytes())
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+	assert.Equal(t, "bad.www.example.com.", rdataString(decoded[1].RREntry().RRecord.RData))
+}
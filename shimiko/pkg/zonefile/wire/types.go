@@ -0,0 +1,57 @@
+package wire
+
+import (
+	"strings"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/rrtype"
+)
+
+var classCodes = map[string]uint16{
+	"IN":   1,
+	"CS":   2,
+	"CH":   3,
+	"HS":   4,
+	"NONE": 254,
+	"ANY":  255,
+}
+
+var classNames = func() map[uint16]string {
+	names := make(map[uint16]string, len(classCodes))
+	for name, code := range classCodes {
+		names[code] = name
+	}
+	return names
+}()
+
+// TypeCode returns the numeric value of a type mnemonic, which may also be in
+// the generic "TYPEnnn" form of RFC 3597.
+func TypeCode(name string) (uint16, bool) {
+	t, ok := rrtype.Lookup(name)
+	return t.Code, ok
+}
+
+// TypeName returns the mnemonic of a type, or its generic "TYPEnnn" form if
+// it doesn't have one.
+func TypeName(code uint16) string {
+	return rrtype.ByCode(code).Name
+}
+
+// ClassCode returns the numeric value of a class mnemonic, which may also be
+// in the generic "CLASSnnn" form of RFC 3597.
+func ClassCode(class string) (uint16, bool) {
+	class = strings.ToUpper(class)
+	if code, ok := classCodes[class]; ok {
+		return code, true
+	}
+	return ast.ParseGenericClass(class)
+}
+
+// ClassName returns the mnemonic of a class, or its generic "CLASSnnn" form if
+// it doesn't have one.
+func ClassName(code uint16) string {
+	if name, ok := classNames[code]; ok {
+		return name
+	}
+	return ast.GenericClass(code)
+}
@@ -0,0 +1,265 @@
+// Package zone indexes the records of a zone file by RRset so that single
+// RRsets can be looked up, replaced and removed without scanning the whole
+// file, while the order of the entries and their comments are kept.
+package zone
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+)
+
+// Key identifies an RRset. Owner is the lowercased absolute owner name and
+// Type is uppercased.
+type Key struct {
+	Owner string
+	Type  string
+}
+
+func (key Key) String() string {
+	return key.Owner + " " + key.Type
+}
+
+// slot is the place of an entry of the zone file. Slots of RRsets can hold
+// any number of records, so that an RRset can grow in place; a slot without
+// nodes is an entry that was removed.
+type slot struct {
+	nodes []ast.Node
+	// key is the RRset the records in nodes belong to. It is zero for slots
+	// holding anything else.
+	key Key
+	// owner is the absolute owner name of the records in nodes as written.
+	owner string
+	// origin is the $ORIGIN in effect at the slot.
+	origin string
+}
+
+// Zone is a zone file indexed by RRset. The zero value is not usable; use
+// New.
+type Zone struct {
+	origin string
+	slots  []*slot
+	index  map[Key][]*slot
+	// endOrigin is the $ORIGIN in effect at the end of the zone file, where
+	// new RRsets are added.
+	endOrigin string
+}
+
+// New indexes entries. origin is the origin in effect before the first
+// entry, as for parser.Resolve, and the origin relative names passed to the
+// methods of Zone are resolved against. $INCLUDE entries are not followed and
+// $GENERATE entries are not expanded, so their records aren't indexed.
+func New(entries []ast.Node, origin string) (*Zone, error) {
+	resolved, err := parser.Resolve(entries, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	zone := &Zone{
+		origin:    origin,
+		slots:     make([]*slot, 0, len(entries)),
+		index:     map[Key][]*slot{},
+		endOrigin: origin,
+	}
+	for i, node := range entries {
+		s := &slot{
+			nodes:  []ast.Node{node},
+			origin: zone.endOrigin,
+		}
+		switch {
+		case node.IsOriginControlEntry():
+			zone.endOrigin = ast.AbsoluteName(node.OriginControlEntry().DomainName, zone.endOrigin)
+		case node.IsRREntry():
+			info := resolved[i].RREntry().Resolved
+			s.key = Key{
+				Owner: strings.ToLower(info.Owner),
+				Type:  strings.ToUpper(node.RREntry().RRecord.Type),
+			}
+			s.owner = info.Owner
+			s.origin = info.Origin
+			zone.index[s.key] = append(zone.index[s.key], s)
+		}
+		zone.slots = append(zone.slots, s)
+	}
+	return zone, nil
+}
+
+// Origin returns the origin the zone was created with.
+func (zone *Zone) Origin() string {
+	return zone.origin
+}
+
+func (zone *Zone) key(name string, rrtype string) Key {
+	return Key{
+		Owner: strings.ToLower(ast.AbsoluteName(name, zone.origin)),
+		Type:  strings.ToUpper(rrtype),
+	}
+}
+
+// Get returns the records of the RRset of name and rrtype in the order they
+// appear in the zone file, or nil if there are none. Relative names are
+// resolved against the origin of the zone.
+func (zone *Zone) Get(name string, rrtype string) []ast.RRecord {
+	var records []ast.RRecord
+	for _, s := range zone.index[zone.key(name, rrtype)] {
+		for _, node := range s.nodes {
+			records = append(records, node.RREntry().RRecord)
+		}
+	}
+	return records
+}
+
+// Has reports whether the zone has an RRset for name and rrtype.
+func (zone *Zone) Has(name string, rrtype string) bool {
+	return len(zone.index[zone.key(name, rrtype)]) > 0
+}
+
+// OriginAt returns the $ORIGIN in effect where the RRset of name and rrtype
+// is, or where Set would add it if there is no such RRset yet. Relative names
+// in the RDATA of records passed to Set are relative to it.
+func (zone *Zone) OriginAt(name string, rrtype string) string {
+	existing := zone.index[zone.key(name, rrtype)]
+	if len(existing) > 0 {
+		return existing[0].origin
+	}
+	return zone.endOrigin
+}
+
+// Set replaces the RRset of name and rrtype with records, whose types must be
+// rrtype. The records take the place of the first record of the RRset in the
+// zone file, or are added at the end if there is no such RRset yet. Records
+// whose RDATA was in the RRset already keep their entries, including their
+// comments and formatting unless the record itself changed. An empty records
+// removes the RRset.
+func (zone *Zone) Set(name string, rrtype string, records []ast.RRecord) error {
+	key := zone.key(name, rrtype)
+	for _, record := range records {
+		if !strings.EqualFold(record.Type, key.Type) {
+			return fmt.Errorf("record of type %s in %s RRset", record.Type, key.Type)
+		}
+	}
+	if len(records) == 0 {
+		zone.Remove(name, rrtype)
+		return nil
+	}
+
+	existing := zone.index[key]
+	var target *slot
+	var old []ast.Node
+	if len(existing) > 0 {
+		target = existing[0]
+		for _, s := range existing {
+			old = append(old, s.nodes...)
+			s.nodes = nil
+		}
+	} else {
+		target = &slot{
+			key:    key,
+			owner:  ast.AbsoluteName(name, zone.origin),
+			origin: zone.endOrigin,
+		}
+		zone.slots = append(zone.slots, target)
+	}
+
+	nodes := make([]ast.Node, 0, len(records))
+	for _, record := range records {
+		i := slices.IndexFunc(old, func(node ast.Node) bool {
+			return sameRData(node.RREntry().RRecord.RData, record.RData)
+		})
+		if i < 0 {
+			nodes = append(nodes, ast.Node{
+				NodeType: ast.NodeTypeRREntry,
+				Entry: ast.RREntry{
+					DomainName: zone.writtenName(name, target.origin),
+					RRecord:    record,
+				},
+			})
+			continue
+		}
+		node := old[i]
+		old = slices.Delete(old, i, i+1)
+		entry := node.RREntry()
+		if !reflect.DeepEqual(entry.RRecord, record) {
+			entry.RRecord = record
+			node.Entry = entry
+		}
+		nodes = append(nodes, node)
+	}
+	target.nodes = nodes
+	zone.index[key] = []*slot{target}
+	return nil
+}
+
+// Remove removes the RRset of name and rrtype and reports whether there was
+// one.
+func (zone *Zone) Remove(name string, rrtype string) bool {
+	key := zone.key(name, rrtype)
+	existing, ok := zone.index[key]
+	if !ok {
+		return false
+	}
+	for _, s := range existing {
+		s.nodes = nil
+	}
+	delete(zone.index, key)
+	return true
+}
+
+// Keys returns the keys of all RRsets in the order they first appear in the
+// zone file.
+func (zone *Zone) Keys() []Key {
+	keys := make([]Key, 0, len(zone.index))
+	seen := map[Key]bool{}
+	for _, s := range zone.slots {
+		if len(s.nodes) == 0 || s.key == (Key{}) || seen[s.key] {
+			continue
+		}
+		seen[s.key] = true
+		keys = append(keys, s.key)
+	}
+	return keys
+}
+
+// Nodes returns the entries of the zone file. Records that relied on the
+// owner of a record that was removed or moved by leaving their own owner
+// blank get it written out.
+func (zone *Zone) Nodes() []ast.Node {
+	entries := make([]ast.Node, 0, len(zone.slots))
+	previousOwner := ""
+	for _, s := range zone.slots {
+		for _, node := range s.nodes {
+			if !node.IsRREntry() {
+				entries = append(entries, node)
+				continue
+			}
+			entry := node.RREntry()
+			if entry.DomainName == "" && !strings.EqualFold(previousOwner, s.owner) {
+				entry.DomainName = s.owner
+				node.Entry = entry
+			}
+			previousOwner = s.owner
+			entries = append(entries, node)
+		}
+	}
+	return entries
+}
+
+// writtenName returns name the way it should be written at a place where
+// origin is in effect: relative names are made absolute if origin isn't the
+// origin of the zone.
+func (zone *Zone) writtenName(name string, origin string) string {
+	if ast.IsAbsoluteName(name) || strings.EqualFold(origin, zone.origin) {
+		return name
+	}
+	return ast.AbsoluteName(name, zone.origin)
+}
+
+func sameRData(a []ast.RData, b []ast.RData) bool {
+	return slices.EqualFunc(a, b, func(a ast.RData, b ast.RData) bool {
+		return a.Value == b.Value
+	})
+}
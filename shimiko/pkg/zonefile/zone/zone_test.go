@@ -0,0 +1,250 @@
+package zone_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/ast"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/parser"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/token"
+	"github.com/sapslaj/homelab-pets/shimiko/pkg/zonefile/zone"
+)
+
+const input = `$ORIGIN example.com.
+$TTL 300
+@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+; web servers
+www   A 192.0.2.1 ; primary
+      A 192.0.2.2
+      TXT "web"
+mail  MX 10 mx1
+www   A 192.0.2.3
+$ORIGIN sub.example.com.
+host  A 192.0.2.4
+`
+
+func newZone(t *testing.T, input string) *zone.Zone {
+	t.Helper()
+	entries, err := parser.ParseReader(strings.NewReader(input))
+	require.NoError(t, err)
+	z, err := zone.New(entries, "example.com.")
+	require.NoError(t, err)
+	return z
+}
+
+func render(t *testing.T, z *zone.Zone) string {
+	t.Helper()
+	toks, err := parser.Tokenize(z.Nodes())
+	require.NoError(t, err)
+	return string(token.RenderTokens(toks))
+}
+
+func record(rrtype string, rdata string) ast.RRecord {
+	return ast.RRecord{
+		Type:  rrtype,
+		RData: ast.SplitRData(rdata),
+	}
+}
+
+func values(records []ast.RRecord) []string {
+	result := []string{}
+	for _, record := range records {
+		fields := []string{}
+		for _, field := range record.RData {
+			fields = append(fields, field.Value)
+		}
+		result = append(result, strings.Join(fields, " "))
+	}
+	return result
+}
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	z := newZone(t, input)
+
+	tests := map[string]struct {
+		name     string
+		rrtype   string
+		expected []string
+	}{
+		"relative name": {
+			name:     "www",
+			rrtype:   "A",
+			expected: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"},
+		},
+		"absolute name in other case": {
+			name:     "WWW.example.com.",
+			rrtype:   "a",
+			expected: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"},
+		},
+		"blank owner": {
+			name:     "www",
+			rrtype:   "TXT",
+			expected: []string{`"web"`},
+		},
+		"apex": {
+			name:     "@",
+			rrtype:   "SOA",
+			expected: []string{"ns1 hostmaster 1 7200 3600 1209600 300"},
+		},
+		"other $ORIGIN": {
+			name:     "host.sub.example.com.",
+			rrtype:   "A",
+			expected: []string{"192.0.2.4"},
+		},
+		"missing": {
+			name:     "www",
+			rrtype:   "AAAA",
+			expected: []string{},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, values(z.Get(tc.name, tc.rrtype)))
+			assert.Equal(t, len(tc.expected) > 0, z.Has(tc.name, tc.rrtype))
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		name     string
+		rrtype   string
+		records  []ast.RRecord
+		expected string
+	}{
+		"replace in place": {
+			name:   "www",
+			rrtype: "A",
+			records: []ast.RRecord{
+				record("A", "192.0.2.1"),
+				record("A", "192.0.2.9"),
+			},
+			expected: `$ORIGIN example.com.
+$TTL 300
+@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+; web servers
+www   A 192.0.2.1 ; primary
+www A 192.0.2.9
+      TXT "web"
+mail  MX 10 mx1
+$ORIGIN sub.example.com.
+host  A 192.0.2.4
+`,
+		},
+		"blank owner after a moved record": {
+			name:   "www",
+			rrtype: "A",
+			records: []ast.RRecord{
+				record("A", "192.0.2.3"),
+			},
+			expected: `$ORIGIN example.com.
+$TTL 300
+@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+; web servers
+www   A 192.0.2.3
+      TXT "web"
+mail  MX 10 mx1
+$ORIGIN sub.example.com.
+host  A 192.0.2.4
+`,
+		},
+		"new RRset under another $ORIGIN": {
+			name:   "new",
+			rrtype: "AAAA",
+			records: []ast.RRecord{
+				record("AAAA", "2001:db8::1"),
+			},
+			expected: input + "new.example.com. AAAA 2001:db8::1\n",
+		},
+		"empty records remove the RRset": {
+			name:   "mail",
+			rrtype: "MX",
+			expected: `$ORIGIN example.com.
+$TTL 300
+@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+; web servers
+www   A 192.0.2.1 ; primary
+      A 192.0.2.2
+      TXT "web"
+www   A 192.0.2.3
+$ORIGIN sub.example.com.
+host  A 192.0.2.4
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			z := newZone(t, input)
+			err := z.Set(tc.name, tc.rrtype, tc.records)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, render(t, z))
+			assert.Equal(t, values(tc.records), values(z.Get(tc.name, tc.rrtype))[:len(tc.records)])
+		})
+	}
+}
+
+func TestSetTypeMismatch(t *testing.T) {
+	t.Parallel()
+
+	z := newZone(t, input)
+	err := z.Set("www", "A", []ast.RRecord{record("AAAA", "2001:db8::1")})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}, values(z.Get("www", "A")))
+}
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+
+	z := newZone(t, input)
+	assert.True(t, z.Remove("www.example.com.", "A"))
+	assert.False(t, z.Remove("www.example.com.", "A"))
+	assert.False(t, z.Has("www", "A"))
+	assert.Equal(t, `$ORIGIN example.com.
+$TTL 300
+@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+; web servers
+www.example.com. TXT "web"
+mail  MX 10 mx1
+$ORIGIN sub.example.com.
+host  A 192.0.2.4
+`, render(t, z))
+}
+
+func TestKeys(t *testing.T) {
+	t.Parallel()
+
+	z := newZone(t, input)
+	z.Remove("mail", "MX")
+	require.NoError(t, z.Set("new", "A", []ast.RRecord{record("A", "192.0.2.5")}))
+	assert.Equal(t, []zone.Key{
+		{Owner: "example.com.", Type: "SOA"},
+		{Owner: "www.example.com.", Type: "A"},
+		{Owner: "www.example.com.", Type: "TXT"},
+		{Owner: "host.sub.example.com.", Type: "A"},
+		{Owner: "new.example.com.", Type: "A"},
+	}, z.Keys())
+}
+
+func TestOriginAt(t *testing.T) {
+	t.Parallel()
+
+	z := newZone(t, input)
+	assert.Equal(t, "example.com.", z.OriginAt("www", "A"))
+	assert.Equal(t, "sub.example.com.", z.OriginAt("host.sub.example.com.", "A"))
+	assert.Equal(t, "sub.example.com.", z.OriginAt("new", "A"))
+}
@@ -458,18 +458,13 @@ func (s *Server) ReconcileAll(ctx context.Context) error {
 
 		successfullyDeleted := true
 
-		err := ps.CoreDNS.DeleteRecord(ctx, record)
-		if err != nil {
-			recordLogger.WarnContext(ctx, "record failed to be deleted from CoreDNS", "error", err)
-			returnErrors = errors.Join(returnErrors, err)
-			successfullyDeleted = false
-		}
-
-		err = ps.Route53.DeleteRecord(ctx, record)
-		if err != nil {
-			recordLogger.WarnContext(ctx, "record failed to be deleted from Route53", "error", err)
-			returnErrors = errors.Join(returnErrors, err)
-			successfullyDeleted = false
+		for _, backend := range ps.Backends {
+			err := backend.DeleteRecord(ctx, record)
+			if err != nil {
+				recordLogger.WarnContext(ctx, "record failed to be deleted", "backend", backend.Name(), "error", err)
+				returnErrors = errors.Join(returnErrors, err)
+				successfullyDeleted = false
+			}
 		}
 
 		if successfullyDeleted {
@@ -510,16 +505,12 @@ func (s *Server) ReconcileAll(ctx context.Context) error {
 		recordLogger := logger.With(slog.Any("record", record))
 		recordLogger.InfoContext(ctx, "upserting record")
 
-		err := ps.CoreDNS.UpsertRecord(ctx, record, record)
-		if err != nil {
-			recordLogger.WarnContext(ctx, "record failed to be updated in CoreDNS", "error", err)
-			returnErrors = errors.Join(returnErrors, err)
-		}
-
-		err = ps.Route53.UpsertRecord(ctx, record, record)
-		if err != nil {
-			recordLogger.WarnContext(ctx, "record failed to be updated in Route53", "error", err)
-			returnErrors = errors.Join(returnErrors, err)
+		for _, backend := range ps.Backends {
+			err := backend.UpsertRecord(ctx, record, record)
+			if err != nil {
+				recordLogger.WarnContext(ctx, "record failed to be updated", "backend", backend.Name(), "error", err)
+				returnErrors = errors.Join(returnErrors, err)
+			}
 		}
 	}
 
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -33,6 +34,7 @@ func (s *Server) Routes() {
 	e.PATCH("/v1/dns-records", s.UpsertDNSRecords)
 	e.DELETE("/v1/dns-records", s.DeleteDNSRecords)
 	e.POST("/v1/dns-records/refresh", s.RefreshDNSRecords)
+	e.GET("/v1/dnssec/ds", s.ShowDNSSECDS)
 	e.GET("/v1/dns-records/:type/:name", s.ShowDNSRecord)
 	e.POST("/v1/dns-records/:type/:name", s.UpsertDNSRecord)
 	e.PUT("/v1/dns-records/:type/:name", s.UpsertDNSRecord)
@@ -661,6 +663,62 @@ func (s *Server) RefreshDNSRecords(c echo.Context) error {
 	}
 }
 
+// ShowDNSSECDS returns the DS records to publish in the parent zone for the
+// KSKs of the zone.
+func (s *Server) ShowDNSSECDS(c echo.Context) error {
+	ctx, span := telemetry.Tracer.Start(
+		c.Request().Context(),
+		"shimiko/server.Server.ShowDNSSECDS",
+	)
+	defer span.End()
+
+	logger := s.RequestLogger(c)
+
+	zone := persistence.DomainName + "."
+	keys, err := persistence.LoadDNSSECKeys(ctx, s.DB, zone, false)
+	if err != nil {
+		logger.ErrorContext(ctx, "error loading DNSSEC keys", "error", err)
+		span.SetStatus(codes.Error, err.Error())
+		return c.JSON(503, map[string]any{
+			"msg":   "error loading DNSSEC keys",
+			"error": err.Error(),
+		})
+	}
+
+	records := []map[string]any{}
+	for _, key := range keys {
+		if !key.IsKSK() {
+			continue
+		}
+		ds, err := key.DS(zone)
+		if err != nil {
+			logger.ErrorContext(ctx, "error computing DS record", "error", err)
+			span.SetStatus(codes.Error, err.Error())
+			return c.JSON(500, map[string]any{
+				"msg":   "error computing DS record",
+				"error": err.Error(),
+			})
+		}
+		digest := strings.ToUpper(hex.EncodeToString(ds.Digest))
+		records = append(records, map[string]any{
+			"key_tag":     ds.KeyTag,
+			"algorithm":   ds.Algorithm,
+			"digest_type": ds.DigestType,
+			"digest":      digest,
+			"record": fmt.Sprintf(
+				"%s IN DS %d %d %d %s",
+				zone, ds.KeyTag, ds.Algorithm, ds.DigestType, digest,
+			),
+		})
+	}
+
+	span.SetStatus(codes.Ok, "")
+	return c.JSON(200, map[string]any{
+		"enabled": persistence.DNSSECEnabled(),
+		"ds":      records,
+	})
+}
+
 func (s *Server) AcmeDNSHealth(c echo.Context) error {
 	_, span := telemetry.Tracer.Start(
 		c.Request().Context(),